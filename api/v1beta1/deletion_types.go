@@ -0,0 +1,38 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// DeletionPolicy is what happens on pinot to the schema, table or tenant of a
+// deleted CR.
+// +kubebuilder:validation:Enum=Delete;Retain;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the object from pinot.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the object on pinot, a new CR of the same
+	// name manages it again.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyOrphan keeps the object on pinot and labels tables with
+	// the CR they were orphaned from. Pinot has no labels for schemas and
+	// tenants, they are kept as with Retain.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// OrphanedFromLabel is the custom config of a table orphaned by its CR, its
+// value is the namespace and name of the CR.
+const OrphanedFromLabel = "datainfra.io/orphaned-from"
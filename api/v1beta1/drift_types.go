@@ -0,0 +1,33 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// DriftPolicy is how the control plane handles a schema, table or tenant that
+// was changed on pinot outside of its CR.
+// +kubebuilder:validation:Enum=Correct;Report
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect applies the spec again to undo the changes.
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyReport only reports the changes in the Drifted condition.
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
+// ConditionDrifted is true when the live object on pinot differs from the
+// spec, the message lists the differences.
+const ConditionDrifted = "Drifted"
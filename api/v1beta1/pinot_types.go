@@ -37,6 +37,58 @@ type PinotSpec struct {
 	PinotNodeConfig []PinotNodeConfig `json:"pinotNodeConfig"`
 	// +required
 	Nodes []NodeSpec `json:"nodes"`
+	// ProgressDeadline is the time each node type in the deployment order is given
+	// to become ready before the rollout is halted and reported as degraded.
+	// Defaults to 10m.
+	// +optional
+	ProgressDeadline *metav1.Duration `json:"progressDeadline,omitempty"`
+	// ControllerAPI is how the control plane reaches the REST api of the pinot
+	// controllers. Defaults to http on port 9000.
+	// +optional
+	ControllerAPI *ControllerAPISpec `json:"controllerApi,omitempty"`
+}
+
+type ControllerAPIScheme string
+
+const (
+	ControllerAPIHTTP  ControllerAPIScheme = "http"
+	ControllerAPIHTTPS ControllerAPIScheme = "https"
+)
+
+type ControllerAPISpec struct {
+	// +kubebuilder:validation:Enum=http;https
+	// +kubebuilder:default=http
+	// +optional
+	Scheme ControllerAPIScheme `json:"scheme,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=9000
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// TLS configures the connection to https controllers.
+	// +optional
+	TLS *ControllerTLSSpec `json:"tls,omitempty"`
+}
+
+// ControllerTLSSpec references the tls material in secrets of the namespace of
+// the Pinot CR.
+type ControllerTLSSpec struct {
+	// CA bundle used to verify the controller certificate, the system roots
+	// are used when unset.
+	// +optional
+	CASecretKeyRef *v1.SecretKeySelector `json:"caSecretKeyRef,omitempty"`
+	// Client certificate presented to the controller for mutual tls, requires
+	// keySecretKeyRef.
+	// +optional
+	CertSecretKeyRef *v1.SecretKeySelector `json:"certSecretKeyRef,omitempty"`
+	// Private key of the client certificate.
+	// +optional
+	KeySecretKeyRef *v1.SecretKeySelector `json:"keySecretKeyRef,omitempty"`
+	// ServerName overrides the name the controller certificate is verified against.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
 type ExternalSpec struct {
@@ -47,18 +99,137 @@ type ExternalSpec struct {
 }
 
 type ZookeeperSpec struct {
-	// +required
-	Spec ZookeeperConfig `json:"spec"`
+	// +optional
+	Spec ZookeeperConfig `json:"spec,omitempty"`
+	// Managed provisions a zookeeper ensemble for the cluster instead of
+	// connecting to an external one at spec.zkAddress.
+	// +optional
+	Managed *ManagedZookeeperSpec `json:"managed,omitempty"`
 }
 
 type ZookeeperConfig struct {
+	// +optional
+	ZkAddress string `json:"zkAddress,omitempty"`
+}
+
+type ManagedZookeeperSpec struct {
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// +kubebuilder:default="zookeeper:3.8.1"
+	// +optional
+	Image string `json:"image,omitempty"`
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Claim spec of the data volume of each zookeeper pod.
 	// +required
-	ZkAddress string `json:"zkAddress"`
+	Storage v1.PersistentVolumeClaimSpec `json:"storage"`
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
 }
 
 type DeepStorageSpec struct {
 	// +optional
 	Spec []DeepStorageConfig `json:"spec"`
+	// Typed deep storage, the operator renders the storage factory and segment
+	// fetcher properties of each node type. Properties in spec are appended
+	// after the rendered ones and take precedence.
+	// +optional
+	Provider *DeepStorageProvider `json:"provider,omitempty"`
+}
+
+type DeepStorageType string
+
+const (
+	DeepStorageS3    DeepStorageType = "s3"
+	DeepStorageGCS   DeepStorageType = "gcs"
+	DeepStorageADLS  DeepStorageType = "adls"
+	DeepStorageHDFS  DeepStorageType = "hdfs"
+	DeepStorageLocal DeepStorageType = "local"
+)
+
+type DeepStorageProvider struct {
+	// +kubebuilder:validation:Enum=s3;gcs;adls;hdfs;local
+	// +required
+	Type DeepStorageType `json:"type"`
+	// Path under the bucket, file system or volume the controller stores segments in.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// +optional
+	S3 *S3StorageSpec `json:"s3,omitempty"`
+	// +optional
+	GCS *GCSStorageSpec `json:"gcs,omitempty"`
+	// +optional
+	ADLS *ADLSStorageSpec `json:"adls,omitempty"`
+	// +optional
+	HDFS *HDFSStorageSpec `json:"hdfs,omitempty"`
+	// +optional
+	Local *LocalStorageSpec `json:"local,omitempty"`
+}
+
+type S3StorageSpec struct {
+	// +required
+	Bucket string `json:"bucket"`
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Endpoint of an s3 compatible store such as minio.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Passed as AWS_ACCESS_KEY_ID, the default credentials chain is used when not set.
+	// +optional
+	AccessKeySecretKeyRef *v1.SecretKeySelector `json:"accessKeySecretKeyRef,omitempty"`
+	// Passed as AWS_SECRET_ACCESS_KEY.
+	// +optional
+	SecretKeySecretKeyRef *v1.SecretKeySelector `json:"secretKeySecretKeyRef,omitempty"`
+}
+
+type GCSStorageSpec struct {
+	// +required
+	Bucket string `json:"bucket"`
+	// +optional
+	ProjectID string `json:"projectId,omitempty"`
+	// Service account json key, mounted as a file.
+	// +optional
+	KeySecretKeyRef *v1.SecretKeySelector `json:"keySecretKeyRef,omitempty"`
+}
+
+type ADLSStorageSpec struct {
+	// +required
+	AccountName string `json:"accountName"`
+	// +required
+	FileSystemName string `json:"fileSystemName"`
+	// Passed as an env var and resolved by pinot through dynamic.env.config.
+	// +optional
+	AccessKeySecretKeyRef *v1.SecretKeySelector `json:"accessKeySecretKeyRef,omitempty"`
+}
+
+type HDFSStorageSpec struct {
+	// Namenode host and port.
+	// +required
+	Endpoint string `json:"endpoint"`
+	// ConfigMap holding core-site.xml and hdfs-site.xml, mounted as the hadoop conf dir.
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// +optional
+	KerberosPrincipal string `json:"kerberosPrincipal,omitempty"`
+	// Kerberos keytab, mounted as a file.
+	// +optional
+	KeytabSecretKeyRef *v1.SecretKeySelector `json:"keytabSecretKeyRef,omitempty"`
+}
+
+type LocalStorageSpec struct {
+	// ReadWriteMany claim mounted on the controller, server and minion pods.
+	// +required
+	ClaimName string `json:"claimName"`
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
 }
 
 type DeepStorageConfig struct {
@@ -72,13 +243,56 @@ type AuthType string
 
 const (
 	BasicAuth AuthType = "basic-auth"
+	// ZkBasicAuth authenticates with basic auth against controllers using the
+	// ZkBasicAuthAccessControlFactory of pinot, where users live in zookeeper.
+	ZkBasicAuth AuthType = "zk-basic-auth"
+	// BearerToken sends a static token in the Authorization header.
+	BearerToken AuthType = "bearer-token"
+	// OAuth2ClientCredentials fetches and refreshes bearer tokens from an
+	// oauth2 / oidc token endpoint with the client credentials grant.
+	OAuth2ClientCredentials AuthType = "oauth2-client-credentials"
 )
 
 type Auth struct {
 	// +required
 	Type AuthType `json:"type"`
+	// SecretRef holds the credentials, it is read from the namespace of the
+	// CR. A namespace other than the one of the CR is refused.
 	// +required
 	SecretRef v1.SecretReference `json:"secretRef"`
+	// SecretKeys overrides the keys the credentials are read from.
+	// +optional
+	SecretKeys *AuthSecretKeys `json:"secretKeys,omitempty"`
+	// OAuth2 is the token endpoint of oauth2-client-credentials auth.
+	// +optional
+	OAuth2 *OAuth2Spec `json:"oauth2,omitempty"`
+}
+
+// AuthSecretKeys are the keys of the auth secret, unset keys default to
+// CONTROL_PLANE_USERNAME, CONTROL_PLANE_PASSWORD, CONTROL_PLANE_TOKEN,
+// CONTROL_PLANE_CLIENT_ID and CONTROL_PLANE_CLIENT_SECRET.
+type AuthSecretKeys struct {
+	// +optional
+	UserName string `json:"username,omitempty"`
+	// +optional
+	Password string `json:"password,omitempty"`
+	// +optional
+	Token string `json:"token,omitempty"`
+	// +optional
+	ClientID string `json:"clientId,omitempty"`
+	// +optional
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+type OAuth2Spec struct {
+	// +required
+	TokenURL string `json:"tokenUrl"`
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+	// Audience is sent as the audience parameter of the token request, as
+	// required by some identity providers.
+	// +optional
+	Audience string `json:"audience,omitempty"`
 }
 
 type K8sConfig struct {
@@ -167,12 +381,165 @@ type NodeSpec struct {
 	PinotNodeConfig string `json:"pinotNodeConfig"`
 }
 
+// Condition types reported on the Pinot status
+const (
+	// PinotReady is true when every node group has all of its desired replicas
+	// ready on the current revision.
+	PinotReady = "Ready"
+	// PinotProgressing is true while at least one node group is rolling out.
+	PinotProgressing = "Progressing"
+	// PinotDegraded is true when the last reconcile failed or a node type did
+	// not become ready within the progress deadline.
+	PinotDegraded = "Degraded"
+	// PinotScaleDownBlocked is true when a server scale down would leave fewer
+	// servers than the replication of the tables they host.
+	PinotScaleDownBlocked = "ScaleDownBlocked"
+	// PinotUnreachable is true while the circuit breaker of the pinot controller
+	// is open, requests to the cluster fail fast until it recovers. It is also
+	// reported on the schemas, tables, tenants and external clusters.
+	PinotUnreachable = "Unreachable"
+)
+
 // PinotStatus defines the observed state of Pinot
 type PinotStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	NodeGroups []NodeGroupStatus `json:"nodeGroups,omitempty"`
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	// +optional
+	RollingRestart *RollingRestartStatus `json:"rollingRestart,omitempty"`
+	// +optional
+	ScaleDown *ScaleDownStatus `json:"scaleDown,omitempty"`
+	// +optional
+	Zookeeper *ZookeeperStatus `json:"zookeeper,omitempty"`
+}
+
+// ZookeeperStatus is the observed state of a managed zookeeper ensemble.
+type ZookeeperStatus struct {
+	// +optional
+	Address string `json:"address,omitempty"`
+	// +optional
+	Replicas int32 `json:"replicas"`
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// Healthy is true when a quorum of the ensemble is ready.
+	// +optional
+	Healthy bool `json:"healthy"`
+}
+
+// RolloutStatus records the node type the ordered rollout is currently
+// waiting on and since when.
+type RolloutStatus struct {
+	// +optional
+	NodeType PinotNodeType `json:"nodeType,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// RollingRestartPhase is the step a server pod is at in a graceful rolling restart.
+type RollingRestartPhase string
+
+const (
+	// RollingRestartDisabling waits for the disabled instance's segments to go OFFLINE.
+	RollingRestartDisabling RollingRestartPhase = "Disabling"
+	// RollingRestartRestarting waits for the deleted pod to come back ready on the new revision.
+	RollingRestartRestarting RollingRestartPhase = "Restarting"
+	// RollingRestartEnabling waits for the enabled instance to serve all its segments again.
+	RollingRestartEnabling RollingRestartPhase = "Enabling"
+)
+
+// RollingRestartStatus records the server pod currently being restarted by
+// the operator. Server pods are restarted one at a time, the instance is
+// disabled in helix before the pod is deleted and enabled once it is back.
+type RollingRestartStatus struct {
+	// +required
+	NodeGroup string `json:"nodeGroup"`
+	// +required
+	Pod string `json:"pod"`
+	// +optional
+	Instance string `json:"instance,omitempty"`
+	// +required
+	Phase RollingRestartPhase `json:"phase"`
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// ScaleDownPhase is the step a server scale down is at.
+type ScaleDownPhase string
+
+const (
+	// ScaleDownBlocked waits for the remaining servers to be able to meet table replication.
+	ScaleDownBlocked ScaleDownPhase = "Blocked"
+	// ScaleDownRebalancing waits for the tables to be rebalanced off the removed instances.
+	ScaleDownRebalancing ScaleDownPhase = "Rebalancing"
+	// ScaleDownShrinking waits for the statefulset to remove the highest ordinals.
+	ScaleDownShrinking ScaleDownPhase = "Shrinking"
+	// ScaleDownDroppingInstances waits for the removed instances to be dropped from helix.
+	ScaleDownDroppingInstances ScaleDownPhase = "DroppingInstances"
+)
+
+// ScaleDownStatus records a server scale down in progress. The statefulset is
+// kept at FromReplicas until the segments of the removed instances have been
+// rebalanced onto the remaining servers.
+type ScaleDownStatus struct {
+	// +required
+	NodeGroup string `json:"nodeGroup"`
+	// +required
+	FromReplicas int32 `json:"fromReplicas"`
+	// +required
+	ToReplicas int32 `json:"toReplicas"`
+	// +optional
+	Instances []string `json:"instances,omitempty"`
+	// InstanceTags are the tags of the removed instances before they were
+	// untagged, they are tagged again when the scale down is cancelled.
+	// +optional
+	InstanceTags map[string][]string `json:"instanceTags,omitempty"`
+	// +optional
+	Tables []string `json:"tables,omitempty"`
+	// +required
+	Phase ScaleDownPhase `json:"phase"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// NodeGroupStatus is the observed state of the deployment or statefulset
+// backing a single NodeSpec.
+type NodeGroupStatus struct {
+	// +required
+	Name string `json:"name"`
+	// +required
+	NodeType PinotNodeType `json:"nodeType"`
+	// +required
+	Kind string `json:"kind"`
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+	// +optional
+	Ready bool `json:"ready"`
+	// +optional
+	Image string `json:"image,omitempty"`
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+	// +optional
+	LastRolloutTime *metav1.Time `json:"lastRolloutTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // Pinot is the Schema for the pinots API
 type Pinot struct {
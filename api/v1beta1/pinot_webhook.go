@@ -0,0 +1,265 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var pinotlog = logf.Log.WithName("pinot-resource")
+
+// kinds supported by the operator-runtime builder for a NodeSpec
+const (
+	DeploymentKind  = "Deployment"
+	StatefulSetKind = "Statefulset"
+)
+
+var (
+	supportedKinds     = []string{DeploymentKind, StatefulSetKind}
+	supportedNodeTypes = []string{string(Controller), string(Broker), string(Server), string(Minion)}
+)
+
+func (r *Pinot) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-datainfra-io-v1beta1-pinot,mutating=false,failurePolicy=fail,sideEffects=None,groups=datainfra.io,resources=pinots,verbs=create;update,versions=v1beta1,name=vpinot.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Pinot{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Pinot) ValidateCreate() error {
+	pinotlog.Info("validate create", "name", r.Name)
+	return r.validatePinot()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Pinot) ValidateUpdate(old runtime.Object) error {
+	pinotlog.Info("validate update", "name", r.Name)
+	return r.validatePinot()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Pinot) ValidateDelete() error {
+	return nil
+}
+
+func (r *Pinot) validatePinot() error {
+	allErrs := r.Spec.validate(field.NewPath("spec"), r.Namespace)
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("Pinot").GroupKind(), r.Name, allErrs)
+}
+
+// validate checks the references between nodes and config groups that the
+// reconciler otherwise silently ignores when they don't match.
+func (s *PinotSpec) validate(specPath *field.Path, namespace string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	zkPath := specPath.Child("external", "zookeeper", "spec", "zkAddress")
+	if s.External.Zookeeper.Managed != nil {
+		if s.External.Zookeeper.Spec.ZkAddress != "" {
+			allErrs = append(allErrs, field.Forbidden(zkPath, "zookeeper address must not be set for a managed zookeeper"))
+		}
+	} else if s.External.Zookeeper.Spec.ZkAddress == "" {
+		allErrs = append(allErrs, field.Required(zkPath, "zookeeper address must be set unless zookeeper is managed"))
+	}
+
+	if provider := s.External.DeepStorage.Provider; provider != nil {
+		allErrs = append(allErrs, provider.validate(specPath.Child("external", "deepStorage", "provider"))...)
+	}
+
+	if s.Auth != (Auth{}) {
+		allErrs = append(allErrs, s.Auth.validate(specPath.Child("auth"), namespace)...)
+	}
+
+	if s.ControllerAPI != nil {
+		allErrs = append(allErrs, s.ControllerAPI.validate(specPath.Child("controllerApi"))...)
+	}
+
+	k8sConfigs := map[string]K8sConfig{}
+	k8sConfigPath := specPath.Child("k8sConfig")
+	for i, k8sConfig := range s.K8sConfig {
+		if _, ok := k8sConfigs[k8sConfig.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(k8sConfigPath.Index(i).Child("name"), k8sConfig.Name))
+		}
+		k8sConfigs[k8sConfig.Name] = k8sConfig
+	}
+
+	pinotNodeConfigs := map[string]bool{}
+	pinotNodeConfigPath := specPath.Child("pinotNodeConfig")
+	for i, pinotNodeConfig := range s.PinotNodeConfig {
+		if pinotNodeConfigs[pinotNodeConfig.Name] {
+			allErrs = append(allErrs, field.Duplicate(pinotNodeConfigPath.Index(i).Child("name"), pinotNodeConfig.Name))
+		}
+		pinotNodeConfigs[pinotNodeConfig.Name] = true
+	}
+
+	deploymentOrder := map[PinotNodeType]bool{}
+	deploymentOrderPath := specPath.Child("deploymentOrder")
+	if len(s.DeploymentOrder) == 0 {
+		allErrs = append(allErrs, field.Required(deploymentOrderPath, "deployment order must list the node types to deploy"))
+	}
+	for i, nodeType := range s.DeploymentOrder {
+		if !isSupportedNodeType(nodeType) {
+			allErrs = append(allErrs, field.NotSupported(deploymentOrderPath.Index(i), nodeType, supportedNodeTypes))
+		} else if deploymentOrder[nodeType] {
+			allErrs = append(allErrs, field.Duplicate(deploymentOrderPath.Index(i), nodeType))
+		}
+		deploymentOrder[nodeType] = true
+	}
+
+	nodeNames := map[string]bool{}
+	nodesPath := specPath.Child("nodes")
+	for i, node := range s.Nodes {
+		nodePath := nodesPath.Index(i)
+
+		if node.Name == "" {
+			allErrs = append(allErrs, field.Required(nodePath.Child("name"), ""))
+		} else if nodeNames[node.Name] {
+			allErrs = append(allErrs, field.Duplicate(nodePath.Child("name"), node.Name))
+		}
+		nodeNames[node.Name] = true
+
+		if node.Kind != DeploymentKind && node.Kind != StatefulSetKind {
+			allErrs = append(allErrs, field.NotSupported(nodePath.Child("kind"), node.Kind, supportedKinds))
+		}
+
+		if !isSupportedNodeType(node.NodeType) {
+			allErrs = append(allErrs, field.NotSupported(nodePath.Child("nodeType"), node.NodeType, supportedNodeTypes))
+		} else if len(s.DeploymentOrder) > 0 && !deploymentOrder[node.NodeType] {
+			allErrs = append(allErrs, field.Invalid(nodePath.Child("nodeType"), node.NodeType, "node type is missing from spec.deploymentOrder and would never be deployed"))
+		}
+
+		if node.Replicas < 0 {
+			allErrs = append(allErrs, field.Invalid(nodePath.Child("replicas"), node.Replicas, "must be greater than or equal to 0"))
+		}
+
+		if k8sConfig, ok := k8sConfigs[node.K8sConfig]; !ok {
+			allErrs = append(allErrs, field.NotFound(nodePath.Child("k8sConfig"), node.K8sConfig))
+		} else if node.Kind == DeploymentKind && len(k8sConfig.StorageConfig) > 0 {
+			allErrs = append(allErrs, field.Forbidden(nodePath.Child("kind"), "k8sConfig ["+k8sConfig.Name+"] defines storageConfig, which requires kind "+StatefulSetKind))
+		}
+
+		if !pinotNodeConfigs[node.PinotNodeConfig] {
+			allErrs = append(allErrs, field.NotFound(nodePath.Child("pinotNodeConfig"), node.PinotNodeConfig))
+		}
+	}
+
+	return allErrs
+}
+
+// validate checks that the provider specific spec matching the type is set.
+func (p *DeepStorageProvider) validate(providerPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	providers := []struct {
+		storageType DeepStorageType
+		isSet       bool
+	}{
+		{DeepStorageS3, p.S3 != nil},
+		{DeepStorageGCS, p.GCS != nil},
+		{DeepStorageADLS, p.ADLS != nil},
+		{DeepStorageHDFS, p.HDFS != nil},
+		{DeepStorageLocal, p.Local != nil},
+	}
+
+	for _, provider := range providers {
+		providerTypePath := providerPath.Child(string(provider.storageType))
+		if provider.storageType == p.Type && !provider.isSet {
+			allErrs = append(allErrs, field.Required(providerTypePath, "must be set for type "+string(p.Type)))
+		} else if provider.storageType != p.Type && provider.isSet {
+			allErrs = append(allErrs, field.Forbidden(providerTypePath, "must not be set for type "+string(p.Type)))
+		}
+	}
+
+	return allErrs
+}
+
+func (a *Auth) validate(authPath *field.Path, namespace string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	supportedAuthTypes := []string{string(BasicAuth), string(ZkBasicAuth), string(BearerToken), string(OAuth2ClientCredentials)}
+	typePath := authPath.Child("type")
+	switch a.Type {
+	case BasicAuth, ZkBasicAuth, BearerToken, OAuth2ClientCredentials:
+	case "":
+		allErrs = append(allErrs, field.Required(typePath, "auth type must be set"))
+	default:
+		allErrs = append(allErrs, field.NotSupported(typePath, a.Type, supportedAuthTypes))
+	}
+
+	if a.SecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(authPath.Child("secretRef", "name"), "auth secret must be set"))
+	}
+	if a.SecretRef.Namespace != "" && a.SecretRef.Namespace != namespace {
+		allErrs = append(allErrs, field.Forbidden(authPath.Child("secretRef", "namespace"), "auth secret is read from the namespace of the CR"))
+	}
+
+	oauth2Path := authPath.Child("oauth2")
+	if a.Type == OAuth2ClientCredentials {
+		if a.OAuth2 == nil {
+			allErrs = append(allErrs, field.Required(oauth2Path, "must be set for type "+string(a.Type)))
+		} else if a.OAuth2.TokenURL == "" {
+			allErrs = append(allErrs, field.Required(oauth2Path.Child("tokenUrl"), "token endpoint must be set"))
+		}
+	} else if a.OAuth2 != nil {
+		allErrs = append(allErrs, field.Forbidden(oauth2Path, "must not be set for type "+string(a.Type)))
+	}
+
+	return allErrs
+}
+
+func (a *ControllerAPISpec) validate(apiPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if a.TLS == nil {
+		return allErrs
+	}
+
+	tlsPath := apiPath.Child("tls")
+	if a.Scheme != ControllerAPIHTTPS {
+		allErrs = append(allErrs, field.Forbidden(tlsPath, "tls requires scheme https"))
+	}
+	if a.TLS.CertSecretKeyRef != nil && a.TLS.KeySecretKeyRef == nil {
+		allErrs = append(allErrs, field.Required(tlsPath.Child("keySecretKeyRef"), "must be set with certSecretKeyRef"))
+	}
+	if a.TLS.KeySecretKeyRef != nil && a.TLS.CertSecretKeyRef == nil {
+		allErrs = append(allErrs, field.Required(tlsPath.Child("certSecretKeyRef"), "must be set with keySecretKeyRef"))
+	}
+
+	return allErrs
+}
+
+func isSupportedNodeType(nodeType PinotNodeType) bool {
+	for _, supported := range supportedNodeTypes {
+		if string(nodeType) == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validPinotSpec() PinotSpec {
+	return PinotSpec{
+		DeploymentOrder: []PinotNodeType{Controller, Broker, Server, Minion},
+		External: ExternalSpec{
+			Zookeeper: ZookeeperSpec{Spec: ZookeeperConfig{ZkAddress: "zk:2181"}},
+		},
+		K8sConfig: []K8sConfig{
+			{Name: "controller", StorageConfig: []StorageConfig{{Name: "data"}}},
+			{Name: "broker"},
+		},
+		PinotNodeConfig: []PinotNodeConfig{
+			{Name: "controller"},
+			{Name: "broker"},
+		},
+		Nodes: []NodeSpec{
+			{Name: "pinot-controller", Kind: StatefulSetKind, NodeType: Controller, Replicas: 1, K8sConfig: "controller", PinotNodeConfig: "controller"},
+			{Name: "pinot-broker", Kind: DeploymentKind, NodeType: Broker, Replicas: 1, K8sConfig: "broker", PinotNodeConfig: "broker"},
+		},
+	}
+}
+
+func TestPinotSpecValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(s *PinotSpec)
+		errs   map[string]field.ErrorType
+	}{
+		{
+			name:   "valid",
+			mutate: func(s *PinotSpec) {},
+			errs:   map[string]field.ErrorType{},
+		},
+		{
+			name:   "missing zk address",
+			mutate: func(s *PinotSpec) { s.External.Zookeeper.Spec.ZkAddress = "" },
+			errs:   map[string]field.ErrorType{"spec.external.zookeeper.spec.zkAddress": field.ErrorTypeRequired},
+		},
+		{
+			name: "managed zookeeper",
+			mutate: func(s *PinotSpec) {
+				s.External.Zookeeper = ZookeeperSpec{Managed: &ManagedZookeeperSpec{Replicas: 3}}
+			},
+			errs: map[string]field.ErrorType{},
+		},
+		{
+			name: "managed zookeeper with address",
+			mutate: func(s *PinotSpec) {
+				s.External.Zookeeper.Managed = &ManagedZookeeperSpec{Replicas: 3}
+			},
+			errs: map[string]field.ErrorType{"spec.external.zookeeper.spec.zkAddress": field.ErrorTypeForbidden},
+		},
+		{
+			name:   "dangling k8sConfig",
+			mutate: func(s *PinotSpec) { s.Nodes[1].K8sConfig = "brokr" },
+			errs:   map[string]field.ErrorType{"spec.nodes[1].k8sConfig": field.ErrorTypeNotFound},
+		},
+		{
+			name:   "dangling pinotNodeConfig",
+			mutate: func(s *PinotSpec) { s.Nodes[0].PinotNodeConfig = "ctrl" },
+			errs:   map[string]field.ErrorType{"spec.nodes[0].pinotNodeConfig": field.ErrorTypeNotFound},
+		},
+		{
+			name:   "duplicate node name",
+			mutate: func(s *PinotSpec) { s.Nodes[1].Name = "pinot-controller" },
+			errs:   map[string]field.ErrorType{"spec.nodes[1].name": field.ErrorTypeDuplicate},
+		},
+		{
+			name:   "invalid kind",
+			mutate: func(s *PinotSpec) { s.Nodes[1].Kind = "StatefulSet" },
+			errs:   map[string]field.ErrorType{"spec.nodes[1].kind": field.ErrorTypeNotSupported},
+		},
+		{
+			name:   "storage config on deployment",
+			mutate: func(s *PinotSpec) { s.Nodes[0].Kind = DeploymentKind },
+			errs:   map[string]field.ErrorType{"spec.nodes[0].kind": field.ErrorTypeForbidden},
+		},
+		{
+			name:   "short deployment order",
+			mutate: func(s *PinotSpec) { s.DeploymentOrder = []PinotNodeType{Controller} },
+			errs:   map[string]field.ErrorType{"spec.nodes[1].nodeType": field.ErrorTypeInvalid},
+		},
+		{
+			name:   "malformed deployment order",
+			mutate: func(s *PinotSpec) { s.DeploymentOrder = []PinotNodeType{Controller, Controller, "brokers"} },
+			errs: map[string]field.ErrorType{
+				"spec.deploymentOrder[1]": field.ErrorTypeDuplicate,
+				"spec.deploymentOrder[2]": field.ErrorTypeNotSupported,
+				"spec.nodes[1].nodeType":  field.ErrorTypeInvalid,
+			},
+		},
+		{
+			name: "typed deep storage",
+			mutate: func(s *PinotSpec) {
+				s.External.DeepStorage.Provider = &DeepStorageProvider{Type: DeepStorageS3, S3: &S3StorageSpec{Bucket: "pinot"}}
+			},
+			errs: map[string]field.ErrorType{},
+		},
+		{
+			name: "deep storage type mismatch",
+			mutate: func(s *PinotSpec) {
+				s.External.DeepStorage.Provider = &DeepStorageProvider{Type: DeepStorageGCS, S3: &S3StorageSpec{Bucket: "pinot"}}
+			},
+			errs: map[string]field.ErrorType{
+				"spec.external.deepStorage.provider.gcs": field.ErrorTypeRequired,
+				"spec.external.deepStorage.provider.s3":  field.ErrorTypeForbidden,
+			},
+		},
+		{
+			name: "oauth2 auth",
+			mutate: func(s *PinotSpec) {
+				s.Auth = Auth{
+					Type:      OAuth2ClientCredentials,
+					SecretRef: v1.SecretReference{Name: "pinot-control-plane-secret"},
+					OAuth2:    &OAuth2Spec{TokenURL: "https://idp.example.com/oauth2/token"},
+				}
+			},
+			errs: map[string]field.ErrorType{},
+		},
+		{
+			name: "oauth2 auth without token endpoint",
+			mutate: func(s *PinotSpec) {
+				s.Auth = Auth{Type: OAuth2ClientCredentials, OAuth2: &OAuth2Spec{}}
+			},
+			errs: map[string]field.ErrorType{
+				"spec.auth.secretRef.name":  field.ErrorTypeRequired,
+				"spec.auth.oauth2.tokenUrl": field.ErrorTypeRequired,
+			},
+		},
+		{
+			name: "auth secret of the namespace of the CR",
+			mutate: func(s *PinotSpec) {
+				s.Auth = Auth{
+					Type:      BasicAuth,
+					SecretRef: v1.SecretReference{Name: "pinot-control-plane-secret", Namespace: "pinot"},
+				}
+			},
+			errs: map[string]field.ErrorType{},
+		},
+		{
+			name: "auth secret of another namespace",
+			mutate: func(s *PinotSpec) {
+				s.Auth = Auth{
+					Type:      BasicAuth,
+					SecretRef: v1.SecretReference{Name: "pinot-control-plane-secret", Namespace: "kube-system"},
+				}
+			},
+			errs: map[string]field.ErrorType{
+				"spec.auth.secretRef.namespace": field.ErrorTypeForbidden,
+			},
+		},
+		{
+			name: "unsupported auth type",
+			mutate: func(s *PinotSpec) {
+				s.Auth = Auth{
+					Type:      "digest",
+					SecretRef: v1.SecretReference{Name: "pinot-control-plane-secret"},
+					OAuth2:    &OAuth2Spec{TokenURL: "https://idp.example.com/oauth2/token"},
+				}
+			},
+			errs: map[string]field.ErrorType{
+				"spec.auth.type":   field.ErrorTypeNotSupported,
+				"spec.auth.oauth2": field.ErrorTypeForbidden,
+			},
+		},
+		{
+			name: "mutual tls controller api",
+			mutate: func(s *PinotSpec) {
+				s.ControllerAPI = &ControllerAPISpec{Scheme: ControllerAPIHTTPS, TLS: &ControllerTLSSpec{
+					CASecretKeyRef:   &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "pinot-tls"}, Key: "ca.crt"},
+					CertSecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "pinot-tls"}, Key: "tls.crt"},
+					KeySecretKeyRef:  &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "pinot-tls"}, Key: "tls.key"},
+				}}
+			},
+			errs: map[string]field.ErrorType{},
+		},
+		{
+			name: "tls over http with cert and no key",
+			mutate: func(s *PinotSpec) {
+				s.ControllerAPI = &ControllerAPISpec{Scheme: ControllerAPIHTTP, TLS: &ControllerTLSSpec{
+					CertSecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "pinot-tls"}, Key: "tls.crt"},
+				}}
+			},
+			errs: map[string]field.ErrorType{
+				"spec.controllerApi.tls":                 field.ErrorTypeForbidden,
+				"spec.controllerApi.tls.keySecretKeyRef": field.ErrorTypeRequired,
+			},
+		},
+		{
+			name:   "empty deployment order",
+			mutate: func(s *PinotSpec) { s.DeploymentOrder = nil },
+			errs:   map[string]field.ErrorType{"spec.deploymentOrder": field.ErrorTypeRequired},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := validPinotSpec()
+			test.mutate(&spec)
+
+			errs := spec.validate(field.NewPath("spec"), "pinot")
+			if len(errs) != len(test.errs) {
+				t.Fatalf("expected %d errors, got %d: %v", len(test.errs), len(errs), errs)
+			}
+			for _, err := range errs {
+				if errType, ok := test.errs[err.Field]; !ok || errType != err.Type {
+					t.Errorf("unexpected error %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PinotExternalClusterSpec defines the pinot controller of a cluster that is
+// not deployed by the control plane
+type PinotExternalClusterSpec struct {
+	// ControllerURL is the url of the controller api, for example
+	// https://pinot-controller.example.com:9000
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +required
+	ControllerURL string `json:"controllerUrl"`
+	// Auth secrets are read from the namespace of the PinotExternalCluster.
+	// +optional
+	Auth *Auth `json:"auth,omitempty"`
+	// TLS configures the connection to https controllers.
+	// +optional
+	TLS *ControllerTLSSpec `json:"tls,omitempty"`
+}
+
+const (
+	PinotExternalClusterReachable   = "Reachable"
+	PinotExternalClusterUnreachable = "Unreachable"
+)
+
+// PinotExternalClusterStatus defines the observed state of PinotExternalCluster
+type PinotExternalClusterStatus struct {
+	Type           string             `json:"type,omitempty"`
+	Status         v1.ConditionStatus `json:"status,omitempty"`
+	Reason         string             `json:"reason,omitempty"`
+	Message        string             `json:"message,omitempty"`
+	LastUpdateTime metav1.Time        `json:"lastUpdateTime,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Url",type=string,JSONPath=`.spec.controllerUrl`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.type`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PinotExternalCluster is the Schema for the pinotexternalclusters API, it
+// points schemas, tables and tenants at a pinot cluster that is not deployed
+// by the control plane.
+type PinotExternalCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PinotExternalClusterSpec   `json:"spec,omitempty"`
+	Status PinotExternalClusterStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PinotExternalClusterList contains a list of PinotExternalCluster
+type PinotExternalClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PinotExternalCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PinotExternalCluster{}, &PinotExternalClusterList{})
+}
@@ -0,0 +1,142 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// PinotSchemaDefinition is a typed pinot schema, it is rendered to the pinot
+// schema json by the schema controller.
+type PinotSchemaDefinition struct {
+	// SchemaName is the name of the schema on pinot.
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[^\s.]+$`
+	SchemaName string `json:"schemaName"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	DimensionFieldSpecs []DimensionFieldSpec `json:"dimensionFieldSpecs,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	MetricFieldSpecs []MetricFieldSpec `json:"metricFieldSpecs,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	DateTimeFieldSpecs []DateTimeFieldSpec `json:"dateTimeFieldSpecs,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	ComplexFieldSpecs []ComplexFieldSpec `json:"complexFieldSpecs,omitempty"`
+	// PrimaryKeyColumns are the columns of the primary key of upsert and
+	// dedup tables.
+	// +optional
+	// +listType=set
+	PrimaryKeyColumns []string `json:"primaryKeyColumns,omitempty"`
+}
+
+// DimensionFieldSpec is a dimension column.
+type DimensionFieldSpec struct {
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +required
+	// +kubebuilder:validation:Enum=INT;LONG;FLOAT;DOUBLE;BIG_DECIMAL;BOOLEAN;TIMESTAMP;STRING;JSON;BYTES
+	DataType string `json:"dataType"`
+	// SingleValueField is false for multi value columns, pinot defaults it
+	// to true.
+	// +optional
+	SingleValueField *bool `json:"singleValueField,omitempty"`
+	// DefaultNullValue is the value of the column in records without it, it
+	// is converted to the data type by pinot.
+	// +optional
+	DefaultNullValue *string `json:"defaultNullValue,omitempty"`
+	// MaxLength is the maximum length of STRING, JSON and BYTES values.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxLength *int32 `json:"maxLength,omitempty"`
+	// +optional
+	TransformFunction string `json:"transformFunction,omitempty"`
+}
+
+// MetricFieldSpec is a metric column.
+type MetricFieldSpec struct {
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +required
+	// +kubebuilder:validation:Enum=INT;LONG;FLOAT;DOUBLE;BIG_DECIMAL;BYTES
+	DataType string `json:"dataType"`
+	// +optional
+	DefaultNullValue *string `json:"defaultNullValue,omitempty"`
+	// +optional
+	TransformFunction string `json:"transformFunction,omitempty"`
+}
+
+// DateTimeFieldSpec is a date time column.
+type DateTimeFieldSpec struct {
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +required
+	// +kubebuilder:validation:Enum=INT;LONG;STRING;TIMESTAMP
+	DataType string `json:"dataType"`
+	// Format is the format of the values, such as 1:MILLISECONDS:EPOCH or
+	// SIMPLE_DATE_FORMAT|yyyy-MM-dd.
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Format string `json:"format"`
+	// Granularity is the granularity of the values, such as 1:DAYS.
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Granularity string `json:"granularity"`
+	// +optional
+	DefaultNullValue *string `json:"defaultNullValue,omitempty"`
+	// +optional
+	TransformFunction string `json:"transformFunction,omitempty"`
+}
+
+// ComplexFieldSpec is a MAP column, its keys and values are described by
+// child field specs.
+type ComplexFieldSpec struct {
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +required
+	// +kubebuilder:validation:Enum=MAP
+	DataType string `json:"dataType"`
+	// +optional
+	// +kubebuilder:default=COMPLEX
+	// +kubebuilder:validation:Enum=COMPLEX
+	FieldType string `json:"fieldType,omitempty"`
+	// ChildFieldSpecs are the field specs of the key and the value of the
+	// map.
+	// +optional
+	ChildFieldSpecs map[string]ChildFieldSpec `json:"childFieldSpecs,omitempty"`
+}
+
+// ChildFieldSpec is a field of a complex column.
+type ChildFieldSpec struct {
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +required
+	// +kubebuilder:validation:Enum=INT;LONG;FLOAT;DOUBLE;BIG_DECIMAL;BOOLEAN;TIMESTAMP;STRING;JSON;BYTES
+	DataType string `json:"dataType"`
+	// +optional
+	// +kubebuilder:default=DIMENSION
+	// +kubebuilder:validation:Enum=DIMENSION
+	FieldType string `json:"fieldType,omitempty"`
+}
@@ -21,12 +21,77 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// PinotSchemaCompatibility classifies a schema change against the schema on
+// pinot.
+type PinotSchemaCompatibility string
+
+const (
+	// SchemaChangeAdditive only adds columns.
+	SchemaChangeAdditive PinotSchemaCompatibility = "Additive"
+	// SchemaChangeCompatible changes settings of columns that existing
+	// segments are still read with, such as their default null value.
+	SchemaChangeCompatible PinotSchemaCompatibility = "Compatible"
+	// SchemaChangeBreaking removes columns, or changes their data type, field
+	// type, single value setting, datetime format or the primary key.
+	SchemaChangeBreaking PinotSchemaCompatibility = "Breaking"
+)
+
+// PinotSchemaChange is the classification of a schema change with a field
+// level diff.
+type PinotSchemaChange struct {
+	Compatibility PinotSchemaCompatibility `json:"compatibility"`
+	// +optional
+	Changes []string `json:"changes,omitempty"`
+}
+
 // PinotSchemaSpec defines the desired state of PinotSchema
+// +kubebuilder:validation:XValidation:rule="has(self.pinotCluster) != has(self.pinotExternalCluster)",message="exactly one of pinotCluster and pinotExternalCluster must be set"
+// +kubebuilder:validation:XValidation:rule="(has(self.schema__dot__json) ? 1 : 0) + (has(self.schemaFrom) ? 1 : 0) + (has(self.schema) ? 1 : 0) == 1",message="exactly one of schema.json, schemaFrom and schema must be set"
 type PinotSchemaSpec struct {
-	// +required
-	PinotCluster string `json:"pinotCluster"`
-	// +required
-	PinotSchemaJson string `json:"schema.json"`
+	// PinotCluster is the Pinot CR of the schema.
+	// +optional
+	PinotCluster string `json:"pinotCluster,omitempty"`
+	// PinotExternalCluster is the PinotExternalCluster of the schema, for
+	// clusters not deployed by the control plane.
+	// +optional
+	PinotExternalCluster string `json:"pinotExternalCluster,omitempty"`
+	// PinotSchemaJson is the schema inlined in the CR.
+	// +optional
+	PinotSchemaJson string `json:"schema.json,omitempty"`
+	// SchemaFrom reads the schema from a ConfigMap, a Secret or a URL.
+	// +optional
+	SchemaFrom *JsonSource `json:"schemaFrom,omitempty"`
+	// Schema is the typed schema, it is rendered to the schema json.
+	// +optional
+	Schema *PinotSchemaDefinition `json:"schema,omitempty"`
+	// DriftPolicy is how changes made to the schema on pinot outside of this CR
+	// are handled, Correct applies the spec again and Report only sets the
+	// Drifted condition.
+	// +optional
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// Mode Plan computes what applying the schema would do on pinot and stores
+	// it in status.plan without changing pinot.
+	// +optional
+	// +kubebuilder:default=Apply
+	Mode ReconcileMode `json:"mode,omitempty"`
+	// AllowBreakingChanges applies schema changes that are not backward
+	// compatible, they are forced on pinot. Breaking changes are refused
+	// otherwise.
+	// +optional
+	AllowBreakingChanges bool `json:"allowBreakingChanges,omitempty"`
+	// RevisionHistoryLimit is the number of applied revisions kept in
+	// status.revisions for rollbacks.
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=50
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// DeletionPolicy is what happens on pinot to the schema when the CR is
+	// deleted, Delete deletes it, Retain and Orphan keep it.
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // PinotSchemaStatus defines the observed state of PinotSchema
@@ -37,6 +102,24 @@ type PinotSchemaStatus struct {
 	Message            string             `json:"message,omitempty"`
 	LastUpdateTime     metav1.Time        `json:"lastUpdateTime,omitempty"`
 	CurrentSchemasJson string             `json:"currentSchemas.json"`
+	// ApplyCount is the number of times the schema was created, updated or
+	// corrected on pinot, the tables of the schema reload their segments when
+	// it increases.
+	// +optional
+	ApplyCount int64 `json:"applyCount,omitempty"`
+	// LastChange is the classification of the last schema change applied or
+	// refused.
+	// +optional
+	LastChange *PinotSchemaChange `json:"lastChange,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Plan *PlanStatus `json:"plan,omitempty"`
+	// Revisions are the last jsons applied to pinot, the oldest first.
+	// +optional
+	Revisions []Revision `json:"revisions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
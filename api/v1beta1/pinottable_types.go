@@ -30,17 +30,66 @@ const (
 )
 
 // PinotTableSpec defines the desired state of PinotTable
+// +kubebuilder:validation:XValidation:rule="has(self.pinotCluster) != has(self.pinotExternalCluster)",message="exactly one of pinotCluster and pinotExternalCluster must be set"
+// +kubebuilder:validation:XValidation:rule="self.pinotTableType == 'hybrid' || has(self.tables__dot__json) != has(self.tableFrom)",message="exactly one of tables.json and tableFrom must be set"
+// +kubebuilder:validation:XValidation:rule="self.pinotTableType == 'hybrid' ? has(self.offlineTables__dot__json) && has(self.realtimeTables__dot__json) && !has(self.tables__dot__json) && !has(self.tableFrom) : !has(self.offlineTables__dot__json) && !has(self.realtimeTables__dot__json)",message="a hybrid table needs offlineTables.json and realtimeTables.json instead of tables.json and tableFrom, other tables cannot set them"
 type PinotTableSpec struct {
-	// +required
-	PinotCluster string `json:"pinotCluster"`
+	// PinotCluster is the Pinot CR of the table.
+	// +optional
+	PinotCluster string `json:"pinotCluster,omitempty"`
+	// PinotExternalCluster is the PinotExternalCluster of the table, for
+	// clusters not deployed by the control plane.
+	// +optional
+	PinotExternalCluster string `json:"pinotExternalCluster,omitempty"`
+	// PinotSchema is the name of the PinotSchema CR of the table, in the
+	// namespace of the table.
 	// +required
 	PinotSchema string `json:"pinotSchema"`
 	// +required
 	PinotTableType PinotTableType `json:"pinotTableType"`
-	// +required
-	PinotTablesJson string `json:"tables.json"`
+	// PinotTablesJson is the table config inlined in the CR.
+	// +optional
+	PinotTablesJson string `json:"tables.json,omitempty"`
+	// TableFrom reads the table config from a ConfigMap, a Secret or a URL.
+	// +optional
+	TableFrom *JsonSource `json:"tableFrom,omitempty"`
+	// OfflineTablesJson is the OFFLINE table config of a hybrid table.
+	// +optional
+	OfflineTablesJson string `json:"offlineTables.json,omitempty"`
+	// RealtimeTablesJson is the REALTIME table config of a hybrid table, it
+	// has the table name of the OFFLINE table config.
+	// +optional
+	RealtimeTablesJson string `json:"realtimeTables.json,omitempty"`
+	// SegmentReload reloads the segments of the table when its schema is
+	// applied to pinot.
 	// +optional
 	SegmentReload bool `json:"segmentReload"`
+	// Rebalance configures the rebalances of the table.
+	// +optional
+	Rebalance *TableRebalance `json:"rebalance,omitempty"`
+	// DriftPolicy is how changes made to the table on pinot outside of this CR
+	// are handled, Correct applies the spec again and Report only sets the
+	// Drifted condition.
+	// +optional
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// Mode Plan computes what applying the table would do on pinot and stores
+	// it in status.plan without changing pinot.
+	// +optional
+	// +kubebuilder:default=Apply
+	Mode ReconcileMode `json:"mode,omitempty"`
+	// RevisionHistoryLimit is the number of applied revisions kept in
+	// status.revisions for rollbacks.
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=50
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// DeletionPolicy is what happens on pinot to the table when the CR is
+	// deleted, Delete deletes it, Retain and Orphan keep it.
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // PinotTableStatus defines the observed state of PinotTable
@@ -51,7 +100,52 @@ type PinotTableStatus struct {
 	Message          string             `json:"message,omitempty"`
 	LastUpdateTime   metav1.Time        `json:"lastUpdateTime,omitempty"`
 	CurrentTableJson string             `json:"currentTable.json"`
-	ReloadStatus     []string           `json:"reloadStatus"`
+	// Deprecated: ReloadStatus is no longer set, reloads are tracked in
+	// Reloads.
+	ReloadStatus []string `json:"reloadStatus"`
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Plan *PlanStatus `json:"plan,omitempty"`
+	// Revisions are the last jsons applied to pinot, the oldest first.
+	// +optional
+	Revisions []Revision `json:"revisions,omitempty"`
+	// Tables is the status of the OFFLINE and REALTIME tables of a hybrid
+	// table.
+	// +optional
+	// +listType=map
+	// +listMapKey=tableType
+	Tables []PinotTableTypeStatus `json:"tables,omitempty"`
+	// Rebalance is the progress and result of the last rebalance.
+	// +optional
+	Rebalance *TableRebalanceStatus `json:"rebalance,omitempty"`
+	// Reloads are the last segment reload jobs of the table, the oldest
+	// first.
+	// +optional
+	Reloads []SegmentReloadJob `json:"reloads,omitempty"`
+	// SchemaApplyCount is the apply count of the schema of the table seen
+	// last, the segments are reloaded when the schema is applied again.
+	// +optional
+	SchemaApplyCount int64 `json:"schemaApplyCount,omitempty"`
+}
+
+// ConditionPending is true while a table waits for its schema and tenants to
+// be applied to pinot before it is created, the reason is what it waits for.
+const ConditionPending = "Pending"
+
+// PinotTableTypeStatus is the status of the OFFLINE or REALTIME table of a
+// hybrid table.
+type PinotTableTypeStatus struct {
+	// TableType is OFFLINE or REALTIME.
+	TableType        string             `json:"tableType"`
+	Type             string             `json:"type,omitempty"`
+	Status           v1.ConditionStatus `json:"status,omitempty"`
+	Reason           string             `json:"reason,omitempty"`
+	Message          string             `json:"message,omitempty"`
+	LastUpdateTime   metav1.Time        `json:"lastUpdateTime,omitempty"`
+	CurrentTableJson string             `json:"currentTable.json,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -29,13 +29,42 @@ const (
 )
 
 // PinotTenantSpec defines the desired state of PinotTenant
+// +kubebuilder:validation:XValidation:rule="has(self.pinotCluster) != has(self.pinotExternalCluster)",message="exactly one of pinotCluster and pinotExternalCluster must be set"
 type PinotTenantSpec struct {
-	// +required
-	PinotCluster string `json:"pinotCluster"`
+	// PinotCluster is the Pinot CR of the tenant.
+	// +optional
+	PinotCluster string `json:"pinotCluster,omitempty"`
+	// PinotExternalCluster is the PinotExternalCluster of the tenant, for
+	// clusters not deployed by the control plane.
+	// +optional
+	PinotExternalCluster string `json:"pinotExternalCluster,omitempty"`
 	// +required
 	PinotTenantType PinotTenantType `json:"pinotTenantType"`
 	// +required
 	PinotTenantsJson string `json:"tenants.json"`
+	// DriftPolicy is how changes made to the tenant on pinot outside of this CR
+	// are handled, Correct applies the spec again and Report only sets the
+	// Drifted condition.
+	// +optional
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// Mode Plan computes what applying the tenant would do on pinot and stores
+	// it in status.plan without changing pinot.
+	// +optional
+	// +kubebuilder:default=Apply
+	Mode ReconcileMode `json:"mode,omitempty"`
+	// RevisionHistoryLimit is the number of applied revisions kept in
+	// status.revisions for rollbacks.
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=50
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// DeletionPolicy is what happens on pinot to the tenant when the CR is
+	// deleted, Delete deletes it, Retain and Orphan keep it.
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // PinotTenantStatus defines the observed state of PinotTenant
@@ -46,6 +75,19 @@ type PinotTenantStatus struct {
 	Message            string             `json:"message,omitempty"`
 	LastUpdateTime     metav1.Time        `json:"lastUpdateTime,omitempty"`
 	CurrentTenantsJson string             `json:"currentTenants.json"`
+	// ApplyCount is the number of times the tenant was created, updated or
+	// corrected on pinot, tables using the tenant wait for it.
+	// +optional
+	ApplyCount int64 `json:"applyCount,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Plan *PlanStatus `json:"plan,omitempty"`
+	// Revisions are the last jsons applied to pinot, the oldest first.
+	// +optional
+	Revisions []Revision `json:"revisions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
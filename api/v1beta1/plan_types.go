@@ -0,0 +1,74 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReconcileMode is how a schema, table or tenant CR is reconciled, it is
+// matched ignoring case.
+// +kubebuilder:validation:Enum=Apply;Plan;apply;plan
+type ReconcileMode string
+
+const (
+	// ReconcileModeApply applies the spec on pinot.
+	ReconcileModeApply ReconcileMode = "Apply"
+	// ReconcileModePlan only computes what applying the spec would do and
+	// stores it in status.plan, nothing is changed on pinot.
+	ReconcileModePlan ReconcileMode = "Plan"
+)
+
+// PlanAction is the action applying the spec would take on pinot.
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "Create"
+	PlanActionUpdate PlanAction = "Update"
+	PlanActionDelete PlanAction = "Delete"
+	PlanActionNone   PlanAction = "None"
+)
+
+// PlanValidation is the result of validating the spec with pinot.
+type PlanValidation string
+
+const (
+	PlanValidationPassed  PlanValidation = "Passed"
+	PlanValidationFailed  PlanValidation = "Failed"
+	PlanValidationSkipped PlanValidation = "Skipped"
+)
+
+// PlanStatus is what applying the spec would do on pinot.
+type PlanStatus struct {
+	Action PlanAction `json:"action"`
+	// Diff lists the differences between the spec and the live object on
+	// pinot, fields pinot adds with their defaults are ignored.
+	// +optional
+	Diff []string `json:"diff,omitempty"`
+	// +optional
+	Validation PlanValidation `json:"validation,omitempty"`
+	// +optional
+	ValidationMessage string `json:"validationMessage,omitempty"`
+	// Message explains the plan, such as a change the control plane would
+	// refuse.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	LastPlanTime metav1.Time `json:"lastPlanTime,omitempty"`
+}
@@ -0,0 +1,117 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TableRebalance configures the rebalances of a table, they are started when
+// a change to the table config needs one and auto is set, or by the rebalance
+// annotation.
+type TableRebalance struct {
+	// Auto rebalances the table when a change to its replication, tenants or
+	// replica group config needs a rebalance.
+	// +optional
+	Auto bool `json:"auto,omitempty"`
+	// DryRun computes the target assignment without moving segments.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// Downtime moves all replicas of a segment at once.
+	// +optional
+	Downtime bool `json:"downtime,omitempty"`
+	// MinAvailableReplicas is the number of replicas of a segment kept
+	// serving during a rebalance without downtime, a negative value is the
+	// number of replicas allowed to be unavailable. Unset keeps one replica.
+	// +optional
+	// +kubebuilder:default=1
+	MinAvailableReplicas *int `json:"minAvailableReplicas,omitempty"`
+	// Bootstrap reassigns all segments from scratch.
+	// +optional
+	Bootstrap bool `json:"bootstrap,omitempty"`
+	// ReassignInstances reassigns the instances of the table, needed for
+	// replica group and tenant changes.
+	// +optional
+	// +kubebuilder:default=true
+	ReassignInstances *bool `json:"reassignInstances,omitempty"`
+	// IncludeConsuming moves the consuming segments of realtime tables.
+	// +optional
+	IncludeConsuming bool `json:"includeConsuming,omitempty"`
+}
+
+// RebalancePhase is the step a table rebalance is at.
+type RebalancePhase string
+
+const (
+	// RebalancePending is a change that needs a rebalance, it is started by
+	// the rebalance annotation.
+	RebalancePending RebalancePhase = "Pending"
+	// RebalanceInProgress waits for the rebalance jobs to finish.
+	RebalanceInProgress RebalancePhase = "InProgress"
+	// RebalanceDone is a rebalance whose jobs all finished.
+	RebalanceDone RebalancePhase = "Done"
+	// RebalanceFailed is a rebalance with a failed job.
+	RebalanceFailed RebalancePhase = "Failed"
+)
+
+// TableRebalanceStatus is the last rebalance of a table.
+type TableRebalanceStatus struct {
+	// +optional
+	Phase RebalancePhase `json:"phase,omitempty"`
+	// Trigger is why the table is rebalanced, the rebalance annotation or the
+	// changed table config fields.
+	// +optional
+	Trigger string `json:"trigger,omitempty"`
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Jobs are the rebalance jobs of the table types of the table.
+	// +optional
+	// +listType=map
+	// +listMapKey=tableType
+	Jobs []RebalanceJob `json:"jobs,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// ObservedConfig is the replication, tenants and replica group config of
+	// the table config rebalanced last, a change to it needs a rebalance.
+	// +optional
+	ObservedConfig string `json:"observedConfig,omitempty"`
+	// TargetConfig is the config of the last rebalance started, it becomes
+	// the observed config once the rebalance is done. A dry-run or a failed
+	// rebalance keeps the change to be rebalanced.
+	// +optional
+	TargetConfig string `json:"targetConfig,omitempty"`
+}
+
+// RebalanceJob is the rebalance job of a table type.
+type RebalanceJob struct {
+	// TableType is OFFLINE or REALTIME.
+	TableType string `json:"tableType"`
+	// +optional
+	JobID string `json:"jobId,omitempty"`
+	// Status is the status pinot reports for the job, IN_PROGRESS, DONE,
+	// NO_OP or FAILED.
+	// +optional
+	Status string `json:"status,omitempty"`
+	// +optional
+	Description string `json:"description,omitempty"`
+	// +optional
+	ElapsedSeconds int64 `json:"elapsedSeconds,omitempty"`
+}
@@ -0,0 +1,70 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReloadPhase is the step a segment reload job is at.
+type ReloadPhase string
+
+const (
+	// ReloadSubmitted is a reload pinot accepted without a job id to follow,
+	// as older pinot versions do.
+	ReloadSubmitted ReloadPhase = "Submitted"
+	// ReloadInProgress waits for the servers to reload the segments.
+	ReloadInProgress ReloadPhase = "InProgress"
+	// ReloadSucceeded is a reload job whose segments were all reloaded.
+	ReloadSucceeded ReloadPhase = "Succeeded"
+	// ReloadFailed is a reload job that failed or timed out before all its
+	// segments were reloaded.
+	ReloadFailed ReloadPhase = "Failed"
+)
+
+// SegmentReloadJob is a segment reload of a table type of the table.
+type SegmentReloadJob struct {
+	// +optional
+	JobID string `json:"jobId,omitempty"`
+	// TableName is the table name with its type.
+	TableName string `json:"tableName"`
+	// Segment is the reloaded segment, empty when all segments are reloaded.
+	// +optional
+	Segment string `json:"segment,omitempty"`
+	// Trigger is why the segments are reloaded, a schema update or the reload
+	// annotation.
+	// +optional
+	Trigger string `json:"trigger,omitempty"`
+	// +optional
+	Phase ReloadPhase `json:"phase,omitempty"`
+	// +optional
+	TotalSegments int `json:"totalSegments,omitempty"`
+	// +optional
+	SucceededSegments int `json:"succeededSegments,omitempty"`
+	// +optional
+	FailedSegments int `json:"failedSegments,omitempty"`
+	// FailedServerCalls is the number of servers pinot could not get the
+	// progress of when the job was last polled.
+	// +optional
+	FailedServerCalls int `json:"failedServerCalls,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	SubmitTime *metav1.Time `json:"submitTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
@@ -0,0 +1,42 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRevisionHistoryLimit is the number of revisions kept when
+// revisionHistoryLimit is unset.
+const DefaultRevisionHistoryLimit = 10
+
+// Revision is a schema, table or tenant json applied to pinot.
+type Revision struct {
+	// Revision numbers the applied json, it increases with every change.
+	Revision int64 `json:"revision"`
+	// Json is the applied json, a json read from a Secret only keeps the name
+	// of the object on pinot and the digest of the json.
+	Json string `json:"json"`
+	// Generation is the generation of the CR the json was applied from.
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+	// +optional
+	AppliedTime metav1.Time `json:"appliedTime,omitempty"`
+	// Response is the response of pinot to the change.
+	// +optional
+	Response string `json:"response,omitempty"`
+}
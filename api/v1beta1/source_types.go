@@ -0,0 +1,46 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// JsonSource is a json document kept outside of the CR, exactly one source
+// is set. ConfigMaps and Secrets are read from the namespace of the CR.
+// +kubebuilder:validation:XValidation:rule="(has(self.configMapKeyRef) ? 1 : 0) + (has(self.secretKeyRef) ? 1 : 0) + (has(self.url) ? 1 : 0) == 1",message="exactly one of configMapKeyRef, secretKeyRef and url must be set"
+type JsonSource struct {
+	// +optional
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// +optional
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// +optional
+	URL *URLSource `json:"url,omitempty"`
+}
+
+// URLSource is a json document fetched over http or https, it is pinned by
+// its checksum.
+type URLSource struct {
+	// +required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// Checksum is the sha256 of the document as sha256:<hex>, a document that
+	// does not match is not applied.
+	// +required
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Checksum string `json:"checksum"`
+}
@@ -22,14 +22,45 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ADLSStorageSpec) DeepCopyInto(out *ADLSStorageSpec) {
+	*out = *in
+	if in.AccessKeySecretKeyRef != nil {
+		in, out := &in.AccessKeySecretKeyRef, &out.AccessKeySecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ADLSStorageSpec.
+func (in *ADLSStorageSpec) DeepCopy() *ADLSStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ADLSStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Auth) DeepCopyInto(out *Auth) {
 	*out = *in
 	out.SecretRef = in.SecretRef
+	if in.SecretKeys != nil {
+		in, out := &in.SecretKeys, &out.SecretKeys
+		*out = new(AuthSecretKeys)
+		**out = **in
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2Spec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Auth.
@@ -42,6 +73,128 @@ func (in *Auth) DeepCopy() *Auth {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthSecretKeys) DeepCopyInto(out *AuthSecretKeys) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthSecretKeys.
+func (in *AuthSecretKeys) DeepCopy() *AuthSecretKeys {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthSecretKeys)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildFieldSpec) DeepCopyInto(out *ChildFieldSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildFieldSpec.
+func (in *ChildFieldSpec) DeepCopy() *ChildFieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildFieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComplexFieldSpec) DeepCopyInto(out *ComplexFieldSpec) {
+	*out = *in
+	if in.ChildFieldSpecs != nil {
+		in, out := &in.ChildFieldSpecs, &out.ChildFieldSpecs
+		*out = make(map[string]ChildFieldSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComplexFieldSpec.
+func (in *ComplexFieldSpec) DeepCopy() *ComplexFieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ComplexFieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerAPISpec) DeepCopyInto(out *ControllerAPISpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ControllerTLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerAPISpec.
+func (in *ControllerAPISpec) DeepCopy() *ControllerAPISpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerAPISpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerTLSSpec) DeepCopyInto(out *ControllerTLSSpec) {
+	*out = *in
+	if in.CASecretKeyRef != nil {
+		in, out := &in.CASecretKeyRef, &out.CASecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertSecretKeyRef != nil {
+		in, out := &in.CertSecretKeyRef, &out.CertSecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeySecretKeyRef != nil {
+		in, out := &in.KeySecretKeyRef, &out.KeySecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerTLSSpec.
+func (in *ControllerTLSSpec) DeepCopy() *ControllerTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DateTimeFieldSpec) DeepCopyInto(out *DateTimeFieldSpec) {
+	*out = *in
+	if in.DefaultNullValue != nil {
+		in, out := &in.DefaultNullValue, &out.DefaultNullValue
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DateTimeFieldSpec.
+func (in *DateTimeFieldSpec) DeepCopy() *DateTimeFieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DateTimeFieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeepStorageConfig) DeepCopyInto(out *DeepStorageConfig) {
 	*out = *in
@@ -57,6 +210,46 @@ func (in *DeepStorageConfig) DeepCopy() *DeepStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeepStorageProvider) DeepCopyInto(out *DeepStorageProvider) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3StorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GCS != nil {
+		in, out := &in.GCS, &out.GCS
+		*out = new(GCSStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ADLS != nil {
+		in, out := &in.ADLS, &out.ADLS
+		*out = new(ADLSStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HDFS != nil {
+		in, out := &in.HDFS, &out.HDFS
+		*out = new(HDFSStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = new(LocalStorageSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeepStorageProvider.
+func (in *DeepStorageProvider) DeepCopy() *DeepStorageProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(DeepStorageProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeepStorageSpec) DeepCopyInto(out *DeepStorageSpec) {
 	*out = *in
@@ -65,6 +258,11 @@ func (in *DeepStorageSpec) DeepCopyInto(out *DeepStorageSpec) {
 		*out = make([]DeepStorageConfig, len(*in))
 		copy(*out, *in)
 	}
+	if in.Provider != nil {
+		in, out := &in.Provider, &out.Provider
+		*out = new(DeepStorageProvider)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeepStorageSpec.
@@ -77,10 +275,40 @@ func (in *DeepStorageSpec) DeepCopy() *DeepStorageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DimensionFieldSpec) DeepCopyInto(out *DimensionFieldSpec) {
+	*out = *in
+	if in.SingleValueField != nil {
+		in, out := &in.SingleValueField, &out.SingleValueField
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DefaultNullValue != nil {
+		in, out := &in.DefaultNullValue, &out.DefaultNullValue
+		*out = new(string)
+		**out = **in
+	}
+	if in.MaxLength != nil {
+		in, out := &in.MaxLength, &out.MaxLength
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DimensionFieldSpec.
+func (in *DimensionFieldSpec) DeepCopy() *DimensionFieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DimensionFieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalSpec) DeepCopyInto(out *ExternalSpec) {
 	*out = *in
-	out.Zookeeper = in.Zookeeper
+	in.Zookeeper.DeepCopyInto(&out.Zookeeper)
 	in.DeepStorage.DeepCopyInto(&out.DeepStorage)
 }
 
@@ -94,38 +322,108 @@ func (in *ExternalSpec) DeepCopy() *ExternalSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSStorageSpec) DeepCopyInto(out *GCSStorageSpec) {
+	*out = *in
+	if in.KeySecretKeyRef != nil {
+		in, out := &in.KeySecretKeyRef, &out.KeySecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSStorageSpec.
+func (in *GCSStorageSpec) DeepCopy() *GCSStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HDFSStorageSpec) DeepCopyInto(out *HDFSStorageSpec) {
+	*out = *in
+	if in.KeytabSecretKeyRef != nil {
+		in, out := &in.KeytabSecretKeyRef, &out.KeytabSecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HDFSStorageSpec.
+func (in *HDFSStorageSpec) DeepCopy() *HDFSStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HDFSStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JsonSource) DeepCopyInto(out *JsonSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(URLSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JsonSource.
+func (in *JsonSource) DeepCopy() *JsonSource {
+	if in == nil {
+		return nil
+	}
+	out := new(JsonSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K8sConfig) DeepCopyInto(out *K8sConfig) {
 	*out = *in
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
-		*out = make([]v1.Volume, len(*in))
+		*out = make([]corev1.Volume, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Port != nil {
 		in, out := &in.Port, &out.Port
-		*out = make([]v1.ContainerPort, len(*in))
+		*out = make([]corev1.ContainerPort, len(*in))
 		copy(*out, *in)
 	}
 	if in.VolumeMount != nil {
 		in, out := &in.VolumeMount, &out.VolumeMount
-		*out = make([]v1.VolumeMount, len(*in))
+		*out = make([]corev1.VolumeMount, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]v1.Toleration, len(*in))
+		*out = make([]corev1.Toleration, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -147,22 +445,22 @@ func (in *K8sConfig) DeepCopyInto(out *K8sConfig) {
 	}
 	if in.Service != nil {
 		in, out := &in.Service, &out.Service
-		*out = new(v1.ServiceSpec)
+		*out = new(corev1.ServiceSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.LivenessProbe != nil {
 		in, out := &in.LivenessProbe, &out.LivenessProbe
-		*out = new(v1.Probe)
+		*out = new(corev1.Probe)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.ReadinessProbe != nil {
 		in, out := &in.ReadinessProbe, &out.ReadinessProbe
-		*out = new(v1.Probe)
+		*out = new(corev1.Probe)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.StartUpProbe != nil {
 		in, out := &in.StartUpProbe, &out.StartUpProbe
-		*out = new(v1.Probe)
+		*out = new(corev1.Probe)
 		(*in).DeepCopyInto(*out)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
@@ -178,6 +476,57 @@ func (in *K8sConfig) DeepCopy() *K8sConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalStorageSpec) DeepCopyInto(out *LocalStorageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalStorageSpec.
+func (in *LocalStorageSpec) DeepCopy() *LocalStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedZookeeperSpec) DeepCopyInto(out *ManagedZookeeperSpec) {
+	*out = *in
+	in.Storage.DeepCopyInto(&out.Storage)
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedZookeeperSpec.
+func (in *ManagedZookeeperSpec) DeepCopy() *ManagedZookeeperSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedZookeeperSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metadata) DeepCopyInto(out *Metadata) {
 	*out = *in
@@ -207,6 +556,45 @@ func (in *Metadata) DeepCopy() *Metadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricFieldSpec) DeepCopyInto(out *MetricFieldSpec) {
+	*out = *in
+	if in.DefaultNullValue != nil {
+		in, out := &in.DefaultNullValue, &out.DefaultNullValue
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricFieldSpec.
+func (in *MetricFieldSpec) DeepCopy() *MetricFieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricFieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeGroupStatus) DeepCopyInto(out *NodeGroupStatus) {
+	*out = *in
+	if in.LastRolloutTime != nil {
+		in, out := &in.LastRolloutTime, &out.LastRolloutTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeGroupStatus.
+func (in *NodeGroupStatus) DeepCopy() *NodeGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeSpec) DeepCopyInto(out *NodeSpec) {
 	*out = *in
@@ -222,13 +610,33 @@ func (in *NodeSpec) DeepCopy() *NodeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2Spec) DeepCopyInto(out *OAuth2Spec) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2Spec.
+func (in *OAuth2Spec) DeepCopy() *OAuth2Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pinot) DeepCopyInto(out *Pinot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pinot.
@@ -249,6 +657,113 @@ func (in *Pinot) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotExternalCluster) DeepCopyInto(out *PinotExternalCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotExternalCluster.
+func (in *PinotExternalCluster) DeepCopy() *PinotExternalCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotExternalCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PinotExternalCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotExternalClusterList) DeepCopyInto(out *PinotExternalClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PinotExternalCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotExternalClusterList.
+func (in *PinotExternalClusterList) DeepCopy() *PinotExternalClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotExternalClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PinotExternalClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotExternalClusterSpec) DeepCopyInto(out *PinotExternalClusterSpec) {
+	*out = *in
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(Auth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ControllerTLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotExternalClusterSpec.
+func (in *PinotExternalClusterSpec) DeepCopy() *PinotExternalClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotExternalClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotExternalClusterStatus) DeepCopyInto(out *PinotExternalClusterStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotExternalClusterStatus.
+func (in *PinotExternalClusterStatus) DeepCopy() *PinotExternalClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotExternalClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotList) DeepCopyInto(out *PinotList) {
 	*out = *in
@@ -301,7 +816,7 @@ func (in *PinotSchema) DeepCopyInto(out *PinotSchema) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -315,14 +830,82 @@ func (in *PinotSchema) DeepCopy() *PinotSchema {
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PinotSchema) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PinotSchema) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotSchemaChange) DeepCopyInto(out *PinotSchemaChange) {
+	*out = *in
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotSchemaChange.
+func (in *PinotSchemaChange) DeepCopy() *PinotSchemaChange {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotSchemaChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotSchemaDefinition) DeepCopyInto(out *PinotSchemaDefinition) {
+	*out = *in
+	if in.DimensionFieldSpecs != nil {
+		in, out := &in.DimensionFieldSpecs, &out.DimensionFieldSpecs
+		*out = make([]DimensionFieldSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MetricFieldSpecs != nil {
+		in, out := &in.MetricFieldSpecs, &out.MetricFieldSpecs
+		*out = make([]MetricFieldSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DateTimeFieldSpecs != nil {
+		in, out := &in.DateTimeFieldSpecs, &out.DateTimeFieldSpecs
+		*out = make([]DateTimeFieldSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ComplexFieldSpecs != nil {
+		in, out := &in.ComplexFieldSpecs, &out.ComplexFieldSpecs
+		*out = make([]ComplexFieldSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PrimaryKeyColumns != nil {
+		in, out := &in.PrimaryKeyColumns, &out.PrimaryKeyColumns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotSchemaDefinition.
+func (in *PinotSchemaDefinition) DeepCopy() *PinotSchemaDefinition {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotSchemaDefinition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotSchemaList) DeepCopyInto(out *PinotSchemaList) {
 	*out = *in
@@ -358,6 +941,21 @@ func (in *PinotSchemaList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotSchemaSpec) DeepCopyInto(out *PinotSchemaSpec) {
 	*out = *in
+	if in.SchemaFrom != nil {
+		in, out := &in.SchemaFrom, &out.SchemaFrom
+		*out = new(JsonSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = new(PinotSchemaDefinition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotSchemaSpec.
@@ -374,6 +972,30 @@ func (in *PinotSchemaSpec) DeepCopy() *PinotSchemaSpec {
 func (in *PinotSchemaStatus) DeepCopyInto(out *PinotSchemaStatus) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.LastChange != nil {
+		in, out := &in.LastChange, &out.LastChange
+		*out = new(PinotSchemaChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(PlanStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]Revision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotSchemaStatus.
@@ -389,7 +1011,7 @@ func (in *PinotSchemaStatus) DeepCopy() *PinotSchemaStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotSpec) DeepCopyInto(out *PinotSpec) {
 	*out = *in
-	out.Auth = in.Auth
+	in.Auth.DeepCopyInto(&out.Auth)
 	if in.Plugins != nil {
 		in, out := &in.Plugins, &out.Plugins
 		*out = make([]string, len(*in))
@@ -418,6 +1040,16 @@ func (in *PinotSpec) DeepCopyInto(out *PinotSpec) {
 		*out = make([]NodeSpec, len(*in))
 		copy(*out, *in)
 	}
+	if in.ProgressDeadline != nil {
+		in, out := &in.ProgressDeadline, &out.ProgressDeadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ControllerAPI != nil {
+		in, out := &in.ControllerAPI, &out.ControllerAPI
+		*out = new(ControllerAPISpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotSpec.
@@ -433,6 +1065,40 @@ func (in *PinotSpec) DeepCopy() *PinotSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotStatus) DeepCopyInto(out *PinotStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeGroups != nil {
+		in, out := &in.NodeGroups, &out.NodeGroups
+		*out = make([]NodeGroupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RollingRestart != nil {
+		in, out := &in.RollingRestart, &out.RollingRestart
+		*out = new(RollingRestartStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleDown != nil {
+		in, out := &in.ScaleDown, &out.ScaleDown
+		*out = new(ScaleDownStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Zookeeper != nil {
+		in, out := &in.Zookeeper, &out.Zookeeper
+		*out = new(ZookeeperStatus)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotStatus.
@@ -450,7 +1116,7 @@ func (in *PinotTable) DeepCopyInto(out *PinotTable) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -507,6 +1173,21 @@ func (in *PinotTableList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotTableSpec) DeepCopyInto(out *PinotTableSpec) {
 	*out = *in
+	if in.TableFrom != nil {
+		in, out := &in.TableFrom, &out.TableFrom
+		*out = new(JsonSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rebalance != nil {
+		in, out := &in.Rebalance, &out.Rebalance
+		*out = new(TableRebalance)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotTableSpec.
@@ -528,6 +1209,44 @@ func (in *PinotTableStatus) DeepCopyInto(out *PinotTableStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(PlanStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]Revision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]PinotTableTypeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rebalance != nil {
+		in, out := &in.Rebalance, &out.Rebalance
+		*out = new(TableRebalanceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Reloads != nil {
+		in, out := &in.Reloads, &out.Reloads
+		*out = make([]SegmentReloadJob, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotTableStatus.
@@ -540,12 +1259,28 @@ func (in *PinotTableStatus) DeepCopy() *PinotTableStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinotTableTypeStatus) DeepCopyInto(out *PinotTableTypeStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotTableTypeStatus.
+func (in *PinotTableTypeStatus) DeepCopy() *PinotTableTypeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PinotTableTypeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotTenant) DeepCopyInto(out *PinotTenant) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -602,6 +1337,11 @@ func (in *PinotTenantList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PinotTenantSpec) DeepCopyInto(out *PinotTenantSpec) {
 	*out = *in
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotTenantSpec.
@@ -618,6 +1358,25 @@ func (in *PinotTenantSpec) DeepCopy() *PinotTenantSpec {
 func (in *PinotTenantStatus) DeepCopyInto(out *PinotTenantStatus) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(PlanStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]Revision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinotTenantStatus.
@@ -630,6 +1389,189 @@ func (in *PinotTenantStatus) DeepCopy() *PinotTenantStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanStatus) DeepCopyInto(out *PlanStatus) {
+	*out = *in
+	if in.Diff != nil {
+		in, out := &in.Diff, &out.Diff
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastPlanTime.DeepCopyInto(&out.LastPlanTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanStatus.
+func (in *PlanStatus) DeepCopy() *PlanStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RebalanceJob) DeepCopyInto(out *RebalanceJob) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RebalanceJob.
+func (in *RebalanceJob) DeepCopy() *RebalanceJob {
+	if in == nil {
+		return nil
+	}
+	out := new(RebalanceJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Revision) DeepCopyInto(out *Revision) {
+	*out = *in
+	in.AppliedTime.DeepCopyInto(&out.AppliedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Revision.
+func (in *Revision) DeepCopy() *Revision {
+	if in == nil {
+		return nil
+	}
+	out := new(Revision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingRestartStatus) DeepCopyInto(out *RollingRestartStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingRestartStatus.
+func (in *RollingRestartStatus) DeepCopy() *RollingRestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingRestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3StorageSpec) DeepCopyInto(out *S3StorageSpec) {
+	*out = *in
+	if in.AccessKeySecretKeyRef != nil {
+		in, out := &in.AccessKeySecretKeyRef, &out.AccessKeySecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeySecretKeyRef != nil {
+		in, out := &in.SecretKeySecretKeyRef, &out.SecretKeySecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3StorageSpec.
+func (in *S3StorageSpec) DeepCopy() *S3StorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(S3StorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleDownStatus) DeepCopyInto(out *ScaleDownStatus) {
+	*out = *in
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceTags != nil {
+		in, out := &in.InstanceTags, &out.InstanceTags
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleDownStatus.
+func (in *ScaleDownStatus) DeepCopy() *ScaleDownStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleDownStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SegmentReloadJob) DeepCopyInto(out *SegmentReloadJob) {
+	*out = *in
+	if in.SubmitTime != nil {
+		in, out := &in.SubmitTime, &out.SubmitTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SegmentReloadJob.
+func (in *SegmentReloadJob) DeepCopy() *SegmentReloadJob {
+	if in == nil {
+		return nil
+	}
+	out := new(SegmentReloadJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageConfig) DeepCopyInto(out *StorageConfig) {
 	*out = *in
@@ -646,6 +1588,74 @@ func (in *StorageConfig) DeepCopy() *StorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TableRebalance) DeepCopyInto(out *TableRebalance) {
+	*out = *in
+	if in.MinAvailableReplicas != nil {
+		in, out := &in.MinAvailableReplicas, &out.MinAvailableReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.ReassignInstances != nil {
+		in, out := &in.ReassignInstances, &out.ReassignInstances
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TableRebalance.
+func (in *TableRebalance) DeepCopy() *TableRebalance {
+	if in == nil {
+		return nil
+	}
+	out := new(TableRebalance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TableRebalanceStatus) DeepCopyInto(out *TableRebalanceStatus) {
+	*out = *in
+	if in.Jobs != nil {
+		in, out := &in.Jobs, &out.Jobs
+		*out = make([]RebalanceJob, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TableRebalanceStatus.
+func (in *TableRebalanceStatus) DeepCopy() *TableRebalanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TableRebalanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *URLSource) DeepCopyInto(out *URLSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new URLSource.
+func (in *URLSource) DeepCopy() *URLSource {
+	if in == nil {
+		return nil
+	}
+	out := new(URLSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZookeeperConfig) DeepCopyInto(out *ZookeeperConfig) {
 	*out = *in
@@ -665,6 +1675,11 @@ func (in *ZookeeperConfig) DeepCopy() *ZookeeperConfig {
 func (in *ZookeeperSpec) DeepCopyInto(out *ZookeeperSpec) {
 	*out = *in
 	out.Spec = in.Spec
+	if in.Managed != nil {
+		in, out := &in.Managed, &out.Managed
+		*out = new(ManagedZookeeperSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperSpec.
@@ -676,3 +1691,18 @@ func (in *ZookeeperSpec) DeepCopy() *ZookeeperSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZookeeperStatus) DeepCopyInto(out *ZookeeperStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperStatus.
+func (in *ZookeeperStatus) DeepCopy() *ZookeeperStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZookeeperStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -32,6 +32,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	datainfraiov1beta1 "github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	externalclustercontroller "github.com/datainfrahq/pinot-control-plane-k8s/internal/externalcluster_controller"
 	pinotcontroller "github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot_controller"
 	schemacontroller "github.com/datainfrahq/pinot-control-plane-k8s/internal/schema_controller"
 	tablecontroller "github.com/datainfrahq/pinot-control-plane-k8s/internal/table_controller"
@@ -109,6 +110,20 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "PinotTenantController")
 		os.Exit(1)
 	}
+
+	if err = (externalclustercontroller.NewPinotExternalClusterReconciler(mgr)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PinotExternalClusterController")
+		os.Exit(1)
+	}
+
+	// webhooks need serving certificates, they are opt in so that existing
+	// installations without cert-manager keep working.
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&datainfraiov1beta1.Pinot{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Pinot")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,89 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalclustercontroller
+
+import (
+	"context"
+	"time"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	PinotExternalClusterControllerReachable   = "PinotExternalClusterControllerReachable"
+	PinotExternalClusterControllerUnreachable = "PinotExternalClusterControllerUnreachable"
+)
+
+// do probes the health endpoint of the controller and records whether it is
+// reachable with the configured endpoint, auth and tls.
+func (r *PinotExternalClusterReconciler) do(ctx context.Context, cluster *v1beta1.PinotExternalCluster) error {
+	var probeErr error
+	pc, err := utils.NewExternalClusterClient(ctx, r.Client, cluster)
+	if err != nil {
+		probeErr = err
+	} else {
+		probeErr = pc.Health(ctx)
+	}
+
+	conditionType, status, reason, msg := v1beta1.PinotExternalClusterReachable, v1.ConditionTrue, PinotExternalClusterControllerReachable, "controller is healthy"
+	eventType := v1.EventTypeNormal
+	if probeErr != nil {
+		conditionType, status, reason, msg = v1beta1.PinotExternalClusterUnreachable, v1.ConditionFalse, PinotExternalClusterControllerUnreachable, probeErr.Error()
+		eventType = v1.EventTypeWarning
+	}
+
+	// the probe goes through the breaker shared with the schemas, tables and
+	// tenants of the cluster
+	var unreachable *metav1.Condition
+	if pc != nil {
+		if condition, changed := utils.NewUnreachableCondition(cluster.Status.Conditions, pc, cluster.Generation); changed {
+			unreachable = &condition
+		}
+	}
+
+	probeChanged := cluster.Status.Type != conditionType || cluster.Status.Message != msg
+	if !probeChanged && unreachable == nil {
+		return nil
+	}
+
+	if _, _, err := utils.PatchStatus(ctx, r.Client, cluster, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotExternalCluster)
+		if probeChanged {
+			in.Status.Type = conditionType
+			in.Status.Status = status
+			in.Status.Reason = reason
+			in.Status.Message = msg
+			in.Status.LastUpdateTime = metav1.Time{Time: time.Now()}
+		}
+		if unreachable != nil {
+			meta.SetStatusCondition(&in.Status.Conditions, *unreachable)
+		}
+		return in
+	}); err != nil {
+		return err
+	}
+
+	if probeChanged {
+		r.Recorder.Event(cluster, eventType, reason, msg)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// OAuth2ClientCredentials is the oauth2 client credentials grant used to
+// obtain bearer tokens from an oauth2 / oidc identity provider.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string
+}
+
+// tokenSourceIdleTimeout evicts the token source of a secret no client was
+// created for since, such as the secret of a deleted cluster.
+const tokenSourceIdleTimeout = time.Hour
+
+// TokenSource fetches bearer tokens with the client credentials grant, a token
+// is reused until it expires.
+type TokenSource struct {
+	mu     sync.Mutex
+	config clientcredentials.Config
+	token  *oauth2.Token
+}
+
+// Token returns the current token, or fetches a new one with ctx once it
+// expired.
+func (s *TokenSource) Token(ctx context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: DefaultRequestTimeout})
+	token, err := s.config.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+type cachedTokenSource struct {
+	version     string
+	tokenSource *TokenSource
+	lastUsed    time.Time
+}
+
+var tokenSources = struct {
+	sync.Mutex
+	byKey map[string]*cachedTokenSource
+}{byKey: map[string]*cachedTokenSource{}}
+
+// GetTokenSource returns the token source of the client credentials read from
+// a secret, secretKey names the secret and version is its resourceVersion.
+// Token sources are shared so that a token is reused across reconciles until
+// it expires, a rotated secret replaces the token source of the previous
+// version. Token sources idle for an hour are evicted.
+func GetTokenSource(secretKey, version string, config OAuth2ClientCredentials) *TokenSource {
+	key := secretKey + "/" + hashOf(
+		[]byte(config.TokenURL),
+		[]byte(strings.Join(config.Scopes, " ")),
+		[]byte(config.Audience),
+	)
+
+	tokenSources.Lock()
+	defer tokenSources.Unlock()
+
+	now := time.Now()
+	for k, cached := range tokenSources.byKey {
+		if now.Sub(cached.lastUsed) > tokenSourceIdleTimeout {
+			delete(tokenSources.byKey, k)
+		}
+	}
+
+	if cached, ok := tokenSources.byKey[key]; ok && cached.version == version {
+		cached.lastUsed = now
+		return cached.tokenSource
+	}
+
+	cc := clientcredentials.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		TokenURL:     config.TokenURL,
+		Scopes:       config.Scopes,
+	}
+	if config.Audience != "" {
+		cc.EndpointParams = url.Values{"audience": {config.Audience}}
+	}
+
+	tokenSource := &TokenSource{config: cc}
+	tokenSources.byKey[key] = &cachedTokenSource{version: version, tokenSource: tokenSource, lastUsed: now}
+	return tokenSource
+}
+
+// token returns the bearer token of the request, empty when the request
+// is not authenticated with a token.
+func (a *Auth) token(ctx context.Context) (string, error) {
+	if a.TokenSource == nil {
+		return a.BearerToken, nil
+	}
+
+	token, err := a.TokenSource.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("fetching oauth2 token: %w", err)
+	}
+	return token.AccessToken, nil
+}
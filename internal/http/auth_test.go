@@ -0,0 +1,163 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newAuthServer answers 200 when the request carries the authorization
+// header and 401 otherwise.
+func newAuthServer(t *testing.T, authorization string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTokenServer issues tokens for the client credentials of pinot and
+// counts the tokens issued.
+func newTokenServer(t *testing.T) (*httptest.Server, *int32) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		clientID, clientSecret, _ := req.BasicAuth()
+		if req.FormValue("grant_type") != "client_credentials" || clientID != "pinot" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"oauth2-token","token_type":"bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+func TestAuth(t *testing.T) {
+	tokenServer, issued := newTokenServer(t)
+
+	tests := []struct {
+		name          string
+		auth          Auth
+		authorization string
+	}{
+		{"basic auth", Auth{BasicAuth: BasicAuth{UserName: "admin", Password: "verysecret"}}, "Basic YWRtaW46dmVyeXNlY3JldA=="},
+		{"bearer token", Auth{BearerToken: "static-token"}, "Bearer static-token"},
+		{
+			"oauth2 client credentials",
+			Auth{TokenSource: GetTokenSource("pinot/oauth2", "1", OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "pinot", ClientSecret: "secret"})},
+			"Bearer oauth2-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthServer(t, tt.authorization)
+			c := &Client{Method: http.MethodGet, URL: server.URL, Auth: tt.auth}
+
+			for i := 0; i < 2; i++ {
+				resp, err := c.Do(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("expected status 200, got %d", resp.StatusCode)
+				}
+			}
+		})
+	}
+
+	if got := atomic.LoadInt32(issued); got != 1 {
+		t.Errorf("expected the oauth2 token to be reused, got %d tokens", got)
+	}
+}
+
+func TestAuthTokenFailure(t *testing.T) {
+	tokenServer, _ := newTokenServer(t)
+	server, requests := newTestServer(t, http.StatusOK)
+	breaker := NewCircuitBreaker(1, time.Hour)
+
+	c := &Client{
+		Method:  http.MethodGet,
+		URL:     server.URL,
+		Auth:    Auth{TokenSource: GetTokenSource("pinot/oauth2-wrong", "1", OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "pinot", ClientSecret: "wrong"})},
+		Breaker: breaker,
+	}
+
+	if _, err := c.Do(context.Background()); err == nil {
+		t.Errorf("expected the token request to fail")
+	}
+	if got := atomic.LoadInt32(requests); got != 0 {
+		t.Errorf("expected no request without a token, got %d requests", got)
+	}
+	if breaker.IsOpen() {
+		t.Errorf("expected token failures not to open the breaker")
+	}
+}
+
+func TestGetTokenSource(t *testing.T) {
+	config := OAuth2ClientCredentials{TokenURL: "http://idp/token", ClientID: "pinot", ClientSecret: "secret"}
+
+	tokenSource := GetTokenSource("pinot/rotated", "1", config)
+	if GetTokenSource("pinot/rotated", "1", config) != tokenSource {
+		t.Errorf("expected the token source to be shared")
+	}
+
+	config.ClientSecret = "rotated"
+	rotated := GetTokenSource("pinot/rotated", "2", config)
+	if rotated == tokenSource {
+		t.Errorf("expected a rotated secret to get a new token source")
+	}
+
+	tokenSources.Lock()
+	for _, cached := range tokenSources.byKey {
+		cached.lastUsed = time.Now().Add(-2 * tokenSourceIdleTimeout)
+	}
+	tokenSources.Unlock()
+
+	GetTokenSource("pinot/other", "1", config)
+	tokenSources.Lock()
+	defer tokenSources.Unlock()
+	if len(tokenSources.byKey) != 1 {
+		t.Errorf("expected the idle token sources to be evicted, got %d token sources", len(tokenSources.byKey))
+	}
+}
+
+func TestTokenSourceContext(t *testing.T) {
+	tokenServer, issued := newTokenServer(t)
+	tokenSource := GetTokenSource("pinot/context", "1", OAuth2ClientCredentials{TokenURL: tokenServer.URL, ClientID: "pinot", ClientSecret: "secret"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tokenSource.Token(ctx); err == nil {
+		t.Errorf("expected a canceled context to fail the token request")
+	}
+	if _, err := tokenSource.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(issued); got != 1 {
+		t.Errorf("expected a single token, got %d", got)
+	}
+}
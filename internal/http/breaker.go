@@ -0,0 +1,133 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+	// breakerIdleTimeout evicts the breaker of an endpoint no client was
+	// created for since, such as the controller of a deleted cluster.
+	breakerIdleTimeout = time.Hour
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit
+// breaker of the endpoint is open.
+var ErrCircuitOpen = errors.New("circuit breaker open, endpoint unreachable")
+
+// CircuitBreaker opens after threshold consecutive failed requests. While open
+// requests fail fast, once the cooldown has passed a single request is let
+// through as a probe, its success closes the breaker and its failure opens it
+// for another cooldown.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow returns false while the breaker is open and cooling down. The request
+// allowed after the cooldown restarts it, the following requests fail fast
+// until the probe succeeds or the cooldown passes again, so a probe that never
+// reports back does not keep the breaker half open.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.openedAt = time.Now()
+	return true
+}
+
+// IsOpen returns true when the last threshold requests failed.
+func (b *CircuitBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+type sharedBreaker struct {
+	breaker  *CircuitBreaker
+	lastUsed time.Time
+}
+
+var circuitBreakers = struct {
+	sync.Mutex
+	breakers map[string]*sharedBreaker
+}{breakers: map[string]*sharedBreaker{}}
+
+// GetCircuitBreaker returns the breaker shared by all requests to an endpoint,
+// the schema, table, tenant and pinot controllers of a cluster share the
+// breaker of its pinot controller. Breakers of endpoints idle for an hour are
+// evicted.
+func GetCircuitBreaker(endpoint string) *CircuitBreaker {
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+
+	now := time.Now()
+	for e, shared := range circuitBreakers.breakers {
+		if now.Sub(shared.lastUsed) > breakerIdleTimeout {
+			delete(circuitBreakers.breakers, e)
+		}
+	}
+
+	shared, ok := circuitBreakers.breakers[endpoint]
+	if !ok {
+		shared = &sharedBreaker{breaker: NewCircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown)}
+		circuitBreakers.breakers[endpoint] = shared
+	}
+	shared.lastUsed = now
+	return shared.breaker
+}
+
+// IsCircuitOpen returns true while the breaker of an endpoint is open, an
+// endpoint without breaker is reachable.
+func IsCircuitOpen(endpoint string) bool {
+	circuitBreakers.Lock()
+	shared, ok := circuitBreakers.breakers[endpoint]
+	circuitBreakers.Unlock()
+	return ok && shared.breaker.IsOpen()
+}
@@ -18,13 +18,21 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"time"
+)
+
+const (
+	DefaultRequestTimeout = 30 * time.Second
 )
 
 // PinotHTTP interface
 type PinotHTTP interface {
-	Do() (*Response, error)
+	Do(ctx context.Context) (*Response, error)
 }
 
 // HTTP client
@@ -34,6 +42,14 @@ type Client struct {
 	HTTPClient http.Client
 	Body       []byte
 	Auth       Auth
+	// Timeout bounds every attempt of the request, DefaultRequestTimeout
+	// when unset.
+	Timeout time.Duration
+	// Retry is the retry policy of idempotent requests, requests are sent
+	// once when unset.
+	Retry *RetryPolicy
+	// Breaker fails requests fast while the endpoint is unreachable.
+	Breaker *CircuitBreaker
 }
 
 func NewHTTPClient(method, url string, client http.Client, body []byte, auth Auth) PinotHTTP {
@@ -52,6 +68,11 @@ func NewHTTPClient(method, url string, client http.Client, body []byte, auth Aut
 // with pinot clusters
 type Auth struct {
 	BasicAuth BasicAuth
+	// BearerToken is a static token sent in the Authorization header.
+	BearerToken string
+	// TokenSource fetches and refreshes bearer tokens, it takes precedence
+	// over BearerToken.
+	TokenSource *TokenSource
 }
 
 // BasicAuth
@@ -66,19 +87,121 @@ type Response struct {
 	StatusCode   int
 }
 
+// RetryPolicy retries idempotent requests that failed with a transport error
+// or a retryable status code, with jittered exponential backoff.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy retries up to 3 times within about 3 seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// backoff returns the wait before retry attempt, full jitter over an
+// exponentially growing window.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	window := p.InitialBackoff << attempt
+	if window <= 0 || window > p.MaxBackoff {
+		window = p.MaxBackoff
+	}
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(window)) + 1)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 // GET /schemas returns 404 when schema not found with code and error as resp.
 // GET /tenants returns 404 when tenant not found with code and error as resp
 // GET /tables returns 200 when table not found with an empty response.
 
-// Do method to be used schema and tenant controller.
-func (c *Client) Do() (*Response, error) {
+// Do sends the request, retrying it according to the retry policy until ctx
+// is done. A response is returned for every status code, errors are
+// transport errors, ctx errors and ErrCircuitOpen.
+func (c *Client) Do(ctx context.Context) (*Response, error) {
+
+	// a failing token endpoint says nothing about the endpoint, the token is
+	// fetched before the breaker is consulted.
+	token, err := c.Auth.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Breaker != nil && !c.Breaker.Allow() {
+		return nil, fmt.Errorf("%w: %s %s", ErrCircuitOpen, c.Method, c.URL)
+	}
+
+	maxRetries := 0
+	if c.Retry != nil && isIdempotent(c.Method) {
+		maxRetries = c.Retry.MaxRetries
+	}
+
+	var resp *Response
+	for attempt := 0; ; attempt++ {
+		resp, err = c.do(ctx, token)
+
+		retryable := (err != nil && ctx.Err() == nil) || (err == nil && isRetryableStatus(resp.StatusCode))
+		if !retryable || attempt >= maxRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.Retry.backoff(attempt)):
+		}
+	}
+
+	if c.Breaker != nil && ctx.Err() == nil {
+		if err != nil || isRetryableStatus(resp.StatusCode) {
+			c.Breaker.Failure()
+		} else {
+			c.Breaker.Success()
+		}
+	}
+
+	return resp, err
+}
+
+// do sends a single attempt of the request, authenticated with the bearer
+// token when set and with basic auth otherwise.
+func (c *Client) do(ctx context.Context, token string) (*Response, error) {
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	req, err := http.NewRequest(c.Method, c.URL, bytes.NewBuffer(c.Body))
+	req, err := http.NewRequestWithContext(ctx, c.Method, c.URL, bytes.NewBuffer(c.Body))
 	if err != nil {
 		return nil, err
 	}
 
-	if c.Auth.BasicAuth != (BasicAuth{}) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.Auth.BasicAuth != (BasicAuth{}) {
 		req.SetBasicAuth(c.Auth.BasicAuth.UserName, c.Auth.BasicAuth.Password)
 	}
 
@@ -0,0 +1,189 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+}
+
+// newTestServer answers with the given status codes in order, repeating the
+// last one, and counts the requests it received.
+func newTestServer(t *testing.T, statusCodes ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n > len(statusCodes) {
+			n = len(statusCodes)
+		}
+		w.WriteHeader(statusCodes[n-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		statusCodes []int
+		wantStatus  int
+		wantCalls   int32
+	}{
+		{"idempotent retried until success", http.MethodGet, []int{503, 502, 200}, 200, 3},
+		{"idempotent retries exhausted", http.MethodDelete, []int{503}, 503, 4},
+		{"non idempotent not retried", http.MethodPost, []int{503, 200}, 503, 1},
+		{"non retryable status", http.MethodGet, []int{500, 200}, 500, 1},
+		{"not found", http.MethodGet, []int{404, 200}, 404, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newTestServer(t, tt.statusCodes...)
+			c := &Client{Method: tt.method, URL: server.URL, Retry: &testRetryPolicy}
+
+			resp, err := c.Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := atomic.LoadInt32(requests); got != tt.wantCalls {
+				t.Errorf("expected %d requests, got %d", tt.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestTimeoutAndCancel(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-block:
+		case <-req.Context().Done():
+		}
+	}))
+	t.Cleanup(func() { close(block); server.Close() })
+
+	c := &Client{Method: http.MethodGet, URL: server.URL, Timeout: 10 * time.Millisecond}
+	if _, err := c.Do(context.Background()); err == nil {
+		t.Errorf("expected the request to time out")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = &Client{Method: http.MethodGet, URL: server.URL, Retry: &testRetryPolicy}
+	if _, err := c.Do(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, requests := newTestServer(t, 503, 503, 200)
+	breaker := NewCircuitBreaker(2, time.Hour)
+	c := &Client{Method: http.MethodGet, URL: server.URL, Breaker: breaker}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !breaker.IsOpen() {
+		t.Fatalf("expected breaker to open after 2 failures")
+	}
+
+	if _, err := c.Do(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected circuit open error, got %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("expected no request while open, got %d requests", got)
+	}
+
+	// once cooled down requests go through and a success closes the breaker
+	breaker.cooldown = 0
+	if resp, err := c.Do(context.Background()); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected request after cooldown to succeed, got %v", err)
+	}
+	if breaker.IsOpen() {
+		t.Errorf("expected breaker to close after a success")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker := NewCircuitBreaker(1, 10*time.Millisecond)
+	breaker.Failure()
+	if breaker.Allow() {
+		t.Fatalf("expected requests to fail fast while open")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !breaker.Allow() {
+		t.Fatalf("expected a probe after the cooldown")
+	}
+	if breaker.Allow() {
+		t.Errorf("expected a single probe while half open")
+	}
+
+	// a probe never reporting back lets another one through after a cooldown
+	time.Sleep(20 * time.Millisecond)
+	if !breaker.Allow() {
+		t.Errorf("expected another probe after the cooldown")
+	}
+	breaker.Success()
+	if !breaker.Allow() || !breaker.Allow() {
+		t.Errorf("expected requests to go through once closed")
+	}
+}
+
+func TestGetCircuitBreaker(t *testing.T) {
+	endpoint := "http://pinot-controller.evict.svc.cluster.local:9000"
+	breaker := GetCircuitBreaker(endpoint)
+	if GetCircuitBreaker(endpoint) != breaker {
+		t.Fatalf("expected the breaker to be shared")
+	}
+
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		breaker.Failure()
+	}
+	if !IsCircuitOpen(endpoint) {
+		t.Errorf("expected the circuit of %s to be open", endpoint)
+	}
+	if IsCircuitOpen("http://unknown:9000") {
+		t.Errorf("expected an endpoint without breaker to be reachable")
+	}
+
+	circuitBreakers.Lock()
+	circuitBreakers.breakers[endpoint].lastUsed = time.Now().Add(-2 * breakerIdleTimeout)
+	circuitBreakers.Unlock()
+
+	GetCircuitBreaker("http://pinot-controller.other.svc.cluster.local:9000")
+	if IsCircuitOpen(endpoint) {
+		t.Errorf("expected the idle breaker of %s to be evicted", endpoint)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newClientCert returns a self signed client certificate and its key as pem.
+func newClientCert(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pinot-control-plane"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestTLSClient(t *testing.T) {
+	clientCert, clientKey := newClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name    string
+		config  *TLSConfig
+		wantErr bool
+	}{
+		{"mutual tls", &TLSConfig{CA: serverCA, Cert: clientCert, Key: clientKey}, false},
+		{"missing client certificate", &TLSConfig{CA: serverCA}, true},
+		{"unknown server ca", &TLSConfig{Cert: clientCert, Key: clientKey}, true},
+		{"insecure skip verify", &TLSConfig{Cert: clientCert, Key: clientKey, InsecureSkipVerify: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := NewTLSClient(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &Client{Method: http.MethodGet, URL: server.URL, HTTPClient: httpClient}
+
+			resp, err := c.Do(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected status 200, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTLSClientInvalidMaterial(t *testing.T) {
+	if _, err := NewTLSClient(&TLSConfig{CA: []byte("not a certificate")}); err == nil {
+		t.Errorf("expected an invalid ca bundle to be rejected")
+	}
+	if _, err := NewTLSClient(&TLSConfig{Cert: []byte("not a certificate")}); err == nil {
+		t.Errorf("expected a certificate without key to be rejected")
+	}
+
+	first, err := NewTLSClient(&TLSConfig{ServerName: "pinot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := NewTLSClient(&TLSConfig{ServerName: "pinot"})
+	if first.Transport != second.Transport {
+		t.Errorf("expected clients of the same configuration to share a transport")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pinot is a typed client for the pinot controller REST api shared by
+// all reconcilers of the control plane.
+package pinot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+)
+
+// Client talks to the REST api of a single pinot controller. Idempotent
+// requests are retried with backoff, all requests to a controller share a
+// circuit breaker.
+type Client struct {
+	baseURL    string
+	auth       internalHTTP.Auth
+	httpClient http.Client
+	breaker    *internalHTTP.CircuitBreaker
+}
+
+// NewClient returns a client for the pinot controller served at baseURL,
+// for example http://pinot-controller.pinot.svc.cluster.local:9000. tlsConfig
+// is only needed for https controllers with a private ca or mutual tls.
+func NewClient(baseURL string, auth internalHTTP.Auth, tlsConfig *internalHTTP.TLSConfig) (*Client, error) {
+	httpClient, err := internalHTTP.NewTLSClient(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		auth:       auth,
+		httpClient: httpClient,
+		breaker:    internalHTTP.GetCircuitBreaker(baseURL),
+	}, nil
+}
+
+// BaseURL returns the url of the pinot controller.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// IsUnreachable returns true while the circuit breaker of the controller is open.
+func (c *Client) IsUnreachable() bool { return c.breaker.IsOpen() }
+
+// do sends a request and returns the response body. Responses other than 200
+// are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (string, error) {
+	if body == nil {
+		body = []byte{}
+	}
+
+	httpClient := &internalHTTP.Client{
+		Method:     method,
+		URL:        c.baseURL + path,
+		HTTPClient: c.httpClient,
+		Body:       body,
+		Auth:       c.auth,
+		Retry:      &internalHTTP.DefaultRetryPolicy,
+		Breaker:    c.breaker,
+	}
+
+	resp, err := httpClient.Do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newError(method, path, resp.StatusCode, resp.ResponseBody)
+	}
+
+	return resp.ResponseBody, nil
+}
+
+// doJSON sends a request and decodes the response body into out.
+func (c *Client) doJSON(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(resp), out)
+}
+
+// doStatus sends a mutating request and returns the status message pinot
+// replied with.
+func (c *Client) doStatus(ctx context.Context, method, path string, body []byte) (string, error) {
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return "", err
+	}
+	return statusMessage(resp), nil
+}
+
+// statusMessage returns the status field of a pinot response, or the raw body
+// when the response carries none.
+func statusMessage(body string) string {
+	status := struct {
+		Status string `json:"status"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &status); err != nil || status.Status == "" {
+		return body
+	}
+	return status.Status
+}
+
+func escape(segment string) string { return url.PathEscape(segment) }
@@ -0,0 +1,221 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+)
+
+type route struct {
+	status int
+	body   string
+}
+
+// newTestClient serves the given responses keyed by method and request uri.
+func newTestClient(t *testing.T, routes map[string]route) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r, ok := routes[req.Method+" "+req.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.RequestURI())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(r.status)
+		w.Write([]byte(r.body))
+	}))
+	t.Cleanup(server.Close)
+	c, err := NewClient(server.URL, internalHTTP.Auth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, map[string]route{
+		"GET /schemas/missing":     {http.StatusNotFound, `{"code":404,"error":"Schema missing not found"}`},
+		"GET /schemas/broken":      {http.StatusInternalServerError, `internal error`},
+		"GET /tables/missing":      {http.StatusOK, `{}`},
+		"GET /tenants/missing":     {http.StatusNotFound, `{"code":404,"error":"Tenant missing not found"}`},
+		"DELETE /instances/gone":   {http.StatusNotFound, `{"code":404,"error":"Instance gone not found"}`},
+		"DELETE /instances/online": {http.StatusConflict, `{"code":409,"error":"Instance online is live"}`},
+	})
+
+	_, err := c.GetSchema(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Message != "Schema missing not found" {
+		t.Errorf("expected decoded pinot error, got %v", err)
+	}
+
+	_, err = c.GetSchema(ctx, "broken")
+	if !IsAPIError(err) || IsNotFound(err) {
+		t.Errorf("expected api error, got %v", err)
+	}
+	if errors.As(err, &apiErr); apiErr.Message != "internal error" {
+		t.Errorf("expected raw body as message, got %q", apiErr.Message)
+	}
+
+	if _, err := c.GetTable(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("expected empty table response as not found, got %v", err)
+	}
+	if _, err := c.GetTenant(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if err := c.DropInstance(ctx, "gone"); err != nil {
+		t.Errorf("expected dropping an unknown instance to succeed, got %v", err)
+	}
+	if err := c.DropInstance(ctx, "online"); !IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+}
+
+func TestSchemaAndTableRequests(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, map[string]route{
+		"POST /schemas":                        {http.StatusOK, `{"unrecognizedProperties":{},"status":"airlineStats successfully added"}`},
+		"POST /schemas/validate":               {http.StatusBadRequest, `{"code":400,"error":"Invalid schema: airlineStats"}`},
+		"PUT /schemas/airlineStats?force=true": {http.StatusOK, `{"unrecognizedProperties":{},"status":"airlineStats successfully added"}`},
+		"GET /tables/airlineStats":             {http.StatusOK, `{"OFFLINE":{"tableName":"airlineStats_OFFLINE","segmentsConfig":{"replication":"2"},"tenants":{"server":"tenantA"}}}`},
+		"DELETE /tables/airlineStats":          {http.StatusOK, `{"status":"Tables: [airlineStats_OFFLINE] deleted"}`},
+		"POST /segments/airlineStats/reload":   {http.StatusOK, `not json`},
+		"GET /tables":                          {http.StatusOK, `{"tables":["airlineStats"]}`},
+	})
+
+	status, err := c.CreateSchema(ctx, `{"schemaName":"airlineStats"}`)
+	if err != nil || status != "airlineStats successfully added" {
+		t.Errorf("unexpected create schema result %q, %v", status, err)
+	}
+
+	if _, err := c.ValidateSchema(ctx, `{"schemaName":"airlineStats"}`); !IsAPIError(err) {
+		t.Errorf("expected an invalid schema to be an api error, got %v", err)
+	}
+	if _, err := c.UpdateSchema(ctx, "airlineStats", `{"schemaName":"airlineStats"}`, true); err != nil {
+		t.Errorf("unexpected forced update schema error %v", err)
+	}
+
+	configs, err := c.GetTableConfigs(ctx, "airlineStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configs[TableTypeOffline]
+	if config.Replicas(TableTypeOffline) != 2 || config.ServerTag(TableTypeOffline) != "tenantA_OFFLINE" {
+		t.Errorf("unexpected table config %+v", config)
+	}
+
+	if status, err := c.DeleteTable(ctx, "airlineStats", ""); err != nil || status != "Tables: [airlineStats_OFFLINE] deleted" {
+		t.Errorf("unexpected delete table result %q, %v", status, err)
+	}
+	if status, err := c.ReloadTable(ctx, "airlineStats"); err != nil || status != "not json" {
+		t.Errorf("expected raw body without status, got %q, %v", status, err)
+	}
+	if tables, err := c.ListTables(ctx); err != nil || len(tables) != 1 {
+		t.Errorf("unexpected tables %v, %v", tables, err)
+	}
+}
+
+func TestRebalanceTable(t *testing.T) {
+	ctx := context.Background()
+	opts := RebalanceOptions{ReassignInstances: true, IncludeConsuming: true, MinAvailableReplicas: 1}
+	path := makeRebalanceTablePath("airlineStats", TableTypeOffline, opts)
+
+	c := newTestClient(t, map[string]route{
+		"POST " + path: {http.StatusOK, `{"status":"FAILED","description":"no servers"}`},
+	})
+
+	result, err := c.RebalanceTable(ctx, "airlineStats", TableTypeOffline, opts)
+	if err == nil || result == nil || result.Status != RebalanceStatusFailed {
+		t.Errorf("expected failed rebalance, got %+v, %v", result, err)
+	}
+	if IsAPIError(err) {
+		t.Errorf("a failed rebalance is not an api error")
+	}
+
+	want := "/tables/airlineStats/rebalance?bootstrap=false&downtime=false&dryRun=false&includeConsuming=true&minAvailableReplicas=1&reassignInstances=true&type=OFFLINE"
+	if path != want {
+		t.Errorf("expected path %s, got %s", want, path)
+	}
+}
+
+func TestReloadJobs(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, map[string]route{
+		"POST /segments/airlineStats/reload":                        {http.StatusOK, `{"status":"{\"airlineStats_OFFLINE\":{\"reloadJobId\":\"job-1\",\"reloadJobMetaZKStorageStatus\":\"SUCCESS\",\"numMessagesSent\":\"2\"}}"}`},
+		"POST /segments/airlineStats_OFFLINE/airlineStats_0/reload": {http.StatusOK, `{"status":"{\"airlineStats_OFFLINE\":{\"reloadJobId\":\"job-2\"}}"}`},
+		"GET /segments/segmentReloadStatus/job-1":                   {http.StatusOK, `{"totalSegmentCount":4,"successCount":3,"totalServersQueried":2,"totalServerCallsFailed":0}`},
+	})
+
+	status, err := c.ReloadTable(ctx, "airlineStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := ReloadJobs(status); len(jobs) != 1 || jobs["airlineStats_OFFLINE"] != "job-1" {
+		t.Errorf("unexpected reload jobs %v", jobs)
+	}
+	status, err = c.ReloadSegment(ctx, "airlineStats_OFFLINE", "airlineStats_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := ReloadJobs(status); jobs["airlineStats_OFFLINE"] != "job-2" {
+		t.Errorf("unexpected reload jobs %v", jobs)
+	}
+	if jobs := ReloadJobs("Submitted reload job for table airlineStats"); len(jobs) != 0 {
+		t.Errorf("expected no reload jobs without json, got %v", jobs)
+	}
+
+	reload, err := c.GetReloadStatus(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reload.TotalSegmentCount != 4 || reload.SuccessCount != 3 {
+		t.Errorf("unexpected reload status %+v", reload)
+	}
+}
+
+func TestGetRebalanceStatus(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, map[string]route{
+		"GET /rebalanceStatus/job-1": {http.StatusOK, `{"tableRebalanceProgressStats":{"status":"IN_PROGRESS","completionStatusMsg":""},"timeElapsedSinceStartInSeconds":12}`},
+	})
+
+	status, err := c.GetRebalanceStatus(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Progress.Status != RebalanceStatusInProgress || status.TimeElapsedSinceStartInSeconds != 12 {
+		t.Errorf("unexpected rebalance status %+v", status)
+	}
+}
+
+func TestReplicaCount(t *testing.T) {
+	for in, want := range map[string]ReplicaCount{`"3"`: 3, `2`: 2, `""`: 0, `null`: 0} {
+		var c ReplicaCount
+		if err := json.Unmarshal([]byte(in), &c); err != nil || c != want {
+			t.Errorf("%s: expected %d, got %d, %v", in, want, c, err)
+		}
+	}
+}
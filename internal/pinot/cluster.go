@@ -0,0 +1,60 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+func makeHealthPath() string { return "/health" }
+
+func makeClusterConfigsPath() string { return "/cluster/configs" }
+
+func makeClusterConfigPath(name string) string { return "/cluster/configs/" + escape(name) }
+
+// Health returns nil when the controller reports itself healthy.
+func (c *Client) Health(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodGet, makeHealthPath(), nil)
+	return err
+}
+
+// GetClusterConfigs returns the cluster level configs.
+func (c *Client) GetClusterConfigs(ctx context.Context) (map[string]string, error) {
+	configs := map[string]string{}
+	if err := c.doJSON(ctx, http.MethodGet, makeClusterConfigsPath(), nil, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// UpdateClusterConfigs sets the given cluster level configs and returns the
+// status message of pinot.
+func (c *Client) UpdateClusterConfigs(ctx context.Context, configs map[string]string) (string, error) {
+	body, err := json.Marshal(configs)
+	if err != nil {
+		return "", err
+	}
+	return c.doStatus(ctx, http.MethodPost, makeClusterConfigsPath(), body)
+}
+
+// DeleteClusterConfig removes a cluster level config and returns the status
+// message of pinot.
+func (c *Client) DeleteClusterConfig(ctx context.Context, name string) (string, error) {
+	return c.doStatus(ctx, http.MethodDelete, makeClusterConfigPath(name), nil)
+}
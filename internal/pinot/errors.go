@@ -0,0 +1,81 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is returned when the pinot controller answers with a status other
+// than 200.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the error reported by pinot, or the raw response body
+	// when it is not a pinot error payload.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s [%s] returned status [%d]: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// newError decodes pinot error payloads of the form {"code":404,"error":"..."}.
+func newError(method, path string, statusCode int, body string) *Error {
+	payload := struct {
+		Code  int    `json:"code"`
+		Error string `json:"error"`
+	}{}
+	message := body
+	if err := json.Unmarshal([]byte(body), &payload); err == nil && payload.Error != "" {
+		message = payload.Error
+	}
+
+	return &Error{
+		Method:     method,
+		Path:       path,
+		StatusCode: statusCode,
+		Message:    message,
+	}
+}
+
+// IsAPIError returns true when err is an error response of the pinot controller,
+// as opposed to a transport error.
+func IsAPIError(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
+
+// IsNotFound returns true when the requested resource does not exist on pinot.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict returns true when pinot refused the request because of the
+// current state of the resource.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == statusCode
+}
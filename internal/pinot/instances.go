@@ -0,0 +1,114 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// instance states accepted by SetInstanceState
+const (
+	InstanceStateEnable  = "ENABLE"
+	InstanceStateDisable = "DISABLE"
+)
+
+const (
+	ServerInstancePrefix = "Server_"
+)
+
+// Instance is the helix config of an instance.
+type Instance struct {
+	InstanceName string   `json:"instanceName"`
+	HostName     string   `json:"hostName"`
+	Port         string   `json:"port"`
+	Enabled      bool     `json:"enabled"`
+	Tags         []string `json:"tags"`
+}
+
+func makeInstancesPath() string { return "/instances" }
+
+func makeInstancePath(instance string) string { return "/instances/" + escape(instance) }
+
+func makeInstanceStatePath(instance, state string) string {
+	return makeInstancePath(instance) + "/state?state=" + url.QueryEscape(state)
+}
+
+func makeUpdateInstanceTagsPath(instance string, tags []string) string {
+	return makeInstancePath(instance) + "/updateTags?tags=" + url.QueryEscape(strings.Join(tags, ",")) + "&updateBrokerResource=false"
+}
+
+// ListInstances returns the names of all instances known to helix.
+func (c *Client) ListInstances(ctx context.Context) ([]string, error) {
+	instances := struct {
+		Instances []string `json:"instances"`
+	}{}
+	if err := c.doJSON(ctx, http.MethodGet, makeInstancesPath(), nil, &instances); err != nil {
+		return nil, err
+	}
+	return instances.Instances, nil
+}
+
+// ListServerInstances returns the names of all server instances known to helix.
+func (c *Client) ListServerInstances(ctx context.Context) ([]string, error) {
+	instances, err := c.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := []string{}
+	for _, instance := range instances {
+		if strings.HasPrefix(instance, ServerInstancePrefix) {
+			servers = append(servers, instance)
+		}
+	}
+	return servers, nil
+}
+
+// GetInstance returns the helix config of an instance.
+func (c *Client) GetInstance(ctx context.Context, instance string) (*Instance, error) {
+	config := &Instance{}
+	if err := c.doJSON(ctx, http.MethodGet, makeInstancePath(instance), nil, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateInstanceTags replaces the tags of an instance.
+func (c *Client) UpdateInstanceTags(ctx context.Context, instance string, tags []string) error {
+	_, err := c.do(ctx, http.MethodPut, makeUpdateInstanceTagsPath(instance, tags), nil)
+	return err
+}
+
+// SetInstanceState enables or disables an instance.
+func (c *Client) SetInstanceState(ctx context.Context, instance, state string) error {
+	_, err := c.do(ctx, http.MethodPut, makeInstanceStatePath(instance, state), nil)
+	return err
+}
+
+// DropInstance removes an instance from helix. Pinot refuses to drop live
+// instances, IsConflict is true for the error until the instance is gone.
+// Dropping an unknown instance is not an error.
+func (c *Client) DropInstance(ctx context.Context, instance string) error {
+	_, err := c.do(ctx, http.MethodDelete, makeInstancePath(instance), nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,782 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pinottest is an in-process fake of the pinot controller REST api for
+// tests. It keeps schemas, tables and tenants in memory and reproduces the
+// quirks of pinot the control plane depends on:
+//
+//   - GET /tables/{name} answers 200 with {} for a missing table
+//   - tables are stored per type under the name suffixed with _OFFLINE or
+//     _REALTIME, and creating a table requires its schema
+//   - tables are updated by their raw or type qualified name
+//   - table creation replies with the "succesfully added" typo of pinot
+//   - errors are {"code":...,"error":...} payloads
+package pinottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+}
+
+// Rebalance is a rebalance request received by the server.
+type Rebalance struct {
+	TableName string
+	TableType string
+	DryRun    bool
+	Downtime  bool
+	Bootstrap bool
+}
+
+type failure struct {
+	method     string
+	pathPrefix string
+	statusCode int
+	message    string
+}
+
+// Server is a fake pinot controller, the zero value is not usable, use
+// NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	schemas map[string]string
+	tables  map[string]map[string]string
+	tenants map[string]string
+	reloads map[string]int
+	// segments are the segment names of a table keyed by table type
+	segments map[string]map[string][]string
+	// reloadsInProgress keeps new reload jobs running until FinishReload
+	reloadsInProgress bool
+	reloadJobs        map[string]*reloadJob
+	rebalances        []Rebalance
+	// rebalanceStatus is the status new rebalance jobs start in
+	rebalanceStatus string
+	rebalanceJobs   map[string]string
+	requests        []Request
+	failures        []failure
+}
+
+// NewServer starts a fake pinot controller, it is closed with Close.
+func NewServer() *Server {
+	s := &Server{
+		schemas: map[string]string{},
+		tables:  map[string]map[string]string{},
+		tenants: map[string]string{},
+		reloads: map[string]int{},
+
+		segments:        map[string]map[string][]string{},
+		reloadJobs:      map[string]*reloadJob{},
+		rebalanceStatus: "NO_OP",
+		rebalanceJobs:   map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Fail makes requests of method whose path starts with pathPrefix fail with
+// statusCode until ClearFailures is called.
+func (s *Server) Fail(method, pathPrefix string, statusCode int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method, pathPrefix, statusCode, message})
+}
+
+// ClearFailures removes the failures added with Fail.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// AddSchema stores a schema as if it had been created through the api.
+func (s *Server) AddSchema(schemaJson string) error {
+	name, err := stringField([]byte(schemaJson), "schemaName")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[name] = schemaJson
+	return nil
+}
+
+// AddTable stores a table config as if it had been created or updated
+// through the api, without checking its schema.
+func (s *Server) AddTable(tableJson string) error {
+	name, tableType, config, err := parseTable([]byte(tableJson))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[name] == nil {
+		s.tables[name] = map[string]string{}
+	}
+	s.tables[name][tableType] = config
+	return nil
+}
+
+// AddSegments adds segments to the table type, OFFLINE or REALTIME, of a
+// table.
+func (s *Server) AddSegments(tableName, tableType string, segmentNames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.segments[tableName] == nil {
+		s.segments[tableName] = map[string][]string{}
+	}
+	tableType = strings.ToUpper(tableType)
+	s.segments[tableName][tableType] = append(s.segments[tableName][tableType], segmentNames...)
+}
+
+// AddTenant stores a tenant as if it had been created or updated through the
+// api.
+func (s *Server) AddTenant(tenantJson string) error {
+	name, err := stringField([]byte(tenantJson), "tenantName")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[name] = tenantJson
+	return nil
+}
+
+// Schema returns the schema json stored under schemaName.
+func (s *Server) Schema(schemaName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, ok := s.schemas[schemaName]
+	return schema, ok
+}
+
+// Table returns the table config of tableType, OFFLINE or REALTIME, of the
+// raw table name.
+func (s *Server) Table(tableName, tableType string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config, ok := s.tables[tableName][strings.ToUpper(tableType)]
+	return config, ok
+}
+
+// Tenant returns the tenant json stored under tenantName.
+func (s *Server) Tenant(tenantName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tenant, ok := s.tenants[tenantName]
+	return tenant, ok
+}
+
+// Reloads returns the number of segment reloads of a table.
+func (s *Server) Reloads(tableName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reloads[tableName]
+}
+
+// SetReloadsInProgress keeps the reload jobs started from now on in progress
+// until FinishReload, they finish at once by default.
+func (s *Server) SetReloadsInProgress(inProgress bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadsInProgress = inProgress
+}
+
+// FinishReload sets the number of segments a reload job reloaded, all of its
+// servers answer.
+func (s *Server) FinishReload(jobID string, successCount int) {
+	s.SetReloadStatus(jobID, -1, successCount, 0)
+}
+
+// SetReloadStatus sets the progress a reload job reports, a negative
+// totalSegmentCount keeps the segments of the job.
+func (s *Server) SetReloadStatus(jobID string, totalSegmentCount, successCount, serverCallsFailed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.reloadJobs[jobID]; ok {
+		if totalSegmentCount >= 0 {
+			job.totalSegmentCount = totalSegmentCount
+		}
+		job.successCount = successCount
+		job.serverCallsFailed = serverCallsFailed
+	}
+}
+
+// Rebalances returns the rebalance requests received.
+func (s *Server) Rebalances() []Rebalance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Rebalance{}, s.rebalances...)
+}
+
+// SetRebalanceStatus sets the status rebalances started from now on reply
+// with, NO_OP by default. IN_PROGRESS jobs run until FinishRebalance.
+func (s *Server) SetRebalanceStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebalanceStatus = status
+}
+
+// FinishRebalance sets the status of a rebalance job.
+func (s *Server) FinishRebalance(jobID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebalanceJobs[jobID] = status
+}
+
+// Requests returns the requests received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery})
+
+	for _, f := range s.failures {
+		if f.method == req.Method && strings.HasPrefix(req.URL.Path, f.pathPrefix) {
+			writeError(w, f.statusCode, f.message)
+			return
+		}
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case segments[0] == "health" && len(segments) == 1:
+		w.Write([]byte("OK"))
+	case segments[0] == "schemas":
+		s.serveSchemas(w, req, segments[1:], body)
+	case segments[0] == "tables":
+		s.serveTables(w, req, segments[1:], body)
+	case segments[0] == "tenants":
+		s.serveTenants(w, req, segments[1:], body)
+	case segments[0] == "rebalanceStatus" && len(segments) == 2 && req.Method == http.MethodGet:
+		s.rebalanceJobStatus(w, segments[1])
+	case segments[0] == "segments" && len(segments) == 3 && segments[1] == "segmentReloadStatus" && req.Method == http.MethodGet:
+		s.reloadJobStatus(w, segments[2])
+	case segments[0] == "segments" && len(segments) == 2 && req.Method == http.MethodGet:
+		s.listSegments(w, segments[1])
+	case segments[0] == "segments" && len(segments) == 3 && segments[2] == "reload" && req.Method == http.MethodPost:
+		s.reloadTable(w, segments[1])
+	case segments[0] == "segments" && len(segments) == 4 && segments[3] == "reload" && req.Method == http.MethodPost:
+		s.reloadSegment(w, segments[1], segments[2])
+	default:
+		writeError(w, http.StatusNotFound, "HTTP 404 Not Found")
+	}
+}
+
+func (s *Server) serveSchemas(w http.ResponseWriter, req *http.Request, segments []string, body []byte) {
+	switch {
+	case len(segments) == 0 && req.Method == http.MethodGet:
+		names := []string{}
+		for name := range s.schemas {
+			names = append(names, name)
+		}
+		writeJSON(w, names)
+
+	case len(segments) == 0 && req.Method == http.MethodPost:
+		name, err := stringField(body, "schemaName")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.schemas[name] = string(body)
+		writeStatus(w, name+" successfully added")
+
+	case len(segments) == 1 && segments[0] == "validate" && req.Method == http.MethodPost:
+		if _, err := stringField(body, "schemaName"); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid schema: "+err.Error())
+			return
+		}
+		w.Write(body)
+
+	case len(segments) == 1 && req.Method == http.MethodGet:
+		schema, ok := s.schemas[segments[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Schema %s not found", segments[0]))
+			return
+		}
+		w.Write([]byte(schema))
+
+	case len(segments) == 1 && req.Method == http.MethodPut:
+		current, ok := s.schemas[segments[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Schema %s not found", segments[0]))
+			return
+		}
+		if req.URL.Query().Get("force") != "true" && !isBackwardCompatible(current, string(body)) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("New schema is not backward compatible with the existing schema %s", segments[0]))
+			return
+		}
+		s.schemas[segments[0]] = string(body)
+		writeStatus(w, segments[0]+" successfully added")
+
+	case len(segments) == 1 && req.Method == http.MethodDelete:
+		if _, ok := s.schemas[segments[0]]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Schema %s not found", segments[0]))
+			return
+		}
+		delete(s.schemas, segments[0])
+		writeStatus(w, fmt.Sprintf("Schema %s deleted", segments[0]))
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "HTTP 405 Method Not Allowed")
+	}
+}
+
+func (s *Server) serveTables(w http.ResponseWriter, req *http.Request, segments []string, body []byte) {
+	switch {
+	case len(segments) == 0 && req.Method == http.MethodGet:
+		names := []string{}
+		for name := range s.tables {
+			names = append(names, name)
+		}
+		writeJSON(w, map[string][]string{"tables": names})
+
+	case len(segments) == 0 && req.Method == http.MethodPost:
+		s.createTable(w, body)
+
+	case len(segments) == 1 && segments[0] == "validate" && req.Method == http.MethodPost:
+		s.validateTable(w, body)
+
+	case len(segments) == 1 && req.Method == http.MethodGet:
+		// pinot answers 200 with an empty object for a missing table
+		configs := map[string]json.RawMessage{}
+		for tableType, config := range s.tables[segments[0]] {
+			configs[tableType] = json.RawMessage(config)
+		}
+		writeJSON(w, configs)
+
+	case len(segments) == 1 && req.Method == http.MethodPut:
+		s.updateTable(w, segments[0], body)
+
+	case len(segments) == 1 && req.Method == http.MethodDelete:
+		s.deleteTable(w, segments[0], req.URL.Query().Get("type"))
+
+	case len(segments) == 2 && segments[1] == "rebalance" && req.Method == http.MethodPost:
+		s.rebalanceTable(w, req, segments[0])
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "HTTP 405 Method Not Allowed")
+	}
+}
+
+// parseTable returns the raw name and the type of a table config, and the
+// config with its name suffixed by the type as stored by pinot.
+func parseTable(body []byte) (string, string, string, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return "", "", "", err
+	}
+
+	name, _ := config["tableName"].(string)
+	tableType, _ := config["tableType"].(string)
+	tableType = strings.ToUpper(tableType)
+	if name == "" || (tableType != "OFFLINE" && tableType != "REALTIME") {
+		return "", "", "", fmt.Errorf("invalid table config, tableName and tableType OFFLINE or REALTIME are required")
+	}
+
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "_OFFLINE"), "_REALTIME")
+	config["tableName"] = name + "_" + tableType
+	stored, err := json.Marshal(config)
+	if err != nil {
+		return "", "", "", err
+	}
+	return name, tableType, string(stored), nil
+}
+
+// schemaName returns the schema of a table config, the raw table name when
+// the segments config does not name it.
+func schemaName(body []byte, tableName string) string {
+	config := struct {
+		SegmentsConfig struct {
+			SchemaName string `json:"schemaName"`
+		} `json:"segmentsConfig"`
+	}{}
+	if err := json.Unmarshal(body, &config); err == nil && config.SegmentsConfig.SchemaName != "" {
+		return config.SegmentsConfig.SchemaName
+	}
+	return tableName
+}
+
+func (s *Server) validateTable(w http.ResponseWriter, body []byte) {
+	name, tableType, config, err := parseTable(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := s.schemas[schemaName(body, name)]; !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid table config for table %s_%s: Schema %s does not exist", name, tableType, schemaName(body, name)))
+		return
+	}
+	w.Write([]byte(config))
+}
+
+func (s *Server) createTable(w http.ResponseWriter, body []byte) {
+	name, tableType, config, err := parseTable(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, ok := s.schemas[schemaName(body, name)]; !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid table config for table %s_%s: Schema %s does not exist", name, tableType, schemaName(body, name)))
+		return
+	}
+	if _, ok := s.tables[name][tableType]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Table %s_%s already exists", name, tableType))
+		return
+	}
+
+	if s.tables[name] == nil {
+		s.tables[name] = map[string]string{}
+	}
+	s.tables[name][tableType] = config
+	writeStatus(w, fmt.Sprintf("Table %s_%s succesfully added", name, tableType))
+}
+
+func (s *Server) updateTable(w http.ResponseWriter, tableName string, body []byte) {
+	name, tableType, config, err := parseTable(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name != tableName && name+"_"+tableType != tableName {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Request table %s does not match table name in the body %s", tableName, name))
+		return
+	}
+	if _, ok := s.tables[name][tableType]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s_%s does not exist", name, tableType))
+		return
+	}
+
+	s.tables[name][tableType] = config
+	writeStatus(w, "Table config updated for "+tableName)
+}
+
+func (s *Server) deleteTable(w http.ResponseWriter, tableName, tableType string) {
+	tableType = strings.ToUpper(tableType)
+	deleted := []string{}
+	for storedType := range s.tables[tableName] {
+		if tableType == "" || tableType == storedType {
+			delete(s.tables[tableName], storedType)
+			deleted = append(deleted, tableName+"_"+storedType)
+		}
+	}
+	if len(s.tables[tableName]) == 0 {
+		delete(s.tables, tableName)
+	}
+
+	if len(deleted) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s does not exist", tableName))
+		return
+	}
+	writeStatus(w, fmt.Sprintf("Tables: %v deleted", deleted))
+}
+
+func (s *Server) rebalanceTable(w http.ResponseWriter, req *http.Request, tableName string) {
+	tableType := strings.ToUpper(req.URL.Query().Get("type"))
+	if _, ok := s.tables[tableName][tableType]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s_%s does not exist", tableName, tableType))
+		return
+	}
+
+	s.rebalances = append(s.rebalances, Rebalance{
+		TableName: tableName,
+		TableType: tableType,
+		DryRun:    req.URL.Query().Get("dryRun") == "true",
+		Downtime:  req.URL.Query().Get("downtime") == "true",
+		Bootstrap: req.URL.Query().Get("bootstrap") == "true",
+	})
+	jobID := fmt.Sprintf("rebalance-%d", len(s.rebalances))
+	s.rebalanceJobs[jobID] = s.rebalanceStatus
+	writeJSON(w, map[string]string{
+		"jobId":       jobID,
+		"status":      s.rebalanceStatus,
+		"description": rebalanceDescription(s.rebalanceStatus),
+	})
+}
+
+func (s *Server) rebalanceJobStatus(w http.ResponseWriter, jobID string) {
+	status, ok := s.rebalanceJobs[jobID]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Failed to resolve rebalance job %s", jobID))
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"tableRebalanceProgressStats": map[string]string{
+			"status":              status,
+			"completionStatusMsg": rebalanceDescription(status),
+		},
+		"timeElapsedSinceStartInSeconds": 1,
+	})
+}
+
+func rebalanceDescription(status string) string {
+	switch status {
+	case "NO_OP":
+		return "Table is already balanced"
+	case "IN_PROGRESS":
+		return "In progress, check controller logs for updates"
+	case "DONE":
+		return "Success with minAvailableReplicas"
+	default:
+		return "Caught exception while rebalancing table"
+	}
+}
+
+func (s *Server) listSegments(w http.ResponseWriter, tableName string) {
+	if len(s.tables[tableName]) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s does not exist", tableName))
+		return
+	}
+
+	resp := []map[string][]string{}
+	for tableType := range s.tables[tableName] {
+		resp = append(resp, map[string][]string{tableType: append([]string{}, s.segments[tableName][tableType]...)})
+	}
+	writeJSON(w, resp)
+}
+
+// reloadJob is a segment reload job, a table reload reloads the segments of
+// every table type, reloadSegmentCount for a table type without segments.
+type reloadJob struct {
+	totalSegmentCount int
+	successCount      int
+	serverCallsFailed int
+}
+
+const reloadSegmentCount = 2
+
+func (s *Server) reloadTable(w http.ResponseWriter, tableName string) {
+	if len(s.tables[tableName]) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s does not exist", tableName))
+		return
+	}
+
+	s.reloads[tableName]++
+	submitted := map[string]interface{}{}
+	for tableType := range s.tables[tableName] {
+		count := len(s.segments[tableName][tableType])
+		if count == 0 {
+			count = reloadSegmentCount
+		}
+		submitted[tableName+"_"+tableType] = s.startReloadJob(count)
+	}
+	writeSubmittedReloads(w, submitted)
+}
+
+func (s *Server) reloadSegment(w http.ResponseWriter, tableNameWithType, segmentName string) {
+	name, tableType := tableNameWithType, ""
+	if i := strings.LastIndex(tableNameWithType, "_"); i > 0 {
+		name, tableType = tableNameWithType[:i], tableNameWithType[i+1:]
+	}
+	if _, ok := s.tables[name][tableType]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Table %s does not exist", tableNameWithType))
+		return
+	}
+
+	writeSubmittedReloads(w, map[string]interface{}{tableNameWithType: s.startReloadJob(1)})
+}
+
+func (s *Server) startReloadJob(totalSegmentCount int) map[string]string {
+	job := &reloadJob{totalSegmentCount: totalSegmentCount, successCount: totalSegmentCount}
+	if s.reloadsInProgress {
+		job.successCount = 0
+	}
+	jobID := fmt.Sprintf("reload-%d", len(s.reloadJobs)+1)
+	s.reloadJobs[jobID] = job
+	return map[string]string{
+		"reloadJobId":                  jobID,
+		"reloadJobMetaZKStorageStatus": "SUCCESS",
+		"numMessagesSent":              "1",
+	}
+}
+
+// writeSubmittedReloads writes the reload jobs as json in the status message,
+// as pinot does.
+func writeSubmittedReloads(w http.ResponseWriter, submitted map[string]interface{}) {
+	status, err := json.Marshal(submitted)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeStatus(w, string(status))
+}
+
+func (s *Server) reloadJobStatus(w http.ResponseWriter, jobID string) {
+	job, ok := s.reloadJobs[jobID]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Failed to find controller job id: %s", jobID))
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"totalSegmentCount":      job.totalSegmentCount,
+		"successCount":           job.successCount,
+		"totalServersQueried":    1 + job.serverCallsFailed,
+		"totalServerCallsFailed": job.serverCallsFailed,
+	})
+}
+
+func (s *Server) serveTenants(w http.ResponseWriter, req *http.Request, segments []string, body []byte) {
+	switch {
+	case len(segments) == 0 && (req.Method == http.MethodPost || req.Method == http.MethodPut):
+		name, err := stringField(body, "tenantName")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, exists := s.tenants[name]
+		if req.Method == http.MethodPost && exists {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Tenant %s already exists", name))
+			return
+		}
+		if req.Method == http.MethodPut && !exists {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Tenant %s not found", name))
+			return
+		}
+		s.tenants[name] = string(body)
+		if req.Method == http.MethodPost {
+			writeStatus(w, "Successfully created tenant")
+		} else {
+			writeStatus(w, "Updated tenant")
+		}
+
+	case len(segments) == 1 && req.Method == http.MethodGet:
+		tenant, ok := s.tenants[segments[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Tenant %s not found", segments[0]))
+			return
+		}
+		servers, brokers := tenantInstances(tenant)
+		writeJSON(w, map[string]interface{}{
+			"ServerInstances": servers,
+			"BrokerInstances": brokers,
+			"tenantName":      segments[0],
+		})
+
+	case len(segments) == 1 && req.Method == http.MethodDelete:
+		if _, ok := s.tenants[segments[0]]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Tenant %s not found", segments[0]))
+			return
+		}
+		delete(s.tenants, segments[0])
+		writeStatus(w, "Successfully deleted tenant "+segments[0])
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "HTTP 405 Method Not Allowed")
+	}
+}
+
+// isBackwardCompatible is true when every column of the current schema is kept
+// with the same data type, the check pinot runs before updating a schema.
+func isBackwardCompatible(currentSchema, newSchema string) bool {
+	columns := func(schema string) map[string]string {
+		fieldSpecs := map[string][]struct {
+			Name     string `json:"name"`
+			DataType string `json:"dataType"`
+		}{}
+		json.Unmarshal([]byte(schema), &fieldSpecs)
+
+		dataTypes := map[string]string{}
+		for _, specs := range fieldSpecs {
+			for _, spec := range specs {
+				dataTypes[spec.Name] = spec.DataType
+			}
+		}
+		return dataTypes
+	}
+
+	newColumns := columns(newSchema)
+	for name, dataType := range columns(currentSchema) {
+		if newDataType, ok := newColumns[name]; !ok || newDataType != dataType {
+			return false
+		}
+	}
+	return true
+}
+
+// tenantInstances returns the server and broker instances tagged with a
+// tenant, one per instance of the tenant role.
+func tenantInstances(tenantJson string) ([]string, []string) {
+	tenant := struct {
+		TenantRole        string `json:"tenantRole"`
+		NumberOfInstances int    `json:"numberOfInstances"`
+	}{}
+	json.Unmarshal([]byte(tenantJson), &tenant)
+
+	servers, brokers := []string{}, []string{}
+	for i := 0; i < tenant.NumberOfInstances; i++ {
+		if strings.EqualFold(tenant.TenantRole, "BROKER") {
+			brokers = append(brokers, fmt.Sprintf("Broker_pinot-broker-%d_8099", i))
+		} else {
+			servers = append(servers, fmt.Sprintf("Server_pinot-server-%d_8098", i))
+		}
+	}
+	return servers, brokers
+}
+
+func stringField(body []byte, field string) (string, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", err
+	}
+	value, _ := fields[field].(string)
+	if value == "" {
+		return "", fmt.Errorf("%s is required", field)
+	}
+	return value, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeStatus(w http.ResponseWriter, status string) {
+	writeJSON(w, map[string]string{"status": status})
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": statusCode, "error": message})
+}
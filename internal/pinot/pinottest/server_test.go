@@ -0,0 +1,208 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinottest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot/pinottest"
+)
+
+const (
+	testSchemaJson = `{"schemaName": "airlineStats"}`
+	testTableJson  = `{"tableName": "airlineStats", "tableType": "OFFLINE", "segmentsConfig": {"replication": "1"}}`
+)
+
+func newTestClient(t *testing.T) (*pinottest.Server, *pinot.Client) {
+	server := pinottest.NewServer()
+	t.Cleanup(server.Close)
+
+	c, err := pinot.NewClient(server.URL, internalHTTP.Auth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, c
+}
+
+func TestTables(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestClient(t)
+
+	if _, err := c.GetTable(ctx, "airlineStats"); !pinot.IsNotFound(err) {
+		t.Fatalf("expected a missing table to be not found, got %v", err)
+	}
+	if _, err := c.CreateTable(ctx, testTableJson); !pinot.IsAPIError(err) {
+		t.Fatalf("expected table creation without schema to fail, got %v", err)
+	}
+	if _, err := c.ValidateTable(ctx, testTableJson); !pinot.IsAPIError(err) {
+		t.Fatalf("expected table validation without schema to fail, got %v", err)
+	}
+
+	if _, err := c.CreateSchema(ctx, testSchemaJson); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ValidateTable(ctx, testTableJson); err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.CreateTable(ctx, testTableJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "Table airlineStats_OFFLINE succesfully added" {
+		t.Errorf("unexpected status [%s]", status)
+	}
+	if _, err := c.CreateTable(ctx, testTableJson); !pinot.IsConflict(err) {
+		t.Errorf("expected an existing table to conflict, got %v", err)
+	}
+
+	configs, err := c.GetTableConfigs(ctx, "airlineStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs[pinot.TableTypeOffline].TableName != "airlineStats_OFFLINE" {
+		t.Errorf("expected the table name to be suffixed by its type, got %v", configs)
+	}
+
+	if _, err := c.UpdateTable(ctx, "airlineStats_OFFLINE", testTableJson); err != nil {
+		t.Errorf("expected an update by the type qualified name, got %v", err)
+	}
+	if _, err := c.UpdateTable(ctx, "airlineStats_REALTIME", testTableJson); !pinot.IsAPIError(err) {
+		t.Errorf("expected an update by the name of another type to fail, got %v", err)
+	}
+
+	server.AddSegments("airlineStats", pinot.TableTypeOffline, "airlineStats_0", "airlineStats_1", "airlineStats_2")
+	if segments, err := c.ListSegments(ctx, "airlineStats"); err != nil || len(segments[pinot.TableTypeOffline]) != 3 {
+		t.Errorf("expected three segments, got %v %v", segments, err)
+	}
+
+	status, err = c.ReloadTable(ctx, "airlineStats")
+	if err != nil || server.Reloads("airlineStats") != 1 {
+		t.Errorf("expected one reload, got %d %v", server.Reloads("airlineStats"), err)
+	}
+	jobs := pinot.ReloadJobs(status)
+	if reload, err := c.GetReloadStatus(ctx, jobs["airlineStats_OFFLINE"]); err != nil || reload.TotalSegmentCount != 3 || reload.SuccessCount != 3 {
+		t.Errorf("expected a finished reload job, got %+v %v", reload, err)
+	}
+
+	server.SetReloadsInProgress(true)
+	status, err = c.ReloadSegment(ctx, "airlineStats_OFFLINE", "airlineStats_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobID := pinot.ReloadJobs(status)["airlineStats_OFFLINE"]
+	if reload, err := c.GetReloadStatus(ctx, jobID); err != nil || reload.TotalSegmentCount != 1 || reload.SuccessCount != 0 {
+		t.Errorf("expected a reload job in progress, got %+v %v", reload, err)
+	}
+	server.FinishReload(jobID, 1)
+	if reload, err := c.GetReloadStatus(ctx, jobID); err != nil || reload.SuccessCount != 1 {
+		t.Errorf("expected a finished reload job, got %+v %v", reload, err)
+	}
+	if _, err := c.GetReloadStatus(ctx, "unknown"); !pinot.IsNotFound(err) {
+		t.Errorf("expected an unknown reload job to be not found, got %v", err)
+	}
+	if _, err := c.RebalanceTable(ctx, "airlineStats", pinot.TableTypeOffline, pinot.RebalanceOptions{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if rebalances := server.Rebalances(); len(rebalances) != 1 || !rebalances[0].DryRun {
+		t.Errorf("expected one dry run rebalance, got %v", rebalances)
+	}
+
+	server.SetRebalanceStatus(pinot.RebalanceStatusInProgress)
+	result, err := c.RebalanceTable(ctx, "airlineStats", pinot.TableTypeOffline, pinot.RebalanceOptions{})
+	if err != nil || result.Status != pinot.RebalanceStatusInProgress {
+		t.Fatalf("expected an in progress rebalance, got %+v %v", result, err)
+	}
+	server.FinishRebalance(result.JobID, pinot.RebalanceStatusDone)
+	if status, err := c.GetRebalanceStatus(ctx, result.JobID); err != nil || status.Progress.Status != pinot.RebalanceStatusDone {
+		t.Errorf("expected the rebalance job to be done, got %+v %v", status, err)
+	}
+
+	if _, err := c.DeleteTable(ctx, "airlineStats", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Table("airlineStats", pinot.TableTypeOffline); ok {
+		t.Errorf("expected the table to be deleted")
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	ctx := context.Background()
+	_, c := newTestClient(t)
+
+	if _, err := c.CreateSchema(ctx, `{"schemaName": "airlineStats", "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ValidateSchema(ctx, `{"dimensionFieldSpecs": []}`); !pinot.IsAPIError(err) {
+		t.Errorf("expected a schema without name to be invalid, got %v", err)
+	}
+
+	retyped := `{"schemaName": "airlineStats", "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "INT"}]}`
+	if _, err := c.UpdateSchema(ctx, "airlineStats", retyped, false); !pinot.IsAPIError(err) {
+		t.Errorf("expected a backward incompatible update to be refused, got %v", err)
+	}
+	if _, err := c.UpdateSchema(ctx, "airlineStats", retyped, true); err != nil {
+		t.Errorf("expected a forced update to succeed, got %v", err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestClient(t)
+
+	if _, err := c.CreateTenant(ctx, `{"tenantName": "sales", "tenantRole": "BROKER", "numberOfInstances": 2}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddTenant(`{"tenantName": "sales", "tenantRole": "BROKER", "numberOfInstances": 1}`); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.GetTenant(ctx, "sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := struct {
+		ServerInstances []string
+		BrokerInstances []string
+	}{}
+	if err := json.Unmarshal([]byte(resp), &instances); err != nil {
+		t.Fatal(err)
+	}
+	if len(instances.BrokerInstances) != 1 || len(instances.ServerInstances) != 0 {
+		t.Errorf("expected one broker instance, got %s", resp)
+	}
+}
+
+func TestFail(t *testing.T) {
+	ctx := context.Background()
+	server, c := newTestClient(t)
+
+	server.Fail(http.MethodGet, "/schemas", http.StatusInternalServerError, "zookeeper unavailable")
+	_, err := c.GetSchema(ctx, "airlineStats")
+	if e, ok := err.(*pinot.Error); !ok || e.StatusCode != http.StatusInternalServerError || e.Message != "zookeeper unavailable" {
+		t.Errorf("expected the injected failure, got %v", err)
+	}
+
+	server.ClearFailures()
+	if _, err := c.GetSchema(ctx, "airlineStats"); !pinot.IsNotFound(err) {
+		t.Errorf("expected a missing schema to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"net/http"
+)
+
+func makeSchemasPath() string { return "/schemas" }
+
+func makeSchemaPath(schemaName string) string { return "/schemas/" + escape(schemaName) }
+
+func makeValidateSchemaPath() string { return "/schemas/validate" }
+
+// makeUpdateSchemaPath forces the update of a schema that is not backward
+// compatible with the current one when force is set.
+func makeUpdateSchemaPath(schemaName string, force bool) string {
+	if force {
+		return makeSchemaPath(schemaName) + "?force=true"
+	}
+	return makeSchemaPath(schemaName)
+}
+
+// ListSchemas returns the names of all schemas.
+func (c *Client) ListSchemas(ctx context.Context) ([]string, error) {
+	schemas := []string{}
+	if err := c.doJSON(ctx, http.MethodGet, makeSchemasPath(), nil, &schemas); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// GetSchema returns the schema json, IsNotFound is true for the error when the
+// schema does not exist.
+func (c *Client) GetSchema(ctx context.Context, schemaName string) (string, error) {
+	return c.do(ctx, http.MethodGet, makeSchemaPath(schemaName), nil)
+}
+
+// CreateSchema adds a schema and returns the status message of pinot.
+func (c *Client) CreateSchema(ctx context.Context, schemaJson string) (string, error) {
+	return c.doStatus(ctx, http.MethodPost, makeSchemasPath(), []byte(schemaJson))
+}
+
+// ValidateSchema validates a schema without adding it, pinot answers with an
+// api error for an invalid schema.
+func (c *Client) ValidateSchema(ctx context.Context, schemaJson string) (string, error) {
+	return c.do(ctx, http.MethodPost, makeValidateSchemaPath(), []byte(schemaJson))
+}
+
+// UpdateSchema replaces an existing schema and returns the status message of
+// pinot. Pinot refuses schemas that are not backward compatible with the
+// current one unless force is set.
+func (c *Client) UpdateSchema(ctx context.Context, schemaName, schemaJson string, force bool) (string, error) {
+	return c.doStatus(ctx, http.MethodPut, makeUpdateSchemaPath(schemaName, force), []byte(schemaJson))
+}
+
+// DeleteSchema deletes a schema and returns the status message of pinot.
+func (c *Client) DeleteSchema(ctx context.Context, schemaName string) (string, error) {
+	return c.doStatus(ctx, http.MethodDelete, makeSchemaPath(schemaName), nil)
+}
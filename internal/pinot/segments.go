@@ -0,0 +1,101 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// ReloadJobStatus is the progress of a segment reload job.
+type ReloadJobStatus struct {
+	TotalSegmentCount               int     `json:"totalSegmentCount"`
+	SuccessCount                    int     `json:"successCount"`
+	TotalServersQueried             int     `json:"totalServersQueried"`
+	TotalServerCallsFailed          int     `json:"totalServerCallsFailed"`
+	TimeElapsedInMinutes            float64 `json:"timeElapsedInMinutes"`
+	EstimatedTimeRemainingInMinutes float64 `json:"estimatedTimeRemainingInMinutes"`
+}
+
+func makeSegmentsPath(tableName string) string { return "/segments/" + escape(tableName) }
+
+func makeReloadTablePath(tableName string) string { return makeSegmentsPath(tableName) + "/reload" }
+
+func makeReloadSegmentPath(tableName, segmentName string) string {
+	return makeSegmentsPath(tableName) + "/" + escape(segmentName) + "/reload"
+}
+
+func makeReloadStatusPath(jobID string) string {
+	return "/segments/segmentReloadStatus/" + escape(jobID)
+}
+
+// ListSegments returns the segment names of a table keyed by table type.
+func (c *Client) ListSegments(ctx context.Context, tableName string) (map[string][]string, error) {
+	resp := []map[string][]string{}
+	if err := c.doJSON(ctx, http.MethodGet, makeSegmentsPath(tableName), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	segments := map[string][]string{}
+	for _, byType := range resp {
+		for tableType, names := range byType {
+			segments[tableType] = append(segments[tableType], names...)
+		}
+	}
+	return segments, nil
+}
+
+// ReloadTable reloads all segments of a table and returns the response of pinot.
+func (c *Client) ReloadTable(ctx context.Context, tableName string) (string, error) {
+	return c.doStatus(ctx, http.MethodPost, makeReloadTablePath(tableName), nil)
+}
+
+// ReloadSegment reloads a segment of a table, tableName has the table type,
+// and returns the response of pinot.
+func (c *Client) ReloadSegment(ctx context.Context, tableName, segmentName string) (string, error) {
+	return c.doStatus(ctx, http.MethodPost, makeReloadSegmentPath(tableName, segmentName), nil)
+}
+
+// GetReloadStatus returns the progress of a segment reload job.
+func (c *Client) GetReloadStatus(ctx context.Context, jobID string) (*ReloadJobStatus, error) {
+	status := &ReloadJobStatus{}
+	if err := c.doJSON(ctx, http.MethodGet, makeReloadStatusPath(jobID), nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ReloadJobs returns the job ids of a reload response keyed by the table name
+// with type, pinot reports them as json in the status message. Pinot versions
+// without reload jobs return none.
+func ReloadJobs(status string) map[string]string {
+	submitted := map[string]struct {
+		ReloadJobID string `json:"reloadJobId"`
+	}{}
+	if err := json.Unmarshal([]byte(status), &submitted); err != nil {
+		return nil
+	}
+
+	jobs := map[string]string{}
+	for tableName, job := range submitted {
+		if job.ReloadJobID != "" {
+			jobs[tableName] = job.ReloadJobID
+		}
+	}
+	return jobs
+}
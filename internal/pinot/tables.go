@@ -0,0 +1,270 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// table types
+const (
+	TableTypeOffline  = "OFFLINE"
+	TableTypeRealtime = "REALTIME"
+)
+
+// helix segment states as reported in the ideal state and external view
+const (
+	SegmentStateOnline    = "ONLINE"
+	SegmentStateConsuming = "CONSUMING"
+)
+
+const (
+	DefaultServerTenant = "DefaultTenant"
+)
+
+// rebalance statuses
+const (
+	RebalanceStatusDone       = "DONE"
+	RebalanceStatusNoOp       = "NO_OP"
+	RebalanceStatusInProgress = "IN_PROGRESS"
+	RebalanceStatusFailed     = "FAILED"
+)
+
+// TableView is the ideal state or external view of a table,
+// table type to segment to instance to segment state.
+type TableView map[string]map[string]map[string]string
+
+// TableConfig holds the fields of a pinot table config the operator acts on.
+type TableConfig struct {
+	TableName      string `json:"tableName"`
+	TableType      string `json:"tableType"`
+	SegmentsConfig struct {
+		SchemaName           string       `json:"schemaName"`
+		Replication          ReplicaCount `json:"replication"`
+		ReplicasPerPartition ReplicaCount `json:"replicasPerPartition"`
+	} `json:"segmentsConfig"`
+	Tenants struct {
+		Broker string `json:"broker"`
+		Server string `json:"server"`
+	} `json:"tenants"`
+}
+
+// ReplicaCount is a replication setting, pinot serializes them as strings.
+type ReplicaCount int
+
+func (c *ReplicaCount) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*c = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*c = ReplicaCount(n)
+	return nil
+}
+
+// Replicas returns the number of servers a table of tableType needs.
+func (t *TableConfig) Replicas(tableType string) int {
+	if tableType == TableTypeRealtime && t.SegmentsConfig.ReplicasPerPartition > 0 {
+		return int(t.SegmentsConfig.ReplicasPerPartition)
+	}
+	return int(t.SegmentsConfig.Replication)
+}
+
+// ServerTag returns the helix tag of the servers a table of tableType is assigned to.
+func (t *TableConfig) ServerTag(tableType string) string {
+	tenant := t.Tenants.Server
+	if tenant == "" {
+		tenant = DefaultServerTenant
+	}
+	return tenant + "_" + tableType
+}
+
+// RebalanceOptions are the query parameters of a table rebalance.
+type RebalanceOptions struct {
+	DryRun               bool
+	ReassignInstances    bool
+	IncludeConsuming     bool
+	Downtime             bool
+	MinAvailableReplicas int
+	Bootstrap            bool
+}
+
+// RebalanceResult is the response of a table rebalance.
+type RebalanceResult struct {
+	JobID       string `json:"jobId"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// RebalanceJobStatus is the progress of a rebalance job.
+type RebalanceJobStatus struct {
+	Progress struct {
+		Status              string `json:"status"`
+		CompletionStatusMsg string `json:"completionStatusMsg"`
+	} `json:"tableRebalanceProgressStats"`
+	TimeElapsedSinceStartInSeconds int64 `json:"timeElapsedSinceStartInSeconds"`
+}
+
+func makeTablesPath() string { return "/tables" }
+
+func makeTablePath(tableName string) string { return "/tables/" + escape(tableName) }
+
+func makeValidateTablePath() string { return "/tables/validate" }
+
+func makeTableViewPath(tableName, view string) string {
+	return makeTablePath(tableName) + "/" + view
+}
+
+func makeRebalanceTablePath(tableName, tableType string, opts RebalanceOptions) string {
+	query := url.Values{}
+	query.Set("type", tableType)
+	query.Set("dryRun", strconv.FormatBool(opts.DryRun))
+	query.Set("reassignInstances", strconv.FormatBool(opts.ReassignInstances))
+	query.Set("includeConsuming", strconv.FormatBool(opts.IncludeConsuming))
+	query.Set("downtime", strconv.FormatBool(opts.Downtime))
+	query.Set("minAvailableReplicas", strconv.Itoa(opts.MinAvailableReplicas))
+	query.Set("bootstrap", strconv.FormatBool(opts.Bootstrap))
+	return makeTablePath(tableName) + "/rebalance?" + query.Encode()
+}
+
+func makeRebalanceStatusPath(jobID string) string { return "/rebalanceStatus/" + escape(jobID) }
+
+// ListTables returns the names of all tables.
+func (c *Client) ListTables(ctx context.Context) ([]string, error) {
+	tables := struct {
+		Tables []string `json:"tables"`
+	}{}
+	if err := c.doJSON(ctx, http.MethodGet, makeTablesPath(), nil, &tables); err != nil {
+		return nil, err
+	}
+	return tables.Tables, nil
+}
+
+// GetTable returns the table configs of a table keyed by table type as json.
+// Pinot answers 200 with an empty object for unknown tables, it is returned
+// as a not found error.
+func (c *Client) GetTable(ctx context.Context, tableName string) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, makeTablePath(tableName), nil)
+	if err != nil {
+		return "", err
+	}
+
+	configs := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(resp), &configs); err == nil && len(configs) == 0 {
+		return "", &Error{
+			Method:     http.MethodGet,
+			Path:       makeTablePath(tableName),
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("table [%s] not found", tableName),
+		}
+	}
+
+	return resp, nil
+}
+
+// GetTableConfigs returns the table configs of a table by table type.
+func (c *Client) GetTableConfigs(ctx context.Context, tableName string) (map[string]TableConfig, error) {
+	resp, err := c.GetTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string]TableConfig{}
+	if err := json.Unmarshal([]byte(resp), &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// CreateTable adds a table and returns the status message of pinot.
+func (c *Client) CreateTable(ctx context.Context, tableJson string) (string, error) {
+	return c.doStatus(ctx, http.MethodPost, makeTablesPath(), []byte(tableJson))
+}
+
+// ValidateTable validates a table config without adding it, pinot answers with
+// an api error for an invalid config or a missing schema.
+func (c *Client) ValidateTable(ctx context.Context, tableJson string) (string, error) {
+	return c.do(ctx, http.MethodPost, makeValidateTablePath(), []byte(tableJson))
+}
+
+// UpdateTable replaces the config of an existing table and returns the status
+// message of pinot.
+func (c *Client) UpdateTable(ctx context.Context, tableName, tableJson string) (string, error) {
+	return c.doStatus(ctx, http.MethodPut, makeTablePath(tableName), []byte(tableJson))
+}
+
+// DeleteTable deletes a table of tableType, or all its types when tableType
+// is empty, and returns the status message of pinot.
+func (c *Client) DeleteTable(ctx context.Context, tableName, tableType string) (string, error) {
+	path := makeTablePath(tableName)
+	if tableType != "" {
+		path += "?type=" + tableType
+	}
+	return c.doStatus(ctx, http.MethodDelete, path, nil)
+}
+
+// GetIdealState returns the ideal state of a table.
+func (c *Client) GetIdealState(ctx context.Context, tableName string) (TableView, error) {
+	return c.getTableView(ctx, tableName, "idealstate")
+}
+
+// GetExternalView returns the external view of a table.
+func (c *Client) GetExternalView(ctx context.Context, tableName string) (TableView, error) {
+	return c.getTableView(ctx, tableName, "externalview")
+}
+
+func (c *Client) getTableView(ctx context.Context, tableName, view string) (TableView, error) {
+	tv := TableView{}
+	if err := c.doJSON(ctx, http.MethodGet, makeTableViewPath(tableName, view), nil, &tv); err != nil {
+		return nil, err
+	}
+	return tv, nil
+}
+
+// RebalanceTable triggers a rebalance of a table of tableType. A FAILED
+// rebalance is returned as an error along with its result.
+func (c *Client) RebalanceTable(ctx context.Context, tableName, tableType string, opts RebalanceOptions) (*RebalanceResult, error) {
+	result := &RebalanceResult{}
+	if err := c.doJSON(ctx, http.MethodPost, makeRebalanceTablePath(tableName, tableType, opts), nil, result); err != nil {
+		return nil, err
+	}
+
+	if result.Status == RebalanceStatusFailed {
+		return result, fmt.Errorf("rebalance of table [%s] type [%s] failed [%s]", tableName, tableType, result.Description)
+	}
+
+	return result, nil
+}
+
+// GetRebalanceStatus returns the progress of a rebalance job.
+func (c *Client) GetRebalanceStatus(ctx context.Context, jobID string) (*RebalanceJobStatus, error) {
+	status := &RebalanceJobStatus{}
+	if err := c.doJSON(ctx, http.MethodGet, makeRebalanceStatusPath(jobID), nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
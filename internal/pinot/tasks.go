@@ -0,0 +1,69 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func makeTaskTypesPath() string { return "/tasks/tasktypes" }
+
+func makeTaskStatesPath(taskType string) string {
+	return "/tasks/" + escape(taskType) + "/taskstates"
+}
+
+func makeScheduleTasksPath(taskType, tableName string) string {
+	query := url.Values{}
+	if taskType != "" {
+		query.Set("taskType", taskType)
+	}
+	if tableName != "" {
+		query.Set("tableName", tableName)
+	}
+	return "/tasks/schedule?" + query.Encode()
+}
+
+// ListTaskTypes returns the minion task types known to the cluster.
+func (c *Client) ListTaskTypes(ctx context.Context) ([]string, error) {
+	taskTypes := []string{}
+	if err := c.doJSON(ctx, http.MethodGet, makeTaskTypesPath(), nil, &taskTypes); err != nil {
+		return nil, err
+	}
+	return taskTypes, nil
+}
+
+// GetTaskStates returns the state of every task of taskType keyed by task name.
+func (c *Client) GetTaskStates(ctx context.Context, taskType string) (map[string]string, error) {
+	states := map[string]string{}
+	if err := c.doJSON(ctx, http.MethodGet, makeTaskStatesPath(taskType), nil, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// ScheduleTasks schedules the tasks of taskType for tableName, empty values
+// schedule all task types or all tables. The names of the scheduled tasks are
+// returned keyed by task type.
+func (c *Client) ScheduleTasks(ctx context.Context, taskType, tableName string) (map[string]string, error) {
+	scheduled := map[string]string{}
+	if err := c.doJSON(ctx, http.MethodPost, makeScheduleTasksPath(taskType, tableName), nil, &scheduled); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
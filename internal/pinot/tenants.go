@@ -0,0 +1,49 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinot
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func makeTenantsPath() string { return "/tenants" }
+
+func makeTenantPath(tenantName string) string { return "/tenants/" + escape(tenantName) }
+
+// GetTenant returns the instances of a tenant as json, IsNotFound is true for
+// the error when the tenant does not exist.
+func (c *Client) GetTenant(ctx context.Context, tenantName string) (string, error) {
+	return c.do(ctx, http.MethodGet, makeTenantPath(tenantName), nil)
+}
+
+// CreateTenant adds a tenant and returns the status message of pinot.
+func (c *Client) CreateTenant(ctx context.Context, tenantJson string) (string, error) {
+	return c.doStatus(ctx, http.MethodPost, makeTenantsPath(), []byte(tenantJson))
+}
+
+// UpdateTenant updates an existing tenant and returns the status message of pinot.
+func (c *Client) UpdateTenant(ctx context.Context, tenantJson string) (string, error) {
+	return c.doStatus(ctx, http.MethodPut, makeTenantsPath(), []byte(tenantJson))
+}
+
+// DeleteTenant deletes a tenant of tenantType, SERVER or BROKER, and returns
+// the status message of pinot.
+func (c *Client) DeleteTenant(ctx context.Context, tenantName, tenantType string) (string, error) {
+	return c.doStatus(ctx, http.MethodDelete, makeTenantPath(tenantName)+"?type="+url.QueryEscape(tenantType), nil)
+}
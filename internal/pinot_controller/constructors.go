@@ -45,26 +45,25 @@ type NodeTypeNodeSpec struct {
 // constructor to nodeTypeNodeSpec. Order is constructed based on the deployment Order
 func getAllNodeSpecForNodeType(pt *v1beta1.Pinot) []NodeTypeNodeSpec {
 
-	// add more nodes types
-	nodeSpecsByNodeType := map[v1beta1.PinotNodeType][]NodeTypeNodeSpec{
-		pt.Spec.DeploymentOrder[0]: make([]NodeTypeNodeSpec, 0, 1),
-		pt.Spec.DeploymentOrder[1]: make([]NodeTypeNodeSpec, 0, 1),
-		pt.Spec.DeploymentOrder[2]: make([]NodeTypeNodeSpec, 0, 1),
-		pt.Spec.DeploymentOrder[3]: make([]NodeTypeNodeSpec, 0, 1),
+	allNodeSpecs := make([]NodeTypeNodeSpec, 0, len(pt.Spec.Nodes))
+
+	for _, nodeType := range pt.Spec.DeploymentOrder {
+		allNodeSpecs = append(allNodeSpecs, getNodeSpecsForNodeType(pt, nodeType)...)
 	}
 
-	for _, nodeSpec := range pt.Spec.Nodes {
-		nodeSpecs := nodeSpecsByNodeType[nodeSpec.NodeType]
-		nodeSpecsByNodeType[nodeSpec.NodeType] = append(nodeSpecs, NodeTypeNodeSpec{nodeSpec.NodeType, nodeSpec})
+	return allNodeSpecs
+}
 
-	}
+// all the nodeSpecs of a single nodeType, in the order they are defined
+func getNodeSpecsForNodeType(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) []NodeTypeNodeSpec {
 
-	allNodeSpecs := make([]NodeTypeNodeSpec, 0, len(pt.Spec.Nodes))
+	nodeSpecs := make([]NodeTypeNodeSpec, 0, 1)
 
-	allNodeSpecs = append(allNodeSpecs, nodeSpecsByNodeType[pt.Spec.DeploymentOrder[0]]...)
-	allNodeSpecs = append(allNodeSpecs, nodeSpecsByNodeType[pt.Spec.DeploymentOrder[1]]...)
-	allNodeSpecs = append(allNodeSpecs, nodeSpecsByNodeType[pt.Spec.DeploymentOrder[2]]...)
-	allNodeSpecs = append(allNodeSpecs, nodeSpecsByNodeType[pt.Spec.DeploymentOrder[3]]...)
+	for _, nodeSpec := range pt.Spec.Nodes {
+		if nodeSpec.NodeType == nodeType {
+			nodeSpecs = append(nodeSpecs, NodeTypeNodeSpec{nodeSpec.NodeType, nodeSpec})
+		}
+	}
 
-	return allNodeSpecs
+	return nodeSpecs
 }
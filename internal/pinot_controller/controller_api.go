@@ -0,0 +1,177 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+// matchServerInstance returns the server instance of a pod. Server instances
+// are named Server_<host>_<port> where host is the pod hostname, its fqdn or
+// its ip. An empty name is returned when no instance matches.
+func matchServerInstance(instances []string, podName, podIP string) string {
+	for _, instance := range instances {
+		host := strings.TrimPrefix(instance, pinot.ServerInstancePrefix)
+		if i := strings.LastIndex(host, "_"); i > 0 {
+			host = host[:i]
+		}
+		if host == podName || strings.HasPrefix(host, podName+".") ||
+			(podIP != "" && host == podIP) {
+			return instance
+		}
+	}
+	return ""
+}
+
+// getServerInstance returns the helix instance of a server pod, or an empty
+// name when the pod never registered with the cluster.
+func getServerInstance(ctx context.Context, pc *pinot.Client, pod *v1.Pod) (string, error) {
+	instances, err := pc.ListServerInstances(ctx)
+	if err != nil {
+		return "", err
+	}
+	return matchServerInstance(instances, pod.Name, pod.Status.PodIP), nil
+}
+
+// isTableConverged returns true when the ideal state of a table assigns no
+// segment to the excluded instances and the external view matches it.
+func isTableConverged(ctx context.Context, pc *pinot.Client, tableName string, excluded []string) (bool, error) {
+	idealState, err := pc.GetIdealState(ctx, tableName)
+	if err != nil {
+		return false, err
+	}
+	externalView, err := pc.GetExternalView(ctx, tableName)
+	if err != nil {
+		return false, err
+	}
+
+	for tableType, segments := range idealState {
+		for segment, instances := range segments {
+			for _, instance := range excluded {
+				if _, ok := instances[instance]; ok {
+					return false, nil
+				}
+			}
+			for instance, state := range instances {
+				if state != pinot.SegmentStateOnline && state != pinot.SegmentStateConsuming {
+					continue
+				}
+				if externalView[tableType][segment][instance] != state {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// isInstanceDrained returns true once the external view has no ONLINE or
+// CONSUMING segment left on the instance.
+func isInstanceDrained(ctx context.Context, pc *pinot.Client, instance string) (bool, error) {
+	tables, err := pc.ListTables(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, table := range tables {
+		externalView, err := pc.GetExternalView(ctx, table)
+		if err != nil {
+			return false, err
+		}
+		for _, segments := range externalView {
+			for _, instances := range segments {
+				if state := instances[instance]; state == pinot.SegmentStateOnline || state == pinot.SegmentStateConsuming {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// isInstanceCaughtUp returns true once every segment the ideal state assigns
+// to the instance is in the same state in the external view.
+func isInstanceCaughtUp(ctx context.Context, pc *pinot.Client, instance string) (bool, error) {
+	tables, err := pc.ListTables(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, table := range tables {
+		idealState, err := pc.GetIdealState(ctx, table)
+		if err != nil {
+			return false, err
+		}
+		externalView, err := pc.GetExternalView(ctx, table)
+		if err != nil {
+			return false, err
+		}
+		for tableType, segments := range idealState {
+			for segment, instances := range segments {
+				state, ok := instances[instance]
+				if !ok || (state != pinot.SegmentStateOnline && state != pinot.SegmentStateConsuming) {
+					continue
+				}
+				if externalView[tableType][segment][instance] != state {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// getControllerSvcUrl returns the url of the service of the first controller node group
+func (r *PinotReconciler) getControllerSvcUrl(pt *v1beta1.Pinot) (string, error) {
+	for _, nodeSpec := range pt.Spec.Nodes {
+		if nodeSpec.NodeType == v1beta1.Controller {
+			svcName := makeSvcName(nodeSpec.Name, nodeSpec.K8sConfig)
+			return utils.GetControllerURL(pt, svcName), nil
+		}
+	}
+	return "", fmt.Errorf("pinot cluster [%s] has no controller node", pt.Name)
+}
+
+// getPinotClient returns a client for the controller of the pinot cluster
+func (r *PinotReconciler) getPinotClient(ctx context.Context, pt *v1beta1.Pinot) (*pinot.Client, error) {
+	svcName, err := r.getControllerSvcUrl(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := utils.GetControllerAuth(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := utils.GetControllerTLSConfig(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pinot.NewClient(svcName, auth, tlsConfig)
+}
@@ -0,0 +1,200 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+)
+
+// testController serves canned pinot controller responses keyed by method and
+// request uri, and records the requests it received.
+type testController struct {
+	mu       sync.Mutex
+	routes   map[string]string
+	requests []string
+}
+
+func (c *testController) received(request string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range c.requests {
+		if r == request {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestPinotClient returns a client of a pinot controller answering the
+// given routes, other requests are answered with a 404.
+func newTestPinotClient(t *testing.T, routes map[string]string) (*pinot.Client, *testController) {
+	tc := &testController{routes: routes}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Method + " " + req.URL.RequestURI()
+		tc.mu.Lock()
+		tc.requests = append(tc.requests, key)
+		body, ok := tc.routes[key]
+		tc.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"error":"not found"}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	pc, err := pinot.NewClient(server.URL, internalHTTP.Auth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc, tc
+}
+
+func TestMatchServerInstance(t *testing.T) {
+	instances := []string{
+		"Server_server-sts-0.server-sts-headless.default.svc.cluster.local_8098",
+		"Server_server-sts-1_8098",
+		"Server_10.0.0.12_8098",
+	}
+
+	tests := []struct {
+		name    string
+		podName string
+		podIP   string
+		want    string
+	}{
+		{"fqdn", "server-sts-0", "", instances[0]},
+		{"hostname", "server-sts-1", "10.0.0.11", instances[1]},
+		{"ip", "server-sts-2", "10.0.0.12", instances[2]},
+		{"name prefix of another pod", "server-sts-", "", ""},
+		{"unregistered", "server-sts-3", "10.0.0.13", ""},
+		{"no ip", "server-sts-4", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchServerInstance(instances, tt.podName, tt.podIP); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsTableConverged(t *testing.T) {
+	tests := []struct {
+		name         string
+		idealState   string
+		externalView string
+		excluded     []string
+		want         bool
+	}{
+		{
+			name:         "converged",
+			idealState:   `{"OFFLINE":{"seg0":{"Server_a_8098":"ONLINE","Server_b_8098":"ONLINE"}}}`,
+			externalView: `{"OFFLINE":{"seg0":{"Server_a_8098":"ONLINE","Server_b_8098":"ONLINE"}}}`,
+			want:         true,
+		},
+		{
+			name:         "segment on an excluded instance",
+			idealState:   `{"OFFLINE":{"seg0":{"Server_a_8098":"ONLINE","Server_c_8098":"ONLINE"}}}`,
+			externalView: `{"OFFLINE":{"seg0":{"Server_a_8098":"ONLINE","Server_c_8098":"ONLINE"}}}`,
+			excluded:     []string{"Server_c_8098"},
+			want:         false,
+		},
+		{
+			name:         "external view behind",
+			idealState:   `{"REALTIME":{"seg0":{"Server_a_8098":"CONSUMING","Server_b_8098":"CONSUMING"}}}`,
+			externalView: `{"REALTIME":{"seg0":{"Server_a_8098":"CONSUMING","Server_b_8098":"OFFLINE"}}}`,
+			want:         false,
+		},
+		{
+			name:         "offline segments are ignored",
+			idealState:   `{"OFFLINE":{"seg0":{"Server_a_8098":"OFFLINE"}}}`,
+			externalView: `{}`,
+			want:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, _ := newTestPinotClient(t, map[string]string{
+				"GET /tables/airlineStats/idealstate":   tt.idealState,
+				"GET /tables/airlineStats/externalview": tt.externalView,
+			})
+			got, err := isTableConverged(context.Background(), pc, "airlineStats", tt.excluded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsInstanceDrained(t *testing.T) {
+	tests := []struct {
+		name         string
+		externalView string
+		want         bool
+	}{
+		{
+			name:         "online segment",
+			externalView: `{"OFFLINE":{"seg0":{"Server_a_8098":"ONLINE","Server_b_8098":"ONLINE"}}}`,
+			want:         false,
+		},
+		{
+			name:         "consuming segment",
+			externalView: `{"REALTIME":{"seg0":{"Server_a_8098":"CONSUMING"}}}`,
+			want:         false,
+		},
+		{
+			name:         "offline segment",
+			externalView: `{"OFFLINE":{"seg0":{"Server_a_8098":"OFFLINE","Server_b_8098":"ONLINE"}}}`,
+			want:         true,
+		},
+		{
+			name:         "no segment",
+			externalView: `{"OFFLINE":{"seg0":{"Server_b_8098":"ONLINE"}}}`,
+			want:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, _ := newTestPinotClient(t, map[string]string{
+				"GET /tables":                           `{"tables":["airlineStats"]}`,
+				"GET /tables/airlineStats/externalview": tt.externalView,
+			})
+			got, err := isInstanceDrained(context.Background(), pc, "Server_a_8098")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	DeepStorageVolumeMountPath      = "/var/pinot/deepstorage"
+	DeepStorageLocalMountPath       = DeepStorageVolumeMountPath + "/local"
+	DeepStorageGCSKeyMountPath      = DeepStorageVolumeMountPath + "/gcs"
+	DeepStorageHDFSKeytabMountPath  = DeepStorageVolumeMountPath + "/hdfs"
+	DeepStorageHadoopConfMountPath  = DeepStorageVolumeMountPath + "/hadoop-conf"
+	DeepStorageLocalVolumeName      = "deepstorage-local"
+	DeepStorageGCSKeyVolumeName     = "deepstorage-gcs-key"
+	DeepStorageHDFSKeytabVolumeName = "deepstorage-hdfs-keytab"
+	DeepStorageHadoopConfVolumeName = "deepstorage-hadoop-conf"
+	DeepStorageADLSAccessKeyEnv     = "PINOT_ADLS_ACCESS_KEY"
+	PinotFSSegmentFetcherClass      = "org.apache.pinot.common.utils.fetcher.PinotFSSegmentFetcher"
+)
+
+// property prefix of the deep storage properties of each node type,
+// brokers never read from deep storage.
+var deepStoragePrefix = map[v1beta1.PinotNodeType]string{
+	v1beta1.Controller: "pinot.controller",
+	v1beta1.Server:     "pinot.server",
+	v1beta1.Minion:     "pinot.minion",
+}
+
+// getDeepStorageProvider returns the typed deep storage of a node type, nil
+// when there is none or the node type does not use deep storage.
+func getDeepStorageProvider(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) *v1beta1.DeepStorageProvider {
+	if _, ok := deepStoragePrefix[nodeType]; !ok {
+		return nil
+	}
+	return pt.Spec.External.DeepStorage.Provider
+}
+
+// makeDeepStorageConfig renders the pinot properties of the typed deep storage
+// for a node type. Credentials are never rendered, they are referenced through
+// env vars or mounted files.
+func makeDeepStorageConfig(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) string {
+	provider := getDeepStorageProvider(pt, nodeType)
+	if provider == nil {
+		return ""
+	}
+
+	prefix := deepStoragePrefix[nodeType]
+	path := strings.Trim(provider.Path, "/")
+
+	var scheme, fsClass, dataDir string
+	fsProperties := [][2]string{}
+	dynamicEnv := []string{}
+
+	switch provider.Type {
+	case v1beta1.DeepStorageS3:
+		if provider.S3 == nil {
+			return ""
+		}
+		scheme, fsClass = "s3", "org.apache.pinot.plugin.filesystem.S3PinotFS"
+		dataDir = "s3://" + provider.S3.Bucket + "/" + path
+		if provider.S3.Region != "" {
+			fsProperties = append(fsProperties, [2]string{"region", provider.S3.Region})
+		}
+		if provider.S3.Endpoint != "" {
+			fsProperties = append(fsProperties, [2]string{"endpoint", provider.S3.Endpoint})
+		}
+	case v1beta1.DeepStorageGCS:
+		if provider.GCS == nil {
+			return ""
+		}
+		scheme, fsClass = "gs", "org.apache.pinot.plugin.filesystem.GcsPinotFS"
+		dataDir = "gs://" + provider.GCS.Bucket + "/" + path
+		if provider.GCS.ProjectID != "" {
+			fsProperties = append(fsProperties, [2]string{"projectId", provider.GCS.ProjectID})
+		}
+		if provider.GCS.KeySecretKeyRef != nil {
+			fsProperties = append(fsProperties, [2]string{"gcpKey", DeepStorageGCSKeyMountPath + "/" + provider.GCS.KeySecretKeyRef.Key})
+		}
+	case v1beta1.DeepStorageADLS:
+		if provider.ADLS == nil {
+			return ""
+		}
+		scheme, fsClass = "adl2", "org.apache.pinot.plugin.filesystem.ADLSGen2PinotFS"
+		dataDir = "adl2://" + provider.ADLS.FileSystemName + "/" + path
+		fsProperties = append(fsProperties,
+			[2]string{"accountName", provider.ADLS.AccountName},
+			[2]string{"fileSystemName", provider.ADLS.FileSystemName},
+		)
+		if provider.ADLS.AccessKeySecretKeyRef != nil {
+			// the value is the name of the env var holding the key, see dynamic.env.config
+			fsProperties = append(fsProperties, [2]string{"accessKey", DeepStorageADLSAccessKeyEnv})
+			dynamicEnv = append(dynamicEnv, prefix+".storage.factory.adl2.accessKey")
+		}
+	case v1beta1.DeepStorageHDFS:
+		if provider.HDFS == nil {
+			return ""
+		}
+		scheme, fsClass = "hdfs", "org.apache.pinot.plugin.filesystem.HadoopPinotFS"
+		dataDir = "hdfs://" + provider.HDFS.Endpoint + "/" + path
+		if provider.HDFS.ConfigMapName != "" {
+			fsProperties = append(fsProperties, [2]string{"hadoop.conf.path", DeepStorageHadoopConfMountPath})
+		}
+		if provider.HDFS.KerberosPrincipal != "" {
+			fsProperties = append(fsProperties, [2]string{"hadoop.kerberos.principle", provider.HDFS.KerberosPrincipal})
+		}
+		if provider.HDFS.KeytabSecretKeyRef != nil {
+			fsProperties = append(fsProperties, [2]string{"hadoop.kerberos.keytab", DeepStorageHDFSKeytabMountPath + "/" + provider.HDFS.KeytabSecretKeyRef.Key})
+		}
+	case v1beta1.DeepStorageLocal:
+		if provider.Local == nil {
+			return ""
+		}
+		dataDir = getLocalDeepStorageMountPath(provider.Local) + "/" + path
+	default:
+		return ""
+	}
+
+	properties := []string{}
+
+	if nodeType == v1beta1.Controller {
+		properties = append(properties, "controller.data.dir="+strings.TrimSuffix(dataDir, "/"))
+	}
+
+	if nodeType == v1beta1.Server {
+		properties = append(properties, "pinot.server.instance.enable.split.commit=true")
+	}
+
+	// local deep storage is served by the default file system and fetcher
+	if scheme != "" {
+		properties = append(properties, fmt.Sprintf("%s.storage.factory.class.%s=%s", prefix, scheme, fsClass))
+		for _, property := range fsProperties {
+			properties = append(properties, fmt.Sprintf("%s.storage.factory.%s.%s=%s", prefix, scheme, property[0], property[1]))
+		}
+		properties = append(properties,
+			fmt.Sprintf("%s.segment.fetcher.protocols=file,http,%s", prefix, scheme),
+			fmt.Sprintf("%s.segment.fetcher.%s.class=%s", prefix, scheme, PinotFSSegmentFetcherClass),
+		)
+	}
+
+	if len(dynamicEnv) > 0 {
+		properties = append(properties, "dynamic.env.config="+strings.Join(dynamicEnv, ","))
+	}
+
+	return strings.Join(properties, "\n")
+}
+
+// appendDeepStorageConfig appends the typed deep storage properties and the
+// raw deep storage data of a node type to its configuration.
+func appendDeepStorageConfig(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType, configuration string) string {
+	if deepStorageConfig := makeDeepStorageConfig(pt, nodeType); deepStorageConfig != "" {
+		configuration = fmt.Sprintf("%s\n%s", configuration, deepStorageConfig)
+	}
+
+	for _, deepStoreConfig := range pt.Spec.External.DeepStorage.Spec {
+		if deepStoreConfig.NodeType == nodeType {
+			configuration = fmt.Sprintf("%s\n%s", configuration, deepStoreConfig.Data)
+		}
+	}
+
+	return configuration
+}
+
+// getDeepStorageEnv returns the env vars carrying deep storage credentials
+func getDeepStorageEnv(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) []v1.EnvVar {
+	provider := getDeepStorageProvider(pt, nodeType)
+	if provider == nil {
+		return nil
+	}
+
+	envs := []v1.EnvVar{}
+
+	switch provider.Type {
+	case v1beta1.DeepStorageS3:
+		if provider.S3 == nil {
+			return nil
+		}
+		if provider.S3.AccessKeySecretKeyRef != nil {
+			envs = append(envs, makeSecretEnv("AWS_ACCESS_KEY_ID", provider.S3.AccessKeySecretKeyRef))
+		}
+		if provider.S3.SecretKeySecretKeyRef != nil {
+			envs = append(envs, makeSecretEnv("AWS_SECRET_ACCESS_KEY", provider.S3.SecretKeySecretKeyRef))
+		}
+	case v1beta1.DeepStorageADLS:
+		if provider.ADLS != nil && provider.ADLS.AccessKeySecretKeyRef != nil {
+			envs = append(envs, makeSecretEnv(DeepStorageADLSAccessKeyEnv, provider.ADLS.AccessKeySecretKeyRef))
+		}
+	}
+
+	return envs
+}
+
+// getDeepStorageVolumes returns the volumes holding deep storage key files,
+// hadoop configuration or the local deep storage claim.
+func getDeepStorageVolumes(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) []v1.Volume {
+	provider := getDeepStorageProvider(pt, nodeType)
+	if provider == nil {
+		return nil
+	}
+
+	volumes := []v1.Volume{}
+
+	switch provider.Type {
+	case v1beta1.DeepStorageGCS:
+		if provider.GCS != nil && provider.GCS.KeySecretKeyRef != nil {
+			volumes = append(volumes, makeSecretVolume(DeepStorageGCSKeyVolumeName, provider.GCS.KeySecretKeyRef))
+		}
+	case v1beta1.DeepStorageHDFS:
+		if provider.HDFS == nil {
+			return nil
+		}
+		if provider.HDFS.KeytabSecretKeyRef != nil {
+			volumes = append(volumes, makeSecretVolume(DeepStorageHDFSKeytabVolumeName, provider.HDFS.KeytabSecretKeyRef))
+		}
+		if provider.HDFS.ConfigMapName != "" {
+			volumes = append(volumes, v1.Volume{
+				Name: DeepStorageHadoopConfVolumeName,
+				VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: provider.HDFS.ConfigMapName},
+					},
+				},
+			})
+		}
+	case v1beta1.DeepStorageLocal:
+		if provider.Local != nil {
+			volumes = append(volumes, v1.Volume{
+				Name: DeepStorageLocalVolumeName,
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: provider.Local.ClaimName},
+				},
+			})
+		}
+	}
+
+	return volumes
+}
+
+func getDeepStorageVolumeMounts(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) []v1.VolumeMount {
+	provider := getDeepStorageProvider(pt, nodeType)
+	if provider == nil {
+		return nil
+	}
+
+	mountPaths := map[string]string{
+		DeepStorageGCSKeyVolumeName:     DeepStorageGCSKeyMountPath,
+		DeepStorageHDFSKeytabVolumeName: DeepStorageHDFSKeytabMountPath,
+		DeepStorageHadoopConfVolumeName: DeepStorageHadoopConfMountPath,
+	}
+	if provider.Local != nil {
+		mountPaths[DeepStorageLocalVolumeName] = getLocalDeepStorageMountPath(provider.Local)
+	}
+
+	volumeMounts := []v1.VolumeMount{}
+	for _, volume := range getDeepStorageVolumes(pt, nodeType) {
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: mountPaths[volume.Name],
+			ReadOnly:  volume.Name != DeepStorageLocalVolumeName,
+		})
+	}
+
+	return volumeMounts
+}
+
+func getLocalDeepStorageMountPath(local *v1beta1.LocalStorageSpec) string {
+	if local.MountPath != "" {
+		return strings.TrimSuffix(local.MountPath, "/")
+	}
+	return DeepStorageLocalMountPath
+}
+
+func makeSecretEnv(name string, ref *v1.SecretKeySelector) v1.EnvVar {
+	return v1.EnvVar{
+		Name:      name,
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: ref},
+	}
+}
+
+func makeSecretVolume(name string, ref *v1.SecretKeySelector) v1.Volume {
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: ref.Name,
+				Items:      []v1.KeyToPath{{Key: ref.Key, Path: ref.Key}},
+			},
+		},
+	}
+}
@@ -0,0 +1,174 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func secretKeyRef(name, key string) *v1.SecretKeySelector {
+	return &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: name}, Key: key}
+}
+
+func TestMakeDeepStorageConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *v1beta1.DeepStorageProvider
+		nodeType v1beta1.PinotNodeType
+		want     []string
+	}{
+		{
+			name: "s3 controller",
+			provider: &v1beta1.DeepStorageProvider{
+				Type: v1beta1.DeepStorageS3,
+				Path: "/segments/",
+				S3: &v1beta1.S3StorageSpec{
+					Bucket:                "pinot",
+					Region:                "us-east-1",
+					Endpoint:              "http://minio:9000",
+					AccessKeySecretKeyRef: secretKeyRef("s3", "access"),
+				},
+			},
+			nodeType: v1beta1.Controller,
+			want: []string{
+				"controller.data.dir=s3://pinot/segments",
+				"pinot.controller.storage.factory.class.s3=org.apache.pinot.plugin.filesystem.S3PinotFS",
+				"pinot.controller.storage.factory.s3.region=us-east-1",
+				"pinot.controller.storage.factory.s3.endpoint=http://minio:9000",
+				"pinot.controller.segment.fetcher.protocols=file,http,s3",
+				"pinot.controller.segment.fetcher.s3.class=" + PinotFSSegmentFetcherClass,
+			},
+		},
+		{
+			name: "gcs server",
+			provider: &v1beta1.DeepStorageProvider{
+				Type: v1beta1.DeepStorageGCS,
+				Path: "segments",
+				GCS: &v1beta1.GCSStorageSpec{
+					Bucket:          "pinot",
+					ProjectID:       "project",
+					KeySecretKeyRef: secretKeyRef("gcs", "key.json"),
+				},
+			},
+			nodeType: v1beta1.Server,
+			want: []string{
+				"pinot.server.instance.enable.split.commit=true",
+				"pinot.server.storage.factory.class.gs=org.apache.pinot.plugin.filesystem.GcsPinotFS",
+				"pinot.server.storage.factory.gs.projectId=project",
+				"pinot.server.storage.factory.gs.gcpKey=" + DeepStorageGCSKeyMountPath + "/key.json",
+				"pinot.server.segment.fetcher.protocols=file,http,gs",
+				"pinot.server.segment.fetcher.gs.class=" + PinotFSSegmentFetcherClass,
+			},
+		},
+		{
+			name: "adls minion",
+			provider: &v1beta1.DeepStorageProvider{
+				Type: v1beta1.DeepStorageADLS,
+				ADLS: &v1beta1.ADLSStorageSpec{
+					AccountName:           "account",
+					FileSystemName:        "pinot",
+					AccessKeySecretKeyRef: secretKeyRef("adls", "key"),
+				},
+			},
+			nodeType: v1beta1.Minion,
+			want: []string{
+				"pinot.minion.storage.factory.class.adl2=org.apache.pinot.plugin.filesystem.ADLSGen2PinotFS",
+				"pinot.minion.storage.factory.adl2.accountName=account",
+				"pinot.minion.storage.factory.adl2.fileSystemName=pinot",
+				"pinot.minion.storage.factory.adl2.accessKey=" + DeepStorageADLSAccessKeyEnv,
+				"pinot.minion.segment.fetcher.protocols=file,http,adl2",
+				"pinot.minion.segment.fetcher.adl2.class=" + PinotFSSegmentFetcherClass,
+				"dynamic.env.config=pinot.minion.storage.factory.adl2.accessKey",
+			},
+		},
+		{
+			name: "hdfs controller",
+			provider: &v1beta1.DeepStorageProvider{
+				Type: v1beta1.DeepStorageHDFS,
+				Path: "pinot",
+				HDFS: &v1beta1.HDFSStorageSpec{
+					Endpoint:           "namenode:8020",
+					ConfigMapName:      "hadoop-conf",
+					KerberosPrincipal:  "pinot@EXAMPLE.COM",
+					KeytabSecretKeyRef: secretKeyRef("hdfs", "pinot.keytab"),
+				},
+			},
+			nodeType: v1beta1.Controller,
+			want: []string{
+				"controller.data.dir=hdfs://namenode:8020/pinot",
+				"pinot.controller.storage.factory.class.hdfs=org.apache.pinot.plugin.filesystem.HadoopPinotFS",
+				"pinot.controller.storage.factory.hdfs.hadoop.conf.path=" + DeepStorageHadoopConfMountPath,
+				"pinot.controller.storage.factory.hdfs.hadoop.kerberos.principle=pinot@EXAMPLE.COM",
+				"pinot.controller.storage.factory.hdfs.hadoop.kerberos.keytab=" + DeepStorageHDFSKeytabMountPath + "/pinot.keytab",
+				"pinot.controller.segment.fetcher.protocols=file,http,hdfs",
+				"pinot.controller.segment.fetcher.hdfs.class=" + PinotFSSegmentFetcherClass,
+			},
+		},
+		{
+			name: "local controller",
+			provider: &v1beta1.DeepStorageProvider{
+				Type:  v1beta1.DeepStorageLocal,
+				Path:  "segments",
+				Local: &v1beta1.LocalStorageSpec{ClaimName: "deepstorage", MountPath: "/mnt/deepstorage/"},
+			},
+			nodeType: v1beta1.Controller,
+			want:     []string{"controller.data.dir=/mnt/deepstorage/segments"},
+		},
+		{
+			name: "local server",
+			provider: &v1beta1.DeepStorageProvider{
+				Type:  v1beta1.DeepStorageLocal,
+				Local: &v1beta1.LocalStorageSpec{ClaimName: "deepstorage"},
+			},
+			nodeType: v1beta1.Server,
+			want:     []string{"pinot.server.instance.enable.split.commit=true"},
+		},
+		{
+			name: "broker",
+			provider: &v1beta1.DeepStorageProvider{
+				Type: v1beta1.DeepStorageS3,
+				S3:   &v1beta1.S3StorageSpec{Bucket: "pinot"},
+			},
+			nodeType: v1beta1.Broker,
+		},
+		{
+			name:     "missing provider spec",
+			provider: &v1beta1.DeepStorageProvider{Type: v1beta1.DeepStorageGCS},
+			nodeType: v1beta1.Controller,
+		},
+		{
+			name:     "no provider",
+			nodeType: v1beta1.Controller,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := &v1beta1.Pinot{}
+			pt.Spec.External.DeepStorage.Provider = tt.provider
+
+			got := makeDeepStorageConfig(pt, tt.nodeType)
+			if want := strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("expected\n%s\ngot\n%s", want, got)
+			}
+		})
+	}
+}
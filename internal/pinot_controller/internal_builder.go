@@ -93,95 +93,31 @@ func (ib *internalBuilder) makeConfigMap(
 
 	var data map[string]string
 
-	if pinotNodeSpec.NodeType == v1beta1.Controller {
-
+	switch pinotNodeSpec.NodeType {
+	case v1beta1.Controller:
 		var configuration string = fmt.Sprintf(
 			"%s\n%s\n%s",
 			pinotNodeConfig.Data,
 			"controller.helix.cluster.name="+ib.pinot.GetName(),
-			"controller.zk.str="+ib.pinot.Spec.External.Zookeeper.Spec.ZkAddress,
+			"controller.zk.str="+getZkAddress(ib.pinot),
 		)
-
 		data = map[string]string{
-			ControllerConfName: configuration,
-		}
-
-		if ib.pinot.Spec.External.DeepStorage.Spec != nil {
-			for _, deepStoreConfig := range ib.pinot.Spec.External.DeepStorage.Spec {
-				if deepStoreConfig.NodeType == pinotNodeSpec.NodeType {
-					data = map[string]string{
-						ControllerConfName: fmt.Sprintf(
-							"%s\n%s",
-							configuration,
-							deepStoreConfig.Data,
-						),
-					}
-				}
-			}
-
+			ControllerConfName: appendDeepStorageConfig(ib.pinot, pinotNodeSpec.NodeType, configuration),
 		}
-	} else if pinotNodeSpec.NodeType == v1beta1.Broker {
-
-		var configuration string = fmt.Sprintf("%s", pinotNodeConfig.Data)
+	case v1beta1.Broker:
 		data = map[string]string{
-			BrokerConfName: configuration,
+			BrokerConfName: appendDeepStorageConfig(ib.pinot, pinotNodeSpec.NodeType, pinotNodeConfig.Data),
 		}
-		if ib.pinot.Spec.External.DeepStorage.Spec != nil {
-			for _, deepStoreConfig := range ib.pinot.Spec.External.DeepStorage.Spec {
-				if deepStoreConfig.NodeType == pinotNodeSpec.NodeType {
-					data = map[string]string{
-						BrokerConfName: fmt.Sprintf(
-							"%s\n%s",
-							configuration,
-							deepStoreConfig.Data,
-						),
-					}
-				}
-			}
-		}
-
-	} else if pinotNodeSpec.NodeType == v1beta1.Minion {
-		var configuration string = fmt.Sprintf("%s", pinotNodeConfig.Data)
+	case v1beta1.Minion:
 		data = map[string]string{
-			MinionConfName: configuration,
+			MinionConfName: appendDeepStorageConfig(ib.pinot, pinotNodeSpec.NodeType, pinotNodeConfig.Data),
 		}
-		if ib.pinot.Spec.External.DeepStorage.Spec != nil {
-			for _, deepStoreConfig := range ib.pinot.Spec.External.DeepStorage.Spec {
-				if deepStoreConfig.NodeType == pinotNodeSpec.NodeType {
-					data = map[string]string{
-						MinionConfName: fmt.Sprintf(
-							"%s\n%s",
-							configuration,
-							deepStoreConfig.Data,
-						),
-					}
-				}
-			}
-
-		}
-
-	} else if pinotNodeSpec.NodeType == v1beta1.Server {
-
-		var configuration string = fmt.Sprintf("%s", pinotNodeConfig.Data)
+	case v1beta1.Server:
 		data = map[string]string{
-			ServerConfName: configuration,
-		}
-		if ib.pinot.Spec.External.DeepStorage.Spec != nil {
-			for _, deepStoreConfig := range ib.pinot.Spec.External.DeepStorage.Spec {
-				if deepStoreConfig.NodeType == pinotNodeSpec.NodeType {
-					data = map[string]string{
-						ServerConfName: fmt.Sprintf(
-							"%s\n%s",
-							configuration,
-							deepStoreConfig.Data,
-						),
-					}
-				}
-			}
-
+			ServerConfName: appendDeepStorageConfig(ib.pinot, pinotNodeSpec.NodeType, pinotNodeConfig.Data),
 		}
-
 	}
+
 	return &builder.BuilderConfigMap{
 		CommonBuilder: builder.CommonBuilder{
 			ObjectMeta: metav1.ObjectMeta{
@@ -210,12 +146,12 @@ func (ib *internalBuilder) makeStsOrDeploy(
 		NodeSelector: k8sConfig.NodeSelector,
 		Containers: []v1.Container{
 			{
-				Name:            pinotNodeSpec.Name + "-" + string(pinotNodeSpec.NodeType),
+				Name:            makeContainerName(pinotNodeSpec),
 				Image:           k8sConfig.Image,
 				Args:            makeArgs(ib.pinot, pinotNodeSpec.NodeType),
 				ImagePullPolicy: k8sConfig.ImagePullPolicy,
 				Ports:           k8sConfig.Port,
-				Env:             getEnv(ib.pinot, pinotNodeConfig, k8sConfig, configHash),
+				Env:             getEnv(ib.pinot, pinotNodeConfig, pinotNodeSpec, k8sConfig, configHash),
 				VolumeMounts:    getVolumeMounts(pinot, k8sConfig, pinotNodeSpec, storageConfig),
 				LivenessProbe:   k8sConfig.LivenessProbe,
 				ReadinessProbe:  k8sConfig.ReadinessProbe,
@@ -310,6 +246,10 @@ func makeStsOrDeployName(nodeSpec, k8sConfig string) string {
 	return nodeSpec + "-" + k8sConfig
 }
 
+func makeContainerName(nodeSpec *v1beta1.NodeSpec) string {
+	return nodeSpec.Name + "-" + string(nodeSpec.NodeType)
+}
+
 func makeConfigMapName(nodeSpec, pinotNodeConfig string) string {
 	return nodeSpec + "-" + pinotNodeConfig + "-" + "config"
 }
@@ -361,6 +301,7 @@ func getVolumeMounts(
 		},
 	)
 
+	volumeMount = append(volumeMount, getDeepStorageVolumeMounts(pinot, pinotNodeSpec.NodeType)...)
 	volumeMount = append(volumeMount, k8sConfig.VolumeMount...)
 	return volumeMount
 }
@@ -385,6 +326,7 @@ func getVolume(
 		},
 	)
 
+	volumeHolder = append(volumeHolder, getDeepStorageVolumes(pinot, pinotNodeSpec.NodeType)...)
 	volumeHolder = append(volumeHolder, k8sConfig.Volumes...)
 	return volumeHolder
 }
@@ -400,7 +342,7 @@ func makeArgs(
 			"-clusterName",
 			pinot.Name,
 			"-zkAddress",
-			pinot.Spec.External.Zookeeper.Spec.ZkAddress,
+			getZkAddress(pinot),
 			"-configFileName",
 			BrokerConfigMapVolumeMountPath + "/" + BrokerConfName,
 		}
@@ -416,7 +358,7 @@ func makeArgs(
 			"-clusterName",
 			pinot.Name,
 			"-zkAddress",
-			pinot.Spec.External.Zookeeper.Spec.ZkAddress,
+			getZkAddress(pinot),
 			"-configFileName",
 			ServerConfigMapVolumeMountPath + "/" + ServerConfName,
 		}
@@ -426,7 +368,7 @@ func makeArgs(
 			"-clusterName",
 			pinot.Name,
 			"-zkAddress",
-			pinot.Spec.External.Zookeeper.Spec.ZkAddress,
+			getZkAddress(pinot),
 			"-configFileName",
 			MinionConfigMapVolumeMountPath + "/" + MinionConfName,
 		}
@@ -438,6 +380,7 @@ func makeArgs(
 func getEnv(
 	pinot *v1beta1.Pinot,
 	pinotNodeConfig *v1beta1.PinotNodeConfig,
+	pinotNodeSpec *v1beta1.NodeSpec,
 	k8sConfigGroup *v1beta1.K8sConfig,
 	configHash []utils.ConfigMapHash,
 ) []v1.EnvVar {
@@ -457,6 +400,7 @@ func getEnv(
 
 	envs = append(envs, k8sConfigGroup.Env...)
 	envs = append(envs, jvmOpts)
+	envs = append(envs, getDeepStorageEnv(pinot, pinotNodeSpec.NodeType)...)
 
 	hashes, _ := utils.MakeConfigMapHash(configHash)
 
@@ -21,6 +21,7 @@ import (
 	"os"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
@@ -64,6 +65,8 @@ func NewPinotReconciler(mgr ctrl.Manager) *PinotReconciler {
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;patch
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 func (r *PinotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
@@ -77,9 +80,22 @@ func (r *PinotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
-	if err := r.do(ctx, pinotCR); err != nil {
+	reconcileErr := r.do(ctx, pinotCR)
+
+	// status is updated on every reconcile so that node group readiness
+	// is reported even when the reconcile itself failed.
+	if err := r.updatePinotStatus(ctx, pinotCR, reconcileErr); err != nil {
 		logr.Error(err, err.Error())
-		return ctrl.Result{}, err
+	}
+
+	if deadlineErr, ok := reconcileErr.(*RolloutDeadlineExceededError); ok {
+		// the rollout stays halted on the failing node type until it becomes
+		// ready or the spec changes, there is nothing to retry with backoff.
+		r.Recorder.Event(pinotCR, v1.EventTypeWarning, PinotRolloutDeadline, deadlineErr.Error())
+		return ctrl.Result{RequeueAfter: r.ReconcileWait}, nil
+	} else if reconcileErr != nil {
+		logr.Error(reconcileErr, reconcileErr.Error())
+		return ctrl.Result{}, reconcileErr
 	} else {
 		return ctrl.Result{RequeueAfter: r.ReconcileWait}, nil
 	}
@@ -18,13 +18,31 @@ package pinotcontroller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/datainfrahq/operator-runtime/builder"
 	"github.com/datainfrahq/operator-runtime/utils"
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	defaultProgressDeadline = 10 * time.Minute
+)
+
+// RolloutDeadlineExceededError is returned when a node type does not become
+// ready within the progress deadline. The rollout halts on that node type.
+type RolloutDeadlineExceededError struct {
+	NodeType v1beta1.PinotNodeType
+	Deadline time.Duration
+}
+
+func (e *RolloutDeadlineExceededError) Error() string {
+	return fmt.Sprintf("node type [%s] did not become ready within progress deadline [%s]", e.NodeType, e.Deadline)
+}
+
 func (r *PinotReconciler) do(ctx context.Context, pt *v1beta1.Pinot) error {
 
 	// create ownerRef passed to each object created
@@ -35,13 +53,72 @@ func (r *PinotReconciler) do(ctx context.Context, pt *v1beta1.Pinot) error {
 		pt.UID,
 	)
 
+	// A managed zookeeper ensemble has to hold a quorum before any pinot
+	// component is rolled out, controllers fail to start without it.
+	if isZookeeperManaged(pt) {
+		if err := r.reconcileZookeeper(ctx, pt, getOwnerRef); err != nil {
+			return err
+		}
+
+		zookeeper, err := r.getZookeeperStatus(ctx, pt)
+		if err != nil {
+			return err
+		}
+		if !zookeeper.Healthy {
+			return nil
+		}
+	}
+
+	// Node types are rolled out strictly in deployment order. A node type is
+	// only reconciled once every node group of the previous node type is ready,
+	// so a broker rollout never starts while controllers are still crash-looping.
+	for _, nodeType := range pt.Spec.DeploymentOrder {
+
+		nodeSpecs := getNodeSpecsForNodeType(pt, nodeType)
+		if len(nodeSpecs) == 0 {
+			continue
+		}
+
+		if err := r.reconcileNodeType(ctx, pt, nodeSpecs, getOwnerRef); err != nil {
+			return err
+		}
+
+		ready, err := r.isNodeTypeReady(ctx, pt, nodeSpecs)
+		if err != nil {
+			return err
+		}
+
+		if !ready {
+			return checkProgressDeadline(pt, nodeType)
+		}
+	}
+
+	return nil
+}
+
+// reconcileNodeType reconciles the configmaps, services, storage and
+// deployments or statefulsets of all the nodeSpecs of a single node type.
+func (r *PinotReconciler) reconcileNodeType(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpecs []NodeTypeNodeSpec,
+	getOwnerRef *metav1.OwnerReference,
+) error {
+
 	var ib *internalBuilder
 
-	nodeSpecs := getAllNodeSpecForNodeType(pt)
+	// a server scale down is advanced before the statefulsets are built since
+	// it decides the replicas they are reconciled with.
+	if nodeSpecs[0].NodeType == v1beta1.Server {
+		if err := r.scaleDownServers(ctx, pt, nodeSpecs); err != nil {
+			return err
+		}
+	}
 
 	pinotConfigMap := []builder.BuilderConfigMap{}
 	pinotConfigMapHash := []utils.ConfigMapHash{}
 	pinotDeploymentOrStatefulset := []builder.BuilderDeploymentStatefulSet{}
+	pinotGracefulStatefulset := []builder.BuilderDeploymentStatefulSet{}
 	pinotStorage := []builder.BuilderStorageConfig{}
 	pinotService := []builder.BuilderService{}
 
@@ -62,14 +139,20 @@ func (r *PinotReconciler) do(ctx context.Context, pt *v1beta1.Pinot) error {
 				pinotConfigMapHash = append(pinotConfigMapHash, utils.ConfigMapHash{Object: &v1.ConfigMap{Data: cm.Data, ObjectMeta: cm.ObjectMeta}})
 				for _, k8sConfig := range pt.Spec.K8sConfig {
 					if nodeSpec.NodeSpec.K8sConfig == k8sConfig.Name {
-						pinotDeploymentOrStatefulset = append(pinotDeploymentOrStatefulset, *ib.makeStsOrDeploy(
+						deployOrSts := *ib.makeStsOrDeploy(
 							ib.pinot,
 							&pinotConfig,
 							&nodeSpec.NodeSpec,
 							&k8sConfig,
 							&k8sConfig.StorageConfig,
 							pinotConfigMapHash,
-						))
+						)
+						if isGracefulRestart(&nodeSpec.NodeSpec) {
+							deployOrSts.Replicas = getServerReplicas(pt, &nodeSpec.NodeSpec)
+							pinotGracefulStatefulset = append(pinotGracefulStatefulset, deployOrSts)
+						} else {
+							pinotDeploymentOrStatefulset = append(pinotDeploymentOrStatefulset, deployOrSts)
+						}
 						pinotService = append(pinotService, *ib.makeService(&k8sConfig, &nodeSpec.NodeSpec))
 						for _, sc := range k8sConfig.StorageConfig {
 							pinotStorage = append(pinotStorage, *ib.makePvc(&sc, &k8sConfig, &nodeSpec.NodeSpec))
@@ -111,6 +194,20 @@ func (r *PinotReconciler) do(ctx context.Context, pt *v1beta1.Pinot) error {
 		return err
 	}
 
+	// server statefulsets are not rolled by the statefulset controller,
+	// the operator restarts their pods one at a time through helix.
+	for _, deployOrSts := range pinotGracefulStatefulset {
+		if err := r.reconcileGracefulStatefulSet(ctx, deployOrSts); err != nil {
+			return err
+		}
+	}
+
+	if len(pinotGracefulStatefulset) > 0 {
+		if err := r.rollingRestartServers(ctx, pt, nodeSpecs); err != nil {
+			return err
+		}
+	}
+
 	// reconcile store
 	if err := builder.ReconcileStore(); err != nil {
 		return err
@@ -118,3 +215,48 @@ func (r *PinotReconciler) do(ctx context.Context, pt *v1beta1.Pinot) error {
 
 	return nil
 }
+
+// isNodeTypeReady returns true when every node group of a node type has all
+// of its desired replicas ready on the current revision.
+func (r *PinotReconciler) isNodeTypeReady(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpecs []NodeTypeNodeSpec,
+) (bool, error) {
+	for _, nodeSpec := range nodeSpecs {
+		nodeGroup, err := r.getNodeGroupStatus(ctx, pt, &nodeSpec.NodeSpec)
+		if err != nil {
+			return false, err
+		}
+		if !nodeGroup.Ready {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkProgressDeadline returns a RolloutDeadlineExceededError when the rollout
+// has been waiting on nodeType for longer than the progress deadline. The wait
+// starts when the status first records nodeType for the current generation.
+func checkProgressDeadline(pt *v1beta1.Pinot, nodeType v1beta1.PinotNodeType) error {
+	rollout := pt.Status.Rollout
+	if rollout == nil || rollout.StartTime == nil ||
+		rollout.NodeType != nodeType ||
+		pt.Status.ObservedGeneration != pt.Generation {
+		return nil
+	}
+
+	deadline := getProgressDeadline(pt)
+	if time.Since(rollout.StartTime.Time) > deadline {
+		return &RolloutDeadlineExceededError{NodeType: nodeType, Deadline: deadline}
+	}
+
+	return nil
+}
+
+func getProgressDeadline(pt *v1beta1.Pinot) time.Duration {
+	if pt.Spec.ProgressDeadline != nil {
+		return pt.Spec.ProgressDeadline.Duration
+	}
+	return defaultProgressDeadline
+}
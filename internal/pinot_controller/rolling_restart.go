@@ -0,0 +1,392 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	PinotServerInstanceDisabled = "ServerInstanceDisabled"
+	PinotServerPodDeleted       = "ServerPodDeleted"
+	PinotServerInstanceEnabled  = "ServerInstanceEnabled"
+	PinotServerRestartCompleted = "ServerRestartCompleted"
+	PinotServerRestartFail      = "ServerRestartFail"
+)
+
+// isGracefulRestart returns true for the node groups whose pods are restarted
+// one at a time by the operator instead of the statefulset controller.
+func isGracefulRestart(nodeSpec *v1beta1.NodeSpec) bool {
+	return nodeSpec.NodeType == v1beta1.Server && nodeSpec.Kind == v1beta1.StatefulSetKind
+}
+
+// reconcileGracefulStatefulSet creates or updates a server statefulset with the
+// OnDelete update strategy, pods are then replaced by rollingRestartServers.
+func (r *PinotReconciler) reconcileGracefulStatefulSet(
+	ctx context.Context,
+	deployOrSts builder.BuilderDeploymentStatefulSet,
+) error {
+
+	sts, err := deployOrSts.MakeStatefulSet()
+	if err != nil {
+		return err
+	}
+
+	sts.Spec.VolumeClaimTemplates = deployOrSts.MakeVolumeClaimTemplates()
+	sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.OnDeleteStatefulSetStrategyType,
+	}
+
+	deployOrSts.DesiredState = sts
+	deployOrSts.CurrentState = &appsv1.StatefulSet{}
+
+	_, err = deployOrSts.CreateOrUpdate(ctx, builder.BuilderRecorder{Recorder: r.Recorder, ControllerName: "pinotOperator"})
+	return err
+}
+
+// rollingRestartServers moves the graceful restart of server pods one step
+// forward. Only a single pod across all server node groups is restarted at a
+// time, its progress is persisted in the Pinot status between reconciles.
+func (r *PinotReconciler) rollingRestartServers(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpecs []NodeTypeNodeSpec,
+) error {
+
+	// pods removed by a scale down in progress are not restarted
+	if pt.Status.ScaleDown != nil {
+		return nil
+	}
+
+	if state := pt.Status.RollingRestart; state != nil {
+		for _, nodeSpec := range nodeSpecs {
+			if nodeSpec.NodeSpec.Name == state.NodeGroup && isGracefulRestart(&nodeSpec.NodeSpec) {
+				return r.continueRestart(ctx, pt, &nodeSpec.NodeSpec, state.DeepCopy())
+			}
+		}
+		// node group has been removed from the spec, nothing left to restart
+		return r.setRollingRestartStatus(ctx, pt, nil)
+	}
+
+	for _, nodeSpec := range nodeSpecs {
+		if !isGracefulRestart(&nodeSpec.NodeSpec) {
+			continue
+		}
+		started, err := r.startRestart(ctx, pt, &nodeSpec.NodeSpec)
+		if err != nil {
+			return err
+		}
+		if started {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// startRestart picks the next pod of a node group that is not on the update
+// revision and disables its instance. Pods that are outdated and not ready are
+// not serving, they are deleted right away so a bad revision can be fixed.
+func (r *PinotReconciler) startRestart(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+) (bool, error) {
+
+	sts, err := r.getStatefulSet(ctx, pt, nodeSpec)
+	if err != nil || sts == nil {
+		return false, err
+	}
+
+	// the update revision is only valid once the statefulset controller observed the spec
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.UpdateRevision == "" {
+		return false, nil
+	}
+
+	pods, err := r.getStatefulSetPods(ctx, sts)
+	if err != nil {
+		return false, err
+	}
+
+	var outdated, unavailable *v1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		isOutdated := pod.Labels[appsv1.ControllerRevisionHashLabelKey] != sts.Status.UpdateRevision
+		if !isPodReady(pod) {
+			if isOutdated {
+				return true, r.deleteServerPod(ctx, pt, nodeSpec, pod, sts.Status.UpdateRevision, "")
+			}
+			unavailable = pod
+		} else if isOutdated && outdated == nil {
+			outdated = pod
+		}
+	}
+
+	// never take down a serving pod while another one of the group is unavailable
+	if outdated == nil || unavailable != nil {
+		return false, nil
+	}
+
+	pc, err := r.getPinotClient(ctx, pt)
+	if err != nil {
+		return false, err
+	}
+
+	instance, err := getServerInstance(ctx, pc, outdated)
+	if err != nil {
+		return false, err
+	}
+
+	// an instance unknown to helix is not serving any segment
+	if instance == "" {
+		return true, r.deleteServerPod(ctx, pt, nodeSpec, outdated, sts.Status.UpdateRevision, "")
+	}
+
+	if err := pc.SetInstanceState(ctx, instance, pinot.InstanceStateDisable); err != nil {
+		r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerRestartFail, err.Error())
+		return false, err
+	}
+
+	r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerInstanceDisabled,
+		fmt.Sprintf("Disabled instance [%s] of pod [%s] before restart", instance, outdated.Name))
+
+	return true, r.setRollingRestartStatus(ctx, pt, &v1beta1.RollingRestartStatus{
+		NodeGroup: nodeSpec.Name,
+		Pod:       outdated.Name,
+		Instance:  instance,
+		Phase:     v1beta1.RollingRestartDisabling,
+		Revision:  sts.Status.UpdateRevision,
+		StartTime: &metav1.Time{Time: time.Now()},
+	})
+}
+
+// continueRestart checks whether the current phase of the restart is complete
+// and moves it to the next one.
+func (r *PinotReconciler) continueRestart(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+	state *v1beta1.RollingRestartStatus,
+) error {
+
+	pc, err := r.getPinotClient(ctx, pt)
+	if err != nil {
+		return err
+	}
+
+	switch state.Phase {
+	case v1beta1.RollingRestartDisabling:
+		drained, err := isInstanceDrained(ctx, pc, state.Instance)
+		if err != nil || !drained {
+			return err
+		}
+
+		pod := &v1.Pod{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pt.Namespace, Name: state.Pod}, pod); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			pod.Name, pod.Namespace = state.Pod, pt.Namespace
+		}
+		return r.deleteServerPod(ctx, pt, nodeSpec, pod, state.Revision, state.Instance)
+
+	case v1beta1.RollingRestartRestarting:
+		sts, err := r.getStatefulSet(ctx, pt, nodeSpec)
+		if err != nil || sts == nil {
+			return err
+		}
+
+		pod := &v1.Pod{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pt.Namespace, Name: state.Pod}, pod); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		if pod.DeletionTimestamp != nil ||
+			pod.Labels[appsv1.ControllerRevisionHashLabelKey] != sts.Status.UpdateRevision ||
+			!isPodReady(pod) {
+			return nil
+		}
+
+		if state.Instance == "" {
+			r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerRestartCompleted,
+				fmt.Sprintf("Restarted pod [%s]", state.Pod))
+			return r.setRollingRestartStatus(ctx, pt, nil)
+		}
+
+		if err := pc.SetInstanceState(ctx, state.Instance, pinot.InstanceStateEnable); err != nil {
+			r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerRestartFail, err.Error())
+			return err
+		}
+
+		r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerInstanceEnabled,
+			fmt.Sprintf("Enabled instance [%s] of pod [%s] after restart", state.Instance, state.Pod))
+
+		state.Phase = v1beta1.RollingRestartEnabling
+		return r.setRollingRestartStatus(ctx, pt, state)
+
+	case v1beta1.RollingRestartEnabling:
+		caughtUp, err := isInstanceCaughtUp(ctx, pc, state.Instance)
+		if err != nil || !caughtUp {
+			return err
+		}
+
+		r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerRestartCompleted,
+			fmt.Sprintf("Instance [%s] of pod [%s] is serving all its segments", state.Instance, state.Pod))
+		return r.setRollingRestartStatus(ctx, pt, nil)
+	}
+
+	return fmt.Errorf("unknown rolling restart phase [%s]", state.Phase)
+}
+
+// deleteServerPod deletes a pod so the statefulset controller recreates it on
+// the update revision and records the restart as in progress.
+func (r *PinotReconciler) deleteServerPod(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+	pod *v1.Pod,
+	revision string,
+	instance string,
+) error {
+
+	if err := r.Client.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerRestartFail, err.Error())
+		return err
+	}
+
+	r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerPodDeleted,
+		fmt.Sprintf("Deleted pod [%s] to restart it on revision [%s]", pod.Name, revision))
+
+	state := &v1beta1.RollingRestartStatus{
+		NodeGroup: nodeSpec.Name,
+		Pod:       pod.Name,
+		Instance:  instance,
+		Phase:     v1beta1.RollingRestartRestarting,
+		Revision:  revision,
+		StartTime: &metav1.Time{Time: time.Now()},
+	}
+	if prev := pt.Status.RollingRestart; prev != nil && prev.Pod == pod.Name {
+		state.StartTime = prev.StartTime
+	}
+
+	return r.setRollingRestartStatus(ctx, pt, state)
+}
+
+// setRollingRestartStatus records the rolling restart state, it is also set
+// on pt for the rest of the reconcile.
+func (r *PinotReconciler) setRollingRestartStatus(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	state *v1beta1.RollingRestartStatus,
+) error {
+
+	if _, _, err := utils.PatchStatus(ctx, r.Client, pt, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.Pinot)
+		in.Status.RollingRestart = state
+		return in
+	}); err != nil {
+		r.Recorder.Event(pt, v1.EventTypeWarning, PinotStatusUpdateFail, err.Error())
+		return err
+	}
+
+	pt.Status.RollingRestart = state
+	return nil
+}
+
+func (r *PinotReconciler) getStatefulSet(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+) (*appsv1.StatefulSet, error) {
+
+	sts := &appsv1.StatefulSet{}
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Namespace: pt.Namespace,
+		Name:      makeStsOrDeployName(nodeSpec.Name, nodeSpec.K8sConfig),
+	}, sts); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return sts, nil
+}
+
+// getStatefulSetPods returns the pods of a statefulset, highest ordinal first
+// like the statefulset controller rolls them.
+func (r *PinotReconciler) getStatefulSetPods(ctx context.Context, sts *appsv1.StatefulSet) ([]v1.Pod, error) {
+	podList := &v1.PodList{}
+	if err := r.Client.List(ctx, podList,
+		client.InNamespace(sts.Namespace),
+		client.MatchingLabels(sts.Spec.Selector.MatchLabels),
+	); err != nil {
+		return nil, err
+	}
+
+	pods := []v1.Pod{}
+	for _, pod := range podList.Items {
+		if metav1.IsControlledBy(&pod, sts) {
+			pods = append(pods, pod)
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return getPodOrdinal(pods[i].Name) > getPodOrdinal(pods[j].Name)
+	})
+
+	return pods, nil
+}
+
+func getPodOrdinal(podName string) int {
+	ordinal, err := strconv.Atoi(podName[strings.LastIndex(podName, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+func isPodReady(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	testCurrentRevision = "server-sts-1"
+	testUpdateRevision  = "server-sts-2"
+)
+
+func TestIsGracefulRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeType v1beta1.PinotNodeType
+		kind     string
+		want     bool
+	}{
+		{"server statefulset", v1beta1.Server, v1beta1.StatefulSetKind, true},
+		{"server deployment", v1beta1.Server, v1beta1.DeploymentKind, false},
+		{"broker statefulset", v1beta1.Broker, v1beta1.StatefulSetKind, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isGracefulRestart(&v1beta1.NodeSpec{NodeType: tt.nodeType, Kind: tt.kind}); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetPodOrdinal(t *testing.T) {
+	tests := []struct {
+		podName string
+		want    int
+	}{
+		{"server-sts-0", 0},
+		{"server-sts-12", 12},
+		{"server", -1},
+		{"server-sts-", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.podName, func(t *testing.T) {
+			if got := getPodOrdinal(tt.podName); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsPodReady(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name   string
+		status v1.ConditionStatus
+		delete bool
+		want   bool
+	}{
+		{"ready", v1.ConditionTrue, false, true},
+		{"not ready", v1.ConditionFalse, false, false},
+		{"no ready condition", "", false, false},
+		{"terminating", v1.ConditionTrue, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{}
+			if tt.status != "" {
+				pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: tt.status}}
+			}
+			if tt.delete {
+				pod.DeletionTimestamp = &now
+			}
+			if got := isPodReady(pod); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testServerStatefulSet(replicas int32, observed bool) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "server-sts", Namespace: "default", UID: "server-sts-uid", Generation: 2},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "server"}},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 1,
+			CurrentRevision:    testCurrentRevision,
+			UpdateRevision:     testUpdateRevision,
+		},
+	}
+	if observed {
+		sts.Status.ObservedGeneration = 2
+	}
+	return sts
+}
+
+func testServerPod(sts *appsv1.StatefulSet, ordinal int, revision string, ready bool) *v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", sts.Name, ordinal),
+			Namespace: sts.Namespace,
+			Labels: map[string]string{
+				"app":                                 "server",
+				appsv1.ControllerRevisionHashLabelKey: revision,
+			},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(sts, appsv1.SchemeGroupVersion.WithKind("StatefulSet"))},
+		},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}}},
+	}
+}
+
+func TestGetStatefulSetPods(t *testing.T) {
+	sts := testServerStatefulSet(3, true)
+	pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "default"}}
+	r := newTestReconciler(t, pt)
+
+	orphan := testServerPod(sts, 3, testCurrentRevision, true)
+	orphan.OwnerReferences = nil
+	for _, obj := range []client.Object{
+		sts,
+		testServerPod(sts, 0, testCurrentRevision, true),
+		testServerPod(sts, 10, testCurrentRevision, true),
+		testServerPod(sts, 2, testCurrentRevision, true),
+		orphan,
+	} {
+		if err := r.Create(context.Background(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pods, err := r.getStatefulSetPods(context.Background(), sts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	if want := []string{"server-sts-10", "server-sts-2", "server-sts-0"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected pods %v, got %v", want, names)
+	}
+}
+
+// TestStartRestart covers the restarts decided before the pinot controller is
+// called, a pod that is outdated and not ready is deleted right away.
+func TestStartRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		observed    bool
+		pods        func(sts *appsv1.StatefulSet) []*v1.Pod
+		wantStarted bool
+		wantDeleted string
+	}{
+		{
+			name:     "statefulset not observed",
+			observed: false,
+			pods: func(sts *appsv1.StatefulSet) []*v1.Pod {
+				return []*v1.Pod{testServerPod(sts, 0, testCurrentRevision, false)}
+			},
+		},
+		{
+			name:     "pods on the update revision",
+			observed: true,
+			pods: func(sts *appsv1.StatefulSet) []*v1.Pod {
+				return []*v1.Pod{
+					testServerPod(sts, 0, testUpdateRevision, true),
+					testServerPod(sts, 1, testUpdateRevision, false),
+				}
+			},
+		},
+		{
+			name:     "outdated pod not ready",
+			observed: true,
+			pods: func(sts *appsv1.StatefulSet) []*v1.Pod {
+				return []*v1.Pod{
+					testServerPod(sts, 0, testCurrentRevision, true),
+					testServerPod(sts, 1, testCurrentRevision, false),
+				}
+			},
+			wantStarted: true,
+			wantDeleted: "server-sts-1",
+		},
+		{
+			name:     "another pod unavailable",
+			observed: true,
+			pods: func(sts *appsv1.StatefulSet) []*v1.Pod {
+				return []*v1.Pod{
+					testServerPod(sts, 0, testCurrentRevision, true),
+					testServerPod(sts, 1, testUpdateRevision, false),
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "default"}}
+			r := newTestReconciler(t, pt)
+
+			sts := testServerStatefulSet(2, tt.observed)
+			if err := r.Create(ctx, sts); err != nil {
+				t.Fatal(err)
+			}
+			pods := tt.pods(sts)
+			for _, pod := range pods {
+				if err := r.Create(ctx, pod); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			started, err := r.startRestart(ctx, pt, testServerNodeSpec(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if started != tt.wantStarted {
+				t.Errorf("expected started %v, got %v", tt.wantStarted, started)
+			}
+
+			for _, pod := range pods {
+				err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &v1.Pod{})
+				if deleted := errors.IsNotFound(err); deleted != (pod.Name == tt.wantDeleted) {
+					t.Errorf("pod %s: expected deleted %v, got %v", pod.Name, pod.Name == tt.wantDeleted, deleted)
+				}
+			}
+
+			state := pt.Status.RollingRestart
+			if tt.wantDeleted == "" {
+				if state != nil {
+					t.Errorf("expected no rolling restart, got %+v", state)
+				}
+				return
+			}
+			if state == nil || state.Phase != v1beta1.RollingRestartRestarting || state.Pod != tt.wantDeleted || state.Revision != testUpdateRevision {
+				t.Errorf("expected pod %s restarting on %s, got %+v", tt.wantDeleted, testUpdateRevision, state)
+			}
+		})
+	}
+}
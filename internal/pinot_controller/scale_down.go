@@ -0,0 +1,446 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	PinotServerScaleDownStarted   = "ServerScaleDownStarted"
+	PinotServerScaleDownBlocked   = "ServerScaleDownBlocked"
+	PinotServerScaleDownShrinking = "ServerScaleDownShrinking"
+	PinotServerScaleDownCompleted = "ServerScaleDownCompleted"
+	PinotServerScaleDownCancelled = "ServerScaleDownCancelled"
+	PinotServerScaleDownFail      = "ServerScaleDownFail"
+)
+
+// tables are rebalanced without downtime, consuming segments move along
+var scaleDownRebalanceOptions = pinot.RebalanceOptions{
+	ReassignInstances:    true,
+	IncludeConsuming:     true,
+	MinAvailableReplicas: 1,
+}
+
+// getServerReplicas returns the replicas a server statefulset is reconciled
+// with. A scale down keeps the current replicas until the segments of the
+// removed instances have been rebalanced onto the remaining servers. Replicas
+// raised back to where the scale down started from are kept.
+func getServerReplicas(pt *v1beta1.Pinot, nodeSpec *v1beta1.NodeSpec) int32 {
+	if state := pt.Status.ScaleDown; state != nil && state.NodeGroup == nodeSpec.Name &&
+		int32(nodeSpec.Replicas) < state.FromReplicas {
+		switch state.Phase {
+		case v1beta1.ScaleDownBlocked, v1beta1.ScaleDownRebalancing:
+			return state.FromReplicas
+		default:
+			return state.ToReplicas
+		}
+	}
+	return int32(nodeSpec.Replicas)
+}
+
+// scaleDownServers moves a server scale down one step forward. A scale down
+// is detected when a server statefulset has more replicas than its nodeSpec,
+// only one node group is scaled down at a time and never during a restart.
+func (r *PinotReconciler) scaleDownServers(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpecs []NodeTypeNodeSpec,
+) error {
+
+	if state := pt.Status.ScaleDown; state != nil {
+		for _, nodeSpec := range nodeSpecs {
+			if nodeSpec.NodeSpec.Name == state.NodeGroup && isGracefulRestart(&nodeSpec.NodeSpec) {
+				return r.continueScaleDown(ctx, pt, &nodeSpec.NodeSpec, state.DeepCopy())
+			}
+		}
+		// node group has been removed from the spec, nothing left to scale down
+		return r.setScaleDownStatus(ctx, pt, nil)
+	}
+
+	if pt.Status.RollingRestart != nil {
+		return nil
+	}
+
+	for _, nodeSpec := range nodeSpecs {
+		if !isGracefulRestart(&nodeSpec.NodeSpec) {
+			continue
+		}
+
+		sts, err := r.getStatefulSet(ctx, pt, &nodeSpec.NodeSpec)
+		if err != nil {
+			return err
+		}
+		if sts == nil || sts.Spec.Replicas == nil || *sts.Spec.Replicas <= int32(nodeSpec.NodeSpec.Replicas) {
+			continue
+		}
+
+		pc, err := r.getPinotClient(ctx, pt)
+		if err != nil {
+			return err
+		}
+		return r.startScaleDown(ctx, pt, pc, &nodeSpec.NodeSpec, *sts.Spec.Replicas)
+	}
+
+	return nil
+}
+
+// startScaleDown checks that the servers left after the scale down can meet
+// the replication of every table hosted on the removed instances. If they can,
+// the removed instances are untagged and the tables are rebalanced off them,
+// otherwise the scale down is recorded as blocked.
+func (r *PinotReconciler) startScaleDown(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	pc *pinot.Client,
+	nodeSpec *v1beta1.NodeSpec,
+	fromReplicas int32,
+) error {
+
+	servers, err := pc.ListServerInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the statefulset removes the highest ordinals
+	removed := []string{}
+	stsName := makeStsOrDeployName(nodeSpec.Name, nodeSpec.K8sConfig)
+	for ordinal := int32(nodeSpec.Replicas); ordinal < fromReplicas; ordinal++ {
+		pod := &v1.Pod{}
+		podName := fmt.Sprintf("%s-%d", stsName, ordinal)
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pt.Namespace, Name: podName}, pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if instance := matchServerInstance(servers, podName, pod.Status.PodIP); instance != "" {
+			removed = append(removed, instance)
+		}
+	}
+
+	state := &v1beta1.ScaleDownStatus{
+		NodeGroup:    nodeSpec.Name,
+		FromReplicas: fromReplicas,
+		ToReplicas:   int32(nodeSpec.Replicas),
+		Instances:    removed,
+		Phase:        v1beta1.ScaleDownRebalancing,
+		StartTime:    &metav1.Time{Time: time.Now()},
+	}
+	if prev := pt.Status.ScaleDown; prev != nil && prev.NodeGroup == state.NodeGroup {
+		state.StartTime = prev.StartTime
+	}
+
+	tables, tableTypes, unsatisfied, err := getScaleDownTables(ctx, pc, servers, removed)
+	if err != nil {
+		return err
+	}
+	state.Tables = tables
+
+	if len(unsatisfied) > 0 {
+		state.Phase = v1beta1.ScaleDownBlocked
+		state.Message = strings.Join(unsatisfied, ", ")
+		if prev := pt.Status.ScaleDown; prev == nil || prev.Message != state.Message {
+			r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerScaleDownBlocked,
+				fmt.Sprintf("Scale down of [%s] to [%d] replicas blocked: %s", nodeSpec.Name, nodeSpec.Replicas, state.Message))
+		}
+		return r.setScaleDownStatus(ctx, pt, state)
+	}
+
+	// untagged instances are not part of any tenant, a rebalance moves their
+	// segments away, their tags are kept to tag them again on a cancel
+	state.InstanceTags = map[string][]string{}
+	for _, instance := range removed {
+		config, err := pc.GetInstance(ctx, instance)
+		if err != nil {
+			return err
+		}
+		state.InstanceTags[instance] = config.Tags
+	}
+	for _, instance := range removed {
+		if err := pc.UpdateInstanceTags(ctx, instance, []string{}); err != nil {
+			r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerScaleDownFail, err.Error())
+			return err
+		}
+	}
+
+	for _, table := range tables {
+		for _, tableType := range tableTypes[table] {
+			if _, err := pc.RebalanceTable(ctx, table, tableType, scaleDownRebalanceOptions); err != nil {
+				r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerScaleDownFail, err.Error())
+				return err
+			}
+		}
+	}
+
+	r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerScaleDownStarted,
+		fmt.Sprintf("Rebalancing tables [%s] off instances [%s] to scale down [%s] to [%d] replicas",
+			strings.Join(tables, ","), strings.Join(removed, ","), nodeSpec.Name, nodeSpec.Replicas))
+
+	return r.setScaleDownStatus(ctx, pt, state)
+}
+
+// continueScaleDown checks whether the current phase of the scale down is
+// complete and moves it to the next one. A scale down whose replicas were
+// raised back is cancelled.
+func (r *PinotReconciler) continueScaleDown(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+	state *v1beta1.ScaleDownStatus,
+) error {
+
+	if state.Phase != v1beta1.ScaleDownBlocked && int32(nodeSpec.Replicas) >= state.FromReplicas {
+		pc, err := r.getPinotClient(ctx, pt)
+		if err != nil {
+			return err
+		}
+		return r.cancelScaleDown(ctx, pt, pc, state)
+	}
+
+	switch state.Phase {
+	case v1beta1.ScaleDownBlocked:
+		// nothing has been changed in the cluster yet, the scale down is re-evaluated
+		// against the current spec so that it can be unblocked or cancelled.
+		if int32(nodeSpec.Replicas) != state.ToReplicas {
+			return r.setScaleDownStatus(ctx, pt, nil)
+		}
+		pc, err := r.getPinotClient(ctx, pt)
+		if err != nil {
+			return err
+		}
+		return r.startScaleDown(ctx, pt, pc, nodeSpec, state.FromReplicas)
+
+	case v1beta1.ScaleDownRebalancing:
+		pc, err := r.getPinotClient(ctx, pt)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range state.Tables {
+			converged, err := isTableConverged(ctx, pc, table, state.Instances)
+			if err != nil || !converged {
+				return err
+			}
+		}
+
+		r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerScaleDownShrinking,
+			fmt.Sprintf("Tables rebalanced, scaling down [%s] to [%d] replicas", state.NodeGroup, state.ToReplicas))
+
+		state.Phase = v1beta1.ScaleDownShrinking
+		return r.setScaleDownStatus(ctx, pt, state)
+
+	case v1beta1.ScaleDownShrinking:
+		sts, err := r.getStatefulSet(ctx, pt, nodeSpec)
+		if err != nil {
+			return err
+		}
+		if sts != nil && sts.Status.Replicas > state.ToReplicas {
+			return nil
+		}
+
+		state.Phase = v1beta1.ScaleDownDroppingInstances
+		return r.setScaleDownStatus(ctx, pt, state)
+
+	case v1beta1.ScaleDownDroppingInstances:
+		pc, err := r.getPinotClient(ctx, pt)
+		if err != nil {
+			return err
+		}
+
+		remaining := []string{}
+		for _, instance := range state.Instances {
+			// pinot refuses to drop an instance while it is still live
+			if err := pc.DropInstance(ctx, instance); pinot.IsConflict(err) {
+				remaining = append(remaining, instance)
+			} else if err != nil {
+				r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerScaleDownFail, err.Error())
+				return err
+			}
+		}
+
+		if len(remaining) > 0 {
+			if len(remaining) == len(state.Instances) {
+				return nil
+			}
+			state.Instances = remaining
+			return r.setScaleDownStatus(ctx, pt, state)
+		}
+
+		r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerScaleDownCompleted,
+			fmt.Sprintf("Scaled down [%s] to [%d] replicas", state.NodeGroup, state.ToReplicas))
+		return r.setScaleDownStatus(ctx, pt, nil)
+	}
+
+	return fmt.Errorf("unknown scale down phase [%s]", state.Phase)
+}
+
+// cancelScaleDown tags the removed instances again with the tags they had
+// before the scale down and clears it. Instances already dropped register
+// again when their pods come back.
+func (r *PinotReconciler) cancelScaleDown(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	pc *pinot.Client,
+	state *v1beta1.ScaleDownStatus,
+) error {
+
+	for _, instance := range state.Instances {
+		tags, ok := state.InstanceTags[instance]
+		if !ok {
+			continue
+		}
+		if err := pc.UpdateInstanceTags(ctx, instance, tags); err != nil && !pinot.IsNotFound(err) {
+			r.Recorder.Event(pt, v1.EventTypeWarning, PinotServerScaleDownFail, err.Error())
+			return err
+		}
+	}
+
+	r.Recorder.Event(pt, v1.EventTypeNormal, PinotServerScaleDownCancelled,
+		fmt.Sprintf("Scale down of [%s] to [%d] replicas cancelled, instances [%s] tagged again",
+			state.NodeGroup, state.ToReplicas, strings.Join(state.Instances, ",")))
+	return r.setScaleDownStatus(ctx, pt, nil)
+}
+
+// getScaleDownTables returns the tables with segments on the removed instances
+// and their table types. For each table type whose server tag would be left
+// with fewer servers than the table replication a message is returned.
+func getScaleDownTables(
+	ctx context.Context,
+	pc *pinot.Client,
+	servers, removed []string,
+) ([]string, map[string][]string, []string, error) {
+
+	isRemoved := map[string]bool{}
+	for _, instance := range removed {
+		isRemoved[instance] = true
+	}
+
+	// servers left per tag, tags are read lazily since most clusters use a single tenant
+	var remainingPerTag map[string]int
+	getRemaining := func(tag string) (int, error) {
+		if remainingPerTag == nil {
+			remainingPerTag = map[string]int{}
+			for _, server := range servers {
+				if isRemoved[server] {
+					continue
+				}
+				instance, err := pc.GetInstance(ctx, server)
+				if err != nil {
+					return 0, err
+				}
+				for _, t := range instance.Tags {
+					remainingPerTag[t]++
+				}
+			}
+		}
+		return remainingPerTag[tag], nil
+	}
+
+	allTables, err := pc.ListTables(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tables := []string{}
+	tableTypes := map[string][]string{}
+	unsatisfied := []string{}
+
+	for _, table := range allTables {
+		idealState, err := pc.GetIdealState(ctx, table)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var configs map[string]pinot.TableConfig
+		for tableType, segments := range idealState {
+			if !hasInstanceSegments(segments, isRemoved) {
+				continue
+			}
+
+			if configs == nil {
+				if configs, err = pc.GetTableConfigs(ctx, table); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			config := configs[tableType]
+
+			remaining, err := getRemaining(config.ServerTag(tableType))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if remaining < config.Replicas(tableType) {
+				unsatisfied = append(unsatisfied, fmt.Sprintf(
+					"table [%s_%s] needs [%d] servers tagged [%s], [%d] would remain",
+					table, tableType, config.Replicas(tableType), config.ServerTag(tableType), remaining,
+				))
+			}
+
+			if len(tableTypes[table]) == 0 {
+				tables = append(tables, table)
+			}
+			tableTypes[table] = append(tableTypes[table], tableType)
+		}
+	}
+
+	sort.Strings(tables)
+	sort.Strings(unsatisfied)
+	return tables, tableTypes, unsatisfied, nil
+}
+
+func hasInstanceSegments(segments map[string]map[string]string, instances map[string]bool) bool {
+	for _, segmentInstances := range segments {
+		for instance := range segmentInstances {
+			if instances[instance] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setScaleDownStatus records the scale down state. The state is also set on
+// pt, the statefulsets reconciled after it in the same reconcile read their
+// replicas from it.
+func (r *PinotReconciler) setScaleDownStatus(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	state *v1beta1.ScaleDownStatus,
+) error {
+
+	if _, _, err := utils.PatchStatus(ctx, r.Client, pt, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.Pinot)
+		in.Status.ScaleDown = state
+		return in
+	}); err != nil {
+		r.Recorder.Event(pt, v1.EventTypeWarning, PinotStatusUpdateFail, err.Error())
+		return err
+	}
+
+	pt.Status.ScaleDown = state
+	return nil
+}
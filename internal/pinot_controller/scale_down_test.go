@@ -0,0 +1,243 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testServer0 = "Server_server-sts-0_8098"
+	testServer1 = "Server_server-sts-1_8098"
+	testServer2 = "Server_server-sts-2_8098"
+)
+
+func testServerNodeSpec(replicas int) *v1beta1.NodeSpec {
+	return &v1beta1.NodeSpec{
+		Name:            "server",
+		Kind:            v1beta1.StatefulSetKind,
+		NodeType:        v1beta1.Server,
+		Replicas:        replicas,
+		K8sConfig:       "sts",
+		PinotNodeConfig: "config",
+	}
+}
+
+// newTestReconciler returns a reconciler backed by a fake client holding pt.
+func newTestReconciler(t *testing.T, pt *v1beta1.Pinot) *PinotReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &PinotReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pt).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+// scaleDownRoutes serves three servers tagged for the default tenant and two
+// tables, airlineStats with the given replication.
+func scaleDownRoutes(replication string) map[string]string {
+	tags := `{"tags":["DefaultTenant_OFFLINE"]}`
+	return map[string]string{
+		"GET /instances":                      `{"instances":["Controller_c_9000","` + testServer0 + `","` + testServer1 + `","` + testServer2 + `"]}`,
+		"GET /instances/" + testServer0:       tags,
+		"GET /instances/" + testServer1:       tags,
+		"GET /instances/" + testServer2:       tags,
+		"GET /tables":                         `{"tables":["airlineStats","events"]}`,
+		"GET /tables/airlineStats/idealstate": `{"OFFLINE":{"seg0":{"` + testServer0 + `":"ONLINE","` + testServer2 + `":"ONLINE"}}}`,
+		"GET /tables/airlineStats":            `{"OFFLINE":{"tableName":"airlineStats_OFFLINE","segmentsConfig":{"replication":"` + replication + `"}}}`,
+		"GET /tables/events/idealstate":       `{"OFFLINE":{"seg0":{"` + testServer0 + `":"ONLINE"}}}`,
+		"GET /tables/events":                  `{"OFFLINE":{"tableName":"events_OFFLINE","segmentsConfig":{"replication":"1"}}}`,
+	}
+}
+
+func TestGetServerReplicas(t *testing.T) {
+	tests := []struct {
+		name      string
+		scaleDown *v1beta1.ScaleDownStatus
+		want      int32
+	}{
+		{"no scale down", nil, 2},
+		{"blocked", &v1beta1.ScaleDownStatus{NodeGroup: "server", FromReplicas: 3, ToReplicas: 2, Phase: v1beta1.ScaleDownBlocked}, 3},
+		{"rebalancing", &v1beta1.ScaleDownStatus{NodeGroup: "server", FromReplicas: 3, ToReplicas: 2, Phase: v1beta1.ScaleDownRebalancing}, 3},
+		{"shrinking", &v1beta1.ScaleDownStatus{NodeGroup: "server", FromReplicas: 3, ToReplicas: 2, Phase: v1beta1.ScaleDownShrinking}, 2},
+		{"dropping instances", &v1beta1.ScaleDownStatus{NodeGroup: "server", FromReplicas: 3, ToReplicas: 2, Phase: v1beta1.ScaleDownDroppingInstances}, 2},
+		{"other node group", &v1beta1.ScaleDownStatus{NodeGroup: "server-b", FromReplicas: 5, ToReplicas: 4, Phase: v1beta1.ScaleDownBlocked}, 2},
+		{"replicas raised back", &v1beta1.ScaleDownStatus{NodeGroup: "server", FromReplicas: 2, ToReplicas: 1, Phase: v1beta1.ScaleDownShrinking}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := &v1beta1.Pinot{Status: v1beta1.PinotStatus{ScaleDown: tt.scaleDown}}
+			if got := getServerReplicas(pt, testServerNodeSpec(2)); got != tt.want {
+				t.Errorf("expected %d replicas, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetScaleDownTables(t *testing.T) {
+	tests := []struct {
+		name            string
+		replication     string
+		removed         []string
+		wantTables      []string
+		wantUnsatisfied int
+	}{
+		{"replication met", "1", []string{testServer2}, []string{"airlineStats"}, 0},
+		{"replication not met", "3", []string{testServer2}, []string{"airlineStats"}, 1},
+		{"tables of every removed instance", "1", []string{testServer0}, []string{"airlineStats", "events"}, 0},
+		{"no segments on removed instances", "3", []string{testServer1}, []string{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, _ := newTestPinotClient(t, scaleDownRoutes(tt.replication))
+			tables, tableTypes, unsatisfied, err := getScaleDownTables(
+				context.Background(), pc, []string{testServer0, testServer1, testServer2}, tt.removed,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tables, tt.wantTables) {
+				t.Errorf("expected tables %v, got %v", tt.wantTables, tables)
+			}
+			for _, table := range tables {
+				if !reflect.DeepEqual(tableTypes[table], []string{"OFFLINE"}) {
+					t.Errorf("expected OFFLINE table type for %s, got %v", table, tableTypes[table])
+				}
+			}
+			if len(unsatisfied) != tt.wantUnsatisfied {
+				t.Errorf("expected %d unsatisfied tables, got %v", tt.wantUnsatisfied, unsatisfied)
+			}
+		})
+	}
+}
+
+// TestStartScaleDown checks that the statefulset keeps its replicas in the
+// reconcile starting a scale down, whether it is blocked or rebalancing.
+func TestStartScaleDown(t *testing.T) {
+	tests := []struct {
+		name        string
+		replication string
+		wantPhase   v1beta1.ScaleDownPhase
+		wantRequest string
+	}{
+		{"blocked", "3", v1beta1.ScaleDownBlocked, ""},
+		{"rebalancing", "1", v1beta1.ScaleDownRebalancing, "PUT /instances/" + testServer2 + "/updateTags?tags=&updateBrokerResource=false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := scaleDownRoutes(tt.replication)
+			routes["PUT /instances/"+testServer2+"/updateTags?tags=&updateBrokerResource=false"] = `{"status":"updated"}`
+			routes["POST /tables/airlineStats/rebalance?bootstrap=false&downtime=false&dryRun=false&includeConsuming=true&minAvailableReplicas=1&reassignInstances=true&type=OFFLINE"] = `{"jobId":"job-1","status":"IN_PROGRESS"}`
+			pc, tc := newTestPinotClient(t, routes)
+
+			pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "default"}}
+			r := newTestReconciler(t, pt)
+			nodeSpec := testServerNodeSpec(2)
+
+			if err := r.startScaleDown(context.Background(), pt, pc, nodeSpec, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			state := pt.Status.ScaleDown
+			if state == nil || state.Phase != tt.wantPhase {
+				t.Fatalf("expected a %s scale down on pt, got %+v", tt.wantPhase, state)
+			}
+			if !reflect.DeepEqual(state.Instances, []string{testServer2}) {
+				t.Errorf("expected instance %s to be removed, got %v", testServer2, state.Instances)
+			}
+			if tt.wantPhase == v1beta1.ScaleDownRebalancing && !reflect.DeepEqual(state.InstanceTags[testServer2], []string{"DefaultTenant_OFFLINE"}) {
+				t.Errorf("expected the tags of %s to be kept, got %v", testServer2, state.InstanceTags)
+			}
+			if got := getServerReplicas(pt, nodeSpec); got != 3 {
+				t.Errorf("expected the statefulset to keep 3 replicas, got %d", got)
+			}
+			if tt.wantRequest != "" && !tc.received(tt.wantRequest) {
+				t.Errorf("expected request %s", tt.wantRequest)
+			}
+
+			stored := &v1beta1.Pinot{}
+			if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "pinot"}, stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status.ScaleDown == nil || stored.Status.ScaleDown.Phase != tt.wantPhase {
+				t.Errorf("expected a %s scale down in status, got %+v", tt.wantPhase, stored.Status.ScaleDown)
+			}
+		})
+	}
+}
+
+// TestCancelScaleDown checks that the removed instances get their tags back
+// once the replicas are raised back, instances already dropped are skipped.
+func TestCancelScaleDown(t *testing.T) {
+	retag := "PUT /instances/" + testServer2 + "/updateTags?tags=DefaultTenant_OFFLINE&updateBrokerResource=false"
+	pc, tc := newTestPinotClient(t, map[string]string{retag: `{"status":"updated"}`})
+
+	state := &v1beta1.ScaleDownStatus{
+		NodeGroup:    "server",
+		FromReplicas: 3,
+		ToReplicas:   1,
+		Instances:    []string{testServer1, testServer2},
+		InstanceTags: map[string][]string{
+			testServer1: {"DefaultTenant_OFFLINE"},
+			testServer2: {"DefaultTenant_OFFLINE"},
+		},
+		Phase: v1beta1.ScaleDownRebalancing,
+	}
+	pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "default"}}
+	pt.Status.ScaleDown = state
+	r := newTestReconciler(t, pt)
+
+	if err := r.cancelScaleDown(context.Background(), pt, pc, state); err != nil {
+		t.Fatal(err)
+	}
+	if !tc.received(retag) {
+		t.Errorf("expected request %s", retag)
+	}
+	if pt.Status.ScaleDown != nil {
+		t.Errorf("expected no scale down on pt, got %+v", pt.Status.ScaleDown)
+	}
+	if got := getServerReplicas(pt, testServerNodeSpec(3)); got != 3 {
+		t.Errorf("expected the statefulset to keep 3 replicas, got %d", got)
+	}
+
+	stored := &v1beta1.Pinot{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "pinot"}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.ScaleDown != nil {
+		t.Errorf("expected no scale down in status, got %+v", stored.Status.ScaleDown)
+	}
+}
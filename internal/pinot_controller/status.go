@@ -0,0 +1,280 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	PinotNodeGroupsReady       = "NodeGroupsReady"
+	PinotNodeGroupsNotReady    = "NodeGroupsNotReady"
+	PinotNodeGroupsRollingOut  = "NodeGroupsRollingOut"
+	PinotNodeGroupsRolledOut   = "NodeGroupsRolledOut"
+	PinotReconcileSuccess      = "ReconcileSuccess"
+	PinotReconcileError        = "ReconcileError"
+	PinotRolloutDeadline       = "RolloutDeadlineExceeded"
+	PinotStatusUpdateFail      = "PinotStatusUpdateFail"
+	PinotScaleDownNotBlocked   = "ScaleDownNotBlocked"
+	PinotReplicationNotMet     = "ReplicationNotMet"
+	PinotControllerReachable   = "ControllerReachable"
+	PinotControllerUnreachable = "ControllerUnreachable"
+)
+
+// getNodeGroupStatus reads the deployment or statefulset backing a nodeSpec
+// and returns its observed state. A missing object is reported with zero
+// ready replicas rather than as an error.
+func (r *PinotReconciler) getNodeGroupStatus(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	nodeSpec *v1beta1.NodeSpec,
+) (v1beta1.NodeGroupStatus, error) {
+
+	status := v1beta1.NodeGroupStatus{
+		Name:            nodeSpec.Name,
+		NodeType:        nodeSpec.NodeType,
+		Kind:            nodeSpec.Kind,
+		DesiredReplicas: int32(nodeSpec.Replicas),
+	}
+	// a server scale down holds the statefulset replicas until the tables
+	// are rebalanced off the removed instances
+	if isGracefulRestart(nodeSpec) {
+		status.DesiredReplicas = getServerReplicas(pt, nodeSpec)
+	}
+
+	key := types.NamespacedName{
+		Namespace: pt.Namespace,
+		Name:      makeStsOrDeployName(nodeSpec.Name, nodeSpec.K8sConfig),
+	}
+
+	var podSpec v1.PodSpec
+
+	switch nodeSpec.Kind {
+	case v1beta1.StatefulSetKind:
+		sts := appsv1.StatefulSet{}
+		if err := r.Client.Get(ctx, key, &sts); err != nil {
+			if errors.IsNotFound(err) {
+				return status, nil
+			}
+			return status, err
+		}
+		podSpec = sts.Spec.Template.Spec
+		status.ReadyReplicas = sts.Status.ReadyReplicas
+		status.UpdatedReplicas = sts.Status.UpdatedReplicas
+		// the current revision is never advanced for the OnDelete strategy,
+		// all replicas being on the update revision is checked instead.
+		status.Ready = sts.Status.ObservedGeneration >= sts.Generation &&
+			sts.Status.UpdatedReplicas == status.DesiredReplicas &&
+			sts.Status.ReadyReplicas == status.DesiredReplicas
+	case v1beta1.DeploymentKind:
+		deploy := appsv1.Deployment{}
+		if err := r.Client.Get(ctx, key, &deploy); err != nil {
+			if errors.IsNotFound(err) {
+				return status, nil
+			}
+			return status, err
+		}
+		podSpec = deploy.Spec.Template.Spec
+		status.ReadyReplicas = deploy.Status.ReadyReplicas
+		status.UpdatedReplicas = deploy.Status.UpdatedReplicas
+		status.Ready = deploy.Status.ObservedGeneration >= deploy.Generation &&
+			deploy.Status.UpdatedReplicas == status.DesiredReplicas &&
+			deploy.Status.ReadyReplicas == status.DesiredReplicas
+	default:
+		return status, fmt.Errorf("unsupported kind [%s] for node [%s]", nodeSpec.Kind, nodeSpec.Name)
+	}
+
+	// the image and the config hash are read from the pinot container, other
+	// containers added to the pod template are not part of the rollout
+	configMapName := makeConfigMapName(pt.Name, nodeSpec.PinotNodeConfig)
+	for _, container := range podSpec.Containers {
+		if container.Name != makeContainerName(nodeSpec) {
+			continue
+		}
+		status.Image = container.Image
+		for _, env := range container.Env {
+			if env.Name == configMapName {
+				status.ConfigHash = env.Value
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// updatePinotStatus computes the per node group status and the Ready, Progressing
+// and Degraded conditions and patches them on the Pinot CR. reconcileErr is the
+// error returned by the last reconcile, if any.
+func (r *PinotReconciler) updatePinotStatus(ctx context.Context, pt *v1beta1.Pinot, reconcileErr error) error {
+
+	previous := map[string]v1beta1.NodeGroupStatus{}
+	for _, nodeGroup := range pt.Status.NodeGroups {
+		previous[nodeGroup.Name] = nodeGroup
+	}
+
+	now := metav1.Time{Time: time.Now()}
+
+	nodeGroups := []v1beta1.NodeGroupStatus{}
+	notReady := []string{}
+	rollingOut := []string{}
+
+	// node groups are iterated in deployment order, the first node type with a
+	// group that is not ready is the one the ordered rollout is waiting on.
+	var rollout *v1beta1.RolloutStatus
+
+	zookeeper, err := r.getZookeeperStatus(ctx, pt)
+	if err != nil {
+		return err
+	}
+	if zookeeper != nil && !zookeeper.Healthy {
+		notReady = append(notReady, makeZookeeperName(pt.Name))
+	}
+
+	for _, nodeSpec := range getAllNodeSpecForNodeType(pt) {
+		nodeGroup, err := r.getNodeGroupStatus(ctx, pt, &nodeSpec.NodeSpec)
+		if err != nil {
+			return err
+		}
+
+		// a change in image or config hash marks the start of a new rollout
+		if prev, ok := previous[nodeGroup.Name]; ok &&
+			prev.Image == nodeGroup.Image &&
+			prev.ConfigHash == nodeGroup.ConfigHash {
+			nodeGroup.LastRolloutTime = prev.LastRolloutTime
+		} else {
+			nodeGroup.LastRolloutTime = &now
+		}
+
+		if !nodeGroup.Ready {
+			notReady = append(notReady, nodeGroup.Name)
+			if rollout == nil {
+				rollout = &v1beta1.RolloutStatus{NodeType: nodeGroup.NodeType, StartTime: &now}
+				if prev := pt.Status.Rollout; prev != nil &&
+					prev.NodeType == nodeGroup.NodeType &&
+					pt.Status.ObservedGeneration == pt.Generation {
+					rollout.StartTime = prev.StartTime
+				}
+			}
+		}
+		if nodeGroup.UpdatedReplicas != nodeGroup.DesiredReplicas {
+			rollingOut = append(rollingOut, nodeGroup.Name)
+		}
+		nodeGroups = append(nodeGroups, nodeGroup)
+	}
+
+	readyCondition := metav1.Condition{
+		Type:               v1beta1.PinotReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pt.Generation,
+		Reason:             PinotNodeGroupsReady,
+		Message:            "All node groups are ready",
+	}
+	if len(notReady) > 0 {
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = PinotNodeGroupsNotReady
+		readyCondition.Message = fmt.Sprintf("Node groups not ready [%s]", strings.Join(notReady, ","))
+	}
+
+	progressingCondition := metav1.Condition{
+		Type:               v1beta1.PinotProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: pt.Generation,
+		Reason:             PinotNodeGroupsRolledOut,
+		Message:            "All node groups are on the desired revision",
+	}
+	if len(rollingOut) > 0 {
+		progressingCondition.Status = metav1.ConditionTrue
+		progressingCondition.Reason = PinotNodeGroupsRollingOut
+		progressingCondition.Message = fmt.Sprintf("Node groups rolling out [%s]", strings.Join(rollingOut, ","))
+	}
+
+	degradedCondition := metav1.Condition{
+		Type:               v1beta1.PinotDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: pt.Generation,
+		Reason:             PinotReconcileSuccess,
+		Message:            "Reconcile succeeded",
+	}
+	if reconcileErr != nil {
+		degradedCondition.Status = metav1.ConditionTrue
+		degradedCondition.Reason = PinotReconcileError
+		degradedCondition.Message = reconcileErr.Error()
+		if _, ok := reconcileErr.(*RolloutDeadlineExceededError); ok {
+			degradedCondition.Reason = PinotRolloutDeadline
+		}
+	}
+
+	scaleDownCondition := metav1.Condition{
+		Type:               v1beta1.PinotScaleDownBlocked,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: pt.Generation,
+		Reason:             PinotScaleDownNotBlocked,
+		Message:            "No server scale down is blocked",
+	}
+	if scaleDown := pt.Status.ScaleDown; scaleDown != nil && scaleDown.Phase == v1beta1.ScaleDownBlocked {
+		scaleDownCondition.Status = metav1.ConditionTrue
+		scaleDownCondition.Reason = PinotReplicationNotMet
+		scaleDownCondition.Message = fmt.Sprintf("Scale down of [%s] to [%d] replicas blocked: %s",
+			scaleDown.NodeGroup, scaleDown.ToReplicas, scaleDown.Message)
+	}
+
+	unreachableCondition := metav1.Condition{
+		Type:               v1beta1.PinotUnreachable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: pt.Generation,
+		Reason:             PinotControllerReachable,
+		Message:            "Pinot controller is reachable",
+	}
+	if svcName, err := r.getControllerSvcUrl(pt); err == nil && internalHTTP.IsCircuitOpen(svcName) {
+		unreachableCondition.Status = metav1.ConditionTrue
+		unreachableCondition.Reason = PinotControllerUnreachable
+		unreachableCondition.Message = fmt.Sprintf("Requests to pinot controller [%s] keep failing, backing off", svcName)
+	}
+
+	if _, _, err := utils.PatchStatus(ctx, r.Client, pt, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.Pinot)
+		in.Status.ObservedGeneration = pt.Generation
+		in.Status.NodeGroups = nodeGroups
+		in.Status.Rollout = rollout
+		in.Status.Zookeeper = zookeeper
+		meta.SetStatusCondition(&in.Status.Conditions, readyCondition)
+		meta.SetStatusCondition(&in.Status.Conditions, progressingCondition)
+		meta.SetStatusCondition(&in.Status.Conditions, degradedCondition)
+		meta.SetStatusCondition(&in.Status.Conditions, scaleDownCondition)
+		meta.SetStatusCondition(&in.Status.Conditions, unreachableCondition)
+		return in
+	}); err != nil {
+		r.Recorder.Event(pt, v1.EventTypeWarning, PinotStatusUpdateFail, err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetNodeGroupStatusImage(t *testing.T) {
+	nodeSpec := testServerNodeSpec(1)
+	pinotContainer := v1.Container{
+		Name:  makeContainerName(nodeSpec),
+		Image: "apachepinot/pinot:0.12.1",
+		Env:   []v1.EnvVar{{Name: makeConfigMapName("pinot", nodeSpec.PinotNodeConfig), Value: "hash"}},
+	}
+	sidecar := v1.Container{
+		Name:  "sidecar",
+		Image: "busybox:latest",
+		Env:   []v1.EnvVar{{Name: makeConfigMapName("pinot", nodeSpec.PinotNodeConfig), Value: "sidecar-hash"}},
+	}
+
+	tests := []struct {
+		name       string
+		containers []v1.Container
+	}{
+		{"pinot container only", []v1.Container{pinotContainer}},
+		{"sidecar after the pinot container", []v1.Container{pinotContainer, sidecar}},
+		{"sidecar before the pinot container", []v1.Container{sidecar, pinotContainer}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "pinot"}}
+			r := newTestReconciler(t, pt)
+
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      makeStsOrDeployName(nodeSpec.Name, nodeSpec.K8sConfig),
+					Namespace: pt.Namespace,
+				},
+				Spec: appsv1.StatefulSetSpec{
+					Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: tt.containers}},
+				},
+			}
+			if err := r.Create(context.Background(), sts); err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := r.getNodeGroupStatus(context.Background(), pt, nodeSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Image != pinotContainer.Image {
+				t.Errorf("expected image %s, got %s", pinotContainer.Image, status.Image)
+			}
+			if status.ConfigHash != "hash" {
+				t.Errorf("expected config hash hash, got %s", status.ConfigHash)
+			}
+		})
+	}
+}
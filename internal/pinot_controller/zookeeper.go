@@ -0,0 +1,290 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ZookeeperClientPort    = 2181
+	ZookeeperPeerPort      = 2888
+	ZookeeperElectionPort  = 3888
+	ZookeeperDataMountPath = "/data"
+	ZookeeperDataVolume    = "data"
+	defaultZookeeperImage  = "zookeeper:3.8.1"
+	defaultZookeeperSize   = 3
+)
+
+func isZookeeperManaged(pt *v1beta1.Pinot) bool {
+	return pt.Spec.External.Zookeeper.Managed != nil
+}
+
+func makeZookeeperName(pinotName string) string { return pinotName + "-zookeeper" }
+
+func makeZookeeperHeadlessSvcName(pinotName string) string {
+	return makeZookeeperName(pinotName) + "-headless"
+}
+
+// zookeeper pods are not labelled with custom_resource, deployments of the
+// cluster select their pods on it.
+func makeZookeeperLabels(pt *v1beta1.Pinot) map[string]string {
+	return map[string]string{
+		"app":           "zookeeper",
+		"pinot_cluster": pt.Name,
+	}
+}
+
+func getZookeeperReplicas(pt *v1beta1.Pinot) int32 {
+	if replicas := pt.Spec.External.Zookeeper.Managed.Replicas; replicas > 0 {
+		return replicas
+	}
+	return defaultZookeeperSize
+}
+
+// getZkAddress returns the connection string of the managed ensemble, or the
+// external zookeeper address.
+func getZkAddress(pt *v1beta1.Pinot) string {
+	if !isZookeeperManaged(pt) {
+		return pt.Spec.External.Zookeeper.Spec.ZkAddress
+	}
+
+	hosts := []string{}
+	for i := int32(0); i < getZookeeperReplicas(pt); i++ {
+		hosts = append(hosts, fmt.Sprintf("%s:%d", makeZookeeperPodHost(pt, i), ZookeeperClientPort))
+	}
+	return strings.Join(hosts, ",")
+}
+
+func makeZookeeperPodHost(pt *v1beta1.Pinot, ordinal int32) string {
+	return fmt.Sprintf("%s-%d.%s.%s.svc.cluster.local",
+		makeZookeeperName(pt.Name), ordinal, makeZookeeperHeadlessSvcName(pt.Name), pt.Namespace)
+}
+
+// reconcileZookeeper creates or updates the headless and client services, the
+// statefulset and the pod disruption budget of the managed ensemble.
+func (r *PinotReconciler) reconcileZookeeper(
+	ctx context.Context,
+	pt *v1beta1.Pinot,
+	ownerRef *metav1.OwnerReference,
+) error {
+
+	objects := []struct {
+		desired client.Object
+		current client.Object
+	}{
+		{makeZookeeperService(pt, true), &v1.Service{}},
+		{makeZookeeperService(pt, false), &v1.Service{}},
+		{makeZookeeperStatefulSet(pt), &appsv1.StatefulSet{}},
+		{makeZookeeperPdb(pt), &policyv1.PodDisruptionBudget{}},
+	}
+
+	for _, object := range objects {
+		commonBuilder := builder.CommonBuilder{
+			ObjectMeta:   metav1.ObjectMeta{Name: object.desired.GetName(), Namespace: pt.Namespace},
+			Client:       r.Client,
+			CrObject:     pt,
+			OwnerRef:     *ownerRef,
+			DesiredState: object.desired,
+			CurrentState: object.current,
+		}
+		if _, err := commonBuilder.CreateOrUpdate(ctx, builder.BuilderRecorder{Recorder: r.Recorder, ControllerName: "pinotOperator"}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getZookeeperStatus returns the health of the managed ensemble, nil when
+// zookeeper is external.
+func (r *PinotReconciler) getZookeeperStatus(ctx context.Context, pt *v1beta1.Pinot) (*v1beta1.ZookeeperStatus, error) {
+	if !isZookeeperManaged(pt) {
+		return nil, nil
+	}
+
+	status := &v1beta1.ZookeeperStatus{
+		Address:  getZkAddress(pt),
+		Replicas: getZookeeperReplicas(pt),
+	}
+
+	sts := &appsv1.StatefulSet{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pt.Namespace, Name: makeZookeeperName(pt.Name)}, sts); err != nil {
+		if errors.IsNotFound(err) {
+			return status, nil
+		}
+		return nil, err
+	}
+
+	status.ReadyReplicas = sts.Status.ReadyReplicas
+	status.Healthy = sts.Status.ReadyReplicas >= status.Replicas/2+1
+
+	return status, nil
+}
+
+func makeZookeeperService(pt *v1beta1.Pinot, headless bool) *v1.Service {
+	svc := &v1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      makeZookeeperName(pt.Name),
+			Namespace: pt.Namespace,
+			Labels:    makeZookeeperLabels(pt),
+		},
+		Spec: v1.ServiceSpec{
+			Selector: makeZookeeperLabels(pt),
+			Ports: []v1.ServicePort{
+				{Name: "client", Port: ZookeeperClientPort, TargetPort: intstr.FromInt(ZookeeperClientPort)},
+			},
+		},
+	}
+
+	if headless {
+		// peers have to resolve each other before they are ready to form a quorum
+		svc.Name = makeZookeeperHeadlessSvcName(pt.Name)
+		svc.Spec.ClusterIP = v1.ClusterIPNone
+		svc.Spec.PublishNotReadyAddresses = true
+		svc.Spec.Ports = append(svc.Spec.Ports,
+			v1.ServicePort{Name: "peer", Port: ZookeeperPeerPort, TargetPort: intstr.FromInt(ZookeeperPeerPort)},
+			v1.ServicePort{Name: "election", Port: ZookeeperElectionPort, TargetPort: intstr.FromInt(ZookeeperElectionPort)},
+		)
+	}
+
+	return svc
+}
+
+func makeZookeeperStatefulSet(pt *v1beta1.Pinot) *appsv1.StatefulSet {
+	managed := pt.Spec.External.Zookeeper.Managed
+	replicas := getZookeeperReplicas(pt)
+
+	image := managed.Image
+	if image == "" {
+		image = defaultZookeeperImage
+	}
+
+	servers := []string{}
+	for i := int32(0); i < replicas; i++ {
+		servers = append(servers, fmt.Sprintf("server.%d=%s:%d:%d;%d",
+			i+1, makeZookeeperPodHost(pt, i), ZookeeperPeerPort, ZookeeperElectionPort, ZookeeperClientPort))
+	}
+
+	container := v1.Container{
+		Name:            "zookeeper",
+		Image:           image,
+		ImagePullPolicy: managed.ImagePullPolicy,
+		// the server id is derived from the pod ordinal, ids start at 1
+		Command: []string{
+			"/bin/bash", "-c",
+			"export ZOO_MY_ID=$((${HOSTNAME##*-}+1)) && exec /docker-entrypoint.sh zkServer.sh start-foreground",
+		},
+		Env: []v1.EnvVar{
+			{Name: "ZOO_SERVERS", Value: strings.Join(servers, " ")},
+			{Name: "ZOO_STANDALONE_ENABLED", Value: strconv.FormatBool(replicas == 1)},
+			{Name: "ZOO_4LW_COMMANDS_WHITELIST", Value: "ruok,srvr,mntr"},
+			{Name: "ZOO_CFG_EXTRA", Value: "quorumListenOnAllIPs=true"},
+		},
+		Ports: []v1.ContainerPort{
+			{Name: "client", ContainerPort: ZookeeperClientPort},
+			{Name: "peer", ContainerPort: ZookeeperPeerPort},
+			{Name: "election", ContainerPort: ZookeeperElectionPort},
+		},
+		ReadinessProbe: &v1.Probe{
+			ProbeHandler: v1.ProbeHandler{
+				Exec: &v1.ExecAction{Command: []string{"/bin/bash", "-c", "zkServer.sh status"}},
+			},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       10,
+		},
+		LivenessProbe: &v1.Probe{
+			ProbeHandler: v1.ProbeHandler{
+				TCPSocket: &v1.TCPSocketAction{Port: intstr.FromInt(ZookeeperClientPort)},
+			},
+			InitialDelaySeconds: 30,
+			PeriodSeconds:       10,
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: ZookeeperDataVolume, MountPath: ZookeeperDataMountPath},
+		},
+		Resources: managed.Resources,
+	}
+
+	return &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      makeZookeeperName(pt.Name),
+			Namespace: pt.Namespace,
+			Labels:    makeZookeeperLabels(pt),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: makeZookeeperHeadlessSvcName(pt.Name),
+			// all peers have to be up to form a quorum, none is ready before that
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: makeZookeeperLabels(pt),
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: makeZookeeperLabels(pt),
+				},
+				Spec: v1.PodSpec{
+					Containers:   []v1.Container{container},
+					NodeSelector: managed.NodeSelector,
+					Tolerations:  managed.Tolerations,
+					Affinity:     managed.Affinity,
+				},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: ZookeeperDataVolume},
+					Spec:       managed.Storage,
+				},
+			},
+		},
+	}
+}
+
+func makeZookeeperPdb(pt *v1beta1.Pinot) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      makeZookeeperName(pt.Name),
+			Namespace: pt.Namespace,
+			Labels:    makeZookeeperLabels(pt),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: makeZookeeperLabels(pt),
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pinotcontroller
+
+import (
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetZkAddress(t *testing.T) {
+	host := func(ordinal string) string {
+		return "pinot-zookeeper-" + ordinal + ".pinot-zookeeper-headless.pinot.svc.cluster.local:2181"
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		managed *v1beta1.ManagedZookeeperSpec
+		want    string
+	}{
+		{
+			name:    "external",
+			address: "zk-0.zk:2181,zk-1.zk:2181",
+			want:    "zk-0.zk:2181,zk-1.zk:2181",
+		},
+		{
+			name:    "managed with default replicas",
+			address: "ignored:2181",
+			managed: &v1beta1.ManagedZookeeperSpec{},
+			want:    host("0") + "," + host("1") + "," + host("2"),
+		},
+		{
+			name:    "managed single node",
+			managed: &v1beta1.ManagedZookeeperSpec{Replicas: 1},
+			want:    host("0"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := &v1beta1.Pinot{ObjectMeta: metav1.ObjectMeta{Name: "pinot", Namespace: "pinot"}}
+			pt.Spec.External.Zookeeper.Spec.ZkAddress = tt.address
+			pt.Spec.External.Zookeeper.Managed = tt.managed
+
+			if got := getZkAddress(pt); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
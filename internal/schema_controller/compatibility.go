@@ -0,0 +1,195 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schemacontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+)
+
+// field spec lists of a pinot schema
+var fieldSpecTypes = []string{
+	"dimensionFieldSpecs",
+	"metricFieldSpecs",
+	"dateTimeFieldSpecs",
+	"complexFieldSpecs",
+}
+
+const (
+	timeFieldSpec           = "timeFieldSpec"
+	incomingGranularitySpec = "incomingGranularitySpec"
+	primaryKeyColumns       = "primaryKeyColumns"
+)
+
+// column settings that change how existing segments are read
+var breakingColumnSettings = map[string]bool{
+	"dataType":         true,
+	"singleValueField": true,
+	"format":           true,
+}
+
+type column struct {
+	fieldSpecType string
+	settings      map[string]interface{}
+}
+
+type pinotSchema struct {
+	columns     map[string]column
+	primaryKeys interface{}
+}
+
+func parseSchema(schemaJson string) (*pinotSchema, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(schemaJson), &raw); err != nil {
+		return nil, err
+	}
+
+	schema := &pinotSchema{
+		columns:     map[string]column{},
+		primaryKeys: raw[primaryKeyColumns],
+	}
+	for _, fieldSpecType := range fieldSpecTypes {
+		fieldSpecs, _ := raw[fieldSpecType].([]interface{})
+		for _, fieldSpec := range fieldSpecs {
+			settings, ok := fieldSpec.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			schema.addColumn(fieldSpecType, settings)
+		}
+	}
+
+	// the deprecated time field spec is named by its incoming granularity
+	if spec, ok := raw[timeFieldSpec].(map[string]interface{}); ok {
+		if settings, ok := spec[incomingGranularitySpec].(map[string]interface{}); ok {
+			schema.addColumn(timeFieldSpec, settings)
+		}
+	}
+
+	return schema, nil
+}
+
+func (s *pinotSchema) addColumn(fieldSpecType string, settings map[string]interface{}) {
+	name, _ := settings["name"].(string)
+	if name == "" {
+		return
+	}
+	// columns are single valued unless set otherwise
+	if _, ok := settings["singleValueField"]; !ok {
+		settings["singleValueField"] = true
+	}
+	s.columns[name] = column{fieldSpecType: fieldSpecType, settings: settings}
+}
+
+// classifySchemaChange compares the desired schema with the current schema on
+// pinot, it returns the changes of every column and the most severe
+// compatibility of them.
+func classifySchemaChange(currentSchemaJson, desiredSchemaJson string) (v1beta1.PinotSchemaChange, error) {
+	change := v1beta1.PinotSchemaChange{Compatibility: v1beta1.SchemaChangeCompatible}
+
+	current, err := parseSchema(currentSchemaJson)
+	if err != nil {
+		return change, fmt.Errorf("Error unmarshalling current schema :: %s", err.Error())
+	}
+	desired, err := parseSchema(desiredSchemaJson)
+	if err != nil {
+		return change, fmt.Errorf("Error unmarshalling desired schema :: %s", err.Error())
+	}
+
+	additive, compatible, breaking := []string{}, []string{}, []string{}
+
+	for name, currentColumn := range current.columns {
+		desiredColumn, ok := desired.columns[name]
+		if !ok {
+			breaking = append(breaking, fmt.Sprintf("%s: removed from %s", name, currentColumn.fieldSpecType))
+			continue
+		}
+		if currentColumn.fieldSpecType != desiredColumn.fieldSpecType {
+			breaking = append(breaking, fmt.Sprintf("%s: moved from %s to %s", name, currentColumn.fieldSpecType, desiredColumn.fieldSpecType))
+			continue
+		}
+
+		for _, setting := range settingNames(currentColumn.settings, desiredColumn.settings) {
+			from, to := currentColumn.settings[setting], desiredColumn.settings[setting]
+			if reflect.DeepEqual(from, to) {
+				continue
+			}
+			diff := fmt.Sprintf("%s: %s changed from %s to %s", name, setting, marshalSetting(from), marshalSetting(to))
+			if breakingColumnSettings[setting] {
+				breaking = append(breaking, diff)
+			} else {
+				compatible = append(compatible, diff)
+			}
+		}
+	}
+
+	for name, desiredColumn := range desired.columns {
+		if _, ok := current.columns[name]; !ok {
+			additive = append(additive, fmt.Sprintf("%s: added to %s", name, desiredColumn.fieldSpecType))
+		}
+	}
+
+	if !reflect.DeepEqual(current.primaryKeys, desired.primaryKeys) {
+		breaking = append(breaking, fmt.Sprintf("%s: changed from %s to %s", primaryKeyColumns, marshalSetting(current.primaryKeys), marshalSetting(desired.primaryKeys)))
+	}
+
+	switch {
+	case len(breaking) > 0:
+		change.Compatibility = v1beta1.SchemaChangeBreaking
+	case len(compatible) > 0:
+		change.Compatibility = v1beta1.SchemaChangeCompatible
+	case len(additive) > 0:
+		change.Compatibility = v1beta1.SchemaChangeAdditive
+	}
+
+	for _, changes := range [][]string{breaking, compatible, additive} {
+		sort.Strings(changes)
+		change.Changes = append(change.Changes, changes...)
+	}
+
+	return change, nil
+}
+
+func settingNames(settings ...map[string]interface{}) []string {
+	names := map[string]bool{}
+	for _, s := range settings {
+		for name := range s {
+			names[name] = true
+		}
+	}
+
+	sorted := []string{}
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func marshalSetting(v interface{}) string {
+	if v == nil {
+		return "unset"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,86 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemacontroller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+)
+
+func TestClassifySchemaChange(t *testing.T) {
+	const current = `{
+  "schemaName": "airlineStats",
+  "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}],
+  "metricFieldSpecs": [{"name": "Delay", "dataType": "INT"}],
+  "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]
+}`
+
+	tests := []struct {
+		name    string
+		desired string
+		want    v1beta1.PinotSchemaChange
+	}{
+		{
+			name:    "unchanged with server defaults",
+			desired: `{"dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING", "singleValueField": true}], "metricFieldSpecs": [{"name": "Delay", "dataType": "INT"}], "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]}`,
+			want:    v1beta1.PinotSchemaChange{Compatibility: v1beta1.SchemaChangeCompatible},
+		},
+		{
+			name:    "added column",
+			desired: `{"dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}, {"name": "Origin", "dataType": "STRING"}], "metricFieldSpecs": [{"name": "Delay", "dataType": "INT"}], "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]}`,
+			want: v1beta1.PinotSchemaChange{
+				Compatibility: v1beta1.SchemaChangeAdditive,
+				Changes:       []string{"Origin: added to dimensionFieldSpecs"},
+			},
+		},
+		{
+			name:    "default null value",
+			desired: `{"dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING", "defaultNullValue": "unknown"}], "metricFieldSpecs": [{"name": "Delay", "dataType": "INT"}], "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]}`,
+			want: v1beta1.PinotSchemaChange{
+				Compatibility: v1beta1.SchemaChangeCompatible,
+				Changes:       []string{`Carrier: defaultNullValue changed from unset to "unknown"`},
+			},
+		},
+		{
+			name:    "removed and retyped columns",
+			desired: `{"dimensionFieldSpecs": [{"name": "Carrier", "dataType": "INT"}, {"name": "Delay", "dataType": "INT"}], "primaryKeyColumns": ["Carrier"]}`,
+			want: v1beta1.PinotSchemaChange{
+				Compatibility: v1beta1.SchemaChangeBreaking,
+				Changes: []string{
+					`Carrier: dataType changed from "STRING" to "INT"`,
+					"DaysSinceEpoch: removed from dateTimeFieldSpecs",
+					"Delay: moved from metricFieldSpecs to dimensionFieldSpecs",
+					`primaryKeyColumns: changed from unset to ["Carrier"]`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := classifySchemaChange(current, tt.desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
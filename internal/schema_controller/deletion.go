@@ -0,0 +1,91 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schemacontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// isDeletionBlocked returns true when the deletion protection annotation keeps
+// the deleted schema, nothing is deleted from pinot until it is removed.
+func (r *PinotSchemaReconciler) isDeletionBlocked(schema *v1beta1.PinotSchema, build builder.Builder) bool {
+	if !utils.IsDeletionProtected(schema.Annotations, deletionProtectionAnnotation) {
+		return false
+	}
+
+	build.Recorder.GenericEvent(
+		schema,
+		v1.EventTypeWarning,
+		fmt.Sprintf("Deletion blocked by annotation [%s], remove it to delete the schema", deletionProtectionAnnotation),
+		PinotSchemaControllerDeleteBlocked,
+	)
+	return true
+}
+
+// deleteSchema deletes the schema of a deleted CR from pinot, or keeps it when
+// the deletion policy is Retain or Orphan.
+func (r *PinotSchemaReconciler) deleteSchema(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	schemaName, err := utils.GetValueFromJson(schema.Spec.PinotSchemaJson, schemaName)
+	if err != nil {
+		return err
+	}
+
+	if !utils.IsDeletedOnPinot(schema.Spec.DeletionPolicy) {
+		// pinot has no labels for schemas, an orphaned schema is only kept
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Schema [%s] kept on pinot, deletion policy [%s]", schemaName, schema.Spec.DeletionPolicy),
+			PinotSchemaControllerRetained,
+		)
+		return nil
+	}
+
+	respDeleteSchema, err := pc.DeleteSchema(ctx, schemaName)
+	if err != nil && !pinot.IsAPIError(err) {
+		return err
+	}
+	if err != nil && !pinot.IsNotFound(err) {
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotSchemaControllerDeleteFail,
+		)
+	} else {
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Resp [%s]", respDeleteSchema),
+			PinotSchemaControllerDeleteSuccess,
+		)
+	}
+	return nil
+}
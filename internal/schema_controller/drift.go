@@ -0,0 +1,190 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schemacontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileDrift compares the applied spec with the live schema on pinot to
+// catch changes made outside of the control plane, they are corrected or only
+// reported depending on the drift policy.
+func (r *PinotSchemaReconciler) reconcileDrift(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	pc *pinot.Client,
+	schemaName string,
+	liveSchema string,
+	build builder.Builder,
+) (controllerutil.OperationResult, error) {
+
+	diffs, err := utils.JsonDrift(schema.Spec.PinotSchemaJson, liveSchema)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if len(diffs) == 0 {
+		// keep the reason of a corrected drift until the next one
+		if meta.IsStatusConditionFalse(schema.Status.Conditions, v1beta1.ConditionDrifted) {
+			return controllerutil.OperationResultNone, nil
+		}
+		_, err := r.patchDriftedCondition(ctx, schema, metav1.ConditionFalse, PinotSchemaControllerInSync, "Schema matches pinot")
+		return controllerutil.OperationResultNone, err
+	}
+
+	if utils.IsSecretSource(schema.Spec.SchemaFrom) {
+		diffs = utils.RedactDrift(diffs)
+	}
+	msg := utils.DriftMessage(diffs)
+
+	if !utils.IsDriftCorrected(schema.Spec.DriftPolicy) {
+		return controllerutil.OperationResultNone, r.reportDrift(
+			ctx,
+			schema,
+			PinotSchemaControllerDrifted,
+			msg,
+			fmt.Sprintf("Schema differs from pinot [%s]", msg),
+			build,
+		)
+	}
+
+	// correcting the drift changes the live schema, it is classified and
+	// validated like a change of the spec
+	change, err := classifySchemaChange(liveSchema, schema.Spec.PinotSchemaJson)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if change.Compatibility == v1beta1.SchemaChangeBreaking && !schema.Spec.AllowBreakingChanges {
+		return controllerutil.OperationResultNone, r.reportDrift(
+			ctx,
+			schema,
+			PinotSchemaControllerDriftCorrectRefused,
+			fmt.Sprintf("%s, correcting refused as it is a breaking change, set allowBreakingChanges to apply it", msg),
+			fmt.Sprintf("Changes [%s]", strings.Join(change.Changes, "; ")),
+			build,
+		)
+	}
+
+	if _, err := pc.ValidateSchema(ctx, schema.Spec.PinotSchemaJson); err != nil {
+		if !pinot.IsAPIError(err) {
+			return controllerutil.OperationResultNone, err
+		}
+		return controllerutil.OperationResultNone, r.reportDrift(
+			ctx,
+			schema,
+			PinotSchemaControllerDriftCorrectFail,
+			fmt.Sprintf("%s, validating failed [%s]", msg, err.Error()),
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			build,
+		)
+	}
+
+	respUpdateSchema, err := pc.UpdateSchema(ctx, schemaName, schema.Spec.PinotSchemaJson, schema.Spec.AllowBreakingChanges)
+	if err != nil && !pinot.IsAPIError(err) {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if err != nil {
+		return controllerutil.OperationResultNone, r.reportDrift(
+			ctx,
+			schema,
+			PinotSchemaControllerDriftCorrectFail,
+			fmt.Sprintf("%s, correcting failed [%s]", msg, err.Error()),
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			build,
+		)
+	}
+
+	// the corrected schema is applied again, its tables reload their segments
+	condition, _ := utils.NewDriftedCondition(schema.Status.Conditions, metav1.ConditionFalse, PinotSchemaControllerDriftCorrected, msg, schema.Generation)
+	if err := r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		in.Status.ApplyCount++
+		return in
+	}); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	build.Recorder.GenericEvent(
+		schema,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Corrected [%s], Resp [%s]", msg, respUpdateSchema),
+		PinotSchemaControllerDriftCorrected,
+	)
+
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// reportDrift sets the Drifted condition for drift that is not corrected, the
+// warning event is only emitted when the condition changes.
+func (r *PinotSchemaReconciler) reportDrift(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	reason string,
+	msg string,
+	event string,
+	build builder.Builder,
+) error {
+
+	changed, err := r.patchDriftedCondition(ctx, schema, metav1.ConditionTrue, reason, msg)
+	if err != nil {
+		return err
+	}
+	if changed {
+		build.Recorder.GenericEvent(schema, v1.EventTypeWarning, event, reason)
+	}
+	return nil
+}
+
+// patchDriftedCondition sets the Drifted condition, it returns false without
+// patching when the condition is unchanged.
+func (r *PinotSchemaReconciler) patchDriftedCondition(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	status metav1.ConditionStatus,
+	reason string,
+	msg string,
+) (bool, error) {
+
+	condition, changed := utils.NewDriftedCondition(schema.Status.Conditions, status, reason, msg, schema.Generation)
+	if !changed {
+		return false, nil
+	}
+
+	if err := r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
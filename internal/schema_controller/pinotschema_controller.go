@@ -21,15 +21,19 @@ import (
 	"os"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
 	datainfraiov1beta1 "github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 	"github.com/go-logr/logr"
 )
 
@@ -57,7 +61,9 @@ func NewPinotSchemaReconciler(mgr ctrl.Manager) *PinotSchemaReconciler {
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinotschemas,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinotschemas/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinotschemas/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=secret,verbs=get
+// +kubebuilder:rbac:groups=datainfra.io,resources=pinotexternalclusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 func (r *PinotSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	pinotSchemaCR := &v1beta1.PinotSchema{}
@@ -78,8 +84,22 @@ func (r *PinotSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PinotSchemaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// schemas are listed by the source of their schema
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1beta1.PinotSchema{}, utils.SourceIndexField, indexSchemaSource); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&datainfraiov1beta1.PinotSchema{}).
+		// re-reconcile schemas read from a ConfigMap or a Secret when it changes
+		Watches(
+			&source.Kind{Type: &v1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSchemasForSource),
+		).
+		Watches(
+			&source.Kind{Type: &v1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSchemasForSource),
+		).
 		WithEventFilter(predicate.Or(
 			GenericPredicates{},
 			predicate.ResourceVersionChangedPredicate{},
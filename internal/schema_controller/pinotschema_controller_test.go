@@ -0,0 +1,584 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemacontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot/pinottest"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+)
+
+const testSchemaJson = `{
+  "schemaName": "airlineStats",
+  "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}],
+  "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]
+}`
+
+const testUpdatedSchemaJson = `{
+  "schemaName": "airlineStats",
+  "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}, {"name": "Origin", "dataType": "STRING"}],
+  "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]
+}`
+
+// testBreakingSchemaJson changes the data type of a column and drops another.
+const testBreakingSchemaJson = `{
+  "schemaName": "airlineStats",
+  "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "INT"}]
+}`
+
+// testDriftedSchemaJson is the schema as changed on pinot, with server defaults
+// the spec does not set.
+const testDriftedSchemaJson = `{
+  "schemaName": "airlineStats",
+  "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "INT", "singleValueField": true}],
+  "dateTimeFieldSpecs": [{"name": "DaysSinceEpoch", "dataType": "INT", "format": "1:DAYS:EPOCH", "granularity": "1:DAYS"}]
+}`
+
+// specs numbers the objects of every spec, objects are not removed between
+// specs as their finalizers need the reconciler.
+var specs int
+
+var _ = Describe("PinotSchema controller", func() {
+	var (
+		ctx    context.Context
+		server *pinottest.Server
+		r      *PinotSchemaReconciler
+		key    types.NamespacedName
+	)
+
+	reconcile := func() error {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		return err
+	}
+
+	getSchema := func() *v1beta1.PinotSchema {
+		schema := &v1beta1.PinotSchema{}
+		Expect(k8sClient.Get(ctx, key, schema)).To(Succeed())
+		return schema
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = pinottest.NewServer()
+		DeferCleanup(server.Close)
+
+		r = &PinotSchemaReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+		}
+
+		specs++
+		name := fmt.Sprintf("schema-%d", specs)
+		cluster := &v1beta1.PinotExternalCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1beta1.PinotExternalClusterSpec{ControllerURL: server.URL},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), cluster)
+
+		key = types.NamespacedName{Name: name, Namespace: "default"}
+		schema := &v1beta1.PinotSchema{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: v1beta1.PinotSchemaSpec{
+				PinotExternalCluster: cluster.Name,
+				PinotSchemaJson:      testSchemaJson,
+			},
+		}
+		Expect(k8sClient.Create(ctx, schema)).To(Succeed())
+	})
+
+	It("creates the schema and registers the finalizer", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		schema := getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerCreateSuccess))
+		Expect(schema.Status.CurrentSchemasJson).To(MatchJSON(testSchemaJson))
+		Expect(schema.Status.ApplyCount).To(Equal(int64(1)))
+	})
+
+	It("updates the schema when the spec changes", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = testUpdatedSchemaJson
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testUpdatedSchemaJson))
+		schema = getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerUpdateSuccess))
+		Expect(schema.Status.ApplyCount).To(Equal(int64(2)))
+		Expect(schema.Status.LastChange).To(Equal(&v1beta1.PinotSchemaChange{
+			Compatibility: v1beta1.SchemaChangeAdditive,
+			Changes:       []string{"Origin: added to dimensionFieldSpecs"},
+		}))
+	})
+
+	It("counts a schema applied before the apply count was kept", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Status.ApplyCount = 0
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schema = getSchema()
+		Expect(schema.Status.ApplyCount).To(Equal(int64(1)))
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerCreateSuccess))
+	})
+
+	It("refuses breaking changes unless they are allowed", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = testBreakingSchemaJson
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		schema = getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerUpdateRefused))
+		Expect(schema.Status.CurrentSchemasJson).To(MatchJSON(testSchemaJson))
+		Expect(schema.Status.LastChange.Compatibility).To(Equal(v1beta1.SchemaChangeBreaking))
+		Expect(schema.Status.LastChange.Changes).To(ConsistOf(
+			`Carrier: dataType changed from "STRING" to "INT"`,
+			"DaysSinceEpoch: removed from dateTimeFieldSpecs",
+		))
+
+		schema.Spec.AllowBreakingChanges = true
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok = server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testBreakingSchemaJson))
+		Expect(getSchema().Status.Type).To(Equal(PinotSchemaControllerUpdateSuccess))
+	})
+
+	It("does not apply schemas pinot fails to validate", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		server.Fail(http.MethodPost, "/schemas/validate", http.StatusBadRequest, "Invalid schema: airlineStats")
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = testUpdatedSchemaJson
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		schema = getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerValidateFail))
+		Expect(schema.Status.Reason).To(ContainSubstring("Invalid schema: airlineStats"))
+	})
+
+	It("deletes the schema from pinot before removing the finalizer", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		Expect(k8sClient.Delete(ctx, getSchema())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeFalse())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotSchema{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports pinot errors in the status", func() {
+		server.Fail(http.MethodPost, "/schemas", http.StatusBadRequest, "Invalid schema: airlineStats")
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeFalse())
+		schema := getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerCreateFail))
+		Expect(schema.Status.Reason).To(ContainSubstring("Invalid schema: airlineStats"))
+	})
+
+	It("corrects changes made on pinot outside of the spec", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(apimeta.IsStatusConditionFalse(getSchema().Status.Conditions, v1beta1.ConditionDrifted)).To(BeTrue())
+
+		// a column dropped on pinot is added back
+		Expect(server.AddSchema(`{"schemaName": "airlineStats", "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}]}`)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		condition := apimeta.FindStatusCondition(getSchema().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(PinotSchemaControllerDriftCorrected))
+		Expect(condition.Message).To(HavePrefix("dateTimeFieldSpecs: desired"))
+		// the tables of the corrected schema reload their segments
+		Expect(getSchema().Status.ApplyCount).To(Equal(int64(2)))
+	})
+
+	It("reports drift it cannot correct without breaking the schema", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		Expect(server.AddSchema(testDriftedSchemaJson)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testDriftedSchemaJson))
+		condition := apimeta.FindStatusCondition(getSchema().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(PinotSchemaControllerDriftCorrectRefused))
+		Expect(condition.Message).To(ContainSubstring(`dimensionFieldSpecs[0].dataType: desired "STRING", live "INT"`))
+		Expect(condition.Message).To(ContainSubstring("correcting refused"))
+		Expect(server.Requests()).NotTo(ContainElement(HaveField("Method", http.MethodPut)))
+	})
+
+	It("forces a breaking correction of drift with allowBreakingChanges", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.AllowBreakingChanges = true
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(server.AddSchema(testDriftedSchemaJson)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		condition := apimeta.FindStatusCondition(getSchema().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(PinotSchemaControllerDriftCorrected))
+	})
+
+	It("does not correct drift to a schema pinot rejects", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		Expect(server.AddSchema(`{"schemaName": "airlineStats", "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}]}`)).To(Succeed())
+		server.Fail(http.MethodPost, "/schemas/validate", http.StatusBadRequest, "Invalid schema: airlineStats")
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		condition := apimeta.FindStatusCondition(getSchema().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(PinotSchemaControllerDriftCorrectFail))
+		Expect(condition.Message).To(ContainSubstring("validating failed"))
+	})
+
+	It("only reports drift with the Report policy", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.DriftPolicy = v1beta1.DriftPolicyReport
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(server.AddSchema(testDriftedSchemaJson)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testDriftedSchemaJson))
+		condition := apimeta.FindStatusCondition(getSchema().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(PinotSchemaControllerDrifted))
+		Expect(condition.Message).To(ContainSubstring(`dimensionFieldSpecs[0].dataType: desired "STRING", live "INT"`))
+	})
+
+	It("plans a new schema without creating it", func() {
+		schema := getSchema()
+		schema.Spec.Mode = v1beta1.ReconcileModePlan
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeFalse())
+		schema = getSchema()
+		Expect(controllerutil.ContainsFinalizer(schema, PinotSchemaControllerFinalizer)).To(BeFalse())
+		Expect(schema.Status.Plan).NotTo(BeNil())
+		Expect(schema.Status.Plan.Action).To(Equal(v1beta1.PlanActionCreate))
+		Expect(schema.Status.Plan.Validation).To(Equal(v1beta1.PlanValidationPassed))
+	})
+
+	It("plans an update with the diff against pinot and applies it once planning stops", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.Mode = v1beta1.ReconcileModePlan
+		schema.Spec.PinotSchemaJson = testUpdatedSchemaJson
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		plan := getSchema().Status.Plan
+		Expect(plan).NotTo(BeNil())
+		Expect(plan.Action).To(Equal(v1beta1.PlanActionUpdate))
+		Expect(plan.Diff).To(ConsistOf(HavePrefix("dimensionFieldSpecs: desired")))
+		Expect(plan.Message).To(Equal("Additive schema change [Origin: added to dimensionFieldSpecs]"))
+
+		schema = getSchema()
+		schema.Spec.Mode = v1beta1.ReconcileModeApply
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok = server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testUpdatedSchemaJson))
+		Expect(getSchema().Status.Plan).To(BeNil())
+	})
+
+	It("releases a planned schema on deletion without deleting it from pinot", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.Mode = v1beta1.ReconcileModePlan
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getSchema())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotSchema{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reads the schema from a configmap and applies its changes", func() {
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"schema.json": testSchemaJson},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = ""
+		schema.Spec.SchemaFrom = &v1beta1.JsonSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: configMap.Name},
+			Key:                  "schema.json",
+		}}
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		schema = getSchema()
+		Expect(schema.Spec.PinotSchemaJson).To(BeEmpty())
+		Expect(schema.Status.CurrentSchemasJson).To(MatchJSON(testSchemaJson))
+
+		configMap.Data["schema.json"] = testUpdatedSchemaJson
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		Expect(indexSchemaSource(getSchema())).To(ConsistOf(utils.SourceIndexKey(configMap)))
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok = server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testUpdatedSchemaJson))
+		schema = getSchema()
+		Expect(schema.Status.Type).To(Equal(PinotSchemaControllerUpdateSuccess))
+		// the schema is applied again without a new generation of the CR
+		Expect(schema.Status.ApplyCount).To(Equal(int64(2)))
+
+		// the applied schema releases the CR once its source is gone
+		Expect(k8sClient.Delete(ctx, configMap)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getSchema())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok = server.Schema("airlineStats")
+		Expect(ok).To(BeFalse())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotSchema{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("does not apply a schema whose source is missing", func() {
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = ""
+		schema.Spec.SchemaFrom = &v1beta1.JsonSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: key.Name},
+			Key:                  "schema.json",
+		}}
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+
+		Expect(reconcile()).NotTo(Succeed())
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("renders the typed schema and applies its changes", func() {
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = ""
+		schema.Spec.Schema = &v1beta1.PinotSchemaDefinition{
+			SchemaName: "airlineStats",
+			DimensionFieldSpecs: []v1beta1.DimensionFieldSpec{
+				{Name: "Carrier", DataType: "STRING"},
+			},
+			DateTimeFieldSpecs: []v1beta1.DateTimeFieldSpec{
+				{Name: "DaysSinceEpoch", DataType: "INT", Format: "1:DAYS:EPOCH", Granularity: "1:DAYS"},
+			},
+		}
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		schema = getSchema()
+		Expect(schema.Spec.PinotSchemaJson).To(BeEmpty())
+		Expect(schema.Status.CurrentSchemasJson).To(MatchJSON(testSchemaJson))
+
+		schema.Spec.Schema.DimensionFieldSpecs = append(schema.Spec.Schema.DimensionFieldSpecs,
+			v1beta1.DimensionFieldSpec{Name: "Origin", DataType: "STRING"},
+		)
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok = server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testUpdatedSchemaJson))
+		Expect(getSchema().Status.LastChange).To(Equal(&v1beta1.PinotSchemaChange{
+			Compatibility: v1beta1.SchemaChangeAdditive,
+			Changes:       []string{"Origin: added to dimensionFieldSpecs"},
+		}))
+	})
+
+	It("keeps the applied revisions and rolls back to one", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.PinotSchemaJson = testUpdatedSchemaJson
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(getSchema().Status.Revisions).To(HaveLen(2))
+
+		// removing the added column again is a breaking change
+		schema = getSchema()
+		schema.Annotations = map[string]string{rollbackAnnotation: "1"}
+		schema.Spec.AllowBreakingChanges = true
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(getSchema().Spec.PinotSchemaJson).To(MatchJSON(testSchemaJson))
+		Expect(reconcile()).To(Succeed())
+
+		schemaJson, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		Expect(schemaJson).To(MatchJSON(testSchemaJson))
+		revisions := getSchema().Status.Revisions
+		Expect(revisions).To(HaveLen(3))
+		Expect(revisions[2].Revision).To(Equal(int64(3)))
+		Expect(revisions[2].Json).To(MatchJSON(testSchemaJson))
+	})
+
+	It("keeps the schema on pinot with the Retain deletion policy", func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getSchema(), PinotSchemaControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+
+		schema := getSchema()
+		schema.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+		Expect(k8sClient.Update(ctx, schema)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getSchema())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Schema("airlineStats")
+		Expect(ok).To(BeTrue())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotSchema{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("returns transport errors to be retried", func() {
+		server.Close()
+		Expect(reconcile()).NotTo(Succeed())
+	})
+})
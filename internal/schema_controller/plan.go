@@ -0,0 +1,162 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schemacontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// plan stores what applying the schema would do on pinot in the status,
+// nothing is changed on pinot. A deleted CR is released without deleting the
+// schema.
+func (r *PinotSchemaReconciler) plan(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	plan, err := r.makePlan(ctx, schema, pc)
+	if err != nil {
+		return err
+	}
+
+	// the plan of a deleted CR is only emitted as an event as the CR is gone
+	// once released
+	if !schema.ObjectMeta.DeletionTimestamp.IsZero() {
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotSchemaControllerPlan,
+		)
+		if controllerutil.ContainsFinalizer(schema, PinotSchemaControllerFinalizer) {
+			controllerutil.RemoveFinalizer(schema, PinotSchemaControllerFinalizer)
+			if err := r.update(ctx, schema); err != nil {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	if utils.IsPlanChanged(schema.Status.Plan, plan) {
+		if err := r.patchPlanStatus(ctx, schema, &plan); err != nil {
+			return err
+		}
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotSchemaControllerPlan,
+		)
+	}
+
+	return nil
+}
+
+// makePlan computes the action the schema controller would take, the diff
+// against the live schema and the validation of the schema by pinot.
+func (r *PinotSchemaReconciler) makePlan(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	pc *pinot.Client,
+) (v1beta1.PlanStatus, error) {
+
+	plan := v1beta1.PlanStatus{
+		Action:             v1beta1.PlanActionNone,
+		ObservedGeneration: schema.Generation,
+		LastPlanTime:       metav1.Time{Time: time.Now()},
+	}
+
+	schemaName, err := utils.GetValueFromJson(schema.Spec.PinotSchemaJson, schemaName)
+	if err != nil {
+		return plan, err
+	}
+
+	liveSchema, err := pc.GetSchema(ctx, schemaName)
+	if err != nil && !pinot.IsNotFound(err) {
+		return plan, err
+	}
+	exists := err == nil
+
+	if !schema.ObjectMeta.DeletionTimestamp.IsZero() {
+		if exists && utils.IsDeletedOnPinot(schema.Spec.DeletionPolicy) {
+			plan.Action = v1beta1.PlanActionDelete
+		}
+		return plan, nil
+	}
+
+	if !exists {
+		plan.Action = v1beta1.PlanActionCreate
+	} else {
+		if plan.Diff, err = utils.JsonDrift(schema.Spec.PinotSchemaJson, liveSchema); err != nil {
+			return plan, err
+		}
+		if utils.IsSecretSource(schema.Spec.SchemaFrom) {
+			plan.Diff = utils.RedactDrift(plan.Diff)
+		}
+		specChanged, err := utils.IsSpecChanged(schema.Status.CurrentSchemasJson, schema.Spec.PinotSchemaJson)
+		if err != nil {
+			return plan, err
+		}
+		plan.Action = utils.PlanUpdateAction(specChanged, plan.Diff, schema.Spec.DriftPolicy)
+
+		change, err := classifySchemaChange(liveSchema, schema.Spec.PinotSchemaJson)
+		if err != nil {
+			return plan, err
+		}
+		if len(change.Changes) > 0 {
+			plan.Message = fmt.Sprintf("%s schema change [%s]", change.Compatibility, strings.Join(change.Changes, "; "))
+		}
+		if change.Compatibility == v1beta1.SchemaChangeBreaking && !schema.Spec.AllowBreakingChanges {
+			plan.Message += ", it is refused unless allowBreakingChanges is set"
+		}
+	}
+
+	_, err = pc.ValidateSchema(ctx, schema.Spec.PinotSchemaJson)
+	if plan.Validation, plan.ValidationMessage, err = utils.PlanValidationResult(err); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+// patchPlanStatus stores the plan in the status, a nil plan removes it.
+func (r *PinotSchemaReconciler) patchPlanStatus(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	plan *v1beta1.PlanStatus,
+) error {
+
+	return r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		in.Status.Plan = plan
+		return in
+	})
+}
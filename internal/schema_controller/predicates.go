@@ -12,6 +12,12 @@ import (
 
 const (
 	ignoreAnnotation = "pinotschema.datainfra.io/reconcile"
+	// rollbackAnnotation rolls the spec back to the revision it is set to
+	rollbackAnnotation = "pinotschema.datainfra.io/rollback-to"
+	// planAnnotation set to true plans the CR like spec.mode Plan
+	planAnnotation = "pinotschema.datainfra.io/plan"
+	// deletionProtectionAnnotation set to true keeps the CR until it is removed
+	deletionProtectionAnnotation = "pinotschema.datainfra.io/deletion-protection"
 )
 
 // All methods to implement GenericPredicates type
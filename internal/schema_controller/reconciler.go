@@ -18,15 +18,17 @@ package schemacontroller
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/datainfrahq/operator-runtime/builder"
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
-	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
 	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,26 +36,30 @@ import (
 )
 
 const (
-	PinotSchemaControllerCreateSuccess      = "PinotSchemaControllerCreateSuccess"
-	PinotSchemaControllerCreateFail         = "PinotSchemaControllerCreateFail"
-	PinotSchemaControllerGetSuccess         = "PinotSchemaControllerGetSuccess"
-	PinotSchemaControllerGetFail            = "PinotSchemaControllerGetFail"
-	PinotSchemaControllerUpdateSuccess      = "PinotSchemaControllerUpdateSuccess"
-	PinotSchemaControllerUpdateFail         = "PinotSchemaControllerUpdateFail"
-	PinotSchemaControllerDeleteSuccess      = "PinotSchemaControllerDeleteSuccess"
-	PinotSchemaControllerDeleteFail         = "PinotSchemaControllerDeleteFail"
-	PinotSchemaControllerPatchStatusSuccess = "PinotSchemaControllerPatchStatusSuccess"
-	PinotSchemaControllerPatchStatusFail    = "PinotSchemaControllerPatchStatusFail"
-	PinotSchemaControllerFinalizer          = "pinotschema.datainfra.io/finalizer"
-)
-
-const (
-	ControlPlaneUserName = "CONTROL_PLANE_USERNAME"
-	ControlPlanePassword = "CONTROL_PLANE_PASSWORD"
-)
-
-const (
-	PinotControllerPort = "9000"
+	PinotSchemaControllerCreateSuccess       = "PinotSchemaControllerCreateSuccess"
+	PinotSchemaControllerCreateFail          = "PinotSchemaControllerCreateFail"
+	PinotSchemaControllerGetSuccess          = "PinotSchemaControllerGetSuccess"
+	PinotSchemaControllerGetFail             = "PinotSchemaControllerGetFail"
+	PinotSchemaControllerUpdateSuccess       = "PinotSchemaControllerUpdateSuccess"
+	PinotSchemaControllerUpdateFail          = "PinotSchemaControllerUpdateFail"
+	PinotSchemaControllerUpdateRefused       = "PinotSchemaControllerUpdateRefused"
+	PinotSchemaControllerValidateFail        = "PinotSchemaControllerValidateFail"
+	PinotSchemaControllerDeleteSuccess       = "PinotSchemaControllerDeleteSuccess"
+	PinotSchemaControllerDeleteFail          = "PinotSchemaControllerDeleteFail"
+	PinotSchemaControllerPatchStatusSuccess  = "PinotSchemaControllerPatchStatusSuccess"
+	PinotSchemaControllerPatchStatusFail     = "PinotSchemaControllerPatchStatusFail"
+	PinotSchemaControllerInSync              = "PinotSchemaControllerInSync"
+	PinotSchemaControllerDrifted             = "PinotSchemaControllerDrifted"
+	PinotSchemaControllerDriftCorrected      = "PinotSchemaControllerDriftCorrected"
+	PinotSchemaControllerDriftCorrectFail    = "PinotSchemaControllerDriftCorrectFail"
+	PinotSchemaControllerDriftCorrectRefused = "PinotSchemaControllerDriftCorrectRefused"
+	PinotSchemaControllerPlan                = "PinotSchemaControllerPlan"
+	PinotSchemaControllerSourceFail          = "PinotSchemaControllerSourceFail"
+	PinotSchemaControllerRollbackSuccess     = "PinotSchemaControllerRollbackSuccess"
+	PinotSchemaControllerRollbackFail        = "PinotSchemaControllerRollbackFail"
+	PinotSchemaControllerDeleteBlocked       = "PinotSchemaControllerDeleteBlocked"
+	PinotSchemaControllerRetained            = "PinotSchemaControllerRetained"
+	PinotSchemaControllerFinalizer           = "pinotschema.datainfra.io/finalizer"
 )
 
 const (
@@ -66,73 +72,68 @@ func (r *PinotSchemaReconciler) do(ctx context.Context, schema *v1beta1.PinotSch
 		builder.ToNewBuilderRecorder(builder.BuilderRecorder{Recorder: r.Recorder, ControllerName: "PinotSchemaController"}),
 	)
 
-	basicAuth, err := r.getAuthCreds(ctx, schema)
-	if err != nil {
+	// a rolled back spec is applied by the reconcile of the update
+	if rolledBack, err := r.rollback(ctx, schema, *build); err != nil || rolledBack {
 		return err
 	}
 
-	svcName, err := r.getControllerSvcUrl(schema.Namespace, schema.Spec.PinotCluster)
-	if err != nil {
+	if err := r.resolveSchemaJson(ctx, schema, *build); err != nil {
 		return err
 	}
 
-	_, err = r.CreateOrUpdate(schema, svcName, *build, internalHTTP.Auth{BasicAuth: basicAuth})
+	pc, err := r.getPinotClient(ctx, schema)
 	if err != nil {
 		return err
 	}
 
+	if err := r.patchUnreachableCondition(ctx, schema, pc); err != nil {
+		return err
+	}
+
+	if utils.IsPlanMode(schema.Spec.Mode, schema.Annotations, planAnnotation) {
+		return r.plan(ctx, schema, pc, *build)
+	}
+
+	// the plan of a schema that was planned before is stale once applied
+	if schema.Status.Plan != nil {
+		if err := r.patchPlanStatus(ctx, schema, nil); err != nil {
+			return err
+		}
+	}
+
+	// a deleted schema is only released, the json it falls back to when its
+	// source is gone may be redacted and is not applied
+	if schema.ObjectMeta.DeletionTimestamp.IsZero() {
+		if _, err := r.CreateOrUpdate(ctx, schema, pc, *build); err != nil {
+			return err
+		}
+	}
+
 	if schema.ObjectMeta.DeletionTimestamp.IsZero() {
 		// The object is not being deleted, so if it does not have our finalizer,
 		// then lets add the finalizer and update the object. This is equivalent
 		// registering our finalizer.
 		if !controllerutil.ContainsFinalizer(schema, PinotSchemaControllerFinalizer) {
 			controllerutil.AddFinalizer(schema, PinotSchemaControllerFinalizer)
-			if err := r.Update(ctx, schema.DeepCopyObject().(*v1beta1.PinotSchema)); err != nil {
+			if err := r.update(ctx, schema); err != nil {
 				return nil
 			}
 		}
 	} else {
 		if controllerutil.ContainsFinalizer(schema, PinotSchemaControllerFinalizer) {
-			// our finalizer is present, so lets handle any external dependency
-			svcName, err := r.getControllerSvcUrl(schema.Namespace, schema.Spec.PinotCluster)
-			if err != nil {
-				return err
+			// a protected schema is kept until the annotation is removed
+			if r.isDeletionBlocked(schema, *build) {
+				return nil
 			}
 
-			schemaName, err := utils.GetValueFromJson(schema.Spec.PinotSchemaJson, schemaName)
-			if err != nil {
-				return err
-			}
-			http := internalHTTP.NewHTTPClient(
-				http.MethodDelete,
-				makeControllerGetUpdateDeleteSchemaPath(svcName, schemaName),
-				http.Client{},
-				[]byte{},
-				internalHTTP.Auth{BasicAuth: basicAuth},
-			)
-			respDeleteSchema, err := http.Do()
-			if err != nil {
+			// our finalizer is present, so lets handle any external dependency
+			if err := r.deleteSchema(ctx, schema, pc, *build); err != nil {
 				return err
 			}
-			if respDeleteSchema.StatusCode != 200 {
-				build.Recorder.GenericEvent(
-					schema,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respDeleteSchema.ResponseBody)),
-					PinotSchemaControllerDeleteFail,
-				)
-			} else {
-				build.Recorder.GenericEvent(
-					schema,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respDeleteSchema.ResponseBody)),
-					PinotSchemaControllerDeleteSuccess,
-				)
-			}
 
 			// remove our finalizer from the list and update it.
 			controllerutil.RemoveFinalizer(schema, PinotSchemaControllerFinalizer)
-			if err := r.Update(ctx, schema.DeepCopyObject().(*v1beta1.PinotSchema)); err != nil {
+			if err := r.update(ctx, schema); err != nil {
 				return nil
 			}
 		}
@@ -144,10 +145,10 @@ func (r *PinotSchemaReconciler) do(ctx context.Context, schema *v1beta1.PinotSch
 // Get Schema if does not exist create
 // if exists check for update
 func (r *PinotSchemaReconciler) CreateOrUpdate(
+	ctx context.Context,
 	schema *v1beta1.PinotSchema,
-	svcName string,
+	pc *pinot.Client,
 	build builder.Builder,
-	auth internalHTTP.Auth,
 ) (controllerutil.OperationResult, error) {
 
 	// get schema name
@@ -157,44 +158,26 @@ func (r *PinotSchemaReconciler) CreateOrUpdate(
 	}
 
 	// get schema
-	getHttp := internalHTTP.NewHTTPClient(
-		http.MethodGet,
-		makeControllerGetUpdateDeleteSchemaPath(svcName, schemaName),
-		http.Client{},
-		[]byte{},
-		auth,
-	)
-
-	respGetSchema, err := getHttp.Do()
-	if err != nil {
-		return controllerutil.OperationResultNone, err
-	}
+	respGetSchema, err := pc.GetSchema(ctx, schemaName)
 
 	// if not found create schema
 	// else check for updates
-	if respGetSchema.StatusCode == 404 {
+	if pinot.IsNotFound(err) {
 
 		// create schema
-		postHttp := internalHTTP.NewHTTPClient(
-			http.MethodPost,
-			makeControllerCreateSchemaPath(svcName),
-			http.Client{},
-			[]byte(schema.Spec.PinotSchemaJson),
-			auth,
-		)
-
-		respCreatechema, err := postHttp.Do()
-		if err != nil {
+		respCreateSchema, err := pc.CreateSchema(ctx, schema.Spec.PinotSchemaJson)
+		if err != nil && !pinot.IsAPIError(err) {
 			return controllerutil.OperationResultNone, err
 		}
 
-		if respCreatechema.StatusCode == 200 {
+		if err == nil {
 			result, err := r.makePatchPinotSchemaStatus(
 				schema,
 				PinotSchemaControllerCreateSuccess,
-				string(respCreatechema.ResponseBody),
+				respCreateSchema,
 				v1.ConditionTrue,
 				PinotSchemaControllerCreateSuccess,
+				nil,
 			)
 			if err != nil {
 				return controllerutil.OperationResultNone, err
@@ -202,139 +185,176 @@ func (r *PinotSchemaReconciler) CreateOrUpdate(
 			build.Recorder.GenericEvent(
 				schema,
 				v1.EventTypeNormal,
-				fmt.Sprintf("Resp [%s]", string(respCreatechema.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", respCreateSchema),
 				PinotSchemaControllerCreateSuccess,
 			)
 			build.Recorder.GenericEvent(
 				schema,
 				v1.EventTypeNormal,
-				fmt.Sprintf("Resp [%s], Result [%s]", string(respCreatechema.ResponseBody), result),
+				fmt.Sprintf("Resp [%s], Result [%s]", respCreateSchema, result),
 				PinotSchemaControllerPatchStatusSuccess)
 			return controllerutil.OperationResultCreated, nil
 
 		} else {
-			_, err := r.makePatchPinotSchemaStatus(
+			if _, err := r.makePatchPinotSchemaStatus(
 				schema,
 				PinotSchemaControllerCreateFail,
-				string(respCreatechema.ResponseBody),
+				err.Error(),
 				v1.ConditionTrue,
 				PinotSchemaControllerCreateFail,
-			)
-			if err != nil {
+				nil,
+			); err != nil {
 				return controllerutil.OperationResultNone, err
 			}
 			build.Recorder.GenericEvent(
 				schema,
 				v1.EventTypeWarning,
-				fmt.Sprintf("Resp [%s], Status", string(respCreatechema.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", err.Error()),
 				PinotSchemaControllerCreateFail,
 			)
-			return controllerutil.OperationResultCreated, nil
+			return controllerutil.OperationResultNone, nil
 
 		}
-	} else if respGetSchema.StatusCode == 200 {
+	} else if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
 
-		// at times of mis-match of state, where resource exists on pinot, but
-		// on creation status wasn't updated.
-		// get the current state ie schema and patch the status
-		if schema.Status.CurrentSchemasJson == "" {
-			build.Recorder.GenericEvent(
-				schema,
-				v1.EventTypeWarning,
-				fmt.Sprintf("Schema Exists on Pinot, but status is not updated"),
-				PinotSchemaControllerUpdateFail,
-			)
+	// at times of mis-match of state, where resource exists on pinot, but
+	// on creation status wasn't updated.
+	// get the current state ie schema and patch the status
+	if schema.Status.CurrentSchemasJson == "" {
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Schema Exists on Pinot, but status is not updated"),
+			PinotSchemaControllerUpdateFail,
+		)
+
+		_, err := r.makePatchPinotSchemaStatus(
+			schema,
+			PinotSchemaControllerCreateSuccess,
+			respGetSchema,
+			v1.ConditionTrue,
+			PinotSchemaControllerUpdateSuccess,
+			nil,
+		)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+	}
+
+	ok, err := utils.IsAppliedJson(schema.Status.CurrentSchemasJson, schema.Spec.PinotSchemaJson)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	// if desiredstate and currentstate not the same then update
+	if !ok {
+
+		// classify the change against the schema on pinot, breaking changes
+		// are only applied when allowed
+		change, err := classifySchemaChange(respGetSchema, schema.Spec.PinotSchemaJson)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
 
-			_, err := r.makePatchPinotSchemaStatus(
+		if change.Compatibility == v1beta1.SchemaChangeBreaking && !schema.Spec.AllowBreakingChanges {
+			return controllerutil.OperationResultNone, r.rejectSchemaChange(
 				schema,
-				PinotSchemaControllerCreateSuccess,
-				string(respGetSchema.ResponseBody),
-				v1.ConditionTrue,
-				PinotSchemaControllerUpdateSuccess,
+				change,
+				PinotSchemaControllerUpdateRefused,
+				"Breaking schema change refused, set allowBreakingChanges to apply it",
+				build,
 			)
-			if err != nil {
+		}
+
+		if _, err := pc.ValidateSchema(ctx, schema.Spec.PinotSchemaJson); err != nil {
+			if !pinot.IsAPIError(err) {
 				return controllerutil.OperationResultNone, err
 			}
+			return controllerutil.OperationResultNone, r.rejectSchemaChange(
+				schema,
+				change,
+				PinotSchemaControllerValidateFail,
+				err.Error(),
+				build,
+			)
 		}
 
-		ok, err := utils.IsEqualJson(schema.Status.CurrentSchemasJson, schema.Spec.PinotSchemaJson)
-		if err != nil {
+		respUpdateSchema, err := pc.UpdateSchema(ctx, schemaName, schema.Spec.PinotSchemaJson, schema.Spec.AllowBreakingChanges)
+		if err != nil && !pinot.IsAPIError(err) {
 			return controllerutil.OperationResultNone, err
 		}
 
-		// if desiredstate and currentstate not the same then update
-		if !ok {
-
-			postHttp := internalHTTP.NewHTTPClient(
-				http.MethodPut,
-				makeControllerGetUpdateDeleteSchemaPath(svcName, schemaName),
-				http.Client{},
-				[]byte(schema.Spec.PinotSchemaJson),
-				auth,
+		if err == nil {
+			// patch status to store the current valid schema json
+			result, err := r.makePatchPinotSchemaStatus(
+				schema,
+				PinotSchemaControllerUpdateSuccess,
+				respUpdateSchema,
+				v1.ConditionTrue,
+				PinotSchemaControllerUpdateSuccess,
+				&change,
 			)
-
-			respUpdateSchema, err := postHttp.Do()
 			if err != nil {
 				return controllerutil.OperationResultNone, err
 			}
+			build.Recorder.GenericEvent(
+				schema,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respUpdateSchema),
+				PinotSchemaControllerUpdateSuccess,
+			)
+			build.Recorder.GenericEvent(
+				schema,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s], Result [%s]", respUpdateSchema, result),
+				PinotSchemaControllerPatchStatusSuccess)
 
-			if respUpdateSchema.StatusCode == 200 {
-				// patch status to store the current valid schema json
-				result, err := r.makePatchPinotSchemaStatus(
-					schema,
-					PinotSchemaControllerUpdateSuccess,
-					string(respUpdateSchema.ResponseBody),
-					v1.ConditionTrue,
-					PinotSchemaControllerUpdateSuccess,
-				)
-				if err != nil {
-					return controllerutil.OperationResultNone, err
-				}
-				build.Recorder.GenericEvent(
-					schema,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respUpdateSchema.ResponseBody)),
-					PinotSchemaControllerUpdateSuccess,
-				)
-				build.Recorder.GenericEvent(
-					schema,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s], Result [%s]", string(respUpdateSchema.ResponseBody), result),
-					PinotSchemaControllerPatchStatusSuccess)
-
-				return controllerutil.OperationResultUpdated, nil
-			} else {
-				// patch status with failure and emit events
-				_, err := r.makePatchPinotSchemaStatus(
-					schema,
-					PinotSchemaControllerUpdateFail,
-					string(respGetSchema.ResponseBody),
-					v1.ConditionTrue,
-					PinotSchemaControllerUpdateFail,
-				)
-				if err != nil {
-					return controllerutil.OperationResultNone, err
-				}
-				build.Recorder.GenericEvent(
-					schema,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respUpdateSchema.ResponseBody)),
-					PinotSchemaControllerUpdateFail,
-				)
+			return controllerutil.OperationResultUpdated, nil
+		} else {
+			// patch status with failure and emit events
+			if _, err := r.makePatchPinotSchemaStatus(
+				schema,
+				PinotSchemaControllerUpdateFail,
+				err.Error(),
+				v1.ConditionTrue,
+				PinotSchemaControllerUpdateFail,
+				&change,
+			); err != nil {
 				return controllerutil.OperationResultNone, err
-
 			}
-		}
+			build.Recorder.GenericEvent(
+				schema,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotSchemaControllerUpdateFail,
+			)
+			return controllerutil.OperationResultNone, nil
 
+		}
 	}
 
-	return controllerutil.OperationResultNone, nil
-}
+	// a schema applied before the apply count was kept is counted once, the
+	// tables waiting for it are created and reload on its next apply
+	if schema.Status.ApplyCount == 0 {
+		if err := r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+			in := obj.(*v1beta1.PinotSchema)
+			if in.Status.ApplyCount == 0 {
+				in.Status.ApplyCount = 1
+			}
+			return in
+		}); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+	}
 
-func makeControllerCreateSchemaPath(svcName string) string { return svcName + "/schemas" }
+	if err := r.redactStatus(ctx, schema); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
 
-func makeControllerGetUpdateDeleteSchemaPath(svcName, schemaName string) string {
-	return svcName + "/schemas/" + schemaName
+	// the spec is applied, compare it with the live schema on pinot
+	return r.reconcileDrift(ctx, schema, pc, schemaName, respGetSchema, build)
 }
 
 func (r *PinotSchemaReconciler) makePatchPinotSchemaStatus(
@@ -343,17 +363,29 @@ func (r *PinotSchemaReconciler) makePatchPinotSchemaStatus(
 	reason string,
 	status v1.ConditionStatus,
 	pinotSchemaConditionType string,
+	change *v1beta1.PinotSchemaChange,
 
 ) (controllerutil.OperationResult, error) {
 
-	if _, _, err := utils.PatchStatus(context.Background(), r.Client, schema, func(obj client.Object) client.Object {
+	schemaJson, err := statusSchemaJson(schema, schema.Spec.PinotSchemaJson)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if err := r.patchStatus(context.Background(), schema, func(obj client.Object) client.Object {
 		in := obj.(*v1beta1.PinotSchema)
-		in.Status.CurrentSchemasJson = schema.Spec.PinotSchemaJson
+		in.Status.CurrentSchemasJson = schemaJson
 		in.Status.LastUpdateTime = metav1.Time{Time: time.Now()}
 		in.Status.Message = msg
 		in.Status.Reason = reason
 		in.Status.Status = status
 		in.Status.Type = pinotSchemaConditionType
+		if pinotSchemaConditionType == PinotSchemaControllerCreateSuccess || pinotSchemaConditionType == PinotSchemaControllerUpdateSuccess {
+			in.Status.ApplyCount++
+			in.Status.Revisions = utils.AppendRevision(in.Status.Revisions, schemaJson, in.Generation, reason, in.Spec.RevisionHistoryLimit)
+		}
+		if change != nil {
+			in.Status.LastChange = change
+		}
 		return in
 	}); err != nil {
 		return controllerutil.OperationResultNone, err
@@ -362,59 +394,111 @@ func (r *PinotSchemaReconciler) makePatchPinotSchemaStatus(
 	return controllerutil.OperationResultUpdatedStatusOnly, nil
 }
 
-func (r *PinotSchemaReconciler) getControllerSvcUrl(namespace, pinotClusterName string) (string, error) {
-	listOpts := []client.ListOption{
-		client.InNamespace(namespace),
-		client.MatchingLabels(map[string]string{
-			"custom_resource": pinotClusterName,
-			"nodeType":        "controller",
-		}),
-	}
-	svcList := &v1.ServiceList{}
-	if err := r.Client.List(context.Background(), svcList, listOpts...); err != nil {
-		return "", err
+// rejectSchemaChange reports a schema change that was not applied in the
+// status, the applied schema is kept so the change is classified again on the
+// next reconcile. Events are only emitted when the status changes.
+func (r *PinotSchemaReconciler) rejectSchemaChange(
+	schema *v1beta1.PinotSchema,
+	change v1beta1.PinotSchemaChange,
+	pinotSchemaConditionType string,
+	reason string,
+	build builder.Builder,
+) error {
+
+	if schema.Status.Type == pinotSchemaConditionType &&
+		schema.Status.Reason == reason &&
+		reflect.DeepEqual(schema.Status.LastChange, &change) {
+		return nil
 	}
-	var svcName string
 
-	for range svcList.Items {
-		svcName = svcList.Items[0].Name
+	if err := r.patchStatus(context.Background(), schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		in.Status.LastUpdateTime = metav1.Time{Time: time.Now()}
+		in.Status.Message = pinotSchemaConditionType
+		in.Status.Reason = reason
+		in.Status.Status = v1.ConditionTrue
+		in.Status.Type = pinotSchemaConditionType
+		in.Status.LastChange = &change
+		return in
+	}); err != nil {
+		return err
 	}
 
-	newName := "http://" + svcName + "." + namespace + ".svc.cluster.local:" + PinotControllerPort
+	build.Recorder.GenericEvent(
+		schema,
+		v1.EventTypeWarning,
+		fmt.Sprintf("Resp [%s], Changes [%s]", reason, strings.Join(change.Changes, "; ")),
+		pinotSchemaConditionType,
+	)
+	return nil
+}
 
-	return newName, nil
+// patchUnreachableCondition sets the Unreachable condition of the schema from
+// the circuit breaker of its pinot controller.
+func (r *PinotSchemaReconciler) patchUnreachableCondition(ctx context.Context, schema *v1beta1.PinotSchema, pc *pinot.Client) error {
+	condition, changed := utils.NewUnreachableCondition(schema.Status.Conditions, pc, schema.Generation)
+	if !changed {
+		return nil
+	}
+
+	return r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	})
 }
 
-func (r *PinotSchemaReconciler) getAuthCreds(ctx context.Context, schema *v1beta1.PinotSchema) (internalHTTP.BasicAuth, error) {
-	pinot := v1beta1.Pinot{}
+// getPinotClient returns a client for the controller of the pinot cluster of the schema
+func (r *PinotSchemaReconciler) getPinotClient(ctx context.Context, schema *v1beta1.PinotSchema) (*pinot.Client, error) {
+	if schema.Spec.PinotExternalCluster != "" {
+		return utils.GetExternalClusterClient(ctx, r.Client, schema.Namespace, schema.Spec.PinotExternalCluster)
+	}
+
+	pt := &v1beta1.Pinot{}
 	if err := r.Client.Get(ctx, types.NamespacedName{
 		Namespace: schema.Namespace,
 		Name:      schema.Spec.PinotCluster,
 	},
-		&pinot,
+		pt,
 	); err != nil {
-		return internalHTTP.BasicAuth{}, err
+		return nil, err
 	}
 
-	if pinot.Spec.Auth != (v1beta1.Auth{}) {
-		secret := v1.Secret{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Namespace: pinot.Spec.Auth.SecretRef.Namespace,
-			Name:      pinot.Spec.Auth.SecretRef.Name,
-		},
-			&secret,
-		); err != nil {
-			return internalHTTP.BasicAuth{}, err
-		}
+	svcName, err := r.getControllerSvcUrl(pt)
+	if err != nil {
+		return nil, err
+	}
 
-		creds := internalHTTP.BasicAuth{
-			UserName: string(secret.Data[ControlPlaneUserName]),
-			Password: string(secret.Data[ControlPlanePassword]),
-		}
+	auth, err := utils.GetControllerAuth(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
 
-		return creds, nil
+	tlsConfig, err := utils.GetControllerTLSConfig(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
 
+	return pinot.NewClient(svcName, auth, tlsConfig)
+}
+
+func (r *PinotSchemaReconciler) getControllerSvcUrl(pt *v1beta1.Pinot) (string, error) {
+	listOpts := []client.ListOption{
+		client.InNamespace(pt.Namespace),
+		client.MatchingLabels(map[string]string{
+			"custom_resource": pt.Name,
+			"nodeType":        "controller",
+		}),
+	}
+	svcList := &v1.ServiceList{}
+	if err := r.Client.List(context.Background(), svcList, listOpts...); err != nil {
+		return "", err
+	}
+	var svcName string
+
+	for range svcList.Items {
+		svcName = svcList.Items[0].Name
 	}
 
-	return internalHTTP.BasicAuth{}, nil
+	return utils.GetControllerURL(pt, svcName), nil
 }
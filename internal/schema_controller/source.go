@@ -0,0 +1,165 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schemacontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SchemaJson returns the schema json of the spec, the json inlined in the
+// spec, read from schemaFrom or rendered from the typed schema.
+func SchemaJson(ctx context.Context, c client.Client, schema *v1beta1.PinotSchema) (string, error) {
+	if schema.Spec.Schema != nil {
+		schemaJson, err := json.Marshal(schema.Spec.Schema)
+		if err != nil {
+			return "", err
+		}
+		return string(schemaJson), nil
+	}
+	return utils.GetJsonOrSource(ctx, c, schema.Namespace, schema.Spec.PinotSchemaJson, schema.Spec.SchemaFrom)
+}
+
+// resolveSchemaJson sets the schema json of the spec from schemaFrom or the
+// typed schema, the spec is only changed in memory.
+func (r *PinotSchemaReconciler) resolveSchemaJson(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	build builder.Builder,
+) error {
+
+	if !isResolved(schema) {
+		return nil
+	}
+
+	if err := utils.ResolveJson(schema, &schema.Spec.PinotSchemaJson, schema.Status.CurrentSchemasJson, func() (string, error) {
+		return SchemaJson(ctx, r.Client, schema)
+	}); err != nil {
+		build.Recorder.GenericEvent(
+			schema,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotSchemaControllerSourceFail,
+		)
+		return err
+	}
+	return nil
+}
+
+// isResolved returns true when the schema json of the spec is read from
+// schemaFrom or rendered from the typed schema.
+func isResolved(schema *v1beta1.PinotSchema) bool {
+	return schema.Spec.SchemaFrom != nil || schema.Spec.Schema != nil
+}
+
+// statusSchemaJson returns the schema json stored in the status and the
+// revisions, a schema read from a Secret only keeps its name and digest.
+func statusSchemaJson(schema *v1beta1.PinotSchema, schemaJson string) (string, error) {
+	return utils.StatusJson(schema.Spec.SchemaFrom, schemaJson, schemaName)
+}
+
+// update updates the schema, a schema json resolved from schemaFrom or the
+// typed schema is not stored in the spec.
+func (r *PinotSchemaReconciler) update(ctx context.Context, schema *v1beta1.PinotSchema) error {
+	return utils.UpdateResolved(ctx, r.Client, schema, &schema.Spec.PinotSchemaJson, isResolved(schema))
+}
+
+// patchStatus patches the status, the resolved schema json is kept in the
+// spec.
+func (r *PinotSchemaReconciler) patchStatus(
+	ctx context.Context,
+	schema *v1beta1.PinotSchema,
+	transform utils.TransformStatusFunc,
+) error {
+	return utils.PatchResolvedStatus(ctx, r.Client, schema, &schema.Spec.PinotSchemaJson, transform)
+}
+
+// redactStatus keeps only the digest of a schema read from a Secret in the
+// status and the revisions, for schemas applied before it was redacted.
+func (r *PinotSchemaReconciler) redactStatus(ctx context.Context, schema *v1beta1.PinotSchema) error {
+	if !utils.IsSecretSource(schema.Spec.SchemaFrom) || utils.IsSecretDigest(schema.Status.CurrentSchemasJson) {
+		return nil
+	}
+
+	currentSchemaJson, err := statusSchemaJson(schema, schema.Status.CurrentSchemasJson)
+	if err != nil {
+		return err
+	}
+	revisions, err := utils.RedactRevisions(schema.Status.Revisions, schemaName)
+	if err != nil {
+		return err
+	}
+
+	if err := r.patchStatus(ctx, schema, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotSchema)
+		in.Status.CurrentSchemasJson = currentSchemaJson
+		in.Status.Revisions = revisions
+		return in
+	}); err != nil {
+		return err
+	}
+	schema.Status.CurrentSchemasJson = currentSchemaJson
+	schema.Status.Revisions = revisions
+	return nil
+}
+
+// indexSchemaSource returns the source of the schema of a PinotSchema for the
+// utils.SourceIndexField index.
+func indexSchemaSource(obj client.Object) []string {
+	schema, ok := obj.(*v1beta1.PinotSchema)
+	if !ok {
+		return nil
+	}
+	return utils.SourceIndexValues(schema.Spec.SchemaFrom)
+}
+
+// findSchemasForSource maps a ConfigMap or a Secret to the schemas reading
+// their schema from it.
+func (r *PinotSchemaReconciler) findSchemasForSource(obj client.Object) []reconcile.Request {
+	key := utils.SourceIndexKey(obj)
+	if key == "" {
+		return nil
+	}
+
+	schemaList := v1beta1.PinotSchemaList{}
+	if err := r.List(
+		context.TODO(),
+		&schemaList,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{utils.SourceIndexField: key},
+	); err != nil {
+		r.Log.Error(err, "Error listing schemas for source")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, schema := range schemaList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: schema.Namespace, Name: schema.Name},
+		})
+	}
+	return requests
+}
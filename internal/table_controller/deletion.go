@@ -0,0 +1,216 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	metadata      = "metadata"
+	customConfigs = "customConfigs"
+)
+
+// isDeletionBlocked returns true when the deletion protection annotation keeps
+// the deleted table, nothing is deleted from pinot until it is removed.
+func (r *PinotTableReconciler) isDeletionBlocked(table *v1beta1.PinotTable, build builder.Builder) bool {
+	if !utils.IsDeletionProtected(table.Annotations, deletionProtectionAnnotation) {
+		return false
+	}
+
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeWarning,
+		fmt.Sprintf("Deletion blocked by annotation [%s], remove it to delete the table", deletionProtectionAnnotation),
+		PinotTableControllerDeleteBlocked,
+	)
+	return true
+}
+
+// deleteTable deletes the table of a deleted CR from pinot, or keeps it when
+// the deletion policy is Retain or Orphan.
+func (r *PinotTableReconciler) deleteTable(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return err
+	}
+
+	switch table.Spec.DeletionPolicy {
+	case v1beta1.DeletionPolicyRetain:
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Table [%s] kept on pinot, deletion policy [%s]", tableName, table.Spec.DeletionPolicy),
+			PinotTableControllerRetained,
+		)
+		return nil
+	case v1beta1.DeletionPolicyOrphan:
+		return r.orphanTable(ctx, table, pc, tableName, build)
+	}
+
+	// the table types of a hybrid table are deleted one by one
+	tableTypes := []string{""}
+	if isHybridTable(table) {
+		tableTypes = hybridTableTypes
+	}
+
+	for _, tableType := range tableTypes {
+		respDeleteTable, err := pc.DeleteTable(ctx, tableName, tableType)
+		if err != nil && !pinot.IsAPIError(err) {
+			return err
+		}
+		if err != nil && !pinot.IsNotFound(err) {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotTableControllerDeleteFail,
+			)
+		} else {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respDeleteTable),
+				PinotTableControllerDeleteSuccess,
+			)
+		}
+	}
+	return nil
+}
+
+// orphanTable keeps the table on pinot and labels it with the CR it was
+// orphaned from in the custom configs of its metadata.
+func (r *PinotTableReconciler) orphanTable(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	tableName string,
+	build builder.Builder,
+) error {
+
+	orphanedFrom := table.Namespace + "/" + table.Name
+
+	respGetTable, err := pc.GetTable(ctx, tableName)
+	if err != nil && !pinot.IsAPIError(err) {
+		return err
+	}
+	if err != nil && !pinot.IsNotFound(err) {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Table [%s] kept on pinot, labelling failed, Resp [%s]", tableName, err.Error()),
+			PinotTableControllerOrphanFail,
+		)
+		return nil
+	}
+
+	orphaned := false
+	for _, tableJson := range desiredTableJsons(table) {
+		liveTable := ""
+		if respGetTable != "" {
+			if liveTable, err = liveTableJson(tableJson, respGetTable); err != nil {
+				return err
+			}
+		}
+		if liveTable == "" {
+			continue
+		}
+		orphaned = true
+
+		orphanedTable, err := labelOrphanedTable(liveTable, orphanedFrom)
+		if err != nil {
+			return err
+		}
+		name, err := updateTableName(table, tableName, tableJson)
+		if err != nil {
+			return err
+		}
+
+		respUpdateTable, err := pc.UpdateTable(ctx, name, orphanedTable)
+		if err != nil && !pinot.IsAPIError(err) {
+			return err
+		}
+		if err != nil {
+			// the table is kept on pinot without the label
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Table [%s] kept on pinot, labelling failed, Resp [%s]", name, err.Error()),
+				PinotTableControllerOrphanFail,
+			)
+			continue
+		}
+
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Table [%s] kept on pinot with custom config [%s: %s], Resp [%s]", name, v1beta1.OrphanedFromLabel, orphanedFrom, respUpdateTable),
+			PinotTableControllerOrphaned,
+		)
+	}
+
+	if !orphaned {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Table [%s] not found on pinot, nothing to orphan", tableName),
+			PinotTableControllerOrphaned,
+		)
+	}
+	return nil
+}
+
+// labelOrphanedTable sets the orphaned from label in the custom configs of the
+// table config.
+func labelOrphanedTable(tableJson, orphanedFrom string) (string, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(tableJson), &config); err != nil {
+		return "", err
+	}
+
+	meta, ok := config[metadata].(map[string]interface{})
+	if !ok {
+		meta = map[string]interface{}{}
+		config[metadata] = meta
+	}
+	configs, ok := meta[customConfigs].(map[string]interface{})
+	if !ok {
+		configs = map[string]interface{}{}
+		meta[customConfigs] = configs
+	}
+	configs[v1beta1.OrphanedFromLabel] = orphanedFrom
+
+	labelled, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(labelled), nil
+}
@@ -0,0 +1,308 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// defaultTenant is the tenant of pinot used by table configs without
+	// tenants, it is not managed by a PinotTenant
+	defaultTenant = "DefaultTenant"
+
+	pendingSchemaNotFound   = "SchemaNotFound"
+	pendingSchemaNotApplied = "SchemaNotApplied"
+	pendingTenantNotFound   = "TenantNotFound"
+	pendingTenantNotApplied = "TenantNotApplied"
+	dependenciesApplied     = "DependenciesApplied"
+)
+
+// tenantRef is a tenant named in a table config.
+type tenantRef struct {
+	name       string
+	tenantType v1beta1.PinotTenantType
+}
+
+// tableTenants returns the broker and server tenants named in the table
+// configs, the default tenant is left out.
+func tableTenants(tableJsons []string) ([]tenantRef, error) {
+	refs := []tenantRef{}
+	seen := map[tenantRef]bool{}
+	for _, tableJson := range tableJsons {
+		config := struct {
+			Tenants struct {
+				Broker string `json:"broker"`
+				Server string `json:"server"`
+			} `json:"tenants"`
+		}{}
+		if err := json.Unmarshal([]byte(tableJson), &config); err != nil {
+			return nil, err
+		}
+
+		for _, ref := range []tenantRef{
+			{config.Tenants.Broker, v1beta1.BrokerTenant},
+			{config.Tenants.Server, v1beta1.ServerTenant},
+		} {
+			if ref.name == "" || ref.name == defaultTenant || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// isSchemaOnPinot is true when the schema of a PinotSchema without an apply
+// count is on pinot, as for schemas applied before the count was kept.
+func isSchemaOnPinot(ctx context.Context, pc *pinot.Client, schema *v1beta1.PinotSchema) (bool, error) {
+	if schema.Status.CurrentSchemasJson == "" {
+		return false, nil
+	}
+	schemaName, err := utils.GetValueFromJson(schema.Status.CurrentSchemasJson, utils.SchemaName)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := pc.GetSchema(ctx, schemaName); err != nil {
+		if pinot.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isTenantOnPinot is true when pinot has instances of the type of the tenant,
+// for tenants created without a PinotTenant or applied before the apply count
+// was kept.
+func isTenantOnPinot(ctx context.Context, pc *pinot.Client, ref tenantRef) (bool, error) {
+	respGetTenant, err := pc.GetTenant(ctx, ref.name)
+	if err != nil {
+		if pinot.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	instances := struct {
+		ServerInstances []string `json:"ServerInstances"`
+		BrokerInstances []string `json:"BrokerInstances"`
+	}{}
+	if err := json.Unmarshal([]byte(respGetTenant), &instances); err != nil {
+		return false, err
+	}
+	if ref.tenantType == v1beta1.BrokerTenant {
+		return len(instances.BrokerInstances) > 0, nil
+	}
+	return len(instances.ServerInstances) > 0, nil
+}
+
+// isTableCreated is true once the table was created on pinot. A failed create
+// records the table json in the status too, a table without revisions is
+// looked up on pinot.
+func isTableCreated(ctx context.Context, pc *pinot.Client, table *v1beta1.PinotTable) (bool, error) {
+	if table.Status.CurrentTableJson == "" {
+		return false, nil
+	}
+	if len(table.Status.Revisions) > 0 {
+		return true, nil
+	}
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return false, err
+	}
+	if _, err := pc.GetTable(ctx, tableName); err != nil {
+		if pinot.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// pendingDependency returns the reason and the message of the first schema or
+// tenant of the table not applied to pinot yet, an empty reason when they all
+// are. A schema or tenant without an apply count is looked up on pinot.
+func (r *PinotTableReconciler) pendingDependency(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+) (string, string, error) {
+
+	schema := &v1beta1.PinotSchema{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: table.Namespace, Name: table.Spec.PinotSchema}, schema); err != nil {
+		if errors.IsNotFound(err) {
+			return pendingSchemaNotFound, fmt.Sprintf("Waiting for PinotSchema [%s] to be created", table.Spec.PinotSchema), nil
+		}
+		return "", "", err
+	}
+	if schema.Status.ApplyCount == 0 {
+		applied, err := isSchemaOnPinot(ctx, pc, schema)
+		if err != nil {
+			return "", "", err
+		}
+		if !applied {
+			return pendingSchemaNotApplied, fmt.Sprintf("Waiting for PinotSchema [%s] to be applied to pinot", schema.Name), nil
+		}
+	}
+
+	refs, err := tableTenants(desiredTableJsons(table))
+	if err != nil {
+		return "", "", err
+	}
+	if len(refs) == 0 {
+		return "", "", nil
+	}
+
+	tenantList := v1beta1.PinotTenantList{}
+	if err := r.List(ctx, &tenantList, client.InNamespace(table.Namespace)); err != nil {
+		return "", "", err
+	}
+
+	for _, ref := range refs {
+		var found *v1beta1.PinotTenant
+		for i := range tenantList.Items {
+			tenant := &tenantList.Items[i]
+			tenantName, err := utils.GetValueFromJson(tenant.Spec.PinotTenantsJson, utils.TenantName)
+			if err != nil {
+				continue
+			}
+			if tenantName == ref.name && tenant.Spec.PinotTenantType == ref.tenantType {
+				found = tenant
+				break
+			}
+		}
+
+		if found != nil && found.Status.ApplyCount > 0 {
+			continue
+		}
+
+		onPinot, err := isTenantOnPinot(ctx, pc, ref)
+		if err != nil {
+			return "", "", err
+		}
+		switch {
+		case onPinot:
+			continue
+		case found == nil:
+			return pendingTenantNotFound, fmt.Sprintf("Waiting for %s tenant [%s] to be created on pinot or by a PinotTenant", ref.tenantType, ref.name), nil
+		default:
+			return pendingTenantNotApplied, fmt.Sprintf("Waiting for PinotTenant [%s] of %s tenant [%s] to be applied to pinot", found.Name, ref.tenantType, ref.name), nil
+		}
+	}
+	return "", "", nil
+}
+
+// waitForDependencies sets the Pending condition while the schema or a tenant
+// of the table is not applied to pinot, it returns true when the table has to
+// wait for them.
+func (r *PinotTableReconciler) waitForDependencies(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) (bool, error) {
+
+	reason, msg, err := r.pendingDependency(ctx, table, pc)
+	if err != nil {
+		return false, err
+	}
+
+	current := meta.FindStatusCondition(table.Status.Conditions, v1beta1.ConditionPending)
+	if reason == "" {
+		// the condition is only set on tables that waited
+		if current != nil && current.Status == metav1.ConditionTrue {
+			return false, r.patchPendingCondition(ctx, table, metav1.ConditionFalse, dependenciesApplied, "Schema and tenants are applied to pinot")
+		}
+		return false, nil
+	}
+
+	if current != nil && current.Status == metav1.ConditionTrue && current.Reason == reason && current.Message == msg {
+		return true, nil
+	}
+
+	if err := r.patchPendingCondition(ctx, table, metav1.ConditionTrue, reason, msg); err != nil {
+		return false, err
+	}
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeNormal,
+		msg,
+		PinotTableControllerPending,
+	)
+	return true, nil
+}
+
+// patchPendingCondition sets the Pending condition.
+func (r *PinotTableReconciler) patchPendingCondition(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	status metav1.ConditionStatus,
+	reason string,
+	msg string,
+) error {
+
+	condition := metav1.Condition{
+		Type:               v1beta1.ConditionPending,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: table.Generation,
+	}
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	})
+}
+
+// findPendingTables maps a PinotTenant to the tables waiting for a schema or a
+// tenant in its namespace.
+func (r *PinotTableReconciler) findPendingTables(obj client.Object) []reconcile.Request {
+	tableList := v1beta1.PinotTableList{}
+	if err := r.List(context.TODO(), &tableList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Error listing tables for tenant")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, table := range tableList.Items {
+		if meta.IsStatusConditionTrue(table.Status.Conditions, v1beta1.ConditionPending) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: table.Namespace, Name: table.Name},
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,247 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tablecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const tableType = "tableType"
+
+// liveTableJson returns the config pinot holds for the table type of
+// tableJson, with the type suffix pinot adds to the table name removed. An
+// empty string is returned when pinot has no config of that type.
+func liveTableJson(tableJson, respGetTable string) (string, error) {
+	desiredType, err := utils.GetValueFromJson(tableJson, tableType)
+	if err != nil {
+		return "", err
+	}
+	desiredType = strings.ToUpper(desiredType)
+
+	configs := map[string]map[string]interface{}{}
+	if err := json.Unmarshal([]byte(respGetTable), &configs); err != nil {
+		return "", err
+	}
+
+	config, ok := configs[desiredType]
+	if !ok {
+		return "", nil
+	}
+	if name, ok := config[utils.TableName].(string); ok {
+		config[utils.TableName] = strings.TrimSuffix(name, "_"+desiredType)
+	}
+
+	live, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(live), nil
+}
+
+// tableDrift returns the differences between a table config of the spec and
+// the live table config of its type, the differences of a hybrid table are
+// prefixed with the table type. The values of a table config read from a
+// Secret are not reported.
+func tableDrift(table *v1beta1.PinotTable, tableJson, liveTable string) ([]string, error) {
+	if liveTable == "" {
+		desiredType, err := tableTypeOf(tableJson)
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("%s: %s table missing in pinot", tableType, desiredType)}, nil
+	}
+
+	diffs, err := utils.JsonDrift(tableJson, liveTable)
+	if err != nil {
+		return nil, err
+	}
+	if utils.IsSecretSource(table.Spec.TableFrom) {
+		return utils.RedactDrift(diffs), nil
+	}
+	if !isHybridTable(table) {
+		return diffs, nil
+	}
+	desiredType, err := tableTypeOf(tableJson)
+	if err != nil {
+		return nil, err
+	}
+	for i := range diffs {
+		diffs[i] = desiredType + "." + diffs[i]
+	}
+	return diffs, nil
+}
+
+// reconcileDrift compares the applied spec with the live table config on pinot
+// to catch changes made outside of the control plane, they are corrected or
+// only reported depending on the drift policy. Both table types of a hybrid
+// table are compared.
+func (r *PinotTableReconciler) reconcileDrift(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	tableName string,
+	respGetTable string,
+	build builder.Builder,
+) (controllerutil.OperationResult, error) {
+
+	tableJsons := desiredTableJsons(table)
+	liveTables := make([]string, len(tableJsons))
+	drifted := make([]bool, len(tableJsons))
+	diffs := []string{}
+	for i, tableJson := range tableJsons {
+		if respGetTable != "" {
+			var err error
+			if liveTables[i], err = liveTableJson(tableJson, respGetTable); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+		}
+		tableDiffs, err := tableDrift(table, tableJson, liveTables[i])
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		drifted[i] = len(tableDiffs) > 0
+		diffs = append(diffs, tableDiffs...)
+	}
+
+	if len(diffs) == 0 {
+		// keep the reason of a corrected drift until the next one
+		if meta.IsStatusConditionFalse(table.Status.Conditions, v1beta1.ConditionDrifted) {
+			return controllerutil.OperationResultNone, nil
+		}
+		_, err := r.patchDriftedCondition(ctx, table, metav1.ConditionFalse, PinotTableControllerInSync, "Table matches pinot")
+		return controllerutil.OperationResultNone, err
+	}
+
+	msg := utils.DriftMessage(diffs)
+
+	if !utils.IsDriftCorrected(table.Spec.DriftPolicy) {
+		changed, err := r.patchDriftedCondition(ctx, table, metav1.ConditionTrue, PinotTableControllerDrifted, msg)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		if changed {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Table differs from pinot [%s]", msg),
+				PinotTableControllerDrifted,
+			)
+		}
+		return controllerutil.OperationResultNone, nil
+	}
+
+	// a table type deleted on pinot is added again
+	resps := []string{}
+	var err error
+	for i, tableJson := range tableJsons {
+		if !drifted[i] {
+			continue
+		}
+		var respCorrectTable string
+		if liveTables[i] == "" {
+			respCorrectTable, err = pc.CreateTable(ctx, tableJson)
+		} else {
+			var name string
+			if name, err = updateTableName(table, tableName, tableJson); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			respCorrectTable, err = pc.UpdateTable(ctx, name, tableJson)
+		}
+		if err != nil && !pinot.IsAPIError(err) {
+			return controllerutil.OperationResultNone, err
+		}
+		if err != nil {
+			break
+		}
+		resps = append(resps, respCorrectTable)
+	}
+	respCorrectTable := strings.Join(resps, ", ")
+
+	if err != nil {
+		changed, patchErr := r.patchDriftedCondition(
+			ctx,
+			table,
+			metav1.ConditionTrue,
+			PinotTableControllerDriftCorrectFail,
+			fmt.Sprintf("%s, correcting failed [%s]", msg, err.Error()),
+		)
+		if patchErr != nil {
+			return controllerutil.OperationResultNone, patchErr
+		}
+		if changed {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotTableControllerDriftCorrectFail,
+			)
+		}
+		return controllerutil.OperationResultNone, nil
+	}
+
+	if _, err := r.patchDriftedCondition(ctx, table, metav1.ConditionFalse, PinotTableControllerDriftCorrected, msg); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Corrected [%s], Resp [%s]", msg, respCorrectTable),
+		PinotTableControllerDriftCorrected,
+	)
+
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// patchDriftedCondition sets the Drifted condition, it returns false without
+// patching when the condition is unchanged.
+func (r *PinotTableReconciler) patchDriftedCondition(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	status metav1.ConditionStatus,
+	reason string,
+	msg string,
+) (bool, error) {
+
+	condition, changed := utils.NewDriftedCondition(table.Status.Conditions, status, reason, msg, table.Generation)
+	if !changed {
+		return false, nil
+	}
+
+	if err := r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
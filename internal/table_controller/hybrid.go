@@ -0,0 +1,356 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// hybridTableTypes are the table types of a hybrid table in the order they
+// are applied.
+var hybridTableTypes = []string{pinot.TableTypeOffline, pinot.TableTypeRealtime}
+
+func isHybridTable(table *v1beta1.PinotTable) bool {
+	return table.Spec.PinotTableType == v1beta1.HybridTable
+}
+
+// desiredTableJsons returns the table configs of the spec, the OFFLINE and
+// REALTIME table configs of a hybrid table.
+func desiredTableJsons(table *v1beta1.PinotTable) []string {
+	if isHybridTable(table) {
+		return []string{table.Spec.OfflineTablesJson, table.Spec.RealtimeTablesJson}
+	}
+	return []string{table.Spec.PinotTablesJson}
+}
+
+// specTableName returns the table name of the spec, both table configs of a
+// hybrid table have it.
+func specTableName(table *v1beta1.PinotTable) (string, error) {
+	return utils.GetValueFromJson(desiredTableJsons(table)[0], utils.TableName)
+}
+
+// tableTypeOf returns the table type of a table config in upper case.
+func tableTypeOf(tableJson string) (string, error) {
+	desiredType, err := utils.GetValueFromJson(tableJson, tableType)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(desiredType), nil
+}
+
+// updateTableName is the name a table config is updated with, the halves of a
+// hybrid table are updated with their type qualified name.
+func updateTableName(table *v1beta1.PinotTable, tableName, tableJson string) (string, error) {
+	if !isHybridTable(table) {
+		return tableName, nil
+	}
+	desiredType, err := tableTypeOf(tableJson)
+	if err != nil {
+		return "", err
+	}
+	return tableName + "_" + desiredType, nil
+}
+
+// hybridTableJson returns the table configs of a hybrid table keyed by table
+// type as pinot returns them, it is the table json of the revisions and the
+// status of a hybrid table. Empty table configs are left out.
+func hybridTableJson(offlineJson, realtimeJson string) (string, error) {
+	configs := map[string]json.RawMessage{}
+	if offlineJson != "" {
+		configs[pinot.TableTypeOffline] = json.RawMessage(offlineJson)
+	}
+	if realtimeJson != "" {
+		configs[pinot.TableTypeRealtime] = json.RawMessage(realtimeJson)
+	}
+
+	hybrid, err := json.Marshal(configs)
+	if err != nil {
+		return "", err
+	}
+	return string(hybrid), nil
+}
+
+// splitHybridTableJson returns the OFFLINE and REALTIME table configs of a
+// hybrid table json.
+func splitHybridTableJson(hybridJson string) (string, string, error) {
+	configs := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(hybridJson), &configs); err != nil {
+		return "", "", err
+	}
+
+	offlineJson, realtimeJson := configs[pinot.TableTypeOffline], configs[pinot.TableTypeRealtime]
+	if len(offlineJson) == 0 || len(realtimeJson) == 0 {
+		return "", "", fmt.Errorf("table json is not the %s and %s table configs of a hybrid table", pinot.TableTypeOffline, pinot.TableTypeRealtime)
+	}
+	return string(offlineJson), string(realtimeJson), nil
+}
+
+// validateHybridTable checks that the table configs of a hybrid table are an
+// OFFLINE and a REALTIME config of the same table.
+func validateHybridTable(table *v1beta1.PinotTable) error {
+	tableName, err := specTableName(table)
+	if err != nil {
+		return err
+	}
+
+	for i, tableJson := range desiredTableJsons(table) {
+		desiredType, err := tableTypeOf(tableJson)
+		if err != nil {
+			return err
+		}
+		if desiredType != hybridTableTypes[i] {
+			return fmt.Errorf("%s table config has tableType [%s]", hybridTableTypes[i], desiredType)
+		}
+
+		name, err := utils.GetValueFromJson(tableJson, utils.TableName)
+		if err != nil {
+			return err
+		}
+		if name != tableName {
+			return fmt.Errorf("%s table config has tableName [%s], the %s table config [%s]", desiredType, name, pinot.TableTypeOffline, tableName)
+		}
+	}
+	return nil
+}
+
+// resolveHybridTableJson sets the table json of a hybrid table in the spec,
+// the spec is only changed in memory.
+func (r *PinotTableReconciler) resolveHybridTableJson(table *v1beta1.PinotTable, build builder.Builder) error {
+	err := validateHybridTable(table)
+	if err == nil {
+		table.Spec.PinotTablesJson, err = hybridTableJson(table.Spec.OfflineTablesJson, table.Spec.RealtimeTablesJson)
+	}
+	if err != nil {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotTableControllerHybridInvalid,
+		)
+		return err
+	}
+	return nil
+}
+
+// getHybridTableStatus returns the status of a table type of a hybrid table.
+func getHybridTableStatus(statuses []v1beta1.PinotTableTypeStatus, tableType string) v1beta1.PinotTableTypeStatus {
+	for _, status := range statuses {
+		if status.TableType == tableType {
+			return status
+		}
+	}
+	return v1beta1.PinotTableTypeStatus{TableType: tableType}
+}
+
+// setHybridTableStatus adds or replaces the status of a table type.
+func setHybridTableStatus(statuses *[]v1beta1.PinotTableTypeStatus, status v1beta1.PinotTableTypeStatus) {
+	for i := range *statuses {
+		if (*statuses)[i].TableType == status.TableType {
+			(*statuses)[i] = status
+			return
+		}
+	}
+	*statuses = append(*statuses, status)
+}
+
+// createOrUpdateHybrid creates or updates the OFFLINE and REALTIME tables of a
+// hybrid table, each reports its status in status.tables. The live tables are
+// compared with the spec once both are applied.
+func (r *PinotTableReconciler) createOrUpdateHybrid(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) (controllerutil.OperationResult, error) {
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	respGetTable, err := pc.GetTable(ctx, tableName)
+	if err != nil && !pinot.IsNotFound(err) {
+		return controllerutil.OperationResultNone, err
+	}
+
+	result := controllerutil.OperationResultNone
+	applying := false
+	for _, tableJson := range desiredTableJsons(table) {
+		desiredType, err := tableTypeOf(tableJson)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+
+		liveTable := ""
+		if respGetTable != "" {
+			if liveTable, err = liveTableJson(tableJson, respGetTable); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+		}
+		currentTableJson := getHybridTableStatus(table.Status.Tables, desiredType).CurrentTableJson
+
+		if liveTable == "" && currentTableJson == "" {
+			applying = true
+			respCreateTable, err := pc.CreateTable(ctx, tableJson)
+			if err != nil && !pinot.IsAPIError(err) {
+				return controllerutil.OperationResultNone, err
+			}
+			if err != nil {
+				if err := r.makePatchHybridTableStatus(table, desiredType, "", PinotTableControllerCreateFail, err.Error(), v1.ConditionTrue, PinotTableControllerCreateFail); err != nil {
+					return controllerutil.OperationResultNone, err
+				}
+				build.Recorder.GenericEvent(
+					table,
+					v1.EventTypeWarning,
+					fmt.Sprintf("%s table, Resp [%s]", desiredType, err.Error()),
+					PinotTableControllerCreateFail,
+				)
+				continue
+			}
+
+			if err := r.makePatchHybridTableStatus(table, desiredType, tableJson, PinotTableControllerCreateSuccess, respCreateTable, v1.ConditionTrue, PinotTableControllerCreateSuccess); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeNormal,
+				fmt.Sprintf("%s table, Resp [%s]", desiredType, respCreateTable),
+				PinotTableControllerCreateSuccess,
+			)
+			result = controllerutil.OperationResultCreated
+			continue
+		}
+
+		if currentTableJson == "" {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("%s table exists on pinot, but status is not updated", desiredType),
+				PinotTableControllerUpdateFail,
+			)
+			if err := r.makePatchHybridTableStatus(table, desiredType, tableJson, PinotTableControllerCreateSuccess, respGetTable, v1.ConditionTrue, PinotTableControllerCreateSuccess); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			currentTableJson = tableJson
+		}
+
+		ok, err := utils.IsEqualJson(currentTableJson, tableJson)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		if ok {
+			continue
+		}
+
+		applying = true
+		respUpdateTable, err := pc.UpdateTable(ctx, tableName+"_"+desiredType, tableJson)
+		if err != nil && !pinot.IsAPIError(err) {
+			return controllerutil.OperationResultNone, err
+		}
+		if err != nil {
+			if err := r.makePatchHybridTableStatus(table, desiredType, currentTableJson, PinotTableControllerUpdateFail, err.Error(), v1.ConditionTrue, PinotTableControllerUpdateFail); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("%s table, Resp [%s]", desiredType, err.Error()),
+				PinotTableControllerUpdateFail,
+			)
+			continue
+		}
+
+		if err := r.makePatchHybridTableStatus(table, desiredType, tableJson, PinotTableControllerUpdateSuccess, respUpdateTable, v1.ConditionTrue, PinotTableControllerUpdateSuccess); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("%s table, Resp [%s]", desiredType, respUpdateTable),
+			PinotTableControllerUpdateSuccess,
+		)
+		if result == controllerutil.OperationResultNone {
+			result = controllerutil.OperationResultUpdated
+		}
+	}
+
+	if applying {
+		return result, nil
+	}
+
+	// both tables are applied, compare them with the live tables on pinot
+	return r.reconcileDrift(ctx, table, pc, tableName, respGetTable, build)
+}
+
+// makePatchHybridTableStatus sets the status of a table type of a hybrid
+// table, the status of the table is the status of the last change to one of
+// its table types. A revision is kept once both table types are applied.
+func (r *PinotTableReconciler) makePatchHybridTableStatus(
+	table *v1beta1.PinotTable,
+	tableType string,
+	currentTableJson string,
+	msg string,
+	reason string,
+	status v1.ConditionStatus,
+	pinotTableConditionType string,
+) error {
+
+	now := metav1.Time{Time: time.Now()}
+	return r.patchStatus(context.Background(), table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		setHybridTableStatus(&in.Status.Tables, v1beta1.PinotTableTypeStatus{
+			TableType:        tableType,
+			Type:             pinotTableConditionType,
+			Status:           status,
+			Reason:           reason,
+			Message:          msg,
+			LastUpdateTime:   now,
+			CurrentTableJson: currentTableJson,
+		})
+
+		in.Status.LastUpdateTime = now
+		in.Status.Message = msg
+		in.Status.Reason = reason
+		in.Status.Status = status
+		in.Status.Type = pinotTableConditionType
+		in.Status.ReloadStatus = []string{}
+
+		offline := getHybridTableStatus(in.Status.Tables, pinot.TableTypeOffline).CurrentTableJson
+		realtime := getHybridTableStatus(in.Status.Tables, pinot.TableTypeRealtime).CurrentTableJson
+		if hybridJson, err := hybridTableJson(offline, realtime); err == nil {
+			in.Status.CurrentTableJson = hybridJson
+			if offline != "" && realtime != "" &&
+				(pinotTableConditionType == PinotTableControllerCreateSuccess || pinotTableConditionType == PinotTableControllerUpdateSuccess) {
+				in.Status.Revisions = utils.AppendRevision(in.Status.Revisions, hybridJson, in.Generation, reason, in.Spec.RevisionHistoryLimit)
+			}
+		}
+		return in
+	})
+}
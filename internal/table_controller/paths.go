@@ -1,28 +0,0 @@
-/*
-DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
-
-Licensed under the Apache License, Version 2.0 (the "License");
-you may not use this file except in compliance with the License.
-You may obtain a copy of the License at
-
-	http://www.apache.org/licenses/LICENSE-2.0
-
-Unless required by applicable law or agreed to in writing, software
-distributed under the License is distributed on an "AS IS" BASIS,
-WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-See the License for the specific language governing permissions and
-limitations under the License.
-*/
-package tablecontroller
-
-func makeControllerCreateTablePath(svcName string) string {
-	return svcName + "/tables"
-}
-
-func makeControllerGetUpdateDeleteTablePath(svcName, tableName string) string {
-	return svcName + "/tables/" + tableName
-}
-
-func makeControllerReloadTable(svcName, tableName string) string {
-	return svcName + "/segments/" + tableName + "/reload"
-}
@@ -18,19 +18,15 @@ package tablecontroller
 
 import (
 	"context"
-	"net/http"
 	"os"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -38,8 +34,6 @@ import (
 
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
 	datainfraiov1beta1 "github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
-	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
-	schemacontroller "github.com/datainfrahq/pinot-control-plane-k8s/internal/schema_controller"
 
 	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 	"github.com/go-logr/logr"
@@ -69,7 +63,11 @@ func NewPinotTableReconciler(mgr ctrl.Manager) *PinotTableReconciler {
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottables,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottables/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottables/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=secret,verbs=get
+// +kubebuilder:rbac:groups=datainfra.io,resources=pinotexternalclusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=datainfra.io,resources=pinotschemas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=datainfra.io,resources=pinottenants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 func (r *PinotTableReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
@@ -92,125 +90,45 @@ func (r *PinotTableReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PinotTableReconciler) SetupWithManager(mgr ctrl.Manager) error {
-
-	p := predicate.Funcs{
-		UpdateFunc: func(e event.UpdateEvent) bool {
-
-			if !e.ObjectNew.GetDeletionTimestamp().IsZero() {
-				return false
-			}
-			r.Log.Info("Update Event Recieved, Wait for couple of seconds for schema controller to update status  - table controller")
-
-			time.Sleep(time.Second * time.Duration(2))
-
-			schema := v1beta1.PinotSchema{}
-
-			if err := r.Client.Get(context.TODO(), types.NamespacedName{
-				Namespace: e.ObjectNew.GetNamespace(),
-				Name:      e.ObjectNew.GetName(),
-			}, &schema); err != nil {
-				r.Log.Error(err, "Error getting schema  - table controller")
-				return false
-			}
-
-			if time.Now().After(schema.Status.LastUpdateTime.Time) {
-				listOpts := []client.ListOption{
-					client.InNamespace(e.ObjectNew.GetNamespace()),
-				}
-
-				tableList := v1beta1.PinotTableList{}
-				if err := r.Client.List(context.TODO(), &tableList, listOpts...); err != nil {
-					return false
-				}
-
-				for _, table := range tableList.Items {
-					if table.Spec.SegmentReload {
-						if schema.Status.Message == schemacontroller.PinotSchemaControllerUpdateSuccess {
-							svcName, err := r.getControllerSvcUrl(table.Namespace, table.Spec.PinotCluster)
-							if err != nil {
-								r.Log.Error(err, "Error getting serviceName  - table controller")
-								return false
-							}
-
-							segmentsConfig, err := utils.GetValueFromJson(table.Spec.PinotTablesJson, utils.SegmentsConfig)
-							if err != nil {
-								r.Log.Error(err, "Error getting schemaName  - table controller")
-								return false
-							}
-							schemaNameinTable, err := utils.GetValueFromJson(segmentsConfig, utils.SchemaName)
-							if err != nil {
-								r.Log.Error(err, "Error getting schemaName in table  - table controller")
-								return false
-							}
-
-							schemaNameinEvent, err := utils.GetValueFromJson(schema.Spec.PinotSchemaJson, utils.SchemaName)
-							if err != nil {
-								r.Log.Error(err, "Error getting schemaName in Update Event  - table controller")
-								return false
-							}
-
-							getTableName, err := utils.GetValueFromJson(table.Spec.PinotTablesJson, utils.TableName)
-							if err != nil {
-								r.Log.Error(err, "Error getting tableName in Update Event  - table controller")
-								return false
-							}
-
-							basicAuth, err := r.getAuthCreds(context.TODO(), &table)
-							if err != nil {
-								r.Log.Error(err, "Error getting authCreds in Update Event  - table controller")
-								return false
-							}
-
-							if schemaNameinTable == schemaNameinEvent {
-								postHttp := internalHTTP.NewHTTPClient(
-									http.MethodPost,
-									makeControllerReloadTable(svcName, getTableName),
-									http.Client{},
-									[]byte{},
-									internalHTTP.Auth{BasicAuth: basicAuth},
-								)
-
-								reloadHttp, err := postHttp.Do()
-								if err != nil {
-									r.Log.Error(err, "Error getting reloading segments in Update Event  - table controller")
-									return false
-								}
-								r.Recorder.Event(&table, v1.EventTypeNormal, reloadHttp.ResponseBody, PinotTableReloadAllSegments)
-
-								if _, _, err := utils.PatchStatus(context.Background(), r.Client, &table, func(obj client.Object) client.Object {
-									in := obj.(*v1beta1.PinotTable)
-									in.Status.ReloadStatus = append(in.Status.ReloadStatus, reloadHttp.ResponseBody)
-									return in
-								}); err != nil {
-									r.Log.Error(err, "Error patching reloading segments in Update Event  - table controller")
-									return false
-								}
-
-							}
-
-						}
-					} else {
-						r.Log.Info("No suitable condition found for reload segments - update controller")
-						return false
-					}
-
-				}
-			}
-
-			return false
-		},
+	// tables are listed by the schema they depend on and by the source of
+	// their table config
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1beta1.PinotTable{}, pinotSchemaField, indexPinotSchema); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1beta1.PinotTable{}, utils.SourceIndexField, indexTableSource); err != nil {
+		return err
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&datainfraiov1beta1.PinotTable{}).
+		// re-reconcile the tables of a schema applied to pinot
 		Watches(
 			&source.Kind{Type: &v1beta1.PinotSchema{}},
-			&handler.EnqueueRequestForObject{},
-			builder.WithPredicates(p),
+			handler.EnqueueRequestsFromMapFunc(r.findTablesForSchema),
 		).
-		WithEventFilter(
-			GenericPredicates{},
+		// re-reconcile the tables waiting for a tenant once it is applied
+		Watches(
+			&source.Kind{Type: &v1beta1.PinotTenant{}},
+			handler.EnqueueRequestsFromMapFunc(r.findPendingTables),
 		).
+		// re-reconcile tables read from a ConfigMap or a Secret when it changes
+		Watches(
+			&source.Kind{Type: &v1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.findTablesForSource),
+		).
+		Watches(
+			&source.Kind{Type: &v1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findTablesForSource),
+		).
+		WithEventFilter(predicate.Or(
+			GenericPredicates{},
+			utils.AnnotationSetPredicate(rollbackAnnotation),
+			utils.AnnotationSetPredicate(rebalanceAnnotation),
+			utils.AnnotationSetPredicate(reloadAnnotation),
+			utils.DeletionProtectionPredicate(deletionProtectionAnnotation),
+			utils.AnnotationChangedPredicate(planAnnotation),
+			applyCountPredicate(),
+		)).
 		Complete(r)
 }
 
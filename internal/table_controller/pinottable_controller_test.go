@@ -0,0 +1,944 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tablecontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot/pinottest"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+)
+
+const testSchemaJson = `{"schemaName": "airlineStats", "dimensionFieldSpecs": [{"name": "Carrier", "dataType": "STRING"}]}`
+
+func testTableJson(replication string) string {
+	return `{
+  "tableName": "airlineStats",
+  "tableType": "OFFLINE",
+  "segmentsConfig": {"schemaName": "airlineStats", "replication": "` + replication + `"},
+  "tenants": {},
+  "tableIndexConfig": {},
+  "metadata": {}
+}`
+}
+
+func testRealtimeTableJson(replicasPerPartition string) string {
+	return `{
+  "tableName": "airlineStats",
+  "tableType": "REALTIME",
+  "segmentsConfig": {"schemaName": "airlineStats", "replicasPerPartition": "` + replicasPerPartition + `"},
+  "tenants": {},
+  "tableIndexConfig": {"streamConfigs": {"streamType": "kafka"}},
+  "metadata": {}
+}`
+}
+
+// specs numbers the objects of every spec, objects are not removed between
+// specs as their finalizers need the reconciler.
+var specs int
+
+var _ = Describe("PinotTable controller", func() {
+	var (
+		ctx    context.Context
+		server *pinottest.Server
+		r      *PinotTableReconciler
+		key    types.NamespacedName
+	)
+
+	reconcile := func() error {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		return err
+	}
+
+	getTable := func() *v1beta1.PinotTable {
+		table := &v1beta1.PinotTable{}
+		Expect(k8sClient.Get(ctx, key, table)).To(Succeed())
+		return table
+	}
+
+	reconcileUntilFinalizer := func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getTable(), PinotTableControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = pinottest.NewServer()
+		DeferCleanup(server.Close)
+
+		r = &PinotTableReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+		}
+
+		specs++
+		name := fmt.Sprintf("table-%d", specs)
+		cluster := &v1beta1.PinotExternalCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1beta1.PinotExternalClusterSpec{ControllerURL: server.URL},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), cluster)
+
+		key = types.NamespacedName{Name: name, Namespace: "default"}
+
+		// the schema CR of the table is applied, the pinot schema is added by
+		// the specs needing it
+		schema := &v1beta1.PinotSchema{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: v1beta1.PinotSchemaSpec{
+				PinotExternalCluster: cluster.Name,
+				PinotSchemaJson:      testSchemaJson,
+			},
+		}
+		Expect(k8sClient.Create(ctx, schema)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), schema)
+		schema.Status.ApplyCount = 1
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+
+		table := &v1beta1.PinotTable{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: v1beta1.PinotTableSpec{
+				PinotExternalCluster: cluster.Name,
+				PinotSchema:          key.Name,
+				PinotTableType:       v1beta1.OfflineTimeTable,
+				PinotTablesJson:      testTableJson("1"),
+			},
+		}
+		Expect(k8sClient.Create(ctx, table)).To(Succeed())
+	})
+
+	It("creates the table and registers the finalizer", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"tableName":"airlineStats_OFFLINE"`))
+		table := getTable()
+		Expect(table.Status.Type).To(Equal(PinotTableControllerCreateSuccess))
+		Expect(table.Status.CurrentTableJson).To(MatchJSON(testTableJson("1")))
+	})
+
+	It("updates the table when the spec changes", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.PinotTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"2"`))
+		Expect(getTable().Status.Type).To(Equal(PinotTableControllerUpdateSuccess))
+	})
+
+	It("deletes the table from pinot before removing the finalizer", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		Expect(k8sClient.Delete(ctx, getTable())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTable{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports a missing schema in the status", func() {
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		table := getTable()
+		Expect(table.Status.Type).To(Equal(PinotTableControllerCreateFail))
+		Expect(table.Status.Reason).To(ContainSubstring("Schema airlineStats does not exist"))
+	})
+
+	It("reports update errors in the status", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		server.Fail(http.MethodPut, "/tables/airlineStats", http.StatusBadRequest, "Invalid table config")
+		table := getTable()
+		table.Spec.PinotTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, _ := server.Table("airlineStats", "OFFLINE")
+		Expect(tableJson).To(ContainSubstring(`"replication":"1"`))
+		table = getTable()
+		Expect(table.Status.Type).To(Equal(PinotTableControllerUpdateFail))
+		Expect(table.Status.Reason).To(ContainSubstring("Invalid table config"))
+	})
+
+	It("corrects changes made on pinot outside of the spec", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+		Expect(reconcile()).To(Succeed())
+		Expect(apimeta.IsStatusConditionFalse(getTable().Status.Conditions, v1beta1.ConditionDrifted)).To(BeTrue())
+
+		Expect(server.AddTable(testTableJson("3"))).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"1"`))
+		condition := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(PinotTableControllerDriftCorrected))
+		Expect(condition.Message).To(Equal(`segmentsConfig.replication: desired "1", live "3"`))
+	})
+
+	It("only reports drift with the Report policy", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.DriftPolicy = v1beta1.DriftPolicyReport
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(server.AddTable(testTableJson("3"))).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"3"`))
+		condition := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(PinotTableControllerDrifted))
+	})
+
+	It("plans a table and reports the validation of pinot", func() {
+		table := getTable()
+		table.Spec.Mode = v1beta1.ReconcileModePlan
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		plan := getTable().Status.Plan
+		Expect(plan).NotTo(BeNil())
+		Expect(plan.Action).To(Equal(v1beta1.PlanActionCreate))
+		Expect(plan.Validation).To(Equal(v1beta1.PlanValidationFailed))
+		Expect(plan.ValidationMessage).To(ContainSubstring("Schema airlineStats does not exist"))
+
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(getTable().Status.Plan.Validation).To(Equal(v1beta1.PlanValidationPassed))
+	})
+
+	It("plans an update with the diff against pinot", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.Mode = v1beta1.ReconcileModePlan
+		table.Spec.PinotTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"1"`))
+		plan := getTable().Status.Plan
+		Expect(plan).NotTo(BeNil())
+		Expect(plan.Action).To(Equal(v1beta1.PlanActionUpdate))
+		Expect(plan.Diff).To(Equal([]string{`segmentsConfig.replication: desired "2", live "1"`}))
+	})
+
+	It("plans a table annotated for plan", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Annotations = map[string]string{planAnnotation: "true"}
+		table.Spec.PinotTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"1"`))
+		plan := getTable().Status.Plan
+		Expect(plan).NotTo(BeNil())
+		Expect(plan.Action).To(Equal(v1beta1.PlanActionUpdate))
+
+		table = getTable()
+		delete(table.Annotations, planAnnotation)
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, _ = server.Table("airlineStats", "OFFLINE")
+		Expect(tableJson).To(ContainSubstring(`"replication":"2"`))
+		Expect(getTable().Status.Plan).To(BeNil())
+	})
+
+	It("reads the table from a secret and applies its changes", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string][]byte{"tables.json": []byte(testTableJson("1"))},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+		table := getTable()
+		table.Spec.PinotTablesJson = ""
+		table.Spec.TableFrom = &v1beta1.JsonSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: secret.Name},
+			Key:                  "tables.json",
+		}}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		table = getTable()
+		Expect(table.Spec.PinotTablesJson).To(BeEmpty())
+		Expect(table.Status.CurrentTableJson).To(ContainSubstring(utils.SecretDigestKey))
+		Expect(table.Status.CurrentTableJson).NotTo(ContainSubstring("segmentsConfig"))
+
+		secret.Data["tables.json"] = []byte(testTableJson("2"))
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Expect(indexTableSource(getTable())).To(ConsistOf(utils.SourceIndexKey(secret)))
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"2"`))
+		table = getTable()
+		Expect(table.Status.Type).To(Equal(PinotTableControllerUpdateSuccess))
+		Expect(table.Status.Revisions).To(HaveLen(2))
+		for _, revision := range table.Status.Revisions {
+			Expect(revision.Json).To(ContainSubstring(utils.SecretDigestKey))
+			Expect(revision.Json).NotTo(ContainSubstring("segmentsConfig"))
+		}
+
+		// the table config of a secret is not written inline by a rollback
+		table.Annotations = map[string]string{rollbackAnnotation: "1"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		Expect(table.Annotations).NotTo(HaveKey(rollbackAnnotation))
+		Expect(table.Spec.PinotTablesJson).To(BeEmpty())
+		Expect(table.Spec.TableFrom).NotTo(BeNil())
+
+		// the redacted table config releases the CR once the secret is gone
+		Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTable{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps the applied revisions and rolls back to one", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.PinotTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		revisions := getTable().Status.Revisions
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[0].Revision).To(Equal(int64(1)))
+		Expect(revisions[0].Json).To(MatchJSON(testTableJson("1")))
+		Expect(revisions[1].Revision).To(Equal(int64(2)))
+		Expect(revisions[1].Json).To(MatchJSON(testTableJson("2")))
+
+		table = getTable()
+		table.Annotations = map[string]string{rollbackAnnotation: "1"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		Expect(table.Annotations).NotTo(HaveKey(rollbackAnnotation))
+		Expect(table.Spec.PinotTablesJson).To(MatchJSON(testTableJson("1")))
+
+		Expect(reconcile()).To(Succeed())
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(`"replication":"1"`))
+		revisions = getTable().Status.Revisions
+		Expect(revisions).To(HaveLen(3))
+		Expect(revisions[2].Revision).To(Equal(int64(3)))
+		Expect(revisions[2].Json).To(MatchJSON(testTableJson("1")))
+	})
+
+	It("ignores a rollback to a revision it does not keep", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Annotations = map[string]string{rollbackAnnotation: "7"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		Expect(table.Annotations).NotTo(HaveKey(rollbackAnnotation))
+		Expect(table.Spec.PinotTablesJson).To(MatchJSON(testTableJson("1")))
+		Expect(table.Status.Revisions).To(HaveLen(1))
+	})
+
+	It("keeps the table on pinot with the Retain deletion policy", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getTable())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTable{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("labels the table on pinot with the Orphan deletion policy", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Spec.DeletionPolicy = v1beta1.DeletionPolicyOrphan
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getTable())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tableJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(tableJson).To(ContainSubstring(fmt.Sprintf(`"customConfigs":{%q:"default/%s"}`, v1beta1.OrphanedFromLabel, key.Name)))
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTable{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps a protected table until the annotation is removed", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Annotations = map[string]string{deletionProtectionAnnotation: "true"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getTable())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(controllerutil.ContainsFinalizer(getTable(), PinotTableControllerFinalizer)).To(BeTrue())
+
+		table = getTable()
+		delete(table.Annotations, deletionProtectionAnnotation)
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTable{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("manages both tables of a hybrid table", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		table := getTable()
+		table.Spec.PinotTableType = v1beta1.HybridTable
+		table.Spec.PinotTablesJson = ""
+		table.Spec.OfflineTablesJson = testTableJson("1")
+		table.Spec.RealtimeTablesJson = testRealtimeTableJson("1")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		_, ok = server.Table("airlineStats", "REALTIME")
+		Expect(ok).To(BeTrue())
+		table = getTable()
+		Expect(table.Spec.PinotTablesJson).To(BeEmpty())
+		Expect(table.Status.Tables).To(HaveLen(2))
+		for _, status := range table.Status.Tables {
+			Expect(status.Type).To(Equal(PinotTableControllerCreateSuccess))
+		}
+		Expect(table.Status.Revisions).To(HaveLen(1))
+		Expect(table.Status.Revisions[0].Json).To(MatchJSON(`{"OFFLINE": ` + testTableJson("1") + `, "REALTIME": ` + testRealtimeTableJson("1") + `}`))
+
+		// only the changed table is updated, by its type qualified name
+		table.Spec.RealtimeTablesJson = testRealtimeTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		realtimeJson, ok := server.Table("airlineStats", "REALTIME")
+		Expect(ok).To(BeTrue())
+		Expect(realtimeJson).To(ContainSubstring(`"replicasPerPartition":"2"`))
+		Expect(server.Requests()).To(ContainElement(pinottest.Request{Method: http.MethodPut, Path: "/tables/airlineStats_REALTIME"}))
+		Expect(server.Requests()).NotTo(ContainElement(pinottest.Request{Method: http.MethodPut, Path: "/tables/airlineStats_OFFLINE"}))
+		table = getTable()
+		Expect(getHybridTableStatus(table.Status.Tables, "OFFLINE").Type).To(Equal(PinotTableControllerCreateSuccess))
+		Expect(getHybridTableStatus(table.Status.Tables, "REALTIME").Type).To(Equal(PinotTableControllerUpdateSuccess))
+		Expect(table.Status.Revisions).To(HaveLen(2))
+
+		// drift of one table is reported with its type
+		offlineJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(server.AddTable(strings.Replace(offlineJson, `"replication":"1"`, `"replication":"3"`, 1))).To(Succeed())
+		table = getTable()
+		table.Spec.DriftPolicy = v1beta1.DriftPolicyReport
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		drifted := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(drifted).NotTo(BeNil())
+		Expect(drifted.Message).To(Equal(`OFFLINE.segmentsConfig.replication: desired "1", live "3"`))
+
+		Expect(k8sClient.Delete(ctx, getTable())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		_, ok = server.Table("airlineStats", "REALTIME")
+		Expect(ok).To(BeFalse())
+		Expect(server.Requests()).To(ContainElements(
+			pinottest.Request{Method: http.MethodDelete, Path: "/tables/airlineStats", Query: "type=OFFLINE"},
+			pinottest.Request{Method: http.MethodDelete, Path: "/tables/airlineStats", Query: "type=REALTIME"},
+		))
+	})
+
+	It("rolls a hybrid table back to a revision", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		table := getTable()
+		table.Spec.PinotTableType = v1beta1.HybridTable
+		table.Spec.PinotTablesJson = ""
+		table.Spec.OfflineTablesJson = testTableJson("1")
+		table.Spec.RealtimeTablesJson = testRealtimeTableJson("1")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		table = getTable()
+		table.Spec.OfflineTablesJson = testTableJson("2")
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		table.Annotations = map[string]string{rollbackAnnotation: "1"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		Expect(table.Spec.OfflineTablesJson).To(MatchJSON(testTableJson("1")))
+		Expect(table.Spec.RealtimeTablesJson).To(MatchJSON(testRealtimeTableJson("1")))
+		offlineJson, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(offlineJson).To(ContainSubstring(`"replication":"1"`))
+	})
+
+	It("rebalances a table whose replication changed and follows the job", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Rebalance).NotTo(BeNil())
+		}).Should(Succeed())
+		Expect(server.Rebalances()).To(BeEmpty())
+
+		server.SetRebalanceStatus("IN_PROGRESS")
+		table := getTable()
+		table.Spec.PinotTablesJson = testTableJson("2")
+		table.Spec.Rebalance = &v1beta1.TableRebalance{Auto: true, Downtime: true}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Rebalance.Phase).To(Equal(v1beta1.RebalanceInProgress))
+		}).Should(Succeed())
+
+		status := getTable().Status.Rebalance
+		Expect(status.Trigger).To(Equal("segmentsConfig.replication changed"))
+		Expect(status.Jobs).To(HaveLen(1))
+		Expect(status.Jobs[0].TableType).To(Equal("OFFLINE"))
+		Expect(server.Rebalances()).To(Equal([]pinottest.Rebalance{{TableName: "airlineStats", TableType: "OFFLINE", Downtime: true}}))
+
+		// the job is polled until it finishes
+		Expect(reconcile()).To(Succeed())
+		Expect(getTable().Status.Rebalance.Phase).To(Equal(v1beta1.RebalanceInProgress))
+		server.FinishRebalance(status.Jobs[0].JobID, "DONE")
+		Expect(reconcile()).To(Succeed())
+
+		status = getTable().Status.Rebalance
+		Expect(status.Phase).To(Equal(v1beta1.RebalanceDone))
+		Expect(status.CompletionTime).NotTo(BeNil())
+		Expect(status.Jobs[0].Status).To(Equal("DONE"))
+		Expect(status.ObservedConfig).To(ContainSubstring(`"segmentsConfig.replication":"2"`))
+		Expect(server.Rebalances()).To(HaveLen(1))
+	})
+
+	It("records a pending rebalance until the rebalance annotation is set", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		reconcileUntilFinalizer()
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Rebalance).NotTo(BeNil())
+		}).Should(Succeed())
+
+		table := getTable()
+		table.Spec.PinotTablesJson = testTableJson("2")
+		minAvailableReplicas := 0
+		table.Spec.Rebalance = &v1beta1.TableRebalance{DryRun: true, MinAvailableReplicas: &minAvailableReplicas}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Rebalance.Phase).To(Equal(v1beta1.RebalancePending))
+		}).Should(Succeed())
+		Expect(server.Rebalances()).To(BeEmpty())
+
+		table = getTable()
+		table.Annotations = map[string]string{rebalanceAnnotation: "true"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		table = getTable()
+		Expect(table.Annotations).NotTo(HaveKey(rebalanceAnnotation))
+		Expect(table.Status.Rebalance.Phase).To(Equal(v1beta1.RebalanceDone))
+		Expect(table.Status.Rebalance.Trigger).To(Equal(rebalanceAnnotationTrigger))
+		Expect(table.Status.Rebalance.DryRun).To(BeTrue())
+		Expect(server.Rebalances()).To(Equal([]pinottest.Rebalance{{TableName: "airlineStats", TableType: "OFFLINE", DryRun: true}}))
+
+		Expect(server.Requests()).To(ContainElement(HaveField("Query", ContainSubstring("minAvailableReplicas=0"))))
+
+		// the dry-run is kept and the change is still to be rebalanced
+		Expect(reconcile()).To(Succeed())
+		status := getTable().Status.Rebalance
+		Expect(status.Phase).To(Equal(v1beta1.RebalanceDone))
+		Expect(status.ObservedConfig).To(ContainSubstring(`"segmentsConfig.replication":"1"`))
+		Expect(status.TargetConfig).To(ContainSubstring(`"segmentsConfig.replication":"2"`))
+		Expect(server.Rebalances()).To(HaveLen(1))
+
+		table = getTable()
+		table.Spec.Rebalance.DryRun = false
+		table.Annotations = map[string]string{rebalanceAnnotation: "true"}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		status = getTable().Status.Rebalance
+		Expect(status.Phase).To(Equal(v1beta1.RebalanceDone))
+		Expect(status.DryRun).To(BeFalse())
+		Expect(status.ObservedConfig).To(ContainSubstring(`"segmentsConfig.replication":"2"`))
+		Expect(server.Rebalances()).To(HaveLen(2))
+	})
+
+	It("reloads the table with the reload annotation and follows the job", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		server.AddSegments("airlineStats", "OFFLINE", "airlineStats_0", "airlineStats_1")
+		server.SetReloadsInProgress(true)
+		reconcileUntilFinalizer()
+
+		table := getTable()
+		table.Annotations = map[string]string{reloadAnnotation: reloadAllSegments}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Reloads).To(HaveLen(1))
+		}).Should(Succeed())
+		Expect(server.Reloads("airlineStats")).To(Equal(1))
+
+		table = getTable()
+		Expect(table.Annotations).NotTo(HaveKey(reloadAnnotation))
+		job := table.Status.Reloads[0]
+		Expect(job.TableName).To(Equal("airlineStats_OFFLINE"))
+		Expect(job.Trigger).To(Equal(reloadAnnotationTrigger))
+		Expect(job.Phase).To(Equal(v1beta1.ReloadInProgress))
+		Expect(job.SubmitTime).NotTo(BeNil())
+
+		// a new job is not done before pinot counts its segments
+		server.SetReloadStatus(job.JobID, 0, 0, 0)
+		Expect(reconcile()).To(Succeed())
+		job = getTable().Status.Reloads[0]
+		Expect(job.Phase).To(Equal(v1beta1.ReloadInProgress))
+
+		// the job is polled until its segments are reloaded
+		server.SetReloadStatus(job.JobID, 2, 0, 0)
+		Expect(reconcile()).To(Succeed())
+		job = getTable().Status.Reloads[0]
+		Expect(job.Phase).To(Equal(v1beta1.ReloadInProgress))
+		Expect(job.TotalSegments).To(Equal(2))
+		Expect(job.SucceededSegments).To(Equal(0))
+
+		// the servers that did not answer are recorded, the job is not done
+		// without them
+		server.SetReloadStatus(job.JobID, 2, 2, 1)
+		Expect(reconcile()).To(Succeed())
+		job = getTable().Status.Reloads[0]
+		Expect(job.Phase).To(Equal(v1beta1.ReloadInProgress))
+		Expect(job.SucceededSegments).To(Equal(2))
+		Expect(job.FailedServerCalls).To(Equal(1))
+
+		server.FinishReload(job.JobID, 2)
+		Expect(reconcile()).To(Succeed())
+		job = getTable().Status.Reloads[0]
+		Expect(job.Phase).To(Equal(v1beta1.ReloadSucceeded))
+		Expect(job.SucceededSegments).To(Equal(2))
+		Expect(job.FailedServerCalls).To(BeZero())
+		Expect(job.CompletionTime).NotTo(BeNil())
+		Expect(server.Reloads("airlineStats")).To(Equal(1))
+	})
+
+	It("reloads the segments of the reload annotation and bounds the history", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		server.AddSegments("airlineStats", "OFFLINE", "airlineStats_0")
+		reconcileUntilFinalizer()
+
+		segments := []string{}
+		for i := 0; i < reloadHistoryLimit; i++ {
+			segments = append(segments, fmt.Sprintf("missing_%d", i))
+		}
+		segments = append(segments, "airlineStats_0")
+
+		table := getTable()
+		table.Annotations = map[string]string{reloadAnnotation: strings.Join(segments, ",")}
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Reloads).NotTo(BeEmpty())
+		}).Should(Succeed())
+
+		// the oldest job is dropped from the history
+		reloads := getTable().Status.Reloads
+		Expect(reloads).To(HaveLen(reloadHistoryLimit))
+		Expect(reloads[0].Segment).To(Equal("missing_1"))
+		Expect(reloads[0].Phase).To(Equal(v1beta1.ReloadFailed))
+		Expect(reloads[0].Message).To(ContainSubstring("segment missing_1 not found"))
+
+		job := reloads[reloadHistoryLimit-1]
+		Expect(job.TableName).To(Equal("airlineStats_OFFLINE"))
+		Expect(job.Segment).To(Equal("airlineStats_0"))
+		Expect(job.Phase).To(Equal(v1beta1.ReloadInProgress))
+
+		Expect(reconcile()).To(Succeed())
+		job = getTable().Status.Reloads[reloadHistoryLimit-1]
+		Expect(job.Phase).To(Equal(v1beta1.ReloadSucceeded))
+		Expect(job.TotalSegments).To(Equal(1))
+		Expect(server.Reloads("airlineStats")).To(BeZero())
+	})
+
+	It("reloads the segments of a table when its schema is applied again", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		schema := &v1beta1.PinotSchema{}
+		Expect(k8sClient.Get(ctx, key, schema)).To(Succeed())
+
+		table := getTable()
+		table.Spec.SegmentReload = true
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+		Expect(indexPinotSchema(table)).To(Equal([]string{schema.Name}))
+
+		// the schema applied before the table needs no reload
+		reconcileUntilFinalizer()
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.SchemaApplyCount).To(Equal(int64(1)))
+		}).Should(Succeed())
+		Expect(server.Reloads("airlineStats")).To(BeZero())
+
+		old := schema.DeepCopy()
+		schema.Status.ApplyCount = 2
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+		Expect(applyCountPredicate().Update(event.UpdateEvent{ObjectOld: old, ObjectNew: schema})).To(BeTrue())
+		Expect(applyCountPredicate().Update(event.UpdateEvent{ObjectOld: schema, ObjectNew: schema})).To(BeFalse())
+
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.SchemaApplyCount).To(Equal(int64(2)))
+		}).Should(Succeed())
+		Expect(server.Reloads("airlineStats")).To(Equal(1))
+		reloads := getTable().Status.Reloads
+		Expect(reloads).To(HaveLen(1))
+		Expect(reloads[0].Trigger).To(Equal(reloadSchemaTrigger))
+
+		// the apply count is reloaded once
+		Expect(reconcile()).To(Succeed())
+		Expect(server.Reloads("airlineStats")).To(Equal(1))
+	})
+
+	It("waits for its schema to be applied before creating the table", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		schema := &v1beta1.PinotSchema{}
+		Expect(k8sClient.Get(ctx, key, schema)).To(Succeed())
+		schema.Status.ApplyCount = 0
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+
+		Expect(reconcile()).To(Succeed())
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		table := getTable()
+		Expect(table.Status.Type).To(BeEmpty())
+		condition := apimeta.FindStatusCondition(table.Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(pendingSchemaNotApplied))
+		Expect(r.findPendingTables(schema)).To(ConsistOf(ctrl.Request{NamespacedName: key}))
+
+		schema.Status.ApplyCount = 1
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		table = getTable()
+		Expect(table.Status.Type).To(Equal(PinotTableControllerCreateSuccess))
+		condition = apimeta.FindStatusCondition(table.Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(dependenciesApplied))
+		Expect(r.findPendingTables(schema)).To(BeEmpty())
+	})
+
+	It("waits for a pending schema after a failed create", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		server.Fail(http.MethodPost, "/tables", http.StatusBadRequest, "Invalid table config")
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(getTable().Status.Type).To(Equal(PinotTableControllerCreateFail))
+		}).Should(Succeed())
+		Expect(getTable().Status.CurrentTableJson).NotTo(BeEmpty())
+
+		server.ClearFailures()
+		schema := &v1beta1.PinotSchema{}
+		Expect(k8sClient.Get(ctx, key, schema)).To(Succeed())
+		schema.Status.ApplyCount = 0
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+
+		Expect(reconcile()).To(Succeed())
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+		condition := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(pendingSchemaNotApplied))
+
+		schema.Status.ApplyCount = 1
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+		reconcileUntilFinalizer()
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(getTable().Status.Type).To(Equal(PinotTableControllerCreateSuccess))
+	})
+
+	It("creates the table on a schema applied before the apply count was kept", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		schema := &v1beta1.PinotSchema{}
+		Expect(k8sClient.Get(ctx, key, schema)).To(Succeed())
+		schema.Status.ApplyCount = 0
+		schema.Status.CurrentSchemasJson = testSchemaJson
+		Expect(k8sClient.Status().Update(ctx, schema)).To(Succeed())
+
+		reconcileUntilFinalizer()
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		Expect(apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)).To(BeNil())
+	})
+
+	It("creates the table on a tenant of pinot without a PinotTenant", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		table := getTable()
+		table.Spec.PinotTablesJson = strings.Replace(testTableJson("1"), `"tenants": {}`, `"tenants": {"broker": "brokerA", "server": "serverA"}`, 1)
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+
+		// only the broker tenant is on pinot
+		Expect(server.AddTenant(`{"tenantRole": "BROKER", "tenantName": "brokerA", "numberOfInstances": 1}`)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		condition := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(pendingTenantNotFound))
+		Expect(condition.Message).To(ContainSubstring("SERVER tenant [serverA]"))
+
+		Expect(server.AddTenant(`{"tenantRole": "SERVER", "tenantName": "serverA", "numberOfInstances": 1}`)).To(Succeed())
+		reconcileUntilFinalizer()
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		condition = apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("waits for the tenants of its table config to be applied", func() {
+		Expect(server.AddSchema(testSchemaJson)).To(Succeed())
+		table := getTable()
+		table.Spec.PinotTablesJson = strings.Replace(testTableJson("1"), `"tenants": {}`, `"tenants": {"broker": "brokerA", "server": "DefaultTenant"}`, 1)
+		Expect(k8sClient.Update(ctx, table)).To(Succeed())
+
+		Expect(reconcile()).To(Succeed())
+		condition := apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(pendingTenantNotFound))
+		Expect(condition.Message).To(ContainSubstring("BROKER tenant [brokerA]"))
+
+		tenant := &v1beta1.PinotTenant{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: v1beta1.PinotTenantSpec{
+				PinotExternalCluster: key.Name,
+				PinotTenantType:      v1beta1.BrokerTenant,
+				PinotTenantsJson:     `{"tenantRole": "BROKER", "tenantName": "brokerA", "numberOfInstances": 1}`,
+			},
+		}
+		Expect(k8sClient.Create(ctx, tenant)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), tenant)
+
+		Expect(reconcile()).To(Succeed())
+		condition = apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition.Reason).To(Equal(pendingTenantNotApplied))
+		_, ok := server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeFalse())
+
+		tenant.Status.ApplyCount = 1
+		Expect(k8sClient.Status().Update(ctx, tenant)).To(Succeed())
+		reconcileUntilFinalizer()
+
+		_, ok = server.Table("airlineStats", "OFFLINE")
+		Expect(ok).To(BeTrue())
+		condition = apimeta.FindStatusCondition(getTable().Status.Conditions, v1beta1.ConditionPending)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("returns transport errors to be retried", func() {
+		server.Close()
+		Expect(reconcile()).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,168 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tablecontroller
+
+import (
+	"context"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// plan stores what applying the table would do on pinot in the status,
+// nothing is changed on pinot. A deleted CR is released without deleting the
+// table.
+func (r *PinotTableReconciler) plan(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	plan, err := r.makePlan(ctx, table, pc)
+	if err != nil {
+		return err
+	}
+
+	// the plan of a deleted CR is only emitted as an event as the CR is gone
+	// once released
+	if !table.ObjectMeta.DeletionTimestamp.IsZero() {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotTableControllerPlan,
+		)
+		if controllerutil.ContainsFinalizer(table, PinotTableControllerFinalizer) {
+			controllerutil.RemoveFinalizer(table, PinotTableControllerFinalizer)
+			if err := r.update(ctx, table); err != nil {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	if utils.IsPlanChanged(table.Status.Plan, plan) {
+		if err := r.patchPlanStatus(ctx, table, &plan); err != nil {
+			return err
+		}
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotTableControllerPlan,
+		)
+	}
+
+	return nil
+}
+
+// makePlan computes the action the table controller would take, the diff
+// against the live table config and the validation of the config by pinot.
+func (r *PinotTableReconciler) makePlan(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+) (v1beta1.PlanStatus, error) {
+
+	plan := v1beta1.PlanStatus{
+		Action:             v1beta1.PlanActionNone,
+		ObservedGeneration: table.Generation,
+		LastPlanTime:       metav1.Time{Time: time.Now()},
+	}
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return plan, err
+	}
+
+	respGetTable, err := pc.GetTable(ctx, tableName)
+	if err != nil && !pinot.IsNotFound(err) {
+		return plan, err
+	}
+
+	tableJsons := desiredTableJsons(table)
+	liveTables := make([]string, len(tableJsons))
+	exists := false
+	for i, tableJson := range tableJsons {
+		if respGetTable != "" {
+			if liveTables[i], err = liveTableJson(tableJson, respGetTable); err != nil {
+				return plan, err
+			}
+		}
+		exists = exists || liveTables[i] != ""
+	}
+
+	if !table.ObjectMeta.DeletionTimestamp.IsZero() {
+		if exists && utils.IsDeletedOnPinot(table.Spec.DeletionPolicy) {
+			plan.Action = v1beta1.PlanActionDelete
+		}
+		return plan, nil
+	}
+
+	if !exists {
+		plan.Action = v1beta1.PlanActionCreate
+	} else {
+		for i, tableJson := range tableJsons {
+			diffs, err := tableDrift(table, tableJson, liveTables[i])
+			if err != nil {
+				return plan, err
+			}
+			plan.Diff = append(plan.Diff, diffs...)
+		}
+		specChanged, err := utils.IsSpecChanged(table.Status.CurrentTableJson, table.Spec.PinotTablesJson)
+		if err != nil {
+			return plan, err
+		}
+		plan.Action = utils.PlanUpdateAction(specChanged, plan.Diff, table.Spec.DriftPolicy)
+	}
+
+	// the first table config pinot refuses is the validation of the plan
+	for _, tableJson := range tableJsons {
+		_, err = pc.ValidateTable(ctx, tableJson)
+		if plan.Validation, plan.ValidationMessage, err = utils.PlanValidationResult(err); err != nil {
+			return plan, err
+		}
+		if plan.Validation != v1beta1.PlanValidationPassed {
+			break
+		}
+	}
+
+	return plan, nil
+}
+
+// patchPlanStatus stores the plan in the status, a nil plan removes it.
+func (r *PinotTableReconciler) patchPlanStatus(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	plan *v1beta1.PlanStatus,
+) error {
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		in.Status.Plan = plan
+		return in
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/datainfrahq/operator-runtime/utils"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -28,6 +29,17 @@ import (
 
 const (
 	ignoreAnnotation = "pinottable.datainfra.io/reconcile"
+	// rollbackAnnotation rolls the spec back to the revision it is set to
+	rollbackAnnotation = "pinottable.datainfra.io/rollback-to"
+	// rebalanceAnnotation rebalances the table, it is removed once started
+	rebalanceAnnotation = "pinottable.datainfra.io/rebalance"
+	// reloadAnnotation reloads all segments of the table, or the comma
+	// separated segments it is set to, it is removed once submitted
+	reloadAnnotation = "pinottable.datainfra.io/reload"
+	// planAnnotation set to true plans the CR like spec.mode Plan
+	planAnnotation = "pinottable.datainfra.io/plan"
+	// deletionProtectionAnnotation set to true keeps the CR until it is removed
+	deletionProtectionAnnotation = "pinottable.datainfra.io/deletion-protection"
 )
 
 // All methods to implement GenericPredicates type
@@ -60,3 +72,32 @@ func Update(e event.UpdateEvent, log logr.Logger) bool {
 		predicates.IgnoreNamespacePredicate(e.ObjectNew) &&
 		predicates.IgnoreUpdate(e)
 }
+
+// applyCountPredicate passes the updates of a PinotSchema or a PinotTenant
+// applied to pinot again, their status changes without a new generation.
+func applyCountPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCount, ok := applyCount(e.ObjectOld)
+			if !ok {
+				return false
+			}
+			newCount, ok := applyCount(e.ObjectNew)
+			return ok && newCount != oldCount
+		},
+	}
+}
+
+// applyCount returns the apply count of a PinotSchema or a PinotTenant.
+func applyCount(obj interface{}) (int64, bool) {
+	switch o := obj.(type) {
+	case *v1beta1.PinotSchema:
+		return o.Status.ApplyCount, true
+	case *v1beta1.PinotTenant:
+		return o.Status.ApplyCount, true
+	}
+	return 0, false
+}
@@ -0,0 +1,434 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rebalanceAnnotationTrigger is the trigger of a rebalance requested with the
+// rebalance annotation.
+const rebalanceAnnotationTrigger = "annotation"
+
+// rebalanceFields are the table config fields whose change needs a rebalance
+// to move segments.
+var rebalanceFields = []string{
+	"segmentsConfig.replication",
+	"segmentsConfig.replicasPerPartition",
+	"segmentsConfig.replicaGroupStrategyConfig",
+	"tenants",
+	"instanceAssignmentConfigMap",
+}
+
+// rebalanceOptions returns the rebalance options of the spec, instances are
+// reassigned and one replica is kept available by default.
+func rebalanceOptions(rebalance *v1beta1.TableRebalance) pinot.RebalanceOptions {
+	opts := pinot.RebalanceOptions{
+		ReassignInstances:    true,
+		MinAvailableReplicas: 1,
+	}
+	if rebalance == nil {
+		return opts
+	}
+
+	opts.DryRun = rebalance.DryRun
+	opts.Downtime = rebalance.Downtime
+	if rebalance.MinAvailableReplicas != nil {
+		opts.MinAvailableReplicas = *rebalance.MinAvailableReplicas
+	}
+	opts.Bootstrap = rebalance.Bootstrap
+	opts.IncludeConsuming = rebalance.IncludeConsuming
+	if rebalance.ReassignInstances != nil {
+		opts.ReassignInstances = *rebalance.ReassignInstances
+	}
+	return opts
+}
+
+// rebalanceConfig returns the rebalance fields of the applied table json as
+// json, the fields of a hybrid table are prefixed with the table type.
+func rebalanceConfig(table *v1beta1.PinotTable, appliedJson string) (string, error) {
+	configs := map[string]json.RawMessage{"": json.RawMessage(appliedJson)}
+	if isHybridTable(table) {
+		hybrid := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(appliedJson), &hybrid); err != nil {
+			return "", err
+		}
+		configs = map[string]json.RawMessage{}
+		for tableType, config := range hybrid {
+			configs[tableType+"."] = config
+		}
+	}
+
+	fields := map[string]interface{}{}
+	for prefix, raw := range configs {
+		config := map[string]interface{}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return "", err
+		}
+		for _, field := range rebalanceFields {
+			if value, ok := lookupField(config, field); ok {
+				fields[prefix+field] = value
+			}
+		}
+	}
+
+	observed, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(observed), nil
+}
+
+// lookupField returns the value of a dotted field of a json object.
+func lookupField(config map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = config
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// changedRebalanceFields returns the rebalance fields that differ between two
+// rebalance configs.
+func changedRebalanceFields(observed, current string) ([]string, error) {
+	o, c := map[string]interface{}{}, map[string]interface{}{}
+	if err := json.Unmarshal([]byte(observed), &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(current), &c); err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for field, value := range c {
+		if !reflect.DeepEqual(o[field], value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range o {
+		if _, ok := c[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// rebalancePhase returns the phase of a rebalance from the status of its jobs.
+func rebalancePhase(jobs []v1beta1.RebalanceJob) v1beta1.RebalancePhase {
+	phase := v1beta1.RebalanceDone
+	for _, job := range jobs {
+		switch job.Status {
+		case pinot.RebalanceStatusDone, pinot.RebalanceStatusNoOp:
+		case pinot.RebalanceStatusInProgress:
+			if phase == v1beta1.RebalanceDone {
+				phase = v1beta1.RebalanceInProgress
+			}
+		default:
+			phase = v1beta1.RebalanceFailed
+		}
+	}
+	return phase
+}
+
+// rebalanceMessage joins the descriptions of the rebalance jobs.
+func rebalanceMessage(jobs []v1beta1.RebalanceJob) string {
+	msgs := []string{}
+	for _, job := range jobs {
+		msgs = append(msgs, fmt.Sprintf("%s %s [%s]", job.TableType, job.Status, job.Description))
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// reconcileRebalance follows a rebalance in progress, or starts one when the
+// rebalance annotation is set or when a change to the applied table config
+// needs one and spec.rebalance.auto is set. Without auto the change is only
+// recorded as a pending rebalance.
+func (r *PinotTableReconciler) reconcileRebalance(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	status := table.Status.Rebalance
+	if status != nil && status.Phase == v1beta1.RebalanceInProgress {
+		return r.pollRebalance(ctx, table, pc, build)
+	}
+
+	// a table is rebalanced once its table config is applied
+	appliedJson, applied, err := appliedTableJson(table)
+	if err != nil || !applied {
+		return err
+	}
+
+	observed, err := rebalanceConfig(table, appliedJson)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := table.Annotations[rebalanceAnnotation]; ok {
+		return r.startRebalance(ctx, table, pc, observed, rebalanceAnnotationTrigger, build)
+	}
+
+	if status == nil || status.ObservedConfig == "" {
+		// the table config applied first needs no rebalance
+		in := &v1beta1.TableRebalanceStatus{}
+		if status != nil {
+			in = status.DeepCopy()
+		}
+		in.ObservedConfig = observed
+		return r.patchRebalanceStatus(ctx, table, in)
+	}
+
+	changed, err := changedRebalanceFields(status.ObservedConfig, observed)
+	if err != nil {
+		return err
+	}
+
+	if len(changed) == 0 {
+		// a change reverted before it was rebalanced
+		if status.Phase == v1beta1.RebalancePending {
+			in := status.DeepCopy()
+			in.Phase, in.Trigger, in.Message = "", "", ""
+			return r.patchRebalanceStatus(ctx, table, in)
+		}
+		return nil
+	}
+
+	// a dry-run or a failed rebalance of the change is kept, the change is
+	// rebalanced again with the annotation
+	if status.TargetConfig == observed &&
+		(status.Phase == v1beta1.RebalanceDone || status.Phase == v1beta1.RebalanceFailed) {
+		return nil
+	}
+
+	trigger := strings.Join(changed, ", ") + " changed"
+	if table.Spec.Rebalance != nil && table.Spec.Rebalance.Auto {
+		return r.startRebalance(ctx, table, pc, observed, trigger, build)
+	}
+
+	if status.Phase == v1beta1.RebalancePending && status.Trigger == trigger {
+		return nil
+	}
+
+	in := status.DeepCopy()
+	in.Phase = v1beta1.RebalancePending
+	in.Trigger = trigger
+	in.Message = fmt.Sprintf("Annotate the table with [%s] to rebalance it", rebalanceAnnotation)
+	in.Jobs = nil
+	in.StartTime = nil
+	in.CompletionTime = nil
+	if err := r.patchRebalanceStatus(ctx, table, in); err != nil {
+		return err
+	}
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Table needs a rebalance, %s", trigger),
+		PinotTableControllerRebalancePending,
+	)
+	return nil
+}
+
+// startRebalance rebalances every table type of the table and records the
+// jobs, the rebalance annotation is removed once they are recorded.
+func (r *PinotTableReconciler) startRebalance(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	observed string,
+	trigger string,
+	build builder.Builder,
+) error {
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return err
+	}
+
+	opts := rebalanceOptions(table.Spec.Rebalance)
+	jobs := []v1beta1.RebalanceJob{}
+	for _, tableJson := range desiredTableJsons(table) {
+		tableType, err := tableTypeOf(tableJson)
+		if err != nil {
+			return err
+		}
+
+		job := v1beta1.RebalanceJob{TableType: tableType}
+		result, err := pc.RebalanceTable(ctx, tableName, tableType, opts)
+		switch {
+		case result != nil:
+			// a failed rebalance is returned along with its result
+			job.JobID = result.JobID
+			job.Status = result.Status
+			job.Description = result.Description
+		case pinot.IsAPIError(err):
+			job.Status = pinot.RebalanceStatusFailed
+			job.Description = err.Error()
+		default:
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	now := metav1.Time{Time: time.Now()}
+	status := &v1beta1.TableRebalanceStatus{
+		Phase:        rebalancePhase(jobs),
+		Trigger:      trigger,
+		DryRun:       opts.DryRun,
+		Message:      rebalanceMessage(jobs),
+		Jobs:         jobs,
+		StartTime:    &now,
+		TargetConfig: observed,
+	}
+	if table.Status.Rebalance != nil {
+		status.ObservedConfig = table.Status.Rebalance.ObservedConfig
+	}
+	if status.Phase != v1beta1.RebalanceInProgress {
+		status.CompletionTime = &now
+	}
+	finishRebalance(status)
+	if err := r.patchRebalanceStatus(ctx, table, status); err != nil {
+		return err
+	}
+
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Rebalance started, %s, Resp [%s]", trigger, status.Message),
+		PinotTableControllerRebalanceStarted,
+	)
+	r.recordRebalanceResult(table, status, build)
+
+	if trigger != rebalanceAnnotationTrigger {
+		return nil
+	}
+
+	return r.removeAnnotation(ctx, table, rebalanceAnnotation)
+}
+
+// pollRebalance updates the jobs of a rebalance in progress with their status
+// on pinot. A job pinot no longer knows, as after a controller restart, is
+// failed.
+func (r *PinotTableReconciler) pollRebalance(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	status := table.Status.Rebalance.DeepCopy()
+	for i := range status.Jobs {
+		job := &status.Jobs[i]
+		if job.Status != pinot.RebalanceStatusInProgress {
+			continue
+		}
+
+		jobStatus, err := pc.GetRebalanceStatus(ctx, job.JobID)
+		if err != nil && !pinot.IsAPIError(err) {
+			return err
+		}
+		if err != nil {
+			job.Status = pinot.RebalanceStatusFailed
+			job.Description = err.Error()
+			continue
+		}
+		job.Status = jobStatus.Progress.Status
+		job.Description = jobStatus.Progress.CompletionStatusMsg
+		job.ElapsedSeconds = jobStatus.TimeElapsedSinceStartInSeconds
+	}
+
+	status.Phase = rebalancePhase(status.Jobs)
+	status.Message = rebalanceMessage(status.Jobs)
+	if status.Phase != v1beta1.RebalanceInProgress {
+		status.CompletionTime = &metav1.Time{Time: time.Now()}
+	}
+	finishRebalance(status)
+	if err := r.patchRebalanceStatus(ctx, table, status); err != nil {
+		return err
+	}
+
+	r.recordRebalanceResult(table, status, build)
+	return nil
+}
+
+// finishRebalance observes the target config of a rebalance once it is done
+// and moved segments, a dry-run only computes the target assignment.
+func finishRebalance(status *v1beta1.TableRebalanceStatus) {
+	if status.Phase == v1beta1.RebalanceDone && !status.DryRun && status.TargetConfig != "" {
+		status.ObservedConfig = status.TargetConfig
+	}
+}
+
+// recordRebalanceResult emits the event of a finished rebalance.
+func (r *PinotTableReconciler) recordRebalanceResult(
+	table *v1beta1.PinotTable,
+	status *v1beta1.TableRebalanceStatus,
+	build builder.Builder,
+) {
+
+	switch status.Phase {
+	case v1beta1.RebalanceDone:
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Rebalance done, Resp [%s]", status.Message),
+			PinotTableControllerRebalanceDone,
+		)
+	case v1beta1.RebalanceFailed:
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Rebalance failed, Resp [%s]", status.Message),
+			PinotTableControllerRebalanceFail,
+		)
+	}
+}
+
+// patchRebalanceStatus stores the rebalance in the status.
+func (r *PinotTableReconciler) patchRebalanceStatus(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	status *v1beta1.TableRebalanceStatus,
+) error {
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		in.Status.Rebalance = status
+		return in
+	})
+}
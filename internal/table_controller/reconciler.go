@@ -19,14 +19,14 @@ package tablecontroller
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/datainfrahq/operator-runtime/builder"
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
-	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
 	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -45,90 +45,123 @@ const (
 	PinotTableControllerUpdateFail         = "PinotTableControllerUpdateFail"
 	PinotTableControllerDeleteSuccess      = "PinotTableControllerDeleteSuccess"
 	PinotTableControllerDeleteFail         = "PinotTableControllerDeleteFail"
+	PinotTableControllerInSync             = "PinotTableControllerInSync"
+	PinotTableControllerDrifted            = "PinotTableControllerDrifted"
+	PinotTableControllerDriftCorrected     = "PinotTableControllerDriftCorrected"
+	PinotTableControllerDriftCorrectFail   = "PinotTableControllerDriftCorrectFail"
 	PinotTableReloadAllSegments            = "PinotTableReloadAllSegments"
+	PinotTableControllerReloadSegment      = "PinotTableControllerReloadSegment"
+	PinotTableControllerReloadSuccess      = "PinotTableControllerReloadSuccess"
+	PinotTableControllerReloadFail         = "PinotTableControllerReloadFail"
+	PinotTableControllerPlan               = "PinotTableControllerPlan"
+	PinotTableControllerSourceFail         = "PinotTableControllerSourceFail"
+	PinotTableControllerHybridInvalid      = "PinotTableControllerHybridInvalid"
+	PinotTableControllerPending            = "PinotTableControllerPending"
+	PinotTableControllerRebalancePending   = "PinotTableControllerRebalancePending"
+	PinotTableControllerRebalanceStarted   = "PinotTableControllerRebalanceStarted"
+	PinotTableControllerRebalanceDone      = "PinotTableControllerRebalanceDone"
+	PinotTableControllerRebalanceFail      = "PinotTableControllerRebalanceFail"
+	PinotTableControllerRollbackSuccess    = "PinotTableControllerRollbackSuccess"
+	PinotTableControllerRollbackFail       = "PinotTableControllerRollbackFail"
+	PinotTableControllerDeleteBlocked      = "PinotTableControllerDeleteBlocked"
+	PinotTableControllerRetained           = "PinotTableControllerRetained"
+	PinotTableControllerOrphaned           = "PinotTableControllerOrphaned"
+	PinotTableControllerOrphanFail         = "PinotTableControllerOrphanFail"
 	PinotTableControllerFinalizer          = "pinottable.datainfra.io/finalizer"
 )
 
-const (
-	PinotControllerPort = "9000"
-)
-
-const (
-	ControlPlaneUserName = "CONTROL_PLANE_USERNAME"
-	ControlPlanePassword = "CONTROL_PLANE_PASSWORD"
-)
-
 func (r *PinotTableReconciler) do(ctx context.Context, table *v1beta1.PinotTable) error {
 
 	build := builder.NewBuilder(
 		builder.ToNewBuilderRecorder(builder.BuilderRecorder{Recorder: r.Recorder, ControllerName: "PinorTableController"}),
 	)
 
-	svcName, err := r.getControllerSvcUrl(table.Namespace, table.Spec.PinotCluster)
-	if err != nil {
+	// a rolled back spec is applied by the reconcile of the update
+	if rolledBack, err := r.rollback(ctx, table, *build); err != nil || rolledBack {
 		return err
 	}
 
-	basicAuth, err := r.getAuthCreds(ctx, table)
-	if err != nil {
+	if err := r.resolveTableJson(ctx, table, *build); err != nil {
 		return err
 	}
 
-	_, err = r.CreateOrUpdate(table, svcName, *build, internalHTTP.Auth{BasicAuth: basicAuth})
+	pc, err := r.getPinotClient(ctx, table)
 	if err != nil {
 		return err
 	}
 
+	if err := r.patchUnreachableCondition(ctx, table, pc); err != nil {
+		return err
+	}
+
+	if utils.IsPlanMode(table.Spec.Mode, table.Annotations, planAnnotation) {
+		return r.plan(ctx, table, pc, *build)
+	}
+
+	// the plan of a table that was planned before is stale once applied
+	if table.Status.Plan != nil {
+		if err := r.patchPlanStatus(ctx, table, nil); err != nil {
+			return err
+		}
+	}
+
+	// a table is created once its schema and tenants are applied to pinot
+	if table.DeletionTimestamp.IsZero() {
+		created, err := isTableCreated(ctx, pc, table)
+		if err != nil {
+			return err
+		}
+		if !created {
+			if pending, err := r.waitForDependencies(ctx, table, pc, *build); err != nil || pending {
+				return err
+			}
+		}
+	}
+
+	// a deleted table is only released, the json it falls back to when its
+	// source is gone may be redacted and is not applied
+	if table.ObjectMeta.DeletionTimestamp.IsZero() {
+		if _, err := r.CreateOrUpdate(ctx, table, pc, *build); err != nil {
+			return err
+		}
+	}
+
 	if table.ObjectMeta.DeletionTimestamp.IsZero() {
 		// The object is not being deleted, so if it does not have our finalizer,
 		// 	then lets add the finalizer and update the object. This is equivalent
 		// 	registering our finalizer.
 		if !controllerutil.ContainsFinalizer(table, PinotTableControllerFinalizer) {
 			controllerutil.AddFinalizer(table, PinotTableControllerFinalizer)
-			if err := r.Update(ctx, table); err != nil {
+			if err := r.update(ctx, table); err != nil {
 				return nil
 			}
 		}
+
+		if err := r.reconcileRebalance(ctx, table, pc, *build); err != nil {
+			return err
+		}
+
+		if err := r.reconcileSchemaReload(ctx, table, pc, *build); err != nil {
+			return err
+		}
+
+		if err := r.reconcileReload(ctx, table, pc, *build); err != nil {
+			return err
+		}
 	} else {
 		if controllerutil.ContainsFinalizer(table, PinotTableControllerFinalizer) {
-			svcName, err := r.getControllerSvcUrl(table.Namespace, table.Spec.PinotCluster)
-			if err != nil {
-				return err
+			// a protected table is kept until the annotation is removed
+			if r.isDeletionBlocked(table, *build) {
+				return nil
 			}
 
-			tenantName, err := utils.GetValueFromJson(table.Spec.PinotTablesJson, utils.TableName)
-			if err != nil {
-				return err
-			}
-			http := internalHTTP.NewHTTPClient(
-				http.MethodDelete,
-				makeControllerGetUpdateDeleteTablePath(svcName, tenantName),
-				http.Client{}, []byte{},
-				internalHTTP.Auth{BasicAuth: basicAuth},
-			)
-			respDeleteTable, err := http.Do()
-			if err != nil {
+			if err := r.deleteTable(ctx, table, pc, *build); err != nil {
 				return err
 			}
-			if respDeleteTable.StatusCode != 200 {
-				build.Recorder.GenericEvent(
-					table,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respDeleteTable.ResponseBody)),
-					PinotTableControllerDeleteFail,
-				)
-			} else {
-				build.Recorder.GenericEvent(
-					table,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respDeleteTable.ResponseBody)),
-					PinotTableControllerDeleteSuccess,
-				)
-			}
 
 			// remove our finalizer from the list and update it.
 			controllerutil.RemoveFinalizer(table, PinotTableControllerFinalizer)
-			if err := r.Update(ctx, table); err != nil {
+			if err := r.update(ctx, table); err != nil {
 				return nil
 			}
 		}
@@ -139,12 +172,16 @@ func (r *PinotTableReconciler) do(ctx context.Context, table *v1beta1.PinotTable
 // Get table if does not exist create
 // if exists check for update
 func (r *PinotTableReconciler) CreateOrUpdate(
+	ctx context.Context,
 	table *v1beta1.PinotTable,
-	svcName string,
+	pc *pinot.Client,
 	build builder.Builder,
-	auth internalHTTP.Auth,
 ) (controllerutil.OperationResult, error) {
 
+	if isHybridTable(table) {
+		return r.createOrUpdateHybrid(ctx, table, pc, build)
+	}
+
 	// get table name
 	tableName, err := utils.GetValueFromJson(table.Spec.PinotTablesJson, utils.TableName)
 	if err != nil {
@@ -152,42 +189,24 @@ func (r *PinotTableReconciler) CreateOrUpdate(
 	}
 
 	// get table
-	getHttp := internalHTTP.NewHTTPClient(
-		http.MethodGet,
-		makeControllerGetUpdateDeleteTablePath(svcName, tableName),
-		http.Client{}, []byte{},
-		auth,
-	)
-
-	respGetTable, err := getHttp.Do()
-	if err != nil {
-		return controllerutil.OperationResultNone, err
-	}
+	respGetTable, err := pc.GetTable(ctx, tableName)
 
-	// get - an empty response
-	if respGetTable.ResponseBody == "{}" {
+	if pinot.IsNotFound(err) {
 
-		postHttp := internalHTTP.NewHTTPClient(
-			http.MethodPost,
-			makeControllerCreateTablePath(svcName),
-			http.Client{},
-			[]byte(table.Spec.PinotTablesJson),
-			auth,
-		)
 		// create table
-		respCreateTable, err := postHttp.Do()
-		if err != nil {
+		respCreateTable, err := pc.CreateTable(ctx, table.Spec.PinotTablesJson)
+		if err != nil && !pinot.IsAPIError(err) {
 			return controllerutil.OperationResultNone, err
 		}
 
 		// create success
-		if respCreateTable.StatusCode == 200 {
+		if err == nil {
 
 			// patch resource
 			_, err := r.makePatchPinotTableStatus(
 				table,
 				PinotTableControllerCreateSuccess,
-				string(respCreateTable.ResponseBody),
+				respCreateTable,
 				v1.ConditionTrue,
 				PinotTableControllerCreateSuccess,
 			)
@@ -197,129 +216,175 @@ func (r *PinotTableReconciler) CreateOrUpdate(
 			build.Recorder.GenericEvent(
 				table,
 				v1.EventTypeNormal,
-				fmt.Sprintf("Resp [%s]", string(respCreateTable.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", respCreateTable),
 				PinotTableControllerCreateSuccess,
 			)
 			return controllerutil.OperationResultCreated, nil
 
 		} else {
-			_, err := r.makePatchPinotTableStatus(
+			if _, err := r.makePatchPinotTableStatus(
 				table,
-				PinotTableControllerCreateSuccess,
-				string(respCreateTable.ResponseBody),
+				PinotTableControllerCreateFail,
+				err.Error(),
 				v1.ConditionTrue,
-				PinotTableControllerCreateSuccess,
-			)
-			if err != nil {
+				PinotTableControllerCreateFail,
+			); err != nil {
 				return controllerutil.OperationResultNone, err
 			}
 
 			build.Recorder.GenericEvent(
 				table,
 				v1.EventTypeWarning,
-				fmt.Sprintf("Resp [%s]", string(respCreateTable.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", err.Error()),
 				PinotTableControllerCreateFail,
 			)
 			return controllerutil.OperationResultNone, nil
 		}
-	} else if respGetTable.ResponseBody != "{}" {
-
-		if table.Status.CurrentTableJson == "" {
-			build.Recorder.GenericEvent(
-				table,
-				v1.EventTypeWarning,
-				fmt.Sprintf("Table Exists on Pinot, but status is not updated"),
-				PinotTableControllerUpdateFail,
-			)
+	} else if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
 
-			_, err := r.makePatchPinotTableStatus(
-				table,
-				PinotTableControllerCreateSuccess,
-				string(respGetTable.ResponseBody),
-				v1.ConditionTrue,
-				PinotTableControllerCreateSuccess,
-			)
-			if err != nil {
-				return controllerutil.OperationResultNone, err
-			}
-		}
+	if table.Status.CurrentTableJson == "" {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Table Exists on Pinot, but status is not updated"),
+			PinotTableControllerUpdateFail,
+		)
 
-		ok, err := utils.IsEqualJson(
-			table.Status.CurrentTableJson,
-			table.Spec.PinotTablesJson,
+		_, err := r.makePatchPinotTableStatus(
+			table,
+			PinotTableControllerCreateSuccess,
+			respGetTable,
+			v1.ConditionTrue,
+			PinotTableControllerCreateSuccess,
 		)
 		if err != nil {
 			return controllerutil.OperationResultNone, err
 		}
+	}
+
+	ok, err := utils.IsAppliedJson(
+		table.Status.CurrentTableJson,
+		table.Spec.PinotTablesJson,
+	)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if !ok {
+		respUpdateTable, err := pc.UpdateTable(ctx, tableName, table.Spec.PinotTablesJson)
+		if err != nil && !pinot.IsAPIError(err) {
+			return controllerutil.OperationResultNone, err
+		}
 
-		if !ok {
-			postHttp := internalHTTP.NewHTTPClient(
-				http.MethodPut,
-				makeControllerGetUpdateDeleteTablePath(svcName, tableName),
-				http.Client{},
-				[]byte(table.Spec.PinotTablesJson),
-				auth,
+		if err == nil {
+			_, err := r.makePatchPinotTableStatus(
+				table,
+				PinotTableControllerUpdateSuccess,
+				respUpdateTable,
+				v1.ConditionTrue,
+				PinotTableControllerUpdateSuccess,
 			)
-			respUpdateTable, err := postHttp.Do()
 			if err != nil {
 				return controllerutil.OperationResultNone, err
 			}
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respUpdateTable),
+				PinotTableControllerUpdateSuccess,
+			)
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respUpdateTable),
+				PinotTableControllerPatchStatusSuccess)
 
-			if respUpdateTable.StatusCode == 200 {
-				_, err := r.makePatchPinotTableStatus(
-					table,
-					PinotTableControllerUpdateSuccess,
-					string(respUpdateTable.ResponseBody),
-					v1.ConditionTrue,
-					PinotTableControllerUpdateSuccess,
-				)
-				if err != nil {
-					return controllerutil.OperationResultNone, err
-				}
-				build.Recorder.GenericEvent(
-					table,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTable.ResponseBody)),
-					PinotTableControllerUpdateSuccess,
-				)
-				build.Recorder.GenericEvent(
-					table,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTable.ResponseBody)),
-					PinotTableControllerPatchStatusSuccess)
-
-				return controllerutil.OperationResultUpdated, nil
-			} else {
-				// patch status with failure and emit events
-				_, err := r.makePatchPinotTableStatus(
-					table,
-					PinotTableControllerUpdateFail,
-					string(respUpdateTable.ResponseBody),
-					v1.ConditionTrue,
-					PinotTableControllerUpdateFail,
-				)
-				if err != nil {
-					return controllerutil.OperationResultNone, err
-				}
-				build.Recorder.GenericEvent(
-					table,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTable.ResponseBody)),
-					PinotTableControllerUpdateFail,
-				)
+			return controllerutil.OperationResultUpdated, nil
+		} else {
+			// patch status with failure and emit events
+			if _, err := r.makePatchPinotTableStatus(
+				table,
+				PinotTableControllerUpdateFail,
+				err.Error(),
+				v1.ConditionTrue,
+				PinotTableControllerUpdateFail,
+			); err != nil {
 				return controllerutil.OperationResultNone, err
 			}
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotTableControllerUpdateFail,
+			)
+			return controllerutil.OperationResultNone, nil
 		}
 	}
 
-	return controllerutil.OperationResultNone, nil
+	if err := r.redactStatus(ctx, table); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	// the spec is applied, compare it with the live table on pinot
+	return r.reconcileDrift(ctx, table, pc, tableName, respGetTable, build)
+}
+
+// patchUnreachableCondition sets the Unreachable condition of the table from
+// the circuit breaker of its pinot controller.
+func (r *PinotTableReconciler) patchUnreachableCondition(ctx context.Context, table *v1beta1.PinotTable, pc *pinot.Client) error {
+	condition, changed := utils.NewUnreachableCondition(table.Status.Conditions, pc, table.Generation)
+	if !changed {
+		return nil
+	}
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	})
+}
+
+// getPinotClient returns a client for the controller of the pinot cluster of the table
+func (r *PinotTableReconciler) getPinotClient(ctx context.Context, table *v1beta1.PinotTable) (*pinot.Client, error) {
+	if table.Spec.PinotExternalCluster != "" {
+		return utils.GetExternalClusterClient(ctx, r.Client, table.Namespace, table.Spec.PinotExternalCluster)
+	}
+
+	pt := &v1beta1.Pinot{}
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Namespace: table.Namespace,
+		Name:      table.Spec.PinotCluster,
+	},
+		pt,
+	); err != nil {
+		return nil, err
+	}
+
+	svcName, err := r.getControllerSvcUrl(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := utils.GetControllerAuth(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := utils.GetControllerTLSConfig(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pinot.NewClient(svcName, auth, tlsConfig)
 }
 
-func (r *PinotTableReconciler) getControllerSvcUrl(namespace, pinotClusterName string) (string, error) {
+func (r *PinotTableReconciler) getControllerSvcUrl(pt *v1beta1.Pinot) (string, error) {
 	listOpts := []client.ListOption{
-		client.InNamespace(namespace),
+		client.InNamespace(pt.Namespace),
 		client.MatchingLabels(map[string]string{
-			"custom_resource": pinotClusterName,
+			"custom_resource": pt.Name,
 			"nodeType":        "controller",
 		}),
 	}
@@ -333,8 +398,7 @@ func (r *PinotTableReconciler) getControllerSvcUrl(namespace, pinotClusterName s
 		svcName = svcList.Items[0].Name
 	}
 
-	newName := "http://" + svcName + "." + namespace + ".svc.cluster.local:" + PinotControllerPort
-	return newName, nil
+	return utils.GetControllerURL(pt, svcName), nil
 }
 
 func (r *PinotTableReconciler) makePatchPinotTableStatus(
@@ -346,15 +410,22 @@ func (r *PinotTableReconciler) makePatchPinotTableStatus(
 
 ) (controllerutil.OperationResult, error) {
 
-	if _, _, err := utils.PatchStatus(context.Background(), r.Client, table, func(obj client.Object) client.Object {
+	tableJson, err := statusTableJson(table, table.Spec.PinotTablesJson)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if err := r.patchStatus(context.Background(), table, func(obj client.Object) client.Object {
 		in := obj.(*v1beta1.PinotTable)
-		in.Status.CurrentTableJson = table.Spec.PinotTablesJson
+		in.Status.CurrentTableJson = tableJson
 		in.Status.LastUpdateTime = metav1.Time{Time: time.Now()}
 		in.Status.Message = msg
 		in.Status.Reason = reason
 		in.Status.Status = status
 		in.Status.Type = pinotTableConditionType
 		in.Status.ReloadStatus = []string{}
+		if pinotTableConditionType == PinotTableControllerCreateSuccess || pinotTableConditionType == PinotTableControllerUpdateSuccess {
+			in.Status.Revisions = utils.AppendRevision(in.Status.Revisions, tableJson, in.Generation, reason, in.Spec.RevisionHistoryLimit)
+		}
 		return in
 	}); err != nil {
 		return controllerutil.OperationResultNone, err
@@ -362,37 +433,3 @@ func (r *PinotTableReconciler) makePatchPinotTableStatus(
 
 	return controllerutil.OperationResultUpdatedStatusOnly, nil
 }
-
-func (r *PinotTableReconciler) getAuthCreds(ctx context.Context, table *v1beta1.PinotTable) (internalHTTP.BasicAuth, error) {
-	pinot := v1beta1.Pinot{}
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Namespace: table.Namespace,
-		Name:      table.Spec.PinotCluster,
-	},
-		&pinot,
-	); err != nil {
-		return internalHTTP.BasicAuth{}, err
-	}
-
-	if pinot.Spec.Auth != (v1beta1.Auth{}) {
-		secret := v1.Secret{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Namespace: pinot.Spec.Auth.SecretRef.Namespace,
-			Name:      pinot.Spec.Auth.SecretRef.Name,
-		},
-			&secret,
-		); err != nil {
-			return internalHTTP.BasicAuth{}, err
-		}
-
-		creds := internalHTTP.BasicAuth{
-			UserName: string(secret.Data[ControlPlaneUserName]),
-			Password: string(secret.Data[ControlPlanePassword]),
-		}
-
-		return creds, nil
-
-	}
-
-	return internalHTTP.BasicAuth{}, nil
-}
@@ -0,0 +1,334 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// reloadHistoryLimit is the number of reload jobs kept in status.reloads
+	reloadHistoryLimit = 10
+	// reloadTimeout fails a reload job whose segments are not all reloaded
+	// after it
+	reloadTimeout = 30 * time.Minute
+	// reloadGracePeriod keeps a new reload job without segments in progress,
+	// pinot counts the segments of a job once its servers picked it up
+	reloadGracePeriod = time.Minute
+	// reloadAllSegments is the reload annotation value reloading the table
+	reloadAllSegments = "all"
+
+	reloadAnnotationTrigger = "annotation"
+	reloadSchemaTrigger     = "schema update"
+)
+
+// reloadSegments returns the segments named by the reload annotation, none
+// when all segments are reloaded.
+func reloadSegments(value string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(value, ",") {
+		segment = strings.TrimSpace(segment)
+		if segment != "" && segment != reloadAllSegments {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// reconcileReload follows the reload jobs in progress, and reloads the table
+// or the segments set in the reload annotation, which is removed once they
+// are submitted.
+func (r *PinotTableReconciler) reconcileReload(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	if err := r.pollReloads(ctx, table, pc, build); err != nil {
+		return err
+	}
+
+	value, ok := table.Annotations[reloadAnnotation]
+	// a table is reloaded once its table config is applied
+	if !ok || table.Status.CurrentTableJson == "" {
+		return nil
+	}
+
+	tableName, err := specTableName(table)
+	if err != nil {
+		return err
+	}
+	if err := r.submitReload(ctx, table, pc, tableName, reloadSegments(value), reloadAnnotationTrigger, build); err != nil {
+		return err
+	}
+
+	return r.removeAnnotation(ctx, table, reloadAnnotation)
+}
+
+// submitReload reloads all segments of the table, or the given segments, and
+// records the reload jobs pinot started in status.reloads.
+func (r *PinotTableReconciler) submitReload(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	tableName string,
+	segments []string,
+	trigger string,
+	build builder.Builder,
+) error {
+
+	jobs := []v1beta1.SegmentReloadJob{}
+	if len(segments) == 0 {
+		resp, err := pc.ReloadTable(ctx, tableName)
+		if err != nil && !pinot.IsAPIError(err) {
+			return err
+		}
+		jobs = append(jobs, reloadJobs(tableName, "", trigger, resp, err)...)
+		if err == nil {
+			build.Recorder.GenericEvent(
+				table,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Reload of all segments submitted, %s, Resp [%s]", trigger, resp),
+				PinotTableReloadAllSegments,
+			)
+		}
+	} else {
+		tableSegments, listErr := pc.ListSegments(ctx, tableName)
+		if listErr != nil && !pinot.IsAPIError(listErr) {
+			return listErr
+		}
+
+		for _, segment := range segments {
+			tableNameWithType := segmentTableName(tableName, tableSegments, segment)
+			if tableNameWithType == "" {
+				err := listErr
+				if err == nil {
+					err = fmt.Errorf("segment %s not found", segment)
+				}
+				jobs = append(jobs, reloadJobs(tableName, segment, trigger, "", err)...)
+				continue
+			}
+
+			resp, err := pc.ReloadSegment(ctx, tableNameWithType, segment)
+			if err != nil && !pinot.IsAPIError(err) {
+				return err
+			}
+			jobs = append(jobs, reloadJobs(tableNameWithType, segment, trigger, resp, err)...)
+			if err == nil {
+				build.Recorder.GenericEvent(
+					table,
+					v1.EventTypeNormal,
+					fmt.Sprintf("Reload of segment [%s] submitted, %s, Resp [%s]", segment, trigger, resp),
+					PinotTableControllerReloadSegment,
+				)
+			}
+		}
+	}
+
+	for _, job := range jobs {
+		r.recordReloadResult(table, job, build)
+	}
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		in.Status.Reloads = append(in.Status.Reloads, jobs...)
+		if len(in.Status.Reloads) > reloadHistoryLimit {
+			in.Status.Reloads = in.Status.Reloads[len(in.Status.Reloads)-reloadHistoryLimit:]
+		}
+		return in
+	})
+}
+
+// reloadJobs returns the reload jobs of a reload response. A reload pinot
+// accepted without job ids is recorded as submitted.
+func reloadJobs(tableName, segment, trigger, resp string, err error) []v1beta1.SegmentReloadJob {
+	now := metav1.Time{Time: time.Now()}
+	job := v1beta1.SegmentReloadJob{
+		TableName:  tableName,
+		Segment:    segment,
+		Trigger:    trigger,
+		SubmitTime: &now,
+	}
+
+	if err != nil {
+		job.Phase = v1beta1.ReloadFailed
+		job.Message = err.Error()
+		job.CompletionTime = &now
+		return []v1beta1.SegmentReloadJob{job}
+	}
+
+	ids := pinot.ReloadJobs(resp)
+	if len(ids) == 0 {
+		job.Phase = v1beta1.ReloadSubmitted
+		job.Message = resp
+		return []v1beta1.SegmentReloadJob{job}
+	}
+
+	tableNames := []string{}
+	for tableNameWithType := range ids {
+		tableNames = append(tableNames, tableNameWithType)
+	}
+	sort.Strings(tableNames)
+
+	jobs := []v1beta1.SegmentReloadJob{}
+	for _, tableNameWithType := range tableNames {
+		job.JobID = ids[tableNameWithType]
+		job.TableName = tableNameWithType
+		job.Phase = v1beta1.ReloadInProgress
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// segmentTableName returns the table name with the type of the table type
+// having the segment, empty when no table type has it.
+func segmentTableName(tableName string, tableSegments map[string][]string, segment string) string {
+	for tableType, segments := range tableSegments {
+		for _, s := range segments {
+			if s == segment {
+				return tableName + "_" + tableType
+			}
+		}
+	}
+	return ""
+}
+
+// pollReloads updates the reload jobs in progress with their status on pinot.
+// A job pinot no longer knows, or one not done after the reload timeout, is
+// failed. A job is only done once every server answered.
+func (r *PinotTableReconciler) pollReloads(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	polled := map[string]v1beta1.SegmentReloadJob{}
+	for _, job := range table.Status.Reloads {
+		if job.Phase != v1beta1.ReloadInProgress {
+			continue
+		}
+
+		reload, err := pc.GetReloadStatus(ctx, job.JobID)
+		if err != nil && !pinot.IsAPIError(err) {
+			return err
+		}
+
+		now := metav1.Time{Time: time.Now()}
+		switch {
+		case err != nil:
+			job.Phase = v1beta1.ReloadFailed
+			job.Message = err.Error()
+		case reload.TotalSegmentCount == 0 && job.SubmitTime != nil && now.Sub(job.SubmitTime.Time) < reloadGracePeriod:
+			continue
+		case reload.TotalServerCallsFailed == 0 && reload.SuccessCount >= reload.TotalSegmentCount:
+			job.Phase = v1beta1.ReloadSucceeded
+			job.TotalSegments = reload.TotalSegmentCount
+			job.SucceededSegments = reload.SuccessCount
+			job.FailedSegments = 0
+			job.FailedServerCalls = 0
+			job.Message = fmt.Sprintf("%d of %d segments reloaded", reload.SuccessCount, reload.TotalSegmentCount)
+		case job.SubmitTime != nil && now.Sub(job.SubmitTime.Time) > reloadTimeout:
+			job.Phase = v1beta1.ReloadFailed
+			job.TotalSegments = reload.TotalSegmentCount
+			job.SucceededSegments = reload.SuccessCount
+			job.FailedSegments = reload.TotalSegmentCount - reload.SuccessCount
+			job.FailedServerCalls = reload.TotalServerCallsFailed
+			job.Message = fmt.Sprintf("%d of %d segments reloaded after %s, %d of %d servers failed",
+				reload.SuccessCount, reload.TotalSegmentCount, reloadTimeout, reload.TotalServerCallsFailed, reload.TotalServersQueried)
+		default:
+			if job.TotalSegments == reload.TotalSegmentCount && job.SucceededSegments == reload.SuccessCount &&
+				job.FailedServerCalls == reload.TotalServerCallsFailed {
+				continue
+			}
+			job.TotalSegments = reload.TotalSegmentCount
+			job.SucceededSegments = reload.SuccessCount
+			job.FailedServerCalls = reload.TotalServerCallsFailed
+			job.Message = fmt.Sprintf("%d of %d segments reloaded, %d servers queried, %d failed",
+				reload.SuccessCount, reload.TotalSegmentCount, reload.TotalServersQueried, reload.TotalServerCallsFailed)
+		}
+		if job.Phase != v1beta1.ReloadInProgress {
+			job.CompletionTime = &now
+			r.recordReloadResult(table, job, build)
+		}
+		polled[job.JobID] = job
+	}
+
+	if len(polled) == 0 {
+		return nil
+	}
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		for i, job := range in.Status.Reloads {
+			if updated, ok := polled[job.JobID]; ok && job.JobID != "" {
+				in.Status.Reloads[i] = updated
+			}
+		}
+		return in
+	})
+}
+
+// recordReloadResult emits the event of a finished reload job.
+func (r *PinotTableReconciler) recordReloadResult(
+	table *v1beta1.PinotTable,
+	job v1beta1.SegmentReloadJob,
+	build builder.Builder,
+) {
+
+	switch job.Phase {
+	case v1beta1.ReloadSucceeded:
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Reload of [%s] succeeded, Resp [%s]", job.TableName, job.Message),
+			PinotTableControllerReloadSuccess,
+		)
+	case v1beta1.ReloadFailed:
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Reload of [%s] failed, Resp [%s]", job.TableName, job.Message),
+			PinotTableControllerReloadFail,
+		)
+	}
+}
+
+// removeAnnotation removes an annotation handled by the reconcile, the status
+// patches refetched the table before patching it so the latest table is
+// updated.
+func (r *PinotTableReconciler) removeAnnotation(ctx context.Context, table *v1beta1.PinotTable, annotation string) error {
+	latest := &v1beta1.PinotTable{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: table.Namespace, Name: table.Name}, latest); err != nil {
+		return err
+	}
+	delete(latest.Annotations, annotation)
+	return r.Update(ctx, latest)
+}
@@ -0,0 +1,80 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// rollback writes the table config of the revision requested by the rollback
+// annotation inline in the spec and removes the annotation, the table config is
+// applied by the next reconcile. tableFrom is replaced. It returns true when the
+// CR was updated.
+func (r *PinotTableReconciler) rollback(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	build builder.Builder,
+) (bool, error) {
+
+	value, ok := table.Annotations[rollbackAnnotation]
+	if !ok || !table.ObjectMeta.DeletionTimestamp.IsZero() {
+		return false, nil
+	}
+
+	in := table.DeepCopy()
+	delete(in.Annotations, rollbackAnnotation)
+
+	revision, err := utils.GetRevision(table.Status.Revisions, value)
+	if err != nil {
+		// the annotation is removed so that the rollback is not retried
+		if err := r.Update(ctx, in); err != nil {
+			return false, err
+		}
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotTableControllerRollbackFail,
+		)
+		return true, nil
+	}
+
+	if isHybridTable(in) {
+		if in.Spec.OfflineTablesJson, in.Spec.RealtimeTablesJson, err = splitHybridTableJson(revision.Json); err != nil {
+			return false, err
+		}
+	} else {
+		in.Spec.PinotTablesJson = revision.Json
+		in.Spec.TableFrom = nil
+	}
+	if err := r.Update(ctx, in); err != nil {
+		return false, err
+	}
+	build.Recorder.GenericEvent(
+		table,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Rolled back to revision [%d] of generation [%d]", revision.Revision, revision.Generation),
+		PinotTableControllerRollbackSuccess,
+	)
+	return true, nil
+}
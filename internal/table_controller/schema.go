@@ -0,0 +1,109 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// pinotSchemaField indexes the tables by the PinotSchema CR they depend on.
+const pinotSchemaField = ".spec.pinotSchema"
+
+// indexPinotSchema returns the PinotSchema CR of a table for the
+// pinotSchemaField index.
+func indexPinotSchema(obj client.Object) []string {
+	table, ok := obj.(*v1beta1.PinotTable)
+	if !ok || table.Spec.PinotSchema == "" {
+		return nil
+	}
+	return []string{table.Spec.PinotSchema}
+}
+
+// findTablesForSchema maps a PinotSchema to the tables depending on it.
+func (r *PinotTableReconciler) findTablesForSchema(obj client.Object) []reconcile.Request {
+	tableList := v1beta1.PinotTableList{}
+	if err := r.List(
+		context.TODO(),
+		&tableList,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{pinotSchemaField: obj.GetName()},
+	); err != nil {
+		r.Log.Error(err, "Error listing tables for schema")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, table := range tableList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: table.Namespace, Name: table.Name},
+		})
+	}
+	return requests
+}
+
+// reconcileSchemaReload reloads the segments of a table with segmentReload
+// set when its schema was applied to pinot since the table last saw it. The
+// schema applied when the table is first reconciled needs no reload.
+func (r *PinotTableReconciler) reconcileSchemaReload(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	// a table is reloaded once its table config is applied
+	if table.Status.CurrentTableJson == "" {
+		return nil
+	}
+
+	schema := &v1beta1.PinotSchema{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: table.Namespace, Name: table.Spec.PinotSchema}, schema); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	applied := schema.Status.ApplyCount
+	if applied == 0 || applied == table.Status.SchemaApplyCount {
+		return nil
+	}
+
+	if table.Status.SchemaApplyCount != 0 && table.Spec.SegmentReload {
+		tableName, err := specTableName(table)
+		if err != nil {
+			return err
+		}
+		if err := r.submitReload(ctx, table, pc, tableName, nil, reloadSchemaTrigger, build); err != nil {
+			return err
+		}
+	}
+
+	return r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		in.Status.SchemaApplyCount = applied
+		return in
+	})
+}
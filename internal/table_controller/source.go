@@ -0,0 +1,170 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tablecontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// resolveTableJson reads the table config of tableFrom into the spec, the
+// spec is only changed in memory. The table json of a hybrid table is its
+// OFFLINE and REALTIME table configs keyed by table type.
+func (r *PinotTableReconciler) resolveTableJson(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	build builder.Builder,
+) error {
+
+	if isHybridTable(table) {
+		return r.resolveHybridTableJson(table, build)
+	}
+
+	if table.Spec.TableFrom == nil {
+		return nil
+	}
+
+	if err := utils.ResolveJson(table, &table.Spec.PinotTablesJson, table.Status.CurrentTableJson, func() (string, error) {
+		return utils.GetJsonFromSource(ctx, r.Client, table.Namespace, table.Spec.TableFrom)
+	}); err != nil {
+		build.Recorder.GenericEvent(
+			table,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotTableControllerSourceFail,
+		)
+		return err
+	}
+	return nil
+}
+
+// statusTableJson returns the table json stored in the status and the
+// revisions, a table config read from a Secret only keeps its name, type and
+// digest.
+func statusTableJson(table *v1beta1.PinotTable, tableJson string) (string, error) {
+	return utils.StatusJson(table.Spec.TableFrom, tableJson, utils.TableName, tableType)
+}
+
+// appliedTableJson returns the table json applied last, a table config read
+// from a Secret is read from the spec once it is applied. It returns false
+// when the table json applied last is not known.
+func appliedTableJson(table *v1beta1.PinotTable) (string, bool, error) {
+	if table.Status.CurrentTableJson == "" {
+		return "", false, nil
+	}
+	if !utils.IsSecretDigest(table.Status.CurrentTableJson) {
+		return table.Status.CurrentTableJson, true, nil
+	}
+
+	ok, err := utils.IsAppliedJson(table.Status.CurrentTableJson, table.Spec.PinotTablesJson)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return table.Spec.PinotTablesJson, true, nil
+}
+
+// update updates the table, a table config resolved from tableFrom or the
+// table json of a hybrid table is not stored in the spec.
+func (r *PinotTableReconciler) update(ctx context.Context, table *v1beta1.PinotTable) error {
+	resolved := table.Spec.TableFrom != nil || isHybridTable(table)
+	return utils.UpdateResolved(ctx, r.Client, table, &table.Spec.PinotTablesJson, resolved)
+}
+
+// patchStatus patches the status, the table config resolved from tableFrom is
+// kept in the spec.
+func (r *PinotTableReconciler) patchStatus(
+	ctx context.Context,
+	table *v1beta1.PinotTable,
+	transform utils.TransformStatusFunc,
+) error {
+	return utils.PatchResolvedStatus(ctx, r.Client, table, &table.Spec.PinotTablesJson, transform)
+}
+
+// redactStatus keeps only the digest of a table config read from a Secret in
+// the status and the revisions, for tables applied before it was redacted.
+func (r *PinotTableReconciler) redactStatus(ctx context.Context, table *v1beta1.PinotTable) error {
+	if !utils.IsSecretSource(table.Spec.TableFrom) || utils.IsSecretDigest(table.Status.CurrentTableJson) {
+		return nil
+	}
+
+	currentTableJson, err := statusTableJson(table, table.Status.CurrentTableJson)
+	if err != nil {
+		return err
+	}
+	revisions, err := utils.RedactRevisions(table.Status.Revisions, utils.TableName, tableType)
+	if err != nil {
+		return err
+	}
+
+	if err := r.patchStatus(ctx, table, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTable)
+		in.Status.CurrentTableJson = currentTableJson
+		in.Status.Revisions = revisions
+		return in
+	}); err != nil {
+		return err
+	}
+	table.Status.CurrentTableJson = currentTableJson
+	table.Status.Revisions = revisions
+	return nil
+}
+
+// indexTableSource returns the source of the table config of a table for the
+// utils.SourceIndexField index.
+func indexTableSource(obj client.Object) []string {
+	table, ok := obj.(*v1beta1.PinotTable)
+	if !ok {
+		return nil
+	}
+	return utils.SourceIndexValues(table.Spec.TableFrom)
+}
+
+// findTablesForSource maps a ConfigMap or a Secret to the tables reading
+// their table config from it.
+func (r *PinotTableReconciler) findTablesForSource(obj client.Object) []reconcile.Request {
+	key := utils.SourceIndexKey(obj)
+	if key == "" {
+		return nil
+	}
+
+	tableList := v1beta1.PinotTableList{}
+	if err := r.List(
+		context.TODO(),
+		&tableList,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{utils.SourceIndexField: key},
+	); err != nil {
+		r.Log.Error(err, "Error listing tables for source")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, table := range tableList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: table.Namespace, Name: table.Name},
+		})
+	}
+	return requests
+}
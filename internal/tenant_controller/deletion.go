@@ -0,0 +1,91 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tenantcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// isDeletionBlocked returns true when the deletion protection annotation keeps
+// the deleted tenant, nothing is deleted from pinot until it is removed.
+func (r *PinotTenantReconciler) isDeletionBlocked(tenant *v1beta1.PinotTenant, build builder.Builder) bool {
+	if !utils.IsDeletionProtected(tenant.Annotations, deletionProtectionAnnotation) {
+		return false
+	}
+
+	build.Recorder.GenericEvent(
+		tenant,
+		v1.EventTypeWarning,
+		fmt.Sprintf("Deletion blocked by annotation [%s], remove it to delete the tenant", deletionProtectionAnnotation),
+		PinotTenantControllerDeleteBlocked,
+	)
+	return true
+}
+
+// deleteTenant deletes the tenant of a deleted CR from pinot, or keeps it when
+// the deletion policy is Retain or Orphan.
+func (r *PinotTenantReconciler) deleteTenant(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	tenantName, err := utils.GetValueFromJson(tenant.Spec.PinotTenantsJson, utils.TenantName)
+	if err != nil {
+		return err
+	}
+
+	if !utils.IsDeletedOnPinot(tenant.Spec.DeletionPolicy) {
+		// pinot has no labels for tenants, an orphaned tenant is only kept
+		build.Recorder.GenericEvent(
+			tenant,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Tenant [%s] kept on pinot, deletion policy [%s]", tenantName, tenant.Spec.DeletionPolicy),
+			PinotTenantControllerRetained,
+		)
+		return nil
+	}
+
+	respDeleteTenant, err := pc.DeleteTenant(ctx, tenantName, string(tenant.Spec.PinotTenantType))
+	if err != nil && !pinot.IsAPIError(err) {
+		return err
+	}
+	if err != nil && !pinot.IsNotFound(err) {
+		build.Recorder.GenericEvent(
+			tenant,
+			v1.EventTypeWarning,
+			fmt.Sprintf("Resp [%s]", err.Error()),
+			PinotTenantControllerDeleteFail,
+		)
+	} else {
+		build.Recorder.GenericEvent(
+			tenant,
+			v1.EventTypeNormal,
+			fmt.Sprintf("Resp [%s]", respDeleteTenant),
+			PinotTenantControllerDeleteSuccess,
+		)
+	}
+	return nil
+}
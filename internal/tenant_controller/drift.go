@@ -0,0 +1,205 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tenantcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	tenantRole        = "tenantRole"
+	numberOfInstances = "numberOfInstances"
+)
+
+// tenantDriftJson returns the part of tenantJson pinot reports back for a
+// tenant, and the same fields built from the live tenant. Pinot only returns
+// the instances tagged with the tenant, so the drift of a tenant is limited to
+// its number of instances.
+func tenantDriftJson(tenantJson, respGetTenant string) (string, string, error) {
+	desired := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(tenantJson), &desired); err != nil {
+		return "", "", err
+	}
+
+	live := struct {
+		ServerInstances []string `json:"ServerInstances"`
+		BrokerInstances []string `json:"BrokerInstances"`
+		TenantName      string   `json:"tenantName"`
+	}{}
+	if err := json.Unmarshal([]byte(respGetTenant), &live); err != nil {
+		return "", "", err
+	}
+
+	role, _ := desired[tenantRole].(string)
+	instances := live.ServerInstances
+	if strings.EqualFold(role, string(v1beta1.BrokerTenant)) {
+		instances = live.BrokerInstances
+	}
+
+	desiredFields := map[string]interface{}{}
+	for _, key := range []string{utils.TenantName, numberOfInstances} {
+		if value, ok := desired[key]; ok {
+			desiredFields[key] = value
+		}
+	}
+	liveFields := map[string]interface{}{
+		utils.TenantName:  live.TenantName,
+		numberOfInstances: len(instances),
+	}
+
+	desiredJson, err := json.Marshal(desiredFields)
+	if err != nil {
+		return "", "", err
+	}
+	liveJson, err := json.Marshal(liveFields)
+	if err != nil {
+		return "", "", err
+	}
+	return string(desiredJson), string(liveJson), nil
+}
+
+// reconcileDrift compares the applied spec with the live tenant on pinot to
+// catch changes made outside of the control plane, they are corrected or only
+// reported depending on the drift policy.
+func (r *PinotTenantReconciler) reconcileDrift(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	pc *pinot.Client,
+	respGetTenant string,
+	build builder.Builder,
+) (controllerutil.OperationResult, error) {
+
+	desiredTenant, liveTenant, err := tenantDriftJson(tenant.Spec.PinotTenantsJson, respGetTenant)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	diffs, err := utils.JsonDrift(desiredTenant, liveTenant)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if len(diffs) == 0 {
+		// keep the reason of a corrected drift until the next one
+		if meta.IsStatusConditionFalse(tenant.Status.Conditions, v1beta1.ConditionDrifted) {
+			return controllerutil.OperationResultNone, nil
+		}
+		_, err := r.patchDriftedCondition(ctx, tenant, metav1.ConditionFalse, PinotTenantControllerInSync, "Tenant matches pinot")
+		return controllerutil.OperationResultNone, err
+	}
+
+	msg := utils.DriftMessage(diffs)
+
+	if !utils.IsDriftCorrected(tenant.Spec.DriftPolicy) {
+		changed, err := r.patchDriftedCondition(ctx, tenant, metav1.ConditionTrue, PinotTenantControllerDrifted, msg)
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		if changed {
+			build.Recorder.GenericEvent(
+				tenant,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Tenant differs from pinot [%s]", msg),
+				PinotTenantControllerDrifted,
+			)
+		}
+		return controllerutil.OperationResultNone, nil
+	}
+
+	respUpdateTenant, err := pc.UpdateTenant(ctx, tenant.Spec.PinotTenantsJson)
+	if err != nil && !pinot.IsAPIError(err) {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if err != nil {
+		changed, patchErr := r.patchDriftedCondition(
+			ctx,
+			tenant,
+			metav1.ConditionTrue,
+			PinotTenantControllerDriftCorrectFail,
+			fmt.Sprintf("%s, correcting failed [%s]", msg, err.Error()),
+		)
+		if patchErr != nil {
+			return controllerutil.OperationResultNone, patchErr
+		}
+		if changed {
+			build.Recorder.GenericEvent(
+				tenant,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotTenantControllerDriftCorrectFail,
+			)
+		}
+		return controllerutil.OperationResultNone, nil
+	}
+
+	condition, _ := utils.NewDriftedCondition(tenant.Status.Conditions, metav1.ConditionFalse, PinotTenantControllerDriftCorrected, msg, tenant.Generation)
+	if _, _, err := utils.PatchStatus(ctx, r.Client, tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTenant)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		in.Status.ApplyCount++
+		return in
+	}); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	build.Recorder.GenericEvent(
+		tenant,
+		v1.EventTypeNormal,
+		fmt.Sprintf("Corrected [%s], Resp [%s]", msg, respUpdateTenant),
+		PinotTenantControllerDriftCorrected,
+	)
+
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// patchDriftedCondition sets the Drifted condition, it returns false without
+// patching when the condition is unchanged.
+func (r *PinotTenantReconciler) patchDriftedCondition(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	status metav1.ConditionStatus,
+	reason string,
+	msg string,
+) (bool, error) {
+
+	condition, changed := utils.NewDriftedCondition(tenant.Status.Conditions, status, reason, msg, tenant.Generation)
+	if !changed {
+		return false, nil
+	}
+
+	if _, _, err := utils.PatchStatus(ctx, r.Client, tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTenant)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
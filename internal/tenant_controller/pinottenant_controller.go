@@ -31,6 +31,7 @@ import (
 
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
 	datainfraiov1beta1 "github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 	"github.com/go-logr/logr"
 )
 
@@ -58,8 +59,9 @@ func NewPinotTenantReconciler(mgr ctrl.Manager) *PinotTenantReconciler {
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottenants,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottenants/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinottenants/finalizers,verbs=update
+// +kubebuilder:rbac:groups=datainfra.io,resources=pinotexternalclusters,verbs=get;list;watch
 // +kubebuilder:rbac:groups=datainfra.io,resources=pinotschemas/finalizers,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=secret,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 func (r *PinotTenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
@@ -89,6 +91,9 @@ func (r *PinotTenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			GenericPredicates{},
 			predicate.GenerationChangedPredicate{},
 			predicate.LabelChangedPredicate{},
+			utils.AnnotationSetPredicate(rollbackAnnotation),
+			utils.DeletionProtectionPredicate(deletionProtectionAnnotation),
+			utils.AnnotationChangedPredicate(planAnnotation),
 		)).
 		Complete(r)
 }
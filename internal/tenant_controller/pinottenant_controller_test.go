@@ -0,0 +1,280 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenantcontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot/pinottest"
+)
+
+func testTenantJson(instances int) string {
+	return fmt.Sprintf(`{"tenantRole": "BROKER", "tenantName": "sampleBrokerTenant", "numberOfInstances": %d}`, instances)
+}
+
+// specs numbers the objects of every spec, objects are not removed between
+// specs as their finalizers need the reconciler.
+var specs int
+
+var _ = Describe("PinotTenant controller", func() {
+	var (
+		ctx    context.Context
+		server *pinottest.Server
+		r      *PinotTenantReconciler
+		key    types.NamespacedName
+	)
+
+	reconcile := func() error {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		return err
+	}
+
+	getTenant := func() *v1beta1.PinotTenant {
+		tenant := &v1beta1.PinotTenant{}
+		Expect(k8sClient.Get(ctx, key, tenant)).To(Succeed())
+		return tenant
+	}
+
+	reconcileUntilFinalizer := func() {
+		Eventually(func(g Gomega) {
+			g.Expect(reconcile()).To(Succeed())
+			g.Expect(controllerutil.ContainsFinalizer(getTenant(), PinotTenantControllerFinalizer)).To(BeTrue())
+		}).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = pinottest.NewServer()
+		DeferCleanup(server.Close)
+
+		r = &PinotTenantReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+		}
+
+		specs++
+		name := fmt.Sprintf("tenant-%d", specs)
+		cluster := &v1beta1.PinotExternalCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1beta1.PinotExternalClusterSpec{ControllerURL: server.URL},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), cluster)
+
+		key = types.NamespacedName{Name: name, Namespace: "default"}
+		tenant := &v1beta1.PinotTenant{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: v1beta1.PinotTenantSpec{
+				PinotExternalCluster: cluster.Name,
+				PinotTenantType:      v1beta1.BrokerTenant,
+				PinotTenantsJson:     testTenantJson(1),
+			},
+		}
+		Expect(k8sClient.Create(ctx, tenant)).To(Succeed())
+	})
+
+	It("creates the tenant and registers the finalizer", func() {
+		reconcileUntilFinalizer()
+
+		tenantJson, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		Expect(tenantJson).To(MatchJSON(testTenantJson(1)))
+		tenant := getTenant()
+		Expect(tenant.Status.Type).To(Equal(PinotTenantControllerCreateSuccess))
+		Expect(tenant.Status.ApplyCount).To(Equal(int64(1)))
+	})
+
+	It("updates the tenant when the spec changes", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Spec.PinotTenantsJson = testTenantJson(2)
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenantJson, _ := server.Tenant("sampleBrokerTenant")
+		Expect(tenantJson).To(MatchJSON(testTenantJson(2)))
+		Expect(getTenant().Status.Type).To(Equal(PinotTenantControllerUpdateSuccess))
+		Expect(getTenant().Status.ApplyCount).To(Equal(int64(2)))
+	})
+
+	It("counts a tenant applied before the apply count was kept", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Status.ApplyCount = 0
+		Expect(k8sClient.Status().Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenant = getTenant()
+		Expect(tenant.Status.ApplyCount).To(Equal(int64(1)))
+		Expect(tenant.Status.Type).To(Equal(PinotTenantControllerCreateSuccess))
+	})
+
+	It("deletes the tenant from pinot before removing the finalizer", func() {
+		reconcileUntilFinalizer()
+
+		Expect(k8sClient.Delete(ctx, getTenant())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeFalse())
+		Expect(server.Requests()).To(ContainElement(pinottest.Request{
+			Method: http.MethodDelete,
+			Path:   "/tenants/sampleBrokerTenant",
+			Query:  "type=BROKER",
+		}))
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTenant{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports pinot errors in the status", func() {
+		server.Fail(http.MethodPost, "/tenants", http.StatusBadRequest, "Failed to allocate broker instances")
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeFalse())
+		tenant := getTenant()
+		Expect(tenant.Status.Type).To(Equal(PinotTenantControllerCreateFail))
+		Expect(tenant.Status.Reason).To(ContainSubstring("Failed to allocate broker instances"))
+	})
+
+	It("corrects changes made on pinot outside of the spec", func() {
+		reconcileUntilFinalizer()
+		Expect(reconcile()).To(Succeed())
+		Expect(apimeta.IsStatusConditionFalse(getTenant().Status.Conditions, v1beta1.ConditionDrifted)).To(BeTrue())
+
+		Expect(server.AddTenant(testTenantJson(3))).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenantJson, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		Expect(tenantJson).To(MatchJSON(testTenantJson(1)))
+		condition := apimeta.FindStatusCondition(getTenant().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(PinotTenantControllerDriftCorrected))
+		Expect(condition.Message).To(Equal("numberOfInstances: desired 1, live 3"))
+	})
+
+	It("only reports drift with the Report policy", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Spec.DriftPolicy = v1beta1.DriftPolicyReport
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(server.AddTenant(testTenantJson(3))).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenantJson, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		Expect(tenantJson).To(MatchJSON(testTenantJson(3)))
+		condition := apimeta.FindStatusCondition(getTenant().Status.Conditions, v1beta1.ConditionDrifted)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(PinotTenantControllerDrifted))
+	})
+
+	It("plans an update with the diff against pinot", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Spec.Mode = v1beta1.ReconcileModePlan
+		tenant.Spec.PinotTenantsJson = testTenantJson(2)
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenantJson, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		Expect(tenantJson).To(MatchJSON(testTenantJson(1)))
+		plan := getTenant().Status.Plan
+		Expect(plan).NotTo(BeNil())
+		Expect(plan.Action).To(Equal(v1beta1.PlanActionUpdate))
+		Expect(plan.Diff).To(Equal([]string{"numberOfInstances: desired 2, live 1"}))
+		Expect(plan.Validation).To(Equal(v1beta1.PlanValidationSkipped))
+	})
+
+	It("keeps the applied revisions and rolls back to one", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Spec.PinotTenantsJson = testTenantJson(2)
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(getTenant().Status.Revisions).To(HaveLen(2))
+
+		tenant = getTenant()
+		tenant.Annotations = map[string]string{rollbackAnnotation: "1"}
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		tenantJson, _ := server.Tenant("sampleBrokerTenant")
+		Expect(tenantJson).To(MatchJSON(testTenantJson(1)))
+		tenant = getTenant()
+		Expect(tenant.Annotations).NotTo(HaveKey(rollbackAnnotation))
+		Expect(tenant.Status.Revisions).To(HaveLen(3))
+		Expect(tenant.Status.Revisions[2].Json).To(MatchJSON(testTenantJson(1)))
+	})
+
+	It("keeps a protected tenant on pinot", func() {
+		reconcileUntilFinalizer()
+
+		tenant := getTenant()
+		tenant.Annotations = map[string]string{deletionProtectionAnnotation: "true"}
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, getTenant())).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok := server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		Expect(controllerutil.ContainsFinalizer(getTenant(), PinotTenantControllerFinalizer)).To(BeTrue())
+
+		// the tenant is released without deleting it once it is retained
+		tenant = getTenant()
+		tenant.Annotations = nil
+		tenant.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+		Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+		Expect(reconcile()).To(Succeed())
+
+		_, ok = server.Tenant("sampleBrokerTenant")
+		Expect(ok).To(BeTrue())
+		err := k8sClient.Get(ctx, key, &v1beta1.PinotTenant{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("returns transport errors to be retried", func() {
+		server.Close()
+		Expect(reconcile()).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,149 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tenantcontroller
+
+import (
+	"context"
+	"time"
+
+	"github.com/datainfrahq/operator-runtime/builder"
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// plan stores what applying the tenant would do on pinot in the status,
+// nothing is changed on pinot. A deleted CR is released without deleting the
+// tenant.
+func (r *PinotTenantReconciler) plan(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	pc *pinot.Client,
+	build builder.Builder,
+) error {
+
+	plan, err := r.makePlan(ctx, tenant, pc)
+	if err != nil {
+		return err
+	}
+
+	// the plan of a deleted CR is only emitted as an event as the CR is gone
+	// once released
+	if !tenant.ObjectMeta.DeletionTimestamp.IsZero() {
+		build.Recorder.GenericEvent(
+			tenant,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotTenantControllerPlan,
+		)
+		if controllerutil.ContainsFinalizer(tenant, PinotTenantControllerFinalizer) {
+			controllerutil.RemoveFinalizer(tenant, PinotTenantControllerFinalizer)
+			if err := r.Update(ctx, tenant); err != nil {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	if utils.IsPlanChanged(tenant.Status.Plan, plan) {
+		if err := r.patchPlanStatus(ctx, tenant, &plan); err != nil {
+			return err
+		}
+		build.Recorder.GenericEvent(
+			tenant,
+			v1.EventTypeNormal,
+			utils.PlanMessage(plan),
+			PinotTenantControllerPlan,
+		)
+	}
+
+	return nil
+}
+
+// makePlan computes the action the tenant controller would take and the diff
+// against the live tenant. Pinot has no tenant validation endpoint, so the
+// validation is skipped.
+func (r *PinotTenantReconciler) makePlan(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	pc *pinot.Client,
+) (v1beta1.PlanStatus, error) {
+
+	plan := v1beta1.PlanStatus{
+		Action:             v1beta1.PlanActionNone,
+		Validation:         v1beta1.PlanValidationSkipped,
+		ValidationMessage:  "pinot has no tenant validation endpoint",
+		ObservedGeneration: tenant.Generation,
+		LastPlanTime:       metav1.Time{Time: time.Now()},
+	}
+
+	tenantName, err := utils.GetValueFromJson(tenant.Spec.PinotTenantsJson, utils.TenantName)
+	if err != nil {
+		return plan, err
+	}
+
+	respGetTenant, err := pc.GetTenant(ctx, tenantName)
+	if err != nil && !pinot.IsNotFound(err) {
+		return plan, err
+	}
+	exists := err == nil
+
+	if !tenant.ObjectMeta.DeletionTimestamp.IsZero() {
+		if exists && utils.IsDeletedOnPinot(tenant.Spec.DeletionPolicy) {
+			plan.Action = v1beta1.PlanActionDelete
+		}
+		return plan, nil
+	}
+
+	if !exists {
+		plan.Action = v1beta1.PlanActionCreate
+		return plan, nil
+	}
+
+	desiredTenant, liveTenant, err := tenantDriftJson(tenant.Spec.PinotTenantsJson, respGetTenant)
+	if err != nil {
+		return plan, err
+	}
+	if plan.Diff, err = utils.JsonDrift(desiredTenant, liveTenant); err != nil {
+		return plan, err
+	}
+	specChanged, err := utils.IsSpecChanged(tenant.Status.CurrentTenantsJson, tenant.Spec.PinotTenantsJson)
+	if err != nil {
+		return plan, err
+	}
+	plan.Action = utils.PlanUpdateAction(specChanged, plan.Diff, tenant.Spec.DriftPolicy)
+
+	return plan, nil
+}
+
+// patchPlanStatus stores the plan in the status, a nil plan removes it.
+func (r *PinotTenantReconciler) patchPlanStatus(
+	ctx context.Context,
+	tenant *v1beta1.PinotTenant,
+	plan *v1beta1.PlanStatus,
+) error {
+
+	_, _, err := utils.PatchStatus(ctx, r.Client, tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTenant)
+		in.Status.Plan = plan
+		return in
+	})
+	return err
+}
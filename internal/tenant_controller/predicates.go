@@ -28,6 +28,12 @@ import (
 
 const (
 	ignoreAnnotation = "pinottenant.datainfra.io/reconcile"
+	// rollbackAnnotation rolls the spec back to the revision it is set to
+	rollbackAnnotation = "pinottenant.datainfra.io/rollback-to"
+	// planAnnotation set to true plans the CR like spec.mode Plan
+	planAnnotation = "pinottenant.datainfra.io/plan"
+	// deletionProtectionAnnotation set to true keeps the CR until it is removed
+	deletionProtectionAnnotation = "pinottenant.datainfra.io/deletion-protection"
 )
 
 // All methods to implement GenericPredicates type
@@ -19,14 +19,14 @@ import (
 	"context"
 	"fmt"
 
-	"net/http"
 	"time"
 
 	"github.com/datainfrahq/operator-runtime/builder"
 	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
-	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
 	"github.com/datainfrahq/pinot-control-plane-k8s/internal/utils"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,34 +44,49 @@ const (
 	PinotTenantControllerDeleteFail         = "PinotTenantControllerDeleteFail"
 	PinotTenantControllerPatchStatusSuccess = "PinotTenantControllerPatchStatusSuccess"
 	PinotTenantControllerPatchStatusFail    = "PinotTenantControllerPatchStatusFail"
+	PinotTenantControllerInSync             = "PinotTenantControllerInSync"
+	PinotTenantControllerDrifted            = "PinotTenantControllerDrifted"
+	PinotTenantControllerDriftCorrected     = "PinotTenantControllerDriftCorrected"
+	PinotTenantControllerDriftCorrectFail   = "PinotTenantControllerDriftCorrectFail"
+	PinotTenantControllerPlan               = "PinotTenantControllerPlan"
+	PinotTenantControllerRollbackSuccess    = "PinotTenantControllerRollbackSuccess"
+	PinotTenantControllerRollbackFail       = "PinotTenantControllerRollbackFail"
+	PinotTenantControllerDeleteBlocked      = "PinotTenantControllerDeleteBlocked"
+	PinotTenantControllerRetained           = "PinotTenantControllerRetained"
 	PinotTenantControllerFinalizer          = "pinottenant.datainfra.io/finalizer"
 )
 
-const (
-	PinotControllerPort = "9000"
-)
-
-const (
-	ControlPlaneUserName = "CONTROL_PLANE_USERNAME"
-	ControlPlanePassword = "CONTROL_PLANE_PASSWORD"
-)
-
 func (r *PinotTenantReconciler) do(ctx context.Context, tenant *v1beta1.PinotTenant) error {
 	build := builder.NewBuilder(
 		builder.ToNewBuilderRecorder(builder.BuilderRecorder{Recorder: r.Recorder, ControllerName: "PinorTableController"}),
 	)
 
-	svcName, err := r.getControllerSvcUrl(tenant.Namespace, tenant.Spec.PinotCluster)
-	if err != nil {
+	// a rolled back spec is applied by the reconcile of the update
+	if rolledBack, err := r.rollback(ctx, tenant, *build); err != nil || rolledBack {
 		return err
 	}
 
-	basicAuth, err := r.getAuthCreds(ctx, tenant)
+	pc, err := r.getPinotClient(ctx, tenant)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.CreateOrUpdate(tenant, svcName, *build, internalHTTP.Auth{BasicAuth: basicAuth})
+	if err := r.patchUnreachableCondition(ctx, tenant, pc); err != nil {
+		return err
+	}
+
+	if utils.IsPlanMode(tenant.Spec.Mode, tenant.Annotations, planAnnotation) {
+		return r.plan(ctx, tenant, pc, *build)
+	}
+
+	// the plan of a tenant that was planned before is stale once applied
+	if tenant.Status.Plan != nil {
+		if err := r.patchPlanStatus(ctx, tenant, nil); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.CreateOrUpdate(ctx, tenant, pc, *build)
 	if err != nil {
 		return err
 	}
@@ -88,44 +103,15 @@ func (r *PinotTenantReconciler) do(ctx context.Context, tenant *v1beta1.PinotTen
 		}
 	} else {
 		if controllerutil.ContainsFinalizer(tenant, PinotTenantControllerFinalizer) {
-			// our finalizer is present, so lets handle any external dependency
-
-			svcName, err := r.getControllerSvcUrl(tenant.Namespace, tenant.Spec.PinotCluster)
-			if err != nil {
-				return err
+			// a protected tenant is kept until the annotation is removed
+			if r.isDeletionBlocked(tenant, *build) {
+				return nil
 			}
 
-			tenantName, err := utils.GetValueFromJson(tenant.Spec.PinotTenantsJson, utils.TenantName)
-			if err != nil {
-				return err
-			}
-			http := internalHTTP.NewHTTPClient(
-				http.MethodDelete,
-				makeControllerDeleteTenantPath(svcName, tenantName,
-					string(tenant.Spec.PinotTenantType)),
-				http.Client{},
-				[]byte{},
-				internalHTTP.Auth{BasicAuth: basicAuth},
-			)
-			respDeleteTenant, err := http.Do()
-			if err != nil {
+			// our finalizer is present, so lets handle any external dependency
+			if err := r.deleteTenant(ctx, tenant, pc, *build); err != nil {
 				return err
 			}
-			if respDeleteTenant.StatusCode != 200 {
-				build.Recorder.GenericEvent(
-					tenant,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respDeleteTenant.ResponseBody)),
-					PinotTenantControllerDeleteFail,
-				)
-			} else {
-				build.Recorder.GenericEvent(
-					tenant,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respDeleteTenant.ResponseBody)),
-					PinotTenantControllerDeleteSuccess,
-				)
-			}
 
 			// remove our finalizer from the list and update it.
 			controllerutil.RemoveFinalizer(tenant, PinotTenantControllerFinalizer)
@@ -137,21 +123,61 @@ func (r *PinotTenantReconciler) do(ctx context.Context, tenant *v1beta1.PinotTen
 	return nil
 }
 
-func makeControllerCreateUpdateTenantPath(svcName string) string { return svcName + "/tenants" }
+// patchUnreachableCondition sets the Unreachable condition of the tenant from
+// the circuit breaker of its pinot controller.
+func (r *PinotTenantReconciler) patchUnreachableCondition(ctx context.Context, tenant *v1beta1.PinotTenant, pc *pinot.Client) error {
+	condition, changed := utils.NewUnreachableCondition(tenant.Status.Conditions, pc, tenant.Generation)
+	if !changed {
+		return nil
+	}
 
-func makeControllerGetTenantPath(svcName, tenantName string) string {
-	return svcName + "/tenants/" + tenantName
+	_, _, err := utils.PatchStatus(ctx, r.Client, tenant, func(obj client.Object) client.Object {
+		in := obj.(*v1beta1.PinotTenant)
+		meta.SetStatusCondition(&in.Status.Conditions, condition)
+		return in
+	})
+	return err
 }
 
-func makeControllerDeleteTenantPath(svcName, tenantName, pinotTenantType string) string {
-	return svcName + "/tenants/" + tenantName + "?type=" + pinotTenantType
+// getPinotClient returns a client for the controller of the pinot cluster of the tenant
+func (r *PinotTenantReconciler) getPinotClient(ctx context.Context, tenant *v1beta1.PinotTenant) (*pinot.Client, error) {
+	if tenant.Spec.PinotExternalCluster != "" {
+		return utils.GetExternalClusterClient(ctx, r.Client, tenant.Namespace, tenant.Spec.PinotExternalCluster)
+	}
+
+	pt := &v1beta1.Pinot{}
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Namespace: tenant.Namespace,
+		Name:      tenant.Spec.PinotCluster,
+	},
+		pt,
+	); err != nil {
+		return nil, err
+	}
+
+	svcName, err := r.getControllerSvcUrl(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := utils.GetControllerAuth(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := utils.GetControllerTLSConfig(ctx, r.Client, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pinot.NewClient(svcName, auth, tlsConfig)
 }
 
-func (r *PinotTenantReconciler) getControllerSvcUrl(namespace, pinotClusterName string) (string, error) {
+func (r *PinotTenantReconciler) getControllerSvcUrl(pt *v1beta1.Pinot) (string, error) {
 	listOpts := []client.ListOption{
-		client.InNamespace(namespace),
+		client.InNamespace(pt.Namespace),
 		client.MatchingLabels(map[string]string{
-			"custom_resource": pinotClusterName,
+			"custom_resource": pt.Name,
 			"nodeType":        "controller",
 		}),
 	}
@@ -165,16 +191,14 @@ func (r *PinotTenantReconciler) getControllerSvcUrl(namespace, pinotClusterName
 		svcName = svcList.Items[0].Name
 	}
 
-	newName := "http://" + svcName + "." + namespace + ".svc.cluster.local:" + PinotControllerPort
-
-	return newName, nil
+	return utils.GetControllerURL(pt, svcName), nil
 }
 
 func (r *PinotTenantReconciler) CreateOrUpdate(
+	ctx context.Context,
 	tenant *v1beta1.PinotTenant,
-	svcName string,
+	pc *pinot.Client,
 	build builder.Builder,
-	auth internalHTTP.Auth,
 ) (controllerutil.OperationResult, error) {
 
 	// get tenant name
@@ -184,37 +208,20 @@ func (r *PinotTenantReconciler) CreateOrUpdate(
 	}
 
 	// get tenant
-	getHttp := internalHTTP.NewHTTPClient(
-		http.MethodGet,
-		makeControllerGetTenantPath(svcName, tenantName),
-		http.Client{},
-		[]byte{},
-		auth,
-	)
-	respGetTenant, err := getHttp.Do()
-	if err != nil {
-		return controllerutil.OperationResultNone, err
-	}
+	respGetTenant, err := pc.GetTenant(ctx, tenantName)
 
 	// if not found create tenant
-	if respGetTenant.StatusCode == 404 {
-
-		postHttp := internalHTTP.NewHTTPClient(
-			http.MethodPost,
-			makeControllerCreateUpdateTenantPath(svcName),
-			http.Client{},
-			[]byte(tenant.Spec.PinotTenantsJson),
-			auth,
-		)
-		respCreateTenant, err := postHttp.Do()
-		if err != nil {
+	if pinot.IsNotFound(err) {
+
+		respCreateTenant, err := pc.CreateTenant(ctx, tenant.Spec.PinotTenantsJson)
+		if err != nil && !pinot.IsAPIError(err) {
 			return controllerutil.OperationResultNone, err
 		}
-		if respCreateTenant.StatusCode == 200 {
+		if err == nil {
 			_, err := r.makePatchPinotTenantStatus(
 				tenant,
 				PinotTenantControllerCreateSuccess,
-				string(respCreateTenant.ResponseBody),
+				respCreateTenant,
 				v1.ConditionTrue,
 				PinotTenantControllerCreateSuccess,
 			)
@@ -224,87 +231,96 @@ func (r *PinotTenantReconciler) CreateOrUpdate(
 			build.Recorder.GenericEvent(
 				tenant,
 				v1.EventTypeNormal,
-				fmt.Sprintf("Resp [%s]", string(respCreateTenant.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", respCreateTenant),
 				PinotTenantControllerCreateSuccess,
 			)
 			return controllerutil.OperationResultCreated, nil
 		} else {
-			_, err := r.makePatchPinotTenantStatus(
+			if _, err := r.makePatchPinotTenantStatus(
 				tenant,
 				PinotTenantControllerCreateFail,
-				string(respCreateTenant.ResponseBody),
+				err.Error(),
 				v1.ConditionTrue,
 				PinotTenantControllerCreateFail,
-			)
-			if err != nil {
+			); err != nil {
 				return controllerutil.OperationResultNone, err
 			}
 			build.Recorder.GenericEvent(
 				tenant, v1.EventTypeWarning,
-				fmt.Sprintf("Resp [%s]", string(respCreateTenant.ResponseBody)),
+				fmt.Sprintf("Resp [%s]", err.Error()),
 				PinotTenantControllerCreateFail,
 			)
 			return controllerutil.OperationResultNone, nil
 		}
 
-	} else if respGetTenant.StatusCode == 200 {
+	} else if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
 
-		ok, err := utils.IsEqualJson(tenant.Status.CurrentTenantsJson, tenant.Spec.PinotTenantsJson)
-		if err != nil {
+	ok, err := utils.IsEqualJson(tenant.Status.CurrentTenantsJson, tenant.Spec.PinotTenantsJson)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if !ok {
+		respUpdateTenant, err := pc.UpdateTenant(ctx, tenant.Spec.PinotTenantsJson)
+		if err != nil && !pinot.IsAPIError(err) {
 			return controllerutil.OperationResultNone, err
 		}
-		if !ok {
-			postHttp := internalHTTP.NewHTTPClient(
-				http.MethodPut,
-				makeControllerCreateUpdateTenantPath(svcName),
-				http.Client{},
-				[]byte(tenant.Spec.PinotTenantsJson),
-				auth,
+
+		if err == nil {
+			build.Recorder.GenericEvent(
+				tenant,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respUpdateTenant),
+				PinotTenantControllerUpdateSuccess,
+			)
+			_, err := r.makePatchPinotTenantStatus(
+				tenant,
+				PinotTenantControllerUpdateSuccess,
+				respUpdateTenant,
+				v1.ConditionTrue,
+				PinotTenantControllerUpdateSuccess,
 			)
-			respUpdateTenant, err := postHttp.Do()
 			if err != nil {
 				return controllerutil.OperationResultNone, err
 			}
+			build.Recorder.GenericEvent(
+				tenant,
+				v1.EventTypeNormal,
+				fmt.Sprintf("Resp [%s]", respUpdateTenant),
+				PinotTenantControllerPatchStatusSuccess,
+			)
+			return controllerutil.OperationResultUpdated, nil
 
-			if respUpdateTenant.StatusCode == 200 {
-				build.Recorder.GenericEvent(
-					tenant,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTenant.ResponseBody)),
-					PinotTenantControllerUpdateSuccess,
-				)
-				_, err := r.makePatchPinotTenantStatus(
-					tenant,
-					PinotTenantControllerUpdateSuccess,
-					string(respUpdateTenant.ResponseBody),
-					v1.ConditionTrue,
-					PinotTenantControllerUpdateSuccess,
-				)
-				if err != nil {
-					return controllerutil.OperationResultNone, err
-				}
-				build.Recorder.GenericEvent(
-					tenant,
-					v1.EventTypeNormal,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTenant.ResponseBody)),
-					PinotTenantControllerPatchStatusSuccess,
-				)
-				return controllerutil.OperationResultUpdated, nil
-
-			} else {
-				build.Recorder.GenericEvent(
-					tenant,
-					v1.EventTypeWarning,
-					fmt.Sprintf("Resp [%s]", string(respUpdateTenant.ResponseBody)),
-					PinotTenantControllerUpdateFail,
-				)
-				return controllerutil.OperationResultNone, err
-			}
+		} else {
+			build.Recorder.GenericEvent(
+				tenant,
+				v1.EventTypeWarning,
+				fmt.Sprintf("Resp [%s]", err.Error()),
+				PinotTenantControllerUpdateFail,
+			)
+			return controllerutil.OperationResultNone, nil
 		}
+	}
 
+	// a tenant applied before the apply count was kept is counted once, the
+	// tables waiting for it are created
+	if tenant.Status.ApplyCount == 0 {
+		if _, _, err := utils.PatchStatus(ctx, r.Client, tenant, func(obj client.Object) client.Object {
+			in := obj.(*v1beta1.PinotTenant)
+			if in.Status.ApplyCount == 0 {
+				in.Status.ApplyCount = 1
+			}
+			return in
+		}); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
 	}
-	return controllerutil.OperationResultNone, nil
+
+	// the spec is applied, compare it with the live tenant on pinot
+	return r.reconcileDrift(ctx, tenant, pc, respGetTenant, build)
 }
+
 func (r *PinotTenantReconciler) makePatchPinotTenantStatus(
 	tenant *v1beta1.PinotTenant,
 	msg string,
@@ -322,6 +338,10 @@ func (r *PinotTenantReconciler) makePatchPinotTenantStatus(
 		in.Status.Reason = reason
 		in.Status.Status = status
 		in.Status.Type = pinotTenantConditionType
+		if pinotTenantConditionType == PinotTenantControllerCreateSuccess || pinotTenantConditionType == PinotTenantControllerUpdateSuccess {
+			in.Status.ApplyCount++
+			in.Status.Revisions = utils.AppendRevision(in.Status.Revisions, in.Spec.PinotTenantsJson, in.Generation, reason, in.Spec.RevisionHistoryLimit)
+		}
 		return in
 	}); err != nil {
 		return controllerutil.OperationResultNone, err
@@ -329,37 +349,3 @@ func (r *PinotTenantReconciler) makePatchPinotTenantStatus(
 
 	return controllerutil.OperationResultUpdatedStatusOnly, nil
 }
-
-func (r *PinotTenantReconciler) getAuthCreds(ctx context.Context, tenant *v1beta1.PinotTenant) (internalHTTP.BasicAuth, error) {
-	pinot := v1beta1.Pinot{}
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Namespace: tenant.Namespace,
-		Name:      tenant.Spec.PinotCluster,
-	},
-		&pinot,
-	); err != nil {
-		return internalHTTP.BasicAuth{}, err
-	}
-
-	if pinot.Spec.Auth != (v1beta1.Auth{}) {
-		secret := v1.Secret{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Namespace: pinot.Spec.Auth.SecretRef.Namespace,
-			Name:      pinot.Spec.Auth.SecretRef.Name,
-		},
-			&secret,
-		); err != nil {
-			return internalHTTP.BasicAuth{}, err
-		}
-
-		creds := internalHTTP.BasicAuth{
-			UserName: string(secret.Data[ControlPlaneUserName]),
-			Password: string(secret.Data[ControlPlanePassword]),
-		}
-
-		return creds, nil
-
-	}
-
-	return internalHTTP.BasicAuth{}, nil
-}
@@ -0,0 +1,268 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaults of the controller api when not set in the Pinot spec
+const (
+	DefaultControllerAPIScheme = v1beta1.ControllerAPIHTTP
+	DefaultControllerAPIPort   = 9000
+)
+
+// default keys of the auth secret
+const (
+	ControlPlaneUserName     = "CONTROL_PLANE_USERNAME"
+	ControlPlanePassword     = "CONTROL_PLANE_PASSWORD"
+	ControlPlaneToken        = "CONTROL_PLANE_TOKEN"
+	ControlPlaneClientID     = "CONTROL_PLANE_CLIENT_ID"
+	ControlPlaneClientSecret = "CONTROL_PLANE_CLIENT_SECRET"
+)
+
+// reasons of the Unreachable condition of schemas, tables, tenants and
+// external clusters
+const (
+	ControllerReachable   = "ControllerReachable"
+	ControllerUnreachable = "ControllerUnreachable"
+)
+
+// GetControllerURL returns the url of the controller api served by svcName in
+// the namespace of the pinot cluster.
+func GetControllerURL(pt *v1beta1.Pinot, svcName string) string {
+	scheme, port := DefaultControllerAPIScheme, int32(DefaultControllerAPIPort)
+	if api := pt.Spec.ControllerAPI; api != nil {
+		if api.Scheme != "" {
+			scheme = api.Scheme
+		}
+		if api.Port != 0 {
+			port = api.Port
+		}
+	}
+
+	return string(scheme) + "://" + svcName + "." + pt.Namespace + ".svc.cluster.local:" + strconv.Itoa(int(port))
+}
+
+// GetControllerTLSConfig reads the tls material of the controller api from
+// the secrets referenced in the Pinot spec. nil is returned for http
+// controllers and for https controllers without tls settings.
+func GetControllerTLSConfig(ctx context.Context, c client.Client, pt *v1beta1.Pinot) (*internalHTTP.TLSConfig, error) {
+	api := pt.Spec.ControllerAPI
+	if api == nil || api.Scheme != v1beta1.ControllerAPIHTTPS {
+		return nil, nil
+	}
+
+	return GetTLSConfig(ctx, c, pt.Namespace, api.TLS)
+}
+
+// GetTLSConfig reads the tls material referenced by spec from the secrets of
+// namespace, nil is returned when spec is nil.
+func GetTLSConfig(ctx context.Context, c client.Client, namespace string, spec *v1beta1.ControllerTLSSpec) (*internalHTTP.TLSConfig, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &internalHTTP.TLSConfig{
+		ServerName:         spec.ServerName,
+		InsecureSkipVerify: spec.InsecureSkipVerify,
+	}
+
+	for _, ref := range []struct {
+		selector *v1.SecretKeySelector
+		value    *[]byte
+	}{
+		{spec.CASecretKeyRef, &tlsConfig.CA},
+		{spec.CertSecretKeyRef, &tlsConfig.Cert},
+		{spec.KeySecretKeyRef, &tlsConfig.Key},
+	} {
+		if ref.selector == nil {
+			continue
+		}
+		value, err := getSecretKey(ctx, c, namespace, ref.selector)
+		if err != nil {
+			return nil, err
+		}
+		*ref.value = value
+	}
+
+	return tlsConfig, nil
+}
+
+// GetControllerAuth reads the credentials of the controller api from the auth
+// secret of the Pinot spec.
+func GetControllerAuth(ctx context.Context, c client.Client, pt *v1beta1.Pinot) (internalHTTP.Auth, error) {
+	return GetAuth(ctx, c, pt.Namespace, pt.Spec.Auth)
+}
+
+// GetAuth reads the credentials of auth from its secret in namespace, the
+// namespace of the CR. A secret reference to another namespace is refused, the
+// controller url of a CR could otherwise send the secrets of any namespace.
+func GetAuth(ctx context.Context, c client.Client, namespace string, auth v1beta1.Auth) (internalHTTP.Auth, error) {
+	if auth == (v1beta1.Auth{}) {
+		return internalHTTP.Auth{}, nil
+	}
+
+	if auth.SecretRef.Namespace != "" && auth.SecretRef.Namespace != namespace {
+		return internalHTTP.Auth{}, fmt.Errorf("auth secret [%s/%s] must be in namespace [%s]", auth.SecretRef.Namespace, auth.SecretRef.Name, namespace)
+	}
+
+	secret := v1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: auth.SecretRef.Name}, &secret); err != nil {
+		return internalHTTP.Auth{}, err
+	}
+
+	keys := v1beta1.AuthSecretKeys{}
+	if auth.SecretKeys != nil {
+		keys = *auth.SecretKeys
+	}
+	value := func(key, defaultKey string) (string, error) {
+		if key == "" {
+			key = defaultKey
+		}
+		v, ok := secret.Data[key]
+		if !ok {
+			return "", fmt.Errorf("key [%s] not found in secret [%s/%s]", key, namespace, secret.Name)
+		}
+		return string(v), nil
+	}
+
+	switch auth.Type {
+	case v1beta1.BasicAuth, v1beta1.ZkBasicAuth:
+		userName, err := value(keys.UserName, ControlPlaneUserName)
+		if err != nil {
+			return internalHTTP.Auth{}, err
+		}
+		password, err := value(keys.Password, ControlPlanePassword)
+		if err != nil {
+			return internalHTTP.Auth{}, err
+		}
+		return internalHTTP.Auth{BasicAuth: internalHTTP.BasicAuth{UserName: userName, Password: password}}, nil
+
+	case v1beta1.BearerToken:
+		token, err := value(keys.Token, ControlPlaneToken)
+		if err != nil {
+			return internalHTTP.Auth{}, err
+		}
+		return internalHTTP.Auth{BearerToken: token}, nil
+
+	case v1beta1.OAuth2ClientCredentials:
+		if auth.OAuth2 == nil {
+			return internalHTTP.Auth{}, fmt.Errorf("auth type [%s] requires oauth2 settings", auth.Type)
+		}
+		clientID, err := value(keys.ClientID, ControlPlaneClientID)
+		if err != nil {
+			return internalHTTP.Auth{}, err
+		}
+		clientSecret, err := value(keys.ClientSecret, ControlPlaneClientSecret)
+		if err != nil {
+			return internalHTTP.Auth{}, err
+		}
+		return internalHTTP.Auth{
+			TokenSource: internalHTTP.GetTokenSource(namespace+"/"+secret.Name, secret.ResourceVersion, internalHTTP.OAuth2ClientCredentials{
+				TokenURL:     auth.OAuth2.TokenURL,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				Scopes:       auth.OAuth2.Scopes,
+				Audience:     auth.OAuth2.Audience,
+			}),
+		}, nil
+	}
+
+	return internalHTTP.Auth{}, fmt.Errorf("unsupported auth type [%s]", auth.Type)
+}
+
+// GetExternalClusterClient returns a client for the controller of the
+// PinotExternalCluster name in namespace.
+func GetExternalClusterClient(ctx context.Context, c client.Client, namespace, name string) (*pinot.Client, error) {
+	cluster := &v1beta1.PinotExternalCluster{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cluster); err != nil {
+		return nil, err
+	}
+
+	return NewExternalClusterClient(ctx, c, cluster)
+}
+
+// NewExternalClusterClient returns a client for the controller of cluster.
+func NewExternalClusterClient(ctx context.Context, c client.Client, cluster *v1beta1.PinotExternalCluster) (*pinot.Client, error) {
+	auth := internalHTTP.Auth{}
+	if cluster.Spec.Auth != nil {
+		var err error
+		if auth, err = GetAuth(ctx, c, cluster.Namespace, *cluster.Spec.Auth); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig, err := GetTLSConfig(ctx, c, cluster.Namespace, cluster.Spec.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return pinot.NewClient(strings.TrimSuffix(cluster.Spec.ControllerURL, "/"), auth, tlsConfig)
+}
+
+// NewUnreachableCondition returns the Unreachable condition following the
+// circuit breaker of the controller pc talks to, and false when conditions
+// already has it with the same status, reason and message.
+func NewUnreachableCondition(conditions []metav1.Condition, pc *pinot.Client, generation int64) (metav1.Condition, bool) {
+	condition := metav1.Condition{
+		Type:               v1beta1.PinotUnreachable,
+		Status:             metav1.ConditionFalse,
+		Reason:             ControllerReachable,
+		Message:            "Pinot controller is reachable",
+		ObservedGeneration: generation,
+	}
+	if pc.IsUnreachable() {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ControllerUnreachable
+		condition.Message = fmt.Sprintf("Requests to pinot controller [%s] keep failing, backing off", pc.BaseURL())
+	}
+
+	current := meta.FindStatusCondition(conditions, v1beta1.PinotUnreachable)
+	if current != nil &&
+		current.Status == condition.Status &&
+		current.Reason == condition.Reason &&
+		current.Message == condition.Message {
+		return condition, false
+	}
+	return condition, true
+}
+
+func getSecretKey(ctx context.Context, c client.Client, namespace string, selector *v1.SecretKeySelector) ([]byte, error) {
+	secret := v1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, &secret); err != nil {
+		return nil, err
+	}
+
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, fmt.Errorf("key [%s] not found in secret [%s/%s]", selector.Key, namespace, selector.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,96 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewUnreachableCondition(t *testing.T) {
+	url := "http://pinot-controller.unreachable.svc.cluster.local:9000"
+	pc, err := pinot.NewClient(url, internalHTTP.Auth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conditions := []metav1.Condition{}
+	condition, changed := NewUnreachableCondition(conditions, pc, 1)
+	if !changed || condition.Status != metav1.ConditionFalse || condition.Reason != ControllerReachable {
+		t.Fatalf("expected a new reachable condition, got %+v changed %v", condition, changed)
+	}
+	meta.SetStatusCondition(&conditions, condition)
+
+	if _, changed := NewUnreachableCondition(conditions, pc, 2); changed {
+		t.Errorf("expected the reachable condition to be unchanged")
+	}
+
+	breaker := internalHTTP.GetCircuitBreaker(url)
+	for i := 0; i < internalHTTP.DefaultBreakerThreshold; i++ {
+		breaker.Failure()
+	}
+	condition, changed = NewUnreachableCondition(conditions, pc, 2)
+	if !changed || condition.Type != v1beta1.PinotUnreachable || condition.Status != metav1.ConditionTrue || condition.Reason != ControllerUnreachable {
+		t.Errorf("expected an unreachable condition, got %+v changed %v", condition, changed)
+	}
+	breaker.Success()
+}
+
+func TestGetAuth(t *testing.T) {
+	data := map[string][]byte{ControlPlaneUserName: []byte("admin"), ControlPlanePassword: []byte("secret")}
+	c := fake.NewClientBuilder().WithObjects(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "pinot"}, Data: data},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}, Data: data},
+	).Build()
+
+	tests := []struct {
+		name      string
+		secretRef v1.SecretReference
+		wantErr   bool
+	}{
+		{"namespace of the CR", v1.SecretReference{Name: "auth"}, false},
+		{"explicit namespace of the CR", v1.SecretReference{Name: "auth", Namespace: "pinot"}, false},
+		{"another namespace", v1.SecretReference{Name: "other", Namespace: "other"}, true},
+		{"secret of another namespace by name", v1.SecretReference{Name: "other"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := GetAuth(context.Background(), c, "pinot", v1beta1.Auth{Type: v1beta1.BasicAuth, SecretRef: tt.secretRef})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", auth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth.BasicAuth.UserName != "admin" || auth.BasicAuth.Password != "secret" {
+				t.Errorf("expected the credentials of the secret, got %+v", auth.BasicAuth)
+			}
+		})
+	}
+}
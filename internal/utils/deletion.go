@@ -0,0 +1,52 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strconv"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// IsDeletedOnPinot returns true when the object of a deleted CR is deleted
+// from pinot, an unset policy deletes it.
+func IsDeletedOnPinot(policy v1beta1.DeletionPolicy) bool {
+	return policy == "" || policy == v1beta1.DeletionPolicyDelete
+}
+
+// IsDeletionProtected returns true when the protection annotation of the CR is
+// set to true, the CR is then not released on deletion.
+func IsDeletionProtected(annotations map[string]string, annotation string) bool {
+	protected, err := strconv.ParseBool(annotations[annotation])
+	return err == nil && protected
+}
+
+// DeletionProtectionPredicate passes the updates that change the protection
+// annotation of a deleted CR, removing it does not change the generation.
+func DeletionProtectionPredicate(annotation string) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectNew.GetDeletionTimestamp() != nil &&
+				e.ObjectNew.GetAnnotations()[annotation] != e.ObjectOld.GetAnnotations()[annotation]
+		},
+	}
+}
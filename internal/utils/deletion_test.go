@@ -0,0 +1,88 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestIsDeletedOnPinot(t *testing.T) {
+	tests := []struct {
+		policy v1beta1.DeletionPolicy
+		want   bool
+	}{
+		{policy: "", want: true},
+		{policy: v1beta1.DeletionPolicyDelete, want: true},
+		{policy: v1beta1.DeletionPolicyRetain, want: false},
+		{policy: v1beta1.DeletionPolicyOrphan, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsDeletedOnPinot(tt.policy); got != tt.want {
+			t.Errorf("IsDeletedOnPinot(%q) = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestIsDeletionProtected(t *testing.T) {
+	const annotation = "pinottable.datainfra.io/deletion-protection"
+	tests := []struct {
+		annotations map[string]string
+		want        bool
+	}{
+		{annotations: nil, want: false},
+		{annotations: map[string]string{annotation: "true"}, want: true},
+		{annotations: map[string]string{annotation: "True"}, want: true},
+		{annotations: map[string]string{annotation: "false"}, want: false},
+		{annotations: map[string]string{annotation: "yes"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsDeletionProtected(tt.annotations, annotation); got != tt.want {
+			t.Errorf("IsDeletionProtected(%v) = %v, want %v", tt.annotations, got, tt.want)
+		}
+	}
+}
+
+func TestDeletionProtectionPredicate(t *testing.T) {
+	const annotation = "pinottable.datainfra.io/deletion-protection"
+	p := DeletionProtectionPredicate(annotation)
+	deleted := metav1.Now()
+	object := func(deletionTimestamp *metav1.Time, annotations map[string]string) *metav1.PartialObjectMetadata {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: deletionTimestamp, Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name string
+		old  *metav1.PartialObjectMetadata
+		new  *metav1.PartialObjectMetadata
+		want bool
+	}{
+		{name: "removed from deleted", old: object(&deleted, map[string]string{annotation: "true"}), new: object(&deleted, nil), want: true},
+		{name: "unchanged on deleted", old: object(&deleted, map[string]string{annotation: "true"}), new: object(&deleted, map[string]string{annotation: "true"}), want: false},
+		{name: "removed from live", old: object(nil, map[string]string{annotation: "true"}), new: object(nil, nil), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxDriftMessageDiffs is the number of differences listed in a Drifted
+// condition, the remaining ones are only counted.
+const maxDriftMessageDiffs = 10
+
+// JsonDrift compares the desired json with the live json fetched from pinot and
+// returns one readable line per difference, sorted by path.
+//
+// Only the fields set in desired are compared, fields pinot adds with their
+// defaults are ignored. Empty values in desired match missing fields, and
+// numbers, booleans and strings compare equal when their text is the same as
+// pinot returns some numeric settings as strings.
+func JsonDrift(desired, live string) ([]string, error) {
+	var d, l interface{}
+	if err := json.Unmarshal([]byte(desired), &d); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling desired json :: %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(live), &l); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling live json :: %s", err.Error())
+	}
+
+	diffs := []string{}
+	jsonDrift("", d, l, &diffs)
+	sort.Strings(diffs)
+	return diffs, nil
+}
+
+func jsonDrift(path string, desired, live interface{}, diffs *[]string) {
+	if isEmptyJson(desired) && isEmptyJson(live) {
+		return
+	}
+	if live == nil {
+		*diffs = append(*diffs, fmt.Sprintf("%s: desired %s, missing in pinot", displayPath(path), marshalJson(desired)))
+		return
+	}
+
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, value := range d {
+			jsonDrift(path+"."+key, value, l[key], diffs)
+		}
+		return
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			break
+		}
+		for i := range d {
+			jsonDrift(fmt.Sprintf("%s[%d]", path, i), d[i], l[i], diffs)
+		}
+		return
+	default:
+		if scalarString(desired) == scalarString(live) {
+			return
+		}
+	}
+
+	*diffs = append(*diffs, fmt.Sprintf("%s: desired %s, live %s", displayPath(path), marshalJson(desired), marshalJson(live)))
+}
+
+func isEmptyJson(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// scalarString returns the text of a json scalar, or an empty string for
+// objects and arrays.
+func scalarString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return ""
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+func marshalJson(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// RedactDrift keeps the paths of the differences returned by JsonDrift, the
+// values of a json read from a Secret are not reported.
+func RedactDrift(diffs []string) []string {
+	redacted := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		path, _, _ := strings.Cut(diff, ": ")
+		redacted = append(redacted, path+": differs from pinot")
+	}
+	return redacted
+}
+
+// DriftMessage joins the differences returned by JsonDrift into the message of
+// a Drifted condition.
+func DriftMessage(diffs []string) string {
+	if len(diffs) <= maxDriftMessageDiffs {
+		return strings.Join(diffs, "; ")
+	}
+	return fmt.Sprintf("%s; and %d more", strings.Join(diffs[:maxDriftMessageDiffs], "; "), len(diffs)-maxDriftMessageDiffs)
+}
+
+// IsDriftCorrected returns true unless the policy only reports drift.
+func IsDriftCorrected(policy v1beta1.DriftPolicy) bool {
+	return policy != v1beta1.DriftPolicyReport
+}
+
+// NewDriftedCondition returns the Drifted condition, and false when conditions
+// already has it with the same status, reason and message.
+func NewDriftedCondition(
+	conditions []metav1.Condition,
+	status metav1.ConditionStatus,
+	reason, message string,
+	generation int64,
+) (metav1.Condition, bool) {
+	condition := metav1.Condition{
+		Type:               v1beta1.ConditionDrifted,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+
+	current := meta.FindStatusCondition(conditions, v1beta1.ConditionDrifted)
+	if current != nil &&
+		current.Status == status &&
+		current.Reason == reason &&
+		current.Message == message &&
+		current.ObservedGeneration == generation {
+		return condition, false
+	}
+	return condition, true
+}
@@ -0,0 +1,110 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJsonDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired string
+		live    string
+		want    []string
+	}{
+		{
+			name:    "server defaults ignored",
+			desired: `{"schemaName":"s","dimensionFieldSpecs":[{"name":"a","dataType":"STRING"}]}`,
+			live:    `{"schemaName":"s","dimensionFieldSpecs":[{"name":"a","dataType":"STRING","singleValueField":true}],"primaryKeyColumns":["a"]}`,
+			want:    []string{},
+		},
+		{
+			name:    "numbers as strings",
+			desired: `{"segmentsConfig":{"replication":2,"enabled":true}}`,
+			live:    `{"segmentsConfig":{"replication":"2","enabled":"true"}}`,
+			want:    []string{},
+		},
+		{
+			name:    "empty desired matches missing",
+			desired: `{"metadata":{},"tags":[]}`,
+			live:    `{}`,
+			want:    []string{},
+		},
+		{
+			name:    "changed and missing values",
+			desired: `{"segmentsConfig":{"replication":"2","retentionTimeUnit":"DAYS"},"tags":["a","b"]}`,
+			live:    `{"segmentsConfig":{"replication":"1"},"tags":["a"]}`,
+			want: []string{
+				`segmentsConfig.replication: desired "2", live "1"`,
+				`segmentsConfig.retentionTimeUnit: desired "DAYS", missing in pinot`,
+				`tags: desired ["a","b"], live ["a"]`,
+			},
+		},
+		{
+			name:    "nested arrays",
+			desired: `{"fields":[{"name":"a"},{"name":"b"}]}`,
+			live:    `{"fields":[{"name":"a"},{"name":"c"}]}`,
+			want:    []string{`fields[1].name: desired "b", live "c"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JsonDrift(tt.desired, tt.live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := JsonDrift(`{`, `{}`); err == nil {
+		t.Errorf("expected an error for invalid desired json")
+	}
+}
+
+func TestDriftMessage(t *testing.T) {
+	diffs := make([]string, maxDriftMessageDiffs+2)
+	for i := range diffs {
+		diffs[i] = "d"
+	}
+	if got := DriftMessage(diffs); !strings.HasSuffix(got, "; and 2 more") {
+		t.Errorf("expected the remaining differences to be counted, got %q", got)
+	}
+	if got := DriftMessage(diffs[:2]); got != "d; d" {
+		t.Errorf("expected all differences, got %q", got)
+	}
+}
+
+func TestRedactDrift(t *testing.T) {
+	diffs := []string{
+		`streamConfigs.sasl.jaas.config: desired "password=secret", live "password=other"`,
+		`tenants.server: desired "DefaultTenant", missing in pinot`,
+	}
+	want := []string{
+		"streamConfigs.sasl.jaas.config: differs from pinot",
+		"tenants.server: differs from pinot",
+	}
+	if got := RedactDrift(diffs); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	"github.com/datainfrahq/pinot-control-plane-k8s/internal/pinot"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// IsPlanMode returns true when the CR is only planned, by its mode or by its
+// plan annotation set to true. The mode is matched ignoring case so `plan`
+// plans the CR too, the annotation can not turn off a Plan mode.
+func IsPlanMode(mode v1beta1.ReconcileMode, annotations map[string]string, annotation string) bool {
+	if plan, err := strconv.ParseBool(annotations[annotation]); err == nil && plan {
+		return true
+	}
+	return strings.EqualFold(string(mode), string(v1beta1.ReconcileModePlan))
+}
+
+// AnnotationChangedPredicate passes the updates that set, change or remove
+// annotation, such as the plan annotation, they do not change the generation.
+func AnnotationChangedPredicate(annotation string) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectNew.GetAnnotations()[annotation] != e.ObjectOld.GetAnnotations()[annotation]
+		},
+	}
+}
+
+// IsSpecChanged returns true when the spec json differs from the json applied
+// last, nothing applied yet is not a change.
+func IsSpecChanged(appliedJson, specJson string) (bool, error) {
+	if appliedJson == "" {
+		return false, nil
+	}
+	ok, err := IsAppliedJson(appliedJson, specJson)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// PlanUpdateAction returns the action of an object that exists on pinot, it
+// is updated when the spec changed or when drift would be corrected.
+func PlanUpdateAction(specChanged bool, diff []string, policy v1beta1.DriftPolicy) v1beta1.PlanAction {
+	if specChanged || (len(diff) > 0 && IsDriftCorrected(policy)) {
+		return v1beta1.PlanActionUpdate
+	}
+	return v1beta1.PlanActionNone
+}
+
+// PlanValidationResult returns the validation of the error of a pinot validate
+// endpoint, errors other than api errors are returned to be retried.
+func PlanValidationResult(err error) (v1beta1.PlanValidation, string, error) {
+	switch {
+	case err == nil:
+		return v1beta1.PlanValidationPassed, "", nil
+	case pinot.IsAPIError(err):
+		return v1beta1.PlanValidationFailed, err.Error(), nil
+	default:
+		return "", "", err
+	}
+}
+
+// IsPlanChanged returns true when plan differs from the current plan, the time
+// of the plans is ignored.
+func IsPlanChanged(current *v1beta1.PlanStatus, plan v1beta1.PlanStatus) bool {
+	if current == nil {
+		return true
+	}
+	c := *current
+	c.LastPlanTime = plan.LastPlanTime
+	return !reflect.DeepEqual(c, plan)
+}
+
+// PlanMessage is the event message of a plan.
+func PlanMessage(plan v1beta1.PlanStatus) string {
+	msg := fmt.Sprintf("Action [%s], Validation [%s]", plan.Action, plan.Validation)
+	if len(plan.Diff) > 0 {
+		msg += fmt.Sprintf(", Diff [%s]", DriftMessage(plan.Diff))
+	}
+	if plan.Message != "" {
+		msg += fmt.Sprintf(", Message [%s]", plan.Message)
+	}
+	return msg
+}
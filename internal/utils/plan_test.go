@@ -0,0 +1,94 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsPlanMode(t *testing.T) {
+	const annotation = "pinottable.datainfra.io/plan"
+	tests := []struct {
+		name        string
+		mode        v1beta1.ReconcileMode
+		annotations map[string]string
+		want        bool
+	}{
+		{"default", "", nil, false},
+		{"apply", v1beta1.ReconcileModeApply, nil, false},
+		{"plan", v1beta1.ReconcileModePlan, nil, true},
+		{"lower case plan", "plan", nil, true},
+		{"plan annotation", v1beta1.ReconcileModeApply, map[string]string{annotation: "true"}, true},
+		{"plan annotation false", "", map[string]string{annotation: "false"}, false},
+		{"plan annotation false in plan mode", v1beta1.ReconcileModePlan, map[string]string{annotation: "false"}, true},
+		{"invalid plan annotation", "", map[string]string{annotation: "yes"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPlanMode(tt.mode, tt.annotations, annotation); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPlanUpdateAction(t *testing.T) {
+	drift := []string{`replication: desired "2", live "1"`}
+	tests := []struct {
+		name        string
+		specChanged bool
+		diff        []string
+		policy      v1beta1.DriftPolicy
+		want        v1beta1.PlanAction
+	}{
+		{"spec changed", true, nil, v1beta1.DriftPolicyReport, v1beta1.PlanActionUpdate},
+		{"drift corrected", false, drift, "", v1beta1.PlanActionUpdate},
+		{"drift reported", false, drift, v1beta1.DriftPolicyReport, v1beta1.PlanActionNone},
+		{"in sync", false, nil, v1beta1.DriftPolicyCorrect, v1beta1.PlanActionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanUpdateAction(tt.specChanged, tt.diff, tt.policy); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsPlanChanged(t *testing.T) {
+	plan := v1beta1.PlanStatus{Action: v1beta1.PlanActionCreate, LastPlanTime: metav1.Now()}
+	if !IsPlanChanged(nil, plan) {
+		t.Errorf("expected a first plan to be a change")
+	}
+
+	replanned := plan
+	replanned.LastPlanTime = metav1.NewTime(plan.LastPlanTime.Add(time.Minute))
+	if IsPlanChanged(&plan, replanned) {
+		t.Errorf("expected the plan time to be ignored")
+	}
+
+	replanned.Action = v1beta1.PlanActionNone
+	if !IsPlanChanged(&plan, replanned) {
+		t.Errorf("expected a new action to be a change")
+	}
+}
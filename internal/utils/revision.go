@@ -0,0 +1,105 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// AppendRevision records json as the latest revision, the oldest revisions
+// beyond limit are dropped. A json equal to the latest revision, such as a
+// corrected drift, is not recorded again.
+func AppendRevision(revisions []v1beta1.Revision, json string, generation int64, response string, limit *int32) []v1beta1.Revision {
+	next := int64(1)
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1]
+		if ok, err := IsEqualJson(latest.Json, json); err == nil && ok {
+			return revisions
+		}
+		next = latest.Revision + 1
+	}
+
+	revisions = append(revisions, v1beta1.Revision{
+		Revision:    next,
+		Json:        json,
+		Generation:  generation,
+		AppliedTime: metav1.Time{Time: time.Now()},
+		Response:    response,
+	})
+
+	keep := v1beta1.DefaultRevisionHistoryLimit
+	if limit != nil {
+		keep = int(*limit)
+	}
+	if len(revisions) > keep {
+		revisions = revisions[len(revisions)-keep:]
+	}
+	return revisions
+}
+
+// GetRevision returns the revision numbered by the value of a rollback
+// annotation, revisions read from a Secret can not be rolled back to.
+func GetRevision(revisions []v1beta1.Revision, value string) (v1beta1.Revision, error) {
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return v1beta1.Revision{}, fmt.Errorf("revision [%s] is not a number", value)
+	}
+
+	for _, revision := range revisions {
+		if revision.Revision != number {
+			continue
+		}
+		// only the digest of a json read from a Secret is kept, it is not
+		// written inline in the spec
+		if IsSecretDigest(revision.Json) {
+			return v1beta1.Revision{}, fmt.Errorf("revision [%d] was read from a secret and is not kept, roll the secret back instead", number)
+		}
+		return revision, nil
+	}
+
+	if len(revisions) == 0 {
+		return v1beta1.Revision{}, fmt.Errorf("revision [%d] not found, no revisions are kept", number)
+	}
+	return v1beta1.Revision{}, fmt.Errorf(
+		"revision [%d] not found, revisions [%d-%d] are kept",
+		number,
+		revisions[0].Revision,
+		revisions[len(revisions)-1].Revision,
+	)
+}
+
+// AnnotationSetPredicate passes the updates that set or change annotation,
+// such as the rollback and rebalance annotations, they do not change the
+// generation of the CR.
+func AnnotationSetPredicate(annotation string) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			value, ok := e.ObjectNew.GetAnnotations()[annotation]
+			return ok && value != e.ObjectOld.GetAnnotations()[annotation]
+		},
+	}
+}
@@ -0,0 +1,145 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func revisionNumbers(revisions []v1beta1.Revision) []int64 {
+	numbers := []int64{}
+	for _, revision := range revisions {
+		numbers = append(numbers, revision.Revision)
+	}
+	return numbers
+}
+
+func TestAppendRevision(t *testing.T) {
+	var revisions []v1beta1.Revision
+	limit := int32(3)
+	for i := 1; i <= 5; i++ {
+		revisions = AppendRevision(revisions, fmt.Sprintf(`{"replication":"%d"}`, i), int64(i), "ok", &limit)
+	}
+	if got := fmt.Sprint(revisionNumbers(revisions)); got != "[3 4 5]" {
+		t.Fatalf("revisions = %s, want [3 4 5]", got)
+	}
+
+	// the json of the latest revision applied again is not a new revision
+	revisions = AppendRevision(revisions, `{ "replication": "5" }`, 6, "ok", &limit)
+	if got := fmt.Sprint(revisionNumbers(revisions)); got != "[3 4 5]" {
+		t.Fatalf("revisions = %s, want [3 4 5]", got)
+	}
+
+	// a json of an older revision is a new revision
+	revisions = AppendRevision(revisions, `{"replication":"3"}`, 7, "ok", &limit)
+	if got := fmt.Sprint(revisionNumbers(revisions)); got != "[4 5 6]" {
+		t.Fatalf("revisions = %s, want [4 5 6]", got)
+	}
+	if latest := revisions[2]; latest.Generation != 7 || latest.Json != `{"replication":"3"}` {
+		t.Errorf("latest revision = %+v", latest)
+	}
+
+	none := int32(0)
+	if revisions := AppendRevision(nil, `{}`, 1, "ok", &none); len(revisions) != 0 {
+		t.Errorf("revisions with limit 0 = %d, want 0", len(revisions))
+	}
+
+	var unlimited []v1beta1.Revision
+	for i := 1; i <= 12; i++ {
+		unlimited = AppendRevision(unlimited, fmt.Sprintf(`{"replication":"%d"}`, i), int64(i), "ok", nil)
+	}
+	if len(unlimited) != v1beta1.DefaultRevisionHistoryLimit {
+		t.Errorf("revisions with the default limit = %d, want %d", len(unlimited), v1beta1.DefaultRevisionHistoryLimit)
+	}
+}
+
+func TestGetRevision(t *testing.T) {
+	revisions := []v1beta1.Revision{
+		{Revision: 4, Json: `{"replication":"4"}`},
+		{Revision: 5, Json: `{"replication":"5"}`},
+	}
+
+	revision, err := GetRevision(revisions, "4")
+	if err != nil {
+		t.Fatalf("GetRevision() error = %v", err)
+	}
+	if revision.Json != `{"replication":"4"}` {
+		t.Errorf("GetRevision() = %+v", revision)
+	}
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{value: "3", wantErr: "revision [3] not found, revisions [4-5] are kept"},
+		{value: "latest", wantErr: "revision [latest] is not a number"},
+	}
+	for _, tt := range tests {
+		if _, err := GetRevision(revisions, tt.value); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("GetRevision(%s) error = %v, want %s", tt.value, err, tt.wantErr)
+		}
+	}
+
+	if _, err := GetRevision(nil, "1"); err == nil {
+		t.Error("GetRevision() without revisions returned no error")
+	}
+
+	redacted, err := RedactRevisions(revisions, "tableName")
+	if err != nil {
+		t.Fatalf("RedactRevisions() error = %v", err)
+	}
+	want := "revision [5] was read from a secret and is not kept, roll the secret back instead"
+	if _, err := GetRevision(redacted, "5"); err == nil || err.Error() != want {
+		t.Errorf("GetRevision() of a redacted revision error = %v, want %s", err, want)
+	}
+}
+
+func TestAnnotationSetPredicate(t *testing.T) {
+	p := AnnotationSetPredicate("pinottable.datainfra.io/rollback-to")
+	object := func(annotations map[string]string) *metav1.PartialObjectMetadata {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		want bool
+	}{
+		{name: "set", old: nil, new: map[string]string{"pinottable.datainfra.io/rollback-to": "2"}, want: true},
+		{name: "changed", old: map[string]string{"pinottable.datainfra.io/rollback-to": "1"}, new: map[string]string{"pinottable.datainfra.io/rollback-to": "2"}, want: true},
+		{name: "unchanged", old: map[string]string{"pinottable.datainfra.io/rollback-to": "2"}, new: map[string]string{"pinottable.datainfra.io/rollback-to": "2"}, want: false},
+		{name: "removed", old: map[string]string{"pinottable.datainfra.io/rollback-to": "2"}, new: nil, want: false},
+		{name: "other annotation", old: nil, new: map[string]string{"team": "ads"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Update(event.UpdateEvent{ObjectOld: object(tt.old), ObjectNew: object(tt.new)}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if p.Create(event.CreateEvent{Object: object(map[string]string{"pinottable.datainfra.io/rollback-to": "2"})}) {
+		t.Error("Create() = true, want false")
+	}
+}
@@ -0,0 +1,323 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	internalHTTP "github.com/datainfrahq/pinot-control-plane-k8s/internal/http"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecretDigestKey is the key of the digest of a json read from a Secret, the
+// status and the revisions of a CR keep it instead of the json.
+const SecretDigestKey = "secretDigest"
+
+// urlSources caches the documents fetched from urls by checksum, a checksum
+// pins the document so it is only fetched once.
+var urlSources = struct {
+	sync.Mutex
+	documents map[string]string
+}{documents: map[string]string{}}
+
+// GetJsonFromSource returns the json document of a source, ConfigMaps and
+// Secrets are read from namespace.
+func GetJsonFromSource(ctx context.Context, c client.Client, namespace string, source *v1beta1.JsonSource) (string, error) {
+	var document string
+
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		value, err := getConfigMapKey(ctx, c, namespace, source.ConfigMapKeyRef)
+		if err != nil {
+			return "", err
+		}
+		document = value
+	case source.SecretKeyRef != nil:
+		value, err := getSecretKey(ctx, c, namespace, source.SecretKeyRef)
+		if err != nil {
+			return "", err
+		}
+		document = string(value)
+	case source.URL != nil:
+		value, err := getURL(ctx, source.URL)
+		if err != nil {
+			return "", err
+		}
+		document = value
+	default:
+		return "", fmt.Errorf("json source has no configMapKeyRef, secretKeyRef or url")
+	}
+
+	if !json.Valid([]byte(document)) {
+		return "", fmt.Errorf("json source [%s] is not valid json", describeSource(source))
+	}
+	return document, nil
+}
+
+// GetJsonOrSource returns the inlined json, or the json document of source
+// when it is set.
+func GetJsonOrSource(ctx context.Context, c client.Client, namespace, inline string, source *v1beta1.JsonSource) (string, error) {
+	if source == nil {
+		return inline, nil
+	}
+	return GetJsonFromSource(ctx, c, namespace, source)
+}
+
+// IsSecretSource returns true when the json document is read from a Secret.
+func IsSecretSource(source *v1beta1.JsonSource) bool {
+	return source != nil && source.SecretKeyRef != nil
+}
+
+// StatusJson returns the json to store in the status and the revisions of a
+// CR, a json read from a Secret is replaced by RedactJson so that the content
+// of the Secret does not leak.
+func StatusJson(source *v1beta1.JsonSource, document string, keep ...string) (string, error) {
+	if !IsSecretSource(source) {
+		return document, nil
+	}
+	return RedactJson(document, keep...)
+}
+
+// RedactJson returns the keep fields of document, such as the name of the
+// object on pinot, with the digest of the whole document. It is enough to
+// release the object and to tell whether a document was applied.
+func RedactJson(document string, keep ...string) (string, error) {
+	if IsSecretDigest(document) {
+		return document, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(document), &fields); err != nil {
+		return "", err
+	}
+	digest, err := jsonDigest(document)
+	if err != nil {
+		return "", err
+	}
+
+	redacted := map[string]interface{}{SecretDigestKey: digest}
+	for _, key := range keep {
+		if value, ok := fields[key]; ok {
+			redacted[key] = value
+		}
+	}
+	b, err := json.Marshal(redacted)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// RedactRevisions replaces the json of every revision by RedactJson.
+func RedactRevisions(revisions []v1beta1.Revision, keep ...string) ([]v1beta1.Revision, error) {
+	redacted := make([]v1beta1.Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		document, err := RedactJson(revision.Json, keep...)
+		if err != nil {
+			return nil, err
+		}
+		revision.Json = document
+		redacted = append(redacted, revision)
+	}
+	return redacted, nil
+}
+
+// IsSecretDigest returns true when document was redacted by RedactJson.
+func IsSecretDigest(document string) bool {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(document), &fields); err != nil {
+		return false
+	}
+	_, ok := fields[SecretDigestKey]
+	return ok
+}
+
+// IsAppliedJson returns true when specJson is the json applied last, stored
+// as is or redacted.
+func IsAppliedJson(appliedJson, specJson string) (bool, error) {
+	if !IsSecretDigest(appliedJson) || IsSecretDigest(specJson) {
+		return IsEqualJson(appliedJson, specJson)
+	}
+
+	digest, err := jsonDigest(specJson)
+	if err != nil {
+		return false, err
+	}
+	applied, err := GetValueFromJson(appliedJson, SecretDigestKey)
+	if err != nil {
+		return false, err
+	}
+	return applied == digest, nil
+}
+
+// jsonDigest returns the sha256 of document, documents equal by IsEqualJson
+// have the same digest.
+func jsonDigest(document string) (string, error) {
+	var o interface{}
+	if err := json.Unmarshal([]byte(document), &o); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ResolveJson sets specJson, a json field of the spec of obj, to the json
+// returned by resolve, the spec is only changed in memory. A deleted CR whose
+// json can not be resolved falls back to appliedJson, the json applied last,
+// so that it can still be released.
+func ResolveJson(obj client.Object, specJson *string, appliedJson string, resolve func() (string, error)) error {
+	document, err := resolve()
+	if err != nil {
+		if !obj.GetDeletionTimestamp().IsZero() && appliedJson != "" {
+			*specJson = appliedJson
+			return nil
+		}
+		return err
+	}
+
+	*specJson = document
+	return nil
+}
+
+// UpdateResolved updates obj without specJson when it was resolved, the json
+// read from a source is never stored in the spec. specJson is set again once
+// updated.
+func UpdateResolved(ctx context.Context, c client.Client, obj client.Object, specJson *string, resolved bool) error {
+	document := *specJson
+	defer func() {
+		*specJson = document
+	}()
+
+	if resolved {
+		*specJson = ""
+	}
+	return c.Update(ctx, obj)
+}
+
+// PatchResolvedStatus patches the status of obj, PatchStatus refetches obj so
+// specJson is set again once patched.
+func PatchResolvedStatus(ctx context.Context, c client.Client, obj client.Object, specJson *string, transform TransformStatusFunc) error {
+	document := *specJson
+	defer func() {
+		*specJson = document
+	}()
+
+	_, _, err := PatchStatus(ctx, c, obj, transform)
+	return err
+}
+
+// SourceIndexField indexes the CRs by the ConfigMap or the Secret their json
+// document is read from.
+const SourceIndexField = ".spec.source"
+
+// SourceIndexKey returns the SourceIndexField key of obj, a ConfigMap or a
+// Secret, and an empty key for other objects.
+func SourceIndexKey(obj client.Object) string {
+	switch obj.(type) {
+	case *v1.ConfigMap:
+		return "configmap/" + obj.GetName()
+	case *v1.Secret:
+		return "secret/" + obj.GetName()
+	}
+	return ""
+}
+
+// SourceIndexValues returns the SourceIndexField keys of the source of a json
+// document, none when it is inlined or fetched from a url.
+func SourceIndexValues(source *v1beta1.JsonSource) []string {
+	switch {
+	case source == nil:
+		return nil
+	case source.ConfigMapKeyRef != nil:
+		return []string{"configmap/" + source.ConfigMapKeyRef.Name}
+	case source.SecretKeyRef != nil:
+		return []string{"secret/" + source.SecretKeyRef.Name}
+	}
+	return nil
+}
+
+func getConfigMapKey(ctx context.Context, c client.Client, namespace string, selector *v1.ConfigMapKeySelector) (string, error) {
+	configMap := v1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, &configMap); err != nil {
+		return "", err
+	}
+
+	if value, ok := configMap.Data[selector.Key]; ok {
+		return value, nil
+	}
+	if value, ok := configMap.BinaryData[selector.Key]; ok {
+		return string(value), nil
+	}
+	return "", fmt.Errorf("key [%s] not found in configmap [%s/%s]", selector.Key, namespace, selector.Name)
+}
+
+func getURL(ctx context.Context, source *v1beta1.URLSource) (string, error) {
+	urlSources.Lock()
+	document, ok := urlSources.documents[source.Checksum]
+	urlSources.Unlock()
+	if ok {
+		return document, nil
+	}
+
+	httpClient := internalHTTP.Client{
+		Method: http.MethodGet,
+		URL:    source.URL,
+		Retry:  &internalHTTP.DefaultRetryPolicy,
+	}
+	resp, err := httpClient.Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET [%s] returned status [%d]", source.URL, resp.StatusCode)
+	}
+
+	sum := sha256.Sum256([]byte(resp.ResponseBody))
+	if checksum := "sha256:" + hex.EncodeToString(sum[:]); checksum != source.Checksum {
+		return "", fmt.Errorf("checksum [%s] of [%s] does not match [%s]", checksum, source.URL, source.Checksum)
+	}
+
+	urlSources.Lock()
+	urlSources.documents[source.Checksum] = resp.ResponseBody
+	urlSources.Unlock()
+	return resp.ResponseBody, nil
+}
+
+func describeSource(source *v1beta1.JsonSource) string {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("configmap %s key %s", source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key)
+	case source.SecretKeyRef != nil:
+		return fmt.Sprintf("secret %s key %s", source.SecretKeyRef.Name, source.SecretKeyRef.Key)
+	case source.URL != nil:
+		return source.URL.URL
+	}
+	return ""
+}
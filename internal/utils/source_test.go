@@ -0,0 +1,200 @@
+/*
+DataInfra Pinot Control Plane (C) 2023 - 2024 DataInfra.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/datainfrahq/pinot-control-plane-k8s/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const sourceJson = `{"schemaName":"airlineStats"}`
+
+func TestGetJsonFromSource(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "pinot", Name: "schemas"},
+			Data:       map[string]string{"schema.json": sourceJson, "broken.json": "{"},
+			BinaryData: map[string][]byte{"binary.json": []byte(sourceJson)},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "pinot", Name: "schemas"},
+			Data:       map[string][]byte{"schema.json": []byte(sourceJson)},
+		},
+	).Build()
+
+	configMapKey := func(key string) *v1beta1.JsonSource {
+		return &v1beta1.JsonSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "schemas"},
+			Key:                  key,
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		source  *v1beta1.JsonSource
+		wantErr bool
+	}{
+		{name: "configmap data", source: configMapKey("schema.json")},
+		{name: "configmap binary data", source: configMapKey("binary.json")},
+		{name: "configmap missing key", source: configMapKey("missing.json"), wantErr: true},
+		{name: "configmap invalid json", source: configMapKey("broken.json"), wantErr: true},
+		{
+			name: "secret",
+			source: &v1beta1.JsonSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "schemas"},
+				Key:                  "schema.json",
+			}},
+		},
+		{name: "no source", source: &v1beta1.JsonSource{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetJsonFromSource(context.Background(), c, "pinot", tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetJsonFromSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != sourceJson {
+				t.Errorf("GetJsonFromSource() = %s, want %s", got, sourceJson)
+			}
+		})
+	}
+}
+
+func TestGetJsonFromURL(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(sourceJson))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte(sourceJson))
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	mismatch := &v1beta1.JsonSource{URL: &v1beta1.URLSource{URL: server.URL, Checksum: "sha256:" + hex.EncodeToString(make([]byte, 32))}}
+	if _, err := GetJsonFromSource(context.Background(), nil, "pinot", mismatch); err == nil {
+		t.Fatal("GetJsonFromSource() of a checksum mismatch returned no error")
+	}
+
+	source := &v1beta1.JsonSource{URL: &v1beta1.URLSource{URL: server.URL, Checksum: checksum}}
+	for i := 0; i < 2; i++ {
+		got, err := GetJsonFromSource(context.Background(), nil, "pinot", source)
+		if err != nil {
+			t.Fatalf("GetJsonFromSource() error = %v", err)
+		}
+		if got != sourceJson {
+			t.Errorf("GetJsonFromSource() = %s, want %s", got, sourceJson)
+		}
+	}
+
+	// the document pinned by the checksum is fetched once
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestSourceIndex(t *testing.T) {
+	source := &v1beta1.JsonSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "schemas"},
+		Key:                  "schema.json",
+	}}
+	values := SourceIndexValues(source)
+
+	if key := SourceIndexKey(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "schemas"}}); len(values) != 1 || values[0] != key {
+		t.Errorf("SourceIndexValues() = %v, want the key %q of the configmap", values, key)
+	}
+	if key := SourceIndexKey(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "schemas"}}); key == values[0] {
+		t.Errorf("SourceIndexKey() of a secret with the same name = %q, want another key", key)
+	}
+	if key := SourceIndexKey(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "tables"}}); key == values[0] {
+		t.Errorf("SourceIndexKey() of another configmap = %q, want another key", key)
+	}
+	if key := SourceIndexKey(&v1.Pod{}); key != "" {
+		t.Errorf("SourceIndexKey() of a pod = %q, want none", key)
+	}
+	if values := SourceIndexValues(nil); len(values) != 0 {
+		t.Errorf("SourceIndexValues() of an inlined json = %v, want none", values)
+	}
+}
+
+func TestStatusJson(t *testing.T) {
+	tableJson := `{"tableName":"airlineStats","tableType":"OFFLINE","streamConfigs":{"sasl.jaas.config":"password=secret"}}`
+	secret := &v1beta1.JsonSource{SecretKeyRef: &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "tables"},
+		Key:                  "tables.json",
+	}}
+	configMap := &v1beta1.JsonSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "tables"},
+		Key:                  "tables.json",
+	}}
+
+	for _, source := range []*v1beta1.JsonSource{nil, configMap} {
+		if got, err := StatusJson(source, tableJson, "tableName"); err != nil || got != tableJson {
+			t.Errorf("StatusJson() = %s, %v, want the json as is", got, err)
+		}
+	}
+
+	redacted, err := StatusJson(secret, tableJson, "tableName", "tableType")
+	if err != nil {
+		t.Fatalf("StatusJson() error = %v", err)
+	}
+	if strings.Contains(redacted, "password") || !IsSecretDigest(redacted) {
+		t.Errorf("StatusJson() = %s, want the digest without the secret", redacted)
+	}
+	if name, _ := GetValueFromJson(redacted, "tableName"); name != "airlineStats" {
+		t.Errorf("StatusJson() table name = %s, want airlineStats", name)
+	}
+	if again, _ := RedactJson(redacted, "tableName"); again != redacted {
+		t.Errorf("RedactJson() of a redacted json = %s, want %s", again, redacted)
+	}
+
+	tests := []struct {
+		name     string
+		applied  string
+		specJson string
+		want     bool
+	}{
+		{"same json", redacted, tableJson, true},
+		{"reformatted json", redacted, `{"tableType":"OFFLINE", "tableName":"airlineStats", "streamConfigs":{"sasl.jaas.config":"password=secret"}}`, true},
+		{"changed json", redacted, `{"tableName":"airlineStats","tableType":"OFFLINE"}`, false},
+		{"redacted spec of a deleted CR", redacted, redacted, true},
+		{"json applied as is", tableJson, tableJson, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsAppliedJson(tt.applied, tt.specJson)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}